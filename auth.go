@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"wingspan-scoring/config"
+	"wingspan-scoring/db"
+)
+
+// adminPasswordOutput is where a generated admin password is printed. It is
+// kept out of the logs so the password isn't shipped or stored with them.
+var adminPasswordOutput io.Writer = os.Stderr
+
+// ensureAdminAccount creates an admin account when there are no accounts
+// yet, so a new server can be signed in to. The username and password come
+// from the auth settings; without a password a random one is generated and
+// printed once to adminPasswordOutput, never through the structured logger.
+func ensureAdminAccount(ctx context.Context, auth config.Auth) error {
+	count, err := db.CountUsers(ctx)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	username := auth.AdminUsername
+	password := auth.AdminPassword
+	generated := password == ""
+	if generated {
+		b := make([]byte, 12)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		password = base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	if _, err := db.CreateUser(ctx, username, password, db.RoleAdmin); err != nil {
+		return err
+	}
+	if generated {
+		slog.Warn("Created admin account with a generated password - change it after signing in", "username", username)
+		fmt.Fprintf(adminPasswordOutput, "Generated password for admin account %q: %s\n", username, password)
+	} else {
+		slog.Info("Created admin account", "username", username)
+	}
+	return nil
+}
+
+func (s *server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.renderLogin(w, r, http.StatusOK, "")
+	case http.MethodPost:
+		user, err := db.Authenticate(r.Context(), r.PostFormValue("username"), r.PostFormValue("password"))
+		if errors.Is(err, db.ErrInvalidCredentials) {
+			s.renderLogin(w, r, http.StatusUnauthorized, "Invalid username or password")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to sign in", "error", err)
+			http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+			return
+		}
+
+		if err := startSession(w, r, user); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to start session", "user", user.Username, "error", err)
+			http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+			return
+		}
+		slog.InfoContext(r.Context(), "User signed in", "user", user.Username)
+		http.Redirect(w, r, safeRedirect(r.FormValue("next"), requestBasePath(r)+"/"), http.StatusSeeOther)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// renderLogin renders the sign-in page with an optional error message
+func (s *server) renderLogin(w http.ResponseWriter, r *http.Request, status int, message string) {
+	data := PageData{
+		PageTitle:   "Sign In",
+		CurrentPage: "login",
+		Version:     version,
+		BasePath:    requestBasePath(r),
+		Error:       message,
+		Next:        safeRedirect(r.FormValue("next"), requestBasePath(r)+"/"),
+	}
+	if s.oidc != nil {
+		data.OIDCName = s.oidc.name
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := tmpl.ExecuteTemplate(w, "login.html", data); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to render template", "error", err)
+	}
+}
+
+func handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if err := db.DeleteSession(r.Context(), cookie.Value); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to end session", "error", err)
+		}
+	}
+	clearSessionCookie(w, r)
+	http.Redirect(w, r, requestBasePath(r)+"/login", http.StatusSeeOther)
+}
+
+// startSession signs a user in and sets their session cookie
+func startSession(w http.ResponseWriter, r *http.Request, user *db.User) error {
+	token, session, err := db.CreateSession(r.Context(), user.ID)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// clearSessionCookie removes the session cookie from the browser
+func clearSessionCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// isHTTPS reports whether the client connected over HTTPS, directly or
+// through a trusted proxy. forwardedProtoMiddleware removes the header from
+// anyone else.
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+// safeRedirect returns next if it's a path on this server, otherwise fallback
+func safeRedirect(next, fallback string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return fallback
+	}
+	return next
+}
+
+// handleGetCurrentUser returns the signed-in user, with a link to their
+// player profile when the account is linked to a player
+func handleGetCurrentUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := requestUser(r)
+	if user == nil {
+		http.Error(w, "Not signed in", http.StatusUnauthorized)
+		return
+	}
+
+	response := struct {
+		*db.User
+		ProfileURL string `json:"profileUrl,omitempty"`
+	}{User: user}
+	if user.PlayerName != "" {
+		response.ProfileURL = requestBasePath(r) + "/api/stats/" + url.PathEscape(user.PlayerName) + "/profile"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func handleUsers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		users, err := db.GetUsers(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to get users", "error", err)
+			http.Error(w, "Failed to retrieve users", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(users)
+	case http.MethodPost:
+		var request struct {
+			Username   string `json:"username"`
+			Password   string `json:"password"`
+			Role       string `json:"role"`
+			PlayerName string `json:"playerName"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		user, err := db.CreateUser(r.Context(), request.Username, request.Password, request.Role)
+		if err == nil && request.PlayerName != "" {
+			user, err = db.SetUserPlayerName(r.Context(), user.ID, request.PlayerName)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		slog.InfoContext(r.Context(), "Created user", "user", user.Username, "role", user.Role)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(user)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleUserRoute updates (PUT) or deletes (DELETE) /api/users/{id}. A PUT
+// sets the role, and the password and linked player when given. Admins
+// can't change their own role or delete themselves, so there's always an admin.
+func handleUserRoute(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/users/"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	self := requestUser(r) != nil && requestUser(r).ID == id
+
+	switch r.Method {
+	case http.MethodPut:
+		var request struct {
+			Role       string  `json:"role"`
+			Password   string  `json:"password"`
+			PlayerName *string `json:"playerName"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if self && request.Role != db.RoleAdmin {
+			http.Error(w, "You can't change your own role", http.StatusBadRequest)
+			return
+		}
+
+		user, err := db.UpdateUser(r.Context(), id, request.Role, request.Password)
+		if err == nil && request.PlayerName != nil {
+			user, err = db.SetUserPlayerName(r.Context(), id, *request.PlayerName)
+		}
+		if errors.Is(err, db.ErrUserNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(user)
+	case http.MethodDelete:
+		if self {
+			http.Error(w, "You can't delete your own account", http.StatusBadRequest)
+			return
+		}
+
+		err := db.DeleteUser(r.Context(), id)
+		if errors.Is(err, db.ErrUserNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to delete user", "user_id", id, "error", err)
+			http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+			return
+		}
+		slog.InfoContext(r.Context(), "Deleted user", "user_id", id)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "User deleted successfully",
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleTokens(w http.ResponseWriter, r *http.Request) {
+	user := requestUser(r)
+	if user == nil {
+		http.Error(w, "Not signed in", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		tokens, err := db.GetAPITokens(r.Context(), user.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to get API tokens", "user", user.Username, "error", err)
+			http.Error(w, "Failed to retrieve API tokens", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tokens)
+	case http.MethodPost:
+		var request struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		token, info, err := db.CreateAPIToken(r.Context(), user.ID, request.Name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		slog.InfoContext(r.Context(), "Created API token", "token", info.Name, "user", user.Username)
+
+		// The token is only ever shown in this response
+		response := struct {
+			*db.APIToken
+			Token string `json:"token"`
+		}{info, token}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleTokenRoute(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := requestUser(r)
+	if user == nil {
+		http.Error(w, "Not signed in", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/tokens/"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+
+	err = db.DeleteAPIToken(r.Context(), user.ID, id)
+	if errors.Is(err, db.ErrAPITokenNotFound) {
+		http.Error(w, "API token not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to delete API token", "token_id", id, "error", err)
+		http.Error(w, "Failed to delete API token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "API token revoked",
+	})
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"wingspan-scoring/config"
+	"wingspan-scoring/db"
+	"wingspan-scoring/oidc"
+)
+
+// oidcCookie carries the state, nonce and PKCE verifier of a sign-in in
+// progress from /login/oidc to the callback
+const oidcCookie = "wingspan_oidc"
+
+// oidcCallbackPath is where the provider sends users back to
+const oidcCallbackPath = "/login/oidc/callback"
+
+// oidcLogin signs users in with an OpenID Connect provider alongside local accounts
+type oidcLogin struct {
+	provider      *oidc.Provider
+	name          string            // Shown on the sign-in button
+	redirectURL   string            // Callback URL registered with the provider; derived from the request when empty
+	usernameClaim string            // Claim for the username of new accounts
+	playerClaim   string            // Claim linking the account to a player
+	rolesClaim    string            // Claim listing the user's groups or roles
+	roleMap       map[string]string // Values of rolesClaim to app roles
+	defaultRole   string            // Role when no value maps to one; empty refuses sign-in
+}
+
+// newOIDCLogin discovers the provider configured in cfg. It returns nil
+// when no issuer is set.
+func newOIDCLogin(ctx context.Context, cfg config.OIDC) (*oidcLogin, error) {
+	if cfg.Issuer == "" {
+		return nil, nil
+	}
+
+	provider, err := oidc.Discover(ctx, oidc.Config{
+		Issuer:       cfg.Issuer,
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		Scopes:       cfg.Scopes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &oidcLogin{
+		provider:      provider,
+		name:          cfg.ProviderName,
+		redirectURL:   cfg.RedirectURL,
+		usernameClaim: cfg.UsernameClaim,
+		playerClaim:   cfg.PlayerClaim,
+		rolesClaim:    cfg.RolesClaim,
+		roleMap:       cfg.RoleMap,
+		defaultRole:   cfg.DefaultRole,
+	}, nil
+}
+
+// mapRoles returns the highest role that values, such as a user's groups,
+// map to, or defaultRole when none do. Without a role map, values that are
+// role names are used directly.
+func mapRoles(values []string, roleMap map[string]string, defaultRole string) string {
+	role := ""
+	for _, value := range values {
+		mapped := roleMap[value]
+		if len(roleMap) == 0 && db.ValidRole(value) {
+			mapped = value
+		}
+		if mapped != "" && (role == "" || !db.HasRole(role, mapped)) {
+			role = mapped
+		}
+	}
+	if role == "" {
+		return defaultRole
+	}
+	return role
+}
+
+// role maps a user's claims to the highest app role they're given
+func (o *oidcLogin) role(claims oidc.Claims) string {
+	return mapRoles(claims.Strings(o.rolesClaim), o.roleMap, o.defaultRole)
+}
+
+// username picks the username for a new account, falling back to the
+// email address and then the subject
+func (o *oidcLogin) username(claims oidc.Claims) string {
+	for _, name := range []string{o.usernameClaim, "email"} {
+		if username := strings.TrimSpace(claims.String(name)); username != "" {
+			return username
+		}
+	}
+	return claims.Subject()
+}
+
+// callbackURL returns the redirect URL sent to the provider
+func (o *oidcLogin) callbackURL(r *http.Request) string {
+	if o.redirectURL != "" {
+		return o.redirectURL
+	}
+	scheme := "http"
+	if isHTTPS(r) {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + oidcCallbackPath
+}
+
+// oidcState is what the sign-in cookie remembers between the redirects
+type oidcState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Next     string `json:"next"`
+}
+
+// handleOIDCLogin starts signing in by redirecting to the provider
+func (s *server) handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if s.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	state := oidcState{Next: safeRedirect(r.URL.Query().Get("next"), requestBasePath(r)+"/")}
+	for _, s := range []*string{&state.State, &state.Nonce, &state.Verifier} {
+		var err error
+		if *s, err = oidc.RandomString(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to start OpenID Connect sign-in", "error", err)
+			http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+			return
+		}
+	}
+	encoded, _ := json.Marshal(state)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(encoded),
+		Path:     "/",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, s.oidc.provider.AuthCodeURL(s.oidc.callbackURL(r), state.State, state.Nonce, state.Verifier), http.StatusFound)
+}
+
+// handleOIDCCallback finishes signing in when the provider sends the user
+// back with an authorization code
+func (s *server) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if s.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var state oidcState
+	cookie, err := r.Cookie(oidcCookie)
+	if err == nil {
+		var decoded []byte
+		if decoded, err = base64.RawURLEncoding.DecodeString(cookie.Value); err == nil {
+			err = json.Unmarshal(decoded, &state)
+		}
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcCookie, Path: "/", MaxAge: -1, HttpOnly: true, Secure: isHTTPS(r)})
+
+	q := r.URL.Query()
+	if err != nil || state.State == "" || q.Get("state") != state.State {
+		s.renderLogin(w, r, http.StatusBadRequest, "Sign-in expired or was started elsewhere, please try again")
+		return
+	}
+	if q.Get("error") != "" {
+		slog.WarnContext(r.Context(), "OpenID Connect sign-in refused", "error", q.Get("error"), "description", q.Get("error_description"))
+		s.renderLogin(w, r, http.StatusUnauthorized, "Sign-in was cancelled or refused")
+		return
+	}
+
+	rawToken, err := s.oidc.provider.Exchange(r.Context(), q.Get("code"), s.oidc.callbackURL(r), state.Verifier)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to exchange OpenID Connect code", "error", err)
+		s.renderLogin(w, r, http.StatusBadGateway, "Sign-in failed, please try again")
+		return
+	}
+	claims, err := s.oidc.provider.Verify(r.Context(), rawToken, state.Nonce)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Failed to verify OpenID Connect ID token", "error", err)
+		s.renderLogin(w, r, http.StatusUnauthorized, "Sign-in failed, please try again")
+		return
+	}
+
+	role := s.oidc.role(claims)
+	if role == "" {
+		slog.WarnContext(r.Context(), "OpenID Connect user has no role in this app", "subject", claims.Subject())
+		s.renderLogin(w, r, http.StatusForbidden, "Your account doesn't have access to this app")
+		return
+	}
+
+	subject := s.oidc.provider.Issuer() + "#" + claims.Subject()
+	user, err := db.UpsertOIDCUser(r.Context(), subject, s.oidc.username(claims), role, claims.String(s.oidc.playerClaim))
+	if errors.Is(err, db.ErrUsernameTaken) {
+		s.renderLogin(w, r, http.StatusConflict, "A local account already has your username")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save OpenID Connect user", "subject", claims.Subject(), "error", err)
+		http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		return
+	}
+
+	if err := startSession(w, r, user); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to start session", "user", user.Username, "error", err)
+		http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "User signed in with OpenID Connect", "user", user.Username)
+	http.Redirect(w, r, safeRedirect(state.Next, "/"), http.StatusSeeOther)
+}
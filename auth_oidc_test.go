@@ -0,0 +1,174 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"wingspan-scoring/db"
+	"wingspan-scoring/oidc/oidctest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newOIDCTestServer returns the app's routes with OpenID Connect sign-in
+// against a mock issuer, with further settings from env
+func newOIDCTestServer(t *testing.T, env map[string]string) (http.Handler, *oidctest.Issuer) {
+	t.Helper()
+	issuer := oidctest.NewIssuer(t)
+	env["OIDC_ISSUER"] = issuer.URL
+	env["OIDC_CLIENT_ID"] = issuer.ClientID
+	env["OIDC_CLIENT_SECRET"] = issuer.ClientSecret
+
+	srv := newConfiguredServer(t, env)
+	require.NotNil(t, srv.oidc)
+	return srv.handler(), issuer
+}
+
+// oidcSignIn runs the sign-in flow through the mock issuer and returns the
+// app's response to the callback
+func oidcSignIn(t *testing.T, handler http.Handler, start string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, start, nil))
+	require.Equal(t, http.StatusFound, w.Code)
+	var stateCookie *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == oidcCookie {
+			stateCookie = cookie
+		}
+	}
+	require.NotNil(t, stateCookie)
+
+	// The mock issuer approves straight away and redirects back with a code
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(w.Header().Get("Location"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, oidcCallbackPath, callback.Path)
+
+	req := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	req.AddCookie(stateCookie)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+// TestOIDCLogin tests signing in with an OpenID Connect provider
+func TestOIDCLogin(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	handler, issuer := newOIDCTestServer(t, map[string]string{"OIDC_ROLE_MAP": "birders=scorer,admins=admin"})
+	issuer.SetClaims(map[string]any{
+		"sub":                "alice-id",
+		"preferred_username": "alice",
+		"name":               "Alice",
+		"groups":             []string{"family", "birders"},
+	})
+
+	loginPage := httptest.NewRecorder()
+	handler.ServeHTTP(loginPage, httptest.NewRequest(http.MethodGet, "/login", nil))
+	assert.Contains(t, loginPage.Body.String(), "Sign in with Single Sign-On")
+
+	resp := oidcSignIn(t, handler, "/login/oidc?next=/history")
+	require.Equal(t, http.StatusSeeOther, resp.Code)
+	assert.Equal(t, "/history", resp.Header().Get("Location"))
+
+	user, err := db.GetUserByUsername(t.Context(), "alice")
+	require.NoError(t, err)
+	assert.Equal(t, db.RoleScorer, user.Role)
+	assert.Equal(t, "Alice", user.PlayerName)
+
+	// The session works like a local one and links to the player's profile
+	c := &authClient{t: t, handler: handler}
+	for _, cookie := range resp.Result().Cookies() {
+		if cookie.Name == sessionCookie {
+			c.cookie = cookie
+		}
+	}
+	require.NotNil(t, c.cookie)
+	me := c.do(http.MethodGet, "/api/me", "", "")
+	require.Equal(t, http.StatusOK, me.Code)
+	assert.Contains(t, me.Body.String(), `"role":"scorer"`)
+	assert.Contains(t, me.Body.String(), `"profileUrl":"/api/stats/Alice/profile"`)
+
+	// Roles follow the provider on every sign-in
+	issuer.SetClaims(map[string]any{"sub": "alice-id", "preferred_username": "alice", "groups": "admins"})
+	resp = oidcSignIn(t, handler, "/g/default/login/oidc")
+	require.Equal(t, http.StatusSeeOther, resp.Code)
+	assert.Equal(t, "/g/default/", resp.Header().Get("Location"))
+	user, err = db.GetUserByUsername(t.Context(), "alice")
+	require.NoError(t, err)
+	assert.Equal(t, db.RoleAdmin, user.Role)
+
+	count, err := db.CountUsers(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+// TestOIDCLogin_Refused tests sign-ins the app turns away
+func TestOIDCLogin_Refused(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	handler, issuer := newOIDCTestServer(t, map[string]string{"OIDC_ROLE_MAP": "birders=scorer", "OIDC_DEFAULT_ROLE": ""})
+
+	// No mapped role and no default role
+	issuer.SetClaims(map[string]any{"sub": "bob-id", "preferred_username": "bob", "groups": []string{"other"}})
+	resp := oidcSignIn(t, handler, "/login/oidc")
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	// A local account already has the username
+	_, err := db.CreateUser(t.Context(), "carol", "password1", db.RoleViewer)
+	require.NoError(t, err)
+	issuer.SetClaims(map[string]any{"sub": "carol-id", "preferred_username": "carol", "groups": "birders"})
+	resp = oidcSignIn(t, handler, "/login/oidc")
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	// Callbacks without the matching state cookie are rejected
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, oidcCallbackPath+"?code=abc&state=forged", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	count, err := db.CountUsers(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+// TestOIDCLogin_Disabled tests that the OpenID Connect routes are absent when it isn't configured
+func TestOIDCLogin_Disabled(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	handler := newAuthTestServer("")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login/oidc", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+	assert.NotContains(t, w.Body.String(), "/login/oidc")
+}
+
+// TestOIDCRole tests mapping claims to roles
+func TestOIDCRole(t *testing.T) {
+	roleMap := map[string]string{"admins": db.RoleAdmin, "birders": db.RoleScorer}
+	login := &oidcLogin{rolesClaim: "groups", roleMap: roleMap, defaultRole: db.RoleViewer}
+	assert.Equal(t, db.RoleAdmin, login.role(map[string]any{"groups": []any{"birders", "admins"}}))
+	assert.Equal(t, db.RoleScorer, login.role(map[string]any{"groups": "birders"}))
+	assert.Equal(t, db.RoleViewer, login.role(map[string]any{"groups": []any{"admin"}}))
+	assert.Equal(t, db.RoleViewer, login.role(map[string]any{}))
+
+	// Without a map, role names in the claim are used as they are
+	login = &oidcLogin{rolesClaim: "roles"}
+	assert.Equal(t, db.RoleScorer, login.role(map[string]any{"roles": []any{"viewer", "scorer", "staff"}}))
+	assert.Equal(t, "", login.role(map[string]any{"roles": "staff"}))
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strings"
+	"wingspan-scoring/config"
+	"wingspan-scoring/db"
+)
+
+// proxyAuth trusts the user named in headers set by an authenticating
+// reverse proxy, such as oauth-proxy, when the request comes from the proxy
+type proxyAuth struct {
+	trusted      []netip.Prefix    // Addresses the proxy connects from
+	userHeader   string            // Header with the username
+	emailHeader  string            // Header with the email address, used when there's no username
+	groupsHeader string            // Header with comma-separated groups
+	roleMap      map[string]string // Groups to app roles
+	defaultRole  string            // Role for new users with no mapped group; empty refuses them
+	crossOrigin  *http.CrossOriginProtection
+}
+
+// errNoRole is returned for proxy users with no role in the app
+var errNoRole = errors.New("user has no role")
+
+// newProxyAuth configures proxy header authentication from cfg. It returns
+// nil when no trusted proxy addresses are set.
+func newProxyAuth(cfg config.Proxy) (*proxyAuth, error) {
+	if len(cfg.TrustedCIDRs) == 0 {
+		return nil, nil
+	}
+
+	trusted, err := cfg.Prefixes()
+	if err != nil {
+		return nil, err
+	}
+
+	return &proxyAuth{
+		trusted:      trusted,
+		userHeader:   cfg.UserHeader,
+		emailHeader:  cfg.EmailHeader,
+		groupsHeader: cfg.GroupsHeader,
+		roleMap:      cfg.RoleMap,
+		defaultRole:  cfg.DefaultRole,
+		crossOrigin:  http.NewCrossOriginProtection(),
+	}, nil
+}
+
+// trustedSource reports whether a request came straight from a trusted
+// proxy. With proxy authentication off, no request does.
+func (p *proxyAuth) trustedSource(r *http.Request) bool {
+	if p == nil {
+		return false
+	}
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := addrPort.Addr().Unmap()
+	for _, prefix := range p.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// user returns the account for a proxy user, creating it on first sight.
+// Mapped groups set the role every time; otherwise existing users keep theirs.
+// Local and OpenID Connect accounts are never matched, so a username taken
+// by one gives db.ErrUsernameTaken.
+func (p *proxyAuth) user(ctx context.Context, username string, groups []string) (*db.User, error) {
+	role := mapRoles(groups, p.roleMap, "")
+
+	user, err := db.GetProxyUser(ctx, username)
+	if errors.Is(err, db.ErrUserNotFound) {
+		if role == "" {
+			role = p.defaultRole
+		}
+		if role == "" {
+			return nil, errNoRole
+		}
+		user, err = db.CreateExternalUser(ctx, username, role)
+		if errors.Is(err, db.ErrUsernameTaken) {
+			// Created by a concurrent request, or taken by another account
+			return db.GetProxyUser(ctx, username)
+		}
+		if err == nil {
+			slog.InfoContext(ctx, "Created user from proxy headers", "user", user.Username, "role", user.Role)
+		}
+		return user, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if role != "" && role != user.Role {
+		return db.UpdateUser(ctx, user.ID, role, "")
+	}
+	return user, nil
+}
+
+// proxyAuthMiddleware signs in the user named by a trusted proxy's headers
+// before authMiddleware runs. The headers are refused from any other
+// address, so clients can't name themselves. Since browsers send the proxy's
+// credentials on their own, cross-origin requests that change data are
+// refused too. When proxy is nil, the headers have no meaning.
+func proxyAuthMiddleware(proxy *proxyAuth, next http.Handler) http.Handler {
+	if proxy == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username := strings.TrimSpace(r.Header.Get(proxy.userHeader))
+		if username == "" {
+			username = strings.TrimSpace(r.Header.Get(proxy.emailHeader))
+		}
+		if username == "" && r.Header.Get(proxy.groupsHeader) == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !proxy.trustedSource(r) {
+			slog.WarnContext(r.Context(), "Refused proxy authentication headers from untrusted address", "remote_addr", r.RemoteAddr)
+			http.Error(w, "Proxy authentication headers aren't accepted from this address", http.StatusForbidden)
+			return
+		}
+		if username == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var groups []string
+		for _, group := range strings.Split(r.Header.Get(proxy.groupsHeader), ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				groups = append(groups, group)
+			}
+		}
+
+		user, err := proxy.user(r.Context(), username, groups)
+		if errors.Is(err, errNoRole) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, db.ErrUsernameTaken) {
+			slog.WarnContext(r.Context(), "Refused proxy user with the username of another account", "user", username)
+			http.Error(w, "A local account already has this username", http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to get proxy user", "user", username, "error", err)
+			http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userKey, user)
+		proxy.crossOrigin.Handler(next).ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// forwardedProtoMiddleware drops X-Forwarded-Proto from requests that didn't
+// come straight from a trusted proxy, so isHTTPS only believes the proxy and
+// clients can't claim HTTPS for secure cookies, HSTS or sign-in callbacks
+func forwardedProtoMiddleware(proxy *proxyAuth, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Forwarded-Proto") != "" && !proxy.trustedSource(r) {
+			r.Header.Del("X-Forwarded-Proto")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"wingspan-scoring/config"
+	"wingspan-scoring/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newProxyTestServer returns the app's routes behind proxy header
+// authentication configured from env
+func newProxyTestServer(t *testing.T, env map[string]string) http.Handler {
+	t.Helper()
+	srv := newConfiguredServer(t, env)
+	require.NotNil(t, srv.proxy)
+	return srv.handler()
+}
+
+// proxyRequest sends a request from remoteAddr with the given headers
+func proxyRequest(handler http.Handler, method, path, remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(testGame))
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+// TestProxyAuth tests signing in users named by a trusted proxy
+func TestProxyAuth(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	handler := newProxyTestServer(t, map[string]string{
+		"PROXY_AUTH_TRUSTED_CIDRS": "10.0.0.0/8, 192.168.1.5",
+		"PROXY_AUTH_ROLE_MAP":      "birders=scorer",
+	})
+	proxied := "10.1.2.3:5000"
+
+	w := proxyRequest(handler, http.MethodGet, "/api/me", proxied, map[string]string{"X-Forwarded-User": "alice"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"username":"alice"`)
+	assert.Contains(t, w.Body.String(), `"role":"viewer"`)
+
+	// Viewers can't save games
+	w = proxyRequest(handler, http.MethodPost, "/api/calculate-game-end", proxied, map[string]string{"X-Forwarded-User": "alice"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Mapped groups update the role, and no CSRF token is needed
+	w = proxyRequest(handler, http.MethodPost, "/api/calculate-game-end", "192.168.1.5:5000", map[string]string{
+		"X-Forwarded-User":   "alice",
+		"X-Forwarded-Groups": "family, birders",
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	user, err := db.GetUserByUsername(t.Context(), "alice")
+	require.NoError(t, err)
+	assert.Equal(t, db.RoleScorer, user.Role)
+
+	// ...but cross-origin browser requests are refused
+	w = proxyRequest(handler, http.MethodPost, "/api/calculate-game-end", proxied, map[string]string{
+		"X-Forwarded-User": "alice",
+		"Sec-Fetch-Site":   "cross-site",
+	})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// The email header names users without a username
+	w = proxyRequest(handler, http.MethodGet, "/api/me", proxied, map[string]string{"X-Forwarded-Email": "bob@example.com"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"username":"bob@example.com"`)
+
+	// Requests from the proxy without a user fall back to normal authentication
+	w = proxyRequest(handler, http.MethodGet, "/api/me", proxied, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// TestProxyAuth_LocalAccount tests that proxy users can't take over local
+// accounts with the same username
+func TestProxyAuth_LocalAccount(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	admin, err := db.CreateUser(t.Context(), "admin", "password123", db.RoleAdmin)
+	require.NoError(t, err)
+	handler := newProxyTestServer(t, map[string]string{
+		"PROXY_AUTH_TRUSTED_CIDRS": "10.0.0.0/8",
+		"PROXY_AUTH_ROLE_MAP":      "family=viewer",
+	})
+
+	w := proxyRequest(handler, http.MethodGet, "/api/me", "10.1.2.3:5000", map[string]string{
+		"X-Forwarded-User":   "admin",
+		"X-Forwarded-Groups": "family",
+	})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NotContains(t, w.Body.String(), `"username"`)
+
+	user, err := db.GetUser(t.Context(), admin.ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.RoleAdmin, user.Role, "the local account's role is untouched")
+}
+
+// TestProxyAuth_Untrusted tests that the headers are refused from other addresses
+func TestProxyAuth_Untrusted(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	handler := newProxyTestServer(t, map[string]string{
+		"PROXY_AUTH_TRUSTED_CIDRS": "10.0.0.0/8",
+		"PROXY_AUTH_USER_HEADER":   "Remote-User",
+	})
+
+	for _, headers := range []map[string]string{
+		{"Remote-User": "mallory"},
+		{"X-Forwarded-Email": "mallory@example.com"},
+		{"X-Forwarded-Groups": "admins"},
+	} {
+		w := proxyRequest(handler, http.MethodGet, "/api/games", "203.0.113.9:5000", headers)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	}
+
+	// The default header no longer names anyone
+	w := proxyRequest(handler, http.MethodGet, "/api/me", "10.0.0.1:5000", map[string]string{"X-Forwarded-User": "mallory"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = proxyRequest(handler, http.MethodGet, "/api/me", "10.0.0.1:5000", map[string]string{"Remote-User": "carol"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	count, err := db.CountUsers(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+// TestProxyAuth_NoRole tests refusing new users without a mapped group
+func TestProxyAuth_NoRole(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	handler := newProxyTestServer(t, map[string]string{
+		"PROXY_AUTH_TRUSTED_CIDRS": "127.0.0.1",
+		"PROXY_AUTH_ROLE_MAP":      "admins=admin",
+		"PROXY_AUTH_DEFAULT_ROLE":  "",
+	})
+
+	w := proxyRequest(handler, http.MethodGet, "/api/me", "127.0.0.1:5000", map[string]string{"X-Forwarded-User": "dan"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = proxyRequest(handler, http.MethodGet, "/api/users", "127.0.0.1:5000", map[string]string{
+		"X-Forwarded-User":   "dan",
+		"X-Forwarded-Groups": "admins",
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// TestForwardedProtoMiddleware tests that only trusted proxies can say a
+// request came over HTTPS
+func TestForwardedProtoMiddleware(t *testing.T) {
+	proxy, err := newProxyAuth(config.Proxy{TrustedCIDRs: []string{"10.0.0.0/8"}})
+	require.NoError(t, err)
+
+	var https bool
+	record := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { https = isHTTPS(r) })
+	for _, tc := range []struct {
+		proxy      *proxyAuth
+		remoteAddr string
+		want       bool
+	}{
+		{proxy, "10.1.2.3:5000", true},
+		{proxy, "203.0.113.9:5000", false},
+		{nil, "10.1.2.3:5000", false},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tc.remoteAddr
+		req.Header.Set("X-Forwarded-Proto", "https")
+		forwardedProtoMiddleware(tc.proxy, record).ServeHTTP(httptest.NewRecorder(), req)
+		assert.Equal(t, tc.want, https, tc.remoteAddr)
+	}
+}
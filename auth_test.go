@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"wingspan-scoring/config"
+	"wingspan-scoring/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAuthTestServer returns the app's routes behind the group and auth middleware
+func newAuthTestServer(anonymousRole string) http.Handler {
+	srv := &server{config: config.Default()}
+	srv.config.Auth.AnonymousRole = anonymousRole
+	return srv.handler()
+}
+
+// authClient sends requests as a browser with a session cookie or an API client with a token
+type authClient struct {
+	t       *testing.T
+	handler http.Handler
+	cookie  *http.Cookie
+	csrf    string
+	token   string
+}
+
+// do sends a request and returns the response
+func (c *authClient) do(method, path, contentType, body string) *httptest.ResponseRecorder {
+	c.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.cookie != nil {
+		req.AddCookie(c.cookie)
+	}
+	if c.csrf != "" {
+		req.Header.Set(csrfHeader, c.csrf)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	w := httptest.NewRecorder()
+	c.handler.ServeHTTP(w, req)
+	return w
+}
+
+// csrfPattern finds the CSRF token rendered into pages
+var csrfPattern = regexp.MustCompile(`data-csrf-token="([^"]+)"`)
+
+// login signs in through the login form, keeping the session cookie and the
+// CSRF token from the home page
+func (c *authClient) login(username, password string) {
+	c.t.Helper()
+	form := url.Values{"username": {username}, "password": {password}}
+	w := c.do(http.MethodPost, "/login", "application/x-www-form-urlencoded", form.Encode())
+	require.Equal(c.t, http.StatusSeeOther, w.Code)
+
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == sessionCookie {
+			c.cookie = cookie
+		}
+	}
+	require.NotNil(c.t, c.cookie)
+
+	w = c.do(http.MethodGet, "/", "", "")
+	require.Equal(c.t, http.StatusOK, w.Code)
+	match := csrfPattern.FindStringSubmatch(w.Body.String())
+	require.Len(c.t, match, 2)
+	c.csrf = match[1]
+}
+
+// testGame is a game-end request body
+const testGame = `{"players":[{"playerName":"Alice","birdPoints":40},{"playerName":"Bob","birdPoints":30}]}`
+
+// TestRequiredRole tests the role each kind of route needs
+func TestRequiredRole(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		role   string
+	}{
+		{http.MethodGet, "/login", ""},
+		{http.MethodPost, "/logout", ""},
+		{http.MethodGet, "/static/css/styles.css", ""},
+		{http.MethodGet, "/api/version", ""},
+		{http.MethodGet, "/", db.RoleViewer},
+		{http.MethodGet, "/api/games", db.RoleViewer},
+		{http.MethodGet, "/metrics", db.RoleViewer},
+		{http.MethodPost, "/api/new-game", db.RoleViewer},
+		{http.MethodPost, "/api/calculate-scores", db.RoleViewer},
+		{http.MethodPost, "/api/tokens", db.RoleViewer},
+		{http.MethodDelete, "/api/tokens/3", db.RoleViewer},
+		{http.MethodPost, "/api/calculate-game-end", db.RoleScorer},
+		{http.MethodPut, "/api/games/1/season", db.RoleScorer},
+		{http.MethodPost, "/api/seasons", db.RoleScorer},
+		{http.MethodPost, "/api/tournaments/1/rounds", db.RoleScorer},
+		{http.MethodDelete, "/api/games/1", db.RoleAdmin},
+		{http.MethodDelete, "/api/seasons/1", db.RoleAdmin},
+		{http.MethodPost, "/api/import", db.RoleAdmin},
+		{http.MethodGet, "/api/users", db.RoleAdmin},
+		{http.MethodPost, "/api/groups", db.RoleAdmin},
+		{http.MethodGet, "/api/groups", db.RoleViewer},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.role, requiredRole(tt.method, tt.path), "%s %s", tt.method, tt.path)
+	}
+}
+
+// TestAuthMiddleware_Anonymous tests requests that aren't signed in
+func TestAuthMiddleware_Anonymous(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	c := &authClient{t: t, handler: newAuthTestServer("")}
+
+	w := c.do(http.MethodGet, "/api/games", "", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+
+	w = c.do(http.MethodGet, "/history?page=2", "", "")
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/login?next=%2Fhistory%3Fpage%3D2", w.Header().Get("Location"))
+
+	w = c.do(http.MethodGet, "/g/default/history", "", "")
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/g/default/login?next=%2Fg%2Fdefault%2Fhistory", w.Header().Get("Location"))
+
+	assert.Equal(t, http.StatusOK, c.do(http.MethodGet, "/login", "", "").Code)
+	assert.Equal(t, http.StatusOK, c.do(http.MethodGet, "/api/version", "", "").Code)
+
+	// An anonymous role opens up matching routes only
+	c.handler = newAuthTestServer(db.RoleViewer)
+	assert.Equal(t, http.StatusOK, c.do(http.MethodGet, "/api/games", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, c.do(http.MethodPost, "/api/calculate-game-end", "application/json", testGame).Code)
+	assert.Equal(t, http.StatusUnauthorized, c.do(http.MethodGet, "/api/tokens", "", "").Code)
+}
+
+// TestLogin tests signing in and out, sessions and CSRF protection
+func TestLogin(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	_, err := db.CreateUser(t.Context(), "scorer", "password1", db.RoleScorer)
+	require.NoError(t, err)
+
+	c := &authClient{t: t, handler: newAuthTestServer("")}
+
+	form := url.Values{"username": {"scorer"}, "password": {"wrong-password"}}
+	w := c.do(http.MethodPost, "/login", "application/x-www-form-urlencoded", form.Encode())
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid username or password")
+
+	form = url.Values{"username": {"scorer"}, "password": {"password1"}, "next": {"/history"}}
+	w = c.do(http.MethodPost, "/login", "application/x-www-form-urlencoded", form.Encode())
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/history", w.Header().Get("Location"))
+
+	c.login("scorer", "password1")
+	assert.True(t, c.cookie.HttpOnly)
+
+	w = c.do(http.MethodGet, "/api/me", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"username":"scorer"`)
+
+	// Changes need the CSRF token
+	csrf := c.csrf
+	c.csrf = ""
+	assert.Equal(t, http.StatusForbidden, c.do(http.MethodPost, "/api/calculate-game-end", "application/json", testGame).Code)
+	c.csrf = "wrong"
+	assert.Equal(t, http.StatusForbidden, c.do(http.MethodPost, "/api/calculate-game-end", "application/json", testGame).Code)
+	c.csrf = csrf
+	assert.Equal(t, http.StatusOK, c.do(http.MethodPost, "/api/calculate-game-end", "application/json", testGame).Code)
+
+	// Scorers can't delete
+	games, err := db.GetAllGameResults(t.Context(), 10, 0)
+	require.NoError(t, err)
+	require.Len(t, games, 1)
+	gamePath := "/api/games/" + strconv.FormatInt(games[0].ID, 10)
+	assert.Equal(t, http.StatusForbidden, c.do(http.MethodDelete, gamePath, "", "").Code)
+	assert.Equal(t, http.StatusForbidden, c.do(http.MethodPost, "/api/import", "", "").Code)
+
+	// The sign-out form sends the token as a field
+	c.csrf = ""
+	logout := url.Values{"csrf_token": {csrf}}
+	w = c.do(http.MethodPost, "/logout", "application/x-www-form-urlencoded", logout.Encode())
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, c.do(http.MethodGet, "/api/me", "", "").Code)
+}
+
+// TestAPITokenAuth tests issuing API tokens and using them in place of a session
+func TestAPITokenAuth(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	_, err := db.CreateUser(t.Context(), "viewer", "password1", db.RoleViewer)
+	require.NoError(t, err)
+	_, err = db.CreateUser(t.Context(), "admin", "password1", db.RoleAdmin)
+	require.NoError(t, err)
+
+	handler := newAuthTestServer("")
+	issue := func(username string) string {
+		c := &authClient{t: t, handler: handler}
+		c.login(username, "password1")
+		w := c.do(http.MethodPost, "/api/tokens", "application/json", `{"name":"scripts"}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+		match := regexp.MustCompile(`"token":"([^"]+)"`).FindStringSubmatch(w.Body.String())
+		require.Len(t, match, 2)
+		return match[1]
+	}
+
+	viewer := &authClient{t: t, handler: handler, token: issue("viewer")}
+	assert.Equal(t, http.StatusOK, viewer.do(http.MethodGet, "/api/games", "", "").Code)
+	assert.Equal(t, http.StatusForbidden, viewer.do(http.MethodPost, "/api/calculate-game-end", "application/json", testGame).Code)
+
+	// Token requests don't need a CSRF token
+	admin := &authClient{t: t, handler: handler, token: issue("admin")}
+	require.Equal(t, http.StatusOK, admin.do(http.MethodPost, "/api/calculate-game-end", "application/json", testGame).Code)
+	games, err := db.GetAllGameResults(t.Context(), 10, 0)
+	require.NoError(t, err)
+	require.Len(t, games, 1)
+	assert.Equal(t, http.StatusOK, admin.do(http.MethodDelete, "/api/games/"+strconv.FormatInt(games[0].ID, 10), "", "").Code)
+
+	w := admin.do(http.MethodGet, "/api/tokens", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), admin.token)
+
+	bad := &authClient{t: t, handler: handler, token: db.APITokenPrefix + "nope"}
+	assert.Equal(t, http.StatusUnauthorized, bad.do(http.MethodGet, "/api/games", "", "").Code)
+}
+
+// TestHandleUsers tests admin account management
+func TestHandleUsers(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	admin, err := db.CreateUser(t.Context(), "admin", "password1", db.RoleAdmin)
+	require.NoError(t, err)
+
+	c := &authClient{t: t, handler: newAuthTestServer("")}
+	c.login("admin", "password1")
+
+	w := c.do(http.MethodPost, "/api/users", "application/json", `{"username":"carol","password":"password2","role":"scorer"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	carol, err := db.GetUserByUsername(t.Context(), "carol")
+	require.NoError(t, err)
+	carolPath := "/api/users/" + strconv.FormatInt(carol.ID, 10)
+
+	assert.Equal(t, http.StatusBadRequest, c.do(http.MethodPost, "/api/users", "application/json", `{"username":"dan","password":"short","role":"viewer"}`).Code)
+
+	w = c.do(http.MethodPut, carolPath, "application/json", `{"role":"viewer"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"role":"viewer"`)
+
+	w = c.do(http.MethodGet, "/api/users", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "password")
+
+	adminPath := "/api/users/" + strconv.FormatInt(admin.ID, 10)
+	assert.Equal(t, http.StatusBadRequest, c.do(http.MethodPut, adminPath, "application/json", `{"role":"viewer"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, c.do(http.MethodDelete, adminPath, "", "").Code)
+
+	assert.Equal(t, http.StatusOK, c.do(http.MethodDelete, carolPath, "", "").Code)
+	assert.Equal(t, http.StatusNotFound, c.do(http.MethodDelete, carolPath, "", "").Code)
+
+	// Non-admins can't manage accounts
+	_, err = db.CreateUser(t.Context(), "scorer", "password1", db.RoleScorer)
+	require.NoError(t, err)
+	scorer := &authClient{t: t, handler: c.handler}
+	scorer.login("scorer", "password1")
+	assert.Equal(t, http.StatusForbidden, scorer.do(http.MethodGet, "/api/users", "", "").Code)
+}
+
+// TestEnsureAdminAccount tests creating the first admin from the settings
+func TestEnsureAdminAccount(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	require.NoError(t, ensureAdminAccount(t.Context(), config.Auth{AdminUsername: "root", AdminPassword: "password1"}))
+
+	user, err := db.Authenticate(t.Context(), "root", "password1")
+	require.NoError(t, err)
+	assert.Equal(t, db.RoleAdmin, user.Role)
+
+	// Existing accounts are left alone
+	require.NoError(t, ensureAdminAccount(t.Context(), config.Auth{AdminUsername: "other"}))
+	count, err := db.CountUsers(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+// TestEnsureAdminAccountGeneratedPassword tests that a generated password is
+// printed outside the structured log
+func TestEnsureAdminAccountGeneratedPassword(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	var logs, out bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	defer slog.SetDefault(defaultLogger)
+	defer func(w io.Writer) { adminPasswordOutput = w }(adminPasswordOutput)
+	adminPasswordOutput = &out
+
+	require.NoError(t, ensureAdminAccount(t.Context(), config.Auth{AdminUsername: "root"}))
+
+	line := strings.TrimSpace(out.String())
+	password := line[strings.LastIndex(line, " ")+1:]
+	require.NotEmpty(t, password)
+	_, err := db.Authenticate(t.Context(), "root", password)
+	require.NoError(t, err)
+	assert.NotContains(t, logs.String(), password)
+	assert.Contains(t, logs.String(), "generated password")
+}
+
+// TestSafeRedirect tests that sign-in only redirects within the server
+func TestSafeRedirect(t *testing.T) {
+	assert.Equal(t, "/history", safeRedirect("/history", "/"))
+	assert.Equal(t, "/", safeRedirect("https://example.com", "/"))
+	assert.Equal(t, "/", safeRedirect("//example.com", "/"))
+	assert.Equal(t, "/", safeRedirect("/\\example.com", "/"))
+	assert.Equal(t, "/g/club/", safeRedirect("", "/g/club/"))
+}
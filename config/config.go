@@ -0,0 +1,595 @@
+// Package config loads the server's settings from defaults, an optional
+// YAML or TOML file, environment variables and command-line flags, in
+// increasing order of precedence, and validates them.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"wingspan-scoring/db"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is every server setting. Each leaf field has a config file key
+// (its yaml tag, nested under its parent's), and may have an environment
+// variable (env tag) and a command-line flag (flag tag). Fields tagged
+// secret are hidden when printed.
+type Config struct {
+	Listen   string   `yaml:"listen" env:"LISTEN_ADDR" flag:"listen" help:"address to listen on, e.g. :8080 (PORT also sets the port)"`
+	DBPath   string   `yaml:"dbPath" env:"DB_PATH" flag:"db" help:"SQLite database file path"`
+	TLS      TLS      `yaml:"tls"`
+	HTTP     HTTP     `yaml:"http"`
+	Log      Log      `yaml:"log"`
+	Tracing  Tracing  `yaml:"tracing"`
+	Auth     Auth     `yaml:"auth"`
+	Features Features `yaml:"features"`
+}
+
+// TLS serves HTTPS from a certificate and key file when both are set
+type TLS struct {
+	CertFile       string        `yaml:"certFile" env:"TLS_CERT_FILE" flag:"tls-cert" help:"TLS certificate file (PEM)"`
+	KeyFile        string        `yaml:"keyFile" env:"TLS_KEY_FILE" flag:"tls-key" help:"TLS private key file (PEM)"`
+	ReloadInterval time.Duration `yaml:"reloadInterval" env:"TLS_RELOAD_INTERVAL"`
+	RedirectListen string        `yaml:"redirectListen" env:"TLS_REDIRECT_ADDR" flag:"tls-redirect-listen" help:"address to redirect plain HTTP to HTTPS from, e.g. :80"`
+	HSTSMaxAge     time.Duration `yaml:"hstsMaxAge" env:"TLS_HSTS_MAX_AGE"`
+}
+
+// HTTP limits how long connections and requests may take and how large
+// requests may be. Zero timeouts mean no limit.
+type HTTP struct {
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout" env:"HTTP_READ_HEADER_TIMEOUT"`
+	ReadTimeout       time.Duration `yaml:"readTimeout" env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout" env:"HTTP_IDLE_TIMEOUT"`
+	ShutdownTimeout   time.Duration `yaml:"shutdownTimeout" env:"HTTP_SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" help:"how long to wait for requests in flight when stopping"`
+	MaxHeaderBytes    int           `yaml:"maxHeaderBytes" env:"HTTP_MAX_HEADER_BYTES"`
+	MaxBodyBytes      int64         `yaml:"maxBodyBytes" env:"HTTP_MAX_BODY_BYTES"`
+}
+
+// Log controls what is logged and how
+type Log struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" flag:"log-level" help:"minimum log level: debug, info, warn or error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" flag:"log-format" help:"log format: text or json"`
+}
+
+// Tracing sends OpenTelemetry traces to an OTLP/HTTP collector when
+// Endpoint is set
+type Tracing struct {
+	Endpoint    string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" flag:"otlp-endpoint" help:"OTLP/HTTP collector URL to send traces to, e.g. http://localhost:4318; empty turns tracing off"`
+	ServiceName string  `yaml:"serviceName" env:"OTEL_SERVICE_NAME"`
+	SampleRatio float64 `yaml:"sampleRatio" env:"OTEL_TRACES_SAMPLE_RATIO"`
+}
+
+// Auth configures accounts and how users sign in
+type Auth struct {
+	AnonymousRole string `yaml:"anonymousRole" env:"ANONYMOUS_ROLE,allowempty" flag:"anonymous-role" help:"role for requests that aren't signed in; empty requires signing in"`
+	AdminUsername string `yaml:"adminUsername" env:"ADMIN_USERNAME"`
+	AdminPassword string `yaml:"adminPassword" env:"ADMIN_PASSWORD" secret:"true"`
+	OIDC          OIDC   `yaml:"oidc"`
+	Proxy         Proxy  `yaml:"proxy"`
+}
+
+// OIDC signs users in with an OpenID Connect provider when Issuer is set
+type OIDC struct {
+	Issuer        string            `yaml:"issuer" env:"OIDC_ISSUER"`
+	ClientID      string            `yaml:"clientId" env:"OIDC_CLIENT_ID"`
+	ClientSecret  string            `yaml:"clientSecret" env:"OIDC_CLIENT_SECRET" secret:"true"`
+	RedirectURL   string            `yaml:"redirectUrl" env:"OIDC_REDIRECT_URL"`
+	Scopes        []string          `yaml:"scopes" env:"OIDC_SCOPES"`
+	ProviderName  string            `yaml:"providerName" env:"OIDC_PROVIDER_NAME"`
+	UsernameClaim string            `yaml:"usernameClaim" env:"OIDC_USERNAME_CLAIM"`
+	PlayerClaim   string            `yaml:"playerClaim" env:"OIDC_PLAYER_CLAIM"`
+	RolesClaim    string            `yaml:"rolesClaim" env:"OIDC_ROLES_CLAIM"`
+	RoleMap       map[string]string `yaml:"roleMap" env:"OIDC_ROLE_MAP"`
+	DefaultRole   string            `yaml:"defaultRole" env:"OIDC_DEFAULT_ROLE,allowempty"`
+}
+
+// Proxy trusts user headers from an authenticating reverse proxy when
+// TrustedCIDRs is set
+type Proxy struct {
+	TrustedCIDRs []string          `yaml:"trustedCidrs" env:"PROXY_AUTH_TRUSTED_CIDRS"`
+	UserHeader   string            `yaml:"userHeader" env:"PROXY_AUTH_USER_HEADER"`
+	EmailHeader  string            `yaml:"emailHeader" env:"PROXY_AUTH_EMAIL_HEADER"`
+	GroupsHeader string            `yaml:"groupsHeader" env:"PROXY_AUTH_GROUPS_HEADER"`
+	RoleMap      map[string]string `yaml:"roleMap" env:"PROXY_AUTH_ROLE_MAP"`
+	DefaultRole  string            `yaml:"defaultRole" env:"PROXY_AUTH_DEFAULT_ROLE,allowempty"`
+}
+
+// Features turns optional parts of the API on or off
+type Features struct {
+	Import      bool `yaml:"import" env:"FEATURE_IMPORT" flag:"feature-import" help:"enable importing games"`
+	Export      bool `yaml:"export" env:"FEATURE_EXPORT" flag:"feature-export" help:"enable exporting games"`
+	Seasons     bool `yaml:"seasons" env:"FEATURE_SEASONS" flag:"feature-seasons" help:"enable seasons"`
+	Tournaments bool `yaml:"tournaments" env:"FEATURE_TOURNAMENTS" flag:"feature-tournaments" help:"enable tournaments"`
+}
+
+// Default returns the settings used when nothing else is configured
+func Default() *Config {
+	return &Config{
+		Listen: ":8080",
+		DBPath: db.DefaultPath,
+		TLS: TLS{
+			ReloadInterval: 30 * time.Second,
+			HSTSMaxAge:     365 * 24 * time.Hour,
+		},
+		HTTP: HTTP{
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       time.Minute,     // Imports upload whole files
+			WriteTimeout:      2 * time.Minute, // Exports can be large
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   25 * time.Second, // Inside Kubernetes' 30s grace period
+			MaxHeaderBytes:    64 << 10,
+			MaxBodyBytes:      32 << 20,
+		},
+		Log: Log{Level: "info", Format: "text"},
+		Tracing: Tracing{
+			ServiceName: "wingspan-scoring",
+			SampleRatio: 1,
+		},
+		Auth: Auth{
+			AdminUsername: "admin",
+			OIDC: OIDC{
+				ProviderName:  "Single Sign-On",
+				UsernameClaim: "preferred_username",
+				PlayerClaim:   "name",
+				RolesClaim:    "groups",
+				DefaultRole:   db.RoleViewer,
+			},
+			Proxy: Proxy{
+				UserHeader:   "X-Forwarded-User",
+				EmailHeader:  "X-Forwarded-Email",
+				GroupsHeader: "X-Forwarded-Groups",
+				DefaultRole:  db.RoleViewer,
+			},
+		},
+		Features: Features{Import: true, Export: true, Seasons: true, Tournaments: true},
+	}
+}
+
+// Options are command-line flags that aren't settings
+type Options struct {
+	File        string // Config file the settings were read from, if any
+	PrintConfig bool   // Print the settings and exit
+}
+
+// Load builds the configuration from args (without the program name) and
+// the environment, read through lookupEnv. The config file is named by the
+// -config flag or CONFIG_FILE. It returns flag.ErrHelp for -h.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, Options, error) {
+	cfg := Default()
+	var opts Options
+
+	fs := flag.NewFlagSet("wingspan-scoring", flag.ContinueOnError)
+	fs.StringVar(&opts.File, "config", "", "YAML (.yaml, .yml) or TOML (.toml) config file (env CONFIG_FILE)")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the resulting configuration, with secrets hidden, and exit")
+
+	// Flags are applied last so they override the file and environment
+	var flagValues []func() error
+	for _, f := range fields(cfg) {
+		if f.flag == "" {
+			continue
+		}
+		help := f.help
+		if f.env != "" {
+			help += " (env " + f.env + ")"
+		}
+		record := func(s string) error {
+			flagValues = append(flagValues, func() error {
+				if err := setString(f.value, s); err != nil {
+					return fmt.Errorf("invalid -%s: %w", f.flag, err)
+				}
+				return nil
+			})
+			return nil
+		}
+		if f.value.Kind() == reflect.Bool {
+			fs.BoolFunc(f.flag, help, record)
+		} else {
+			fs.Func(f.flag, help, record)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, opts, err
+	}
+	if fs.NArg() > 0 {
+		return nil, opts, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	if opts.File == "" {
+		opts.File, _ = lookupEnv("CONFIG_FILE")
+	}
+	if opts.File != "" {
+		if err := cfg.loadFile(opts.File); err != nil {
+			return nil, opts, err
+		}
+	}
+	if err := cfg.loadEnv(lookupEnv); err != nil {
+		return nil, opts, err
+	}
+	for _, apply := range flagValues {
+		if err := apply(); err != nil {
+			return nil, opts, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, opts, err
+	}
+	return cfg, opts, nil
+}
+
+// loadFile applies a YAML or TOML config file, chosen by its extension.
+// Unknown keys are errors so typos don't go unnoticed.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	values := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	known := make(map[string]field)
+	for _, f := range fields(c) {
+		known[f.key] = f
+	}
+	return applyFileValues(values, "", known)
+}
+
+// applyFileValues sets the fields named by a (nested) map from a config file
+func applyFileValues(values map[string]any, prefix string, known map[string]field) error {
+	for name, value := range values {
+		key := prefix + name
+		if f, ok := known[key]; ok {
+			if err := setAny(f.value, value); err != nil {
+				return fmt.Errorf("invalid %s in config file: %w", key, err)
+			}
+			continue
+		}
+		nested, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("unknown setting %s in config file", key)
+		}
+		if err := applyFileValues(nested, key+".", known); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadEnv applies environment variables. Empty variables are ignored unless
+// the field allows empty values.
+func (c *Config) loadEnv(lookupEnv func(string) (string, bool)) error {
+	for _, f := range fields(c) {
+		if f.env == "" {
+			continue
+		}
+		value, ok := lookupEnv(f.env)
+		if !ok || (value == "" && !f.allowEmpty) {
+			continue
+		}
+		if err := setString(f.value, value); err != nil {
+			return fmt.Errorf("invalid %s: %w", f.env, err)
+		}
+	}
+
+	// PORT is kept from before LISTEN_ADDR existed
+	if port, ok := lookupEnv("PORT"); ok && port != "" {
+		if _, set := lookupEnv("LISTEN_ADDR"); !set {
+			c.Listen = ":" + port
+		}
+	}
+	return nil
+}
+
+// Validate checks that the settings make sense together
+func (c *Config) Validate() error {
+	var errs []error
+	if _, port, err := net.SplitHostPort(c.Listen); err != nil || port == "" {
+		errs = append(errs, fmt.Errorf("listen address %q must be host:port or :port", c.Listen))
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("TLS needs both a certificate and a key file"))
+	}
+	if c.TLS.ReloadInterval <= 0 {
+		errs = append(errs, errors.New("TLS reload interval must be positive"))
+	}
+	if c.TLS.HSTSMaxAge < 0 {
+		errs = append(errs, errors.New("HSTS max age can't be negative"))
+	}
+	if c.TLS.RedirectListen != "" {
+		if c.TLS.CertFile == "" {
+			errs = append(errs, errors.New("redirecting to HTTPS needs a TLS certificate"))
+		}
+		if _, port, err := net.SplitHostPort(c.TLS.RedirectListen); err != nil || port == "" {
+			errs = append(errs, fmt.Errorf("redirect address %q must be host:port or :port", c.TLS.RedirectListen))
+		} else if c.TLS.RedirectListen == c.Listen {
+			errs = append(errs, errors.New("redirect address must differ from the listen address"))
+		}
+	}
+	if min(c.HTTP.ReadHeaderTimeout, c.HTTP.ReadTimeout, c.HTTP.WriteTimeout, c.HTTP.IdleTimeout) < 0 {
+		errs = append(errs, errors.New("HTTP timeouts can't be negative"))
+	}
+	if c.HTTP.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown timeout must be positive"))
+	}
+	if c.HTTP.MaxHeaderBytes <= 0 || c.HTTP.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("HTTP header and body limits must be positive"))
+	}
+	if !slices.Contains([]string{"debug", "info", "warn", "error"}, c.Log.Level) {
+		errs = append(errs, fmt.Errorf("log level %q must be debug, info, warn or error", c.Log.Level))
+	}
+	if !slices.Contains([]string{"text", "json"}, c.Log.Format) {
+		errs = append(errs, fmt.Errorf("log format %q must be text or json", c.Log.Format))
+	}
+	if c.Tracing.Endpoint != "" {
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("tracing endpoint %q must be an http or https URL", c.Tracing.Endpoint))
+		}
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("trace sample ratio must be between 0 and 1"))
+	}
+
+	errs = append(errs, checkRole("anonymous role", c.Auth.AnonymousRole))
+	if c.Auth.AdminUsername == "" {
+		errs = append(errs, errors.New("admin username is required"))
+	}
+	if c.Auth.OIDC.Issuer != "" {
+		if c.Auth.OIDC.ClientID == "" {
+			errs = append(errs, errors.New("OpenID Connect needs a client ID"))
+		}
+		errs = append(errs, checkRole("OpenID Connect default role", c.Auth.OIDC.DefaultRole))
+		errs = append(errs, checkRoleMap("OpenID Connect role map", c.Auth.OIDC.RoleMap))
+	}
+	if len(c.Auth.Proxy.TrustedCIDRs) > 0 {
+		if _, err := c.Auth.Proxy.Prefixes(); err != nil {
+			errs = append(errs, err)
+		}
+		if c.Auth.Proxy.UserHeader == "" || c.Auth.Proxy.EmailHeader == "" || c.Auth.Proxy.GroupsHeader == "" {
+			errs = append(errs, errors.New("proxy authentication header names can't be empty"))
+		}
+		errs = append(errs, checkRole("proxy default role", c.Auth.Proxy.DefaultRole))
+		errs = append(errs, checkRoleMap("proxy role map", c.Auth.Proxy.RoleMap))
+	}
+	return errors.Join(errs...)
+}
+
+// checkRole accepts a valid role or an empty one
+func checkRole(name, role string) error {
+	if role != "" && !db.ValidRole(role) {
+		return fmt.Errorf("%s %q must be viewer, scorer, admin or empty", name, role)
+	}
+	return nil
+}
+
+// checkRoleMap checks that every value maps to a valid role
+func checkRoleMap(name string, roleMap map[string]string) error {
+	for value, role := range roleMap {
+		if !db.ValidRole(role) {
+			return fmt.Errorf("%s maps %q to %q, which isn't viewer, scorer or admin", name, value, role)
+		}
+	}
+	return nil
+}
+
+// Prefixes parses the trusted proxy CIDRs, where a bare address is a single host
+func (p Proxy) Prefixes() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range p.TrustedCIDRs {
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy address %q: %w", entry, err)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy CIDR %q: %w", entry, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Write prints the settings as YAML, in the config file format, with
+// secrets hidden
+func (c *Config) Write(w io.Writer) error {
+	redacted := *c
+	for _, f := range fields(&redacted) {
+		if f.secret && f.value.String() != "" {
+			f.value.SetString("REDACTED")
+		}
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&redacted); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// field is one setting found by walking a Config
+type field struct {
+	key        string // Dotted config file key, such as auth.oidc.issuer
+	env        string
+	allowEmpty bool
+	flag       string
+	help       string
+	secret     bool
+	value      reflect.Value
+}
+
+// fields lists the settings of cfg, with values that set them in place
+func fields(cfg *Config) []field {
+	return appendFields(nil, reflect.ValueOf(cfg).Elem(), "")
+}
+
+// appendFields walks a settings struct, descending into nested structs
+func appendFields(list []field, v reflect.Value, prefix string) []field {
+	t := v.Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		key := prefix + sf.Tag.Get("yaml")
+		if sf.Type.Kind() == reflect.Struct {
+			list = appendFields(list, v.Field(i), key+".")
+			continue
+		}
+
+		env, options, _ := strings.Cut(sf.Tag.Get("env"), ",")
+		list = append(list, field{
+			key:        key,
+			env:        env,
+			allowEmpty: options == "allowempty",
+			flag:       sf.Tag.Get("flag"),
+			help:       sf.Tag.Get("help"),
+			secret:     sf.Tag.Get("secret") == "true",
+			value:      v.Field(i),
+		})
+	}
+	return list
+}
+
+// durationType is set from strings such as "30s"
+var durationType = reflect.TypeFor[time.Duration]()
+
+// setString sets a field from an environment variable or flag. Lists are
+// separated by commas or spaces and maps are written key=value,key=value.
+func setString(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(strings.TrimSpace(s))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		v.Set(reflect.ValueOf(strings.FieldsFunc(s, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t' || r == '\n'
+		})))
+	case reflect.Map:
+		m := make(map[string]string)
+		for _, pair := range strings.Split(s, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(pair, "=")
+			key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+			if !ok || key == "" {
+				return fmt.Errorf("%q isn't key=value", pair)
+			}
+			m[key] = value
+		}
+		v.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// setAny sets a field from a decoded config file value
+func setAny(v reflect.Value, value any) error {
+	switch value := value.(type) {
+	case string:
+		return setString(v, value)
+	case bool:
+		if v.Kind() != reflect.Bool {
+			return fmt.Errorf("expected %s, got a boolean", v.Type())
+		}
+		v.SetBool(value)
+	case int, int64, uint64, float64:
+		if v.Kind() == reflect.Float64 {
+			return setString(v, fmt.Sprint(value))
+		}
+		if v.Type() == durationType || (v.Kind() != reflect.Int && v.Kind() != reflect.Int64) {
+			return fmt.Errorf("expected %s, got a number", v.Type())
+		}
+		n, err := strconv.ParseInt(fmt.Sprint(value), 10, 64)
+		if err != nil {
+			return fmt.Errorf("expected a whole number, got %v", value)
+		}
+		v.SetInt(n)
+	case []any:
+		if v.Kind() != reflect.Slice {
+			return fmt.Errorf("expected %s, got a list", v.Type())
+		}
+		list := make([]string, len(value))
+		for i, item := range value {
+			s, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("expected a list of strings")
+			}
+			list[i] = s
+		}
+		v.Set(reflect.ValueOf(list))
+	case map[string]any:
+		if v.Kind() != reflect.Map {
+			return fmt.Errorf("expected %s, got a table", v.Type())
+		}
+		m := make(map[string]string, len(value))
+		for key, item := range value {
+			s, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("expected string values")
+			}
+			m[key] = s
+		}
+		v.Set(reflect.ValueOf(m))
+	case nil:
+		v.Set(reflect.Zero(v.Type()))
+	default:
+		return fmt.Errorf("unsupported value %v", value)
+	}
+	return nil
+}
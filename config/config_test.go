@@ -0,0 +1,241 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// env returns a lookupEnv reading from a map
+func env(values map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+}
+
+// writeFile writes a config file into a temporary directory
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// TestLoad_Defaults tests the settings with nothing configured
+func TestLoad_Defaults(t *testing.T) {
+	cfg, opts, err := Load(nil, env(nil))
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+	assert.Equal(t, ":8080", cfg.Listen)
+	assert.True(t, cfg.Features.Import)
+	assert.False(t, opts.PrintConfig)
+}
+
+// TestLoad_Precedence tests that flags beat environment variables, which beat the config file
+func TestLoad_Precedence(t *testing.T) {
+	path := writeFile(t, "wingspan.yaml", `
+listen: ":7000"
+dbPath: /var/lib/wingspan/file.db
+http:
+  writeTimeout: 5m
+  maxBodyBytes: 1048576
+log:
+  level: debug
+features:
+  export: false
+  tournaments: false
+auth:
+  oidc:
+    issuer: https://idp.example
+    clientId: wingspan
+    scopes: [openid, email]
+    roleMap:
+      admins: admin
+`)
+
+	cfg, opts, err := Load(
+		[]string{"-config", path, "-listen", ":9000", "-feature-tournaments", "-shutdown-timeout", "10s"},
+		env(map[string]string{"LISTEN_ADDR": ":8000", "DB_PATH": "/data/env.db", "LOG_FORMAT": "json"}),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, path, opts.File)
+	assert.Equal(t, ":9000", cfg.Listen)
+	assert.Equal(t, "/data/env.db", cfg.DBPath)
+	assert.Equal(t, "debug", cfg.Log.Level)
+	assert.Equal(t, "json", cfg.Log.Format)
+	assert.Equal(t, 5*time.Minute, cfg.HTTP.WriteTimeout)
+	assert.Equal(t, 10*time.Second, cfg.HTTP.ShutdownTimeout)
+	assert.Equal(t, int64(1<<20), cfg.HTTP.MaxBodyBytes)
+	assert.Equal(t, 64<<10, cfg.HTTP.MaxHeaderBytes)
+	assert.False(t, cfg.Features.Export)
+	assert.True(t, cfg.Features.Tournaments)
+	assert.True(t, cfg.Features.Import)
+	assert.Equal(t, []string{"openid", "email"}, cfg.Auth.OIDC.Scopes)
+	assert.Equal(t, map[string]string{"admins": "admin"}, cfg.Auth.OIDC.RoleMap)
+	assert.Equal(t, "Single Sign-On", cfg.Auth.OIDC.ProviderName)
+}
+
+// TestLoad_TOML tests reading a TOML file named by CONFIG_FILE
+func TestLoad_TOML(t *testing.T) {
+	path := writeFile(t, "wingspan.toml", `
+listen = "127.0.0.1:8081"
+
+[tls]
+certFile = "/certs/tls.crt"
+keyFile = "/certs/tls.key"
+redirectListen = ":80"
+hstsMaxAge = "1h"
+
+[tracing]
+endpoint = "http://otel-collector:4318"
+sampleRatio = 0.25
+
+[auth.proxy]
+trustedCidrs = ["10.0.0.0/8"]
+roleMap = { birders = "scorer" }
+`)
+
+	cfg, _, err := Load(nil, env(map[string]string{"CONFIG_FILE": path}))
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:8081", cfg.Listen)
+	assert.Equal(t, "/certs/tls.crt", cfg.TLS.CertFile)
+	assert.Equal(t, ":80", cfg.TLS.RedirectListen)
+	assert.Equal(t, time.Hour, cfg.TLS.HSTSMaxAge)
+	assert.Equal(t, "http://otel-collector:4318", cfg.Tracing.Endpoint)
+	assert.Equal(t, 0.25, cfg.Tracing.SampleRatio)
+	assert.Equal(t, []string{"10.0.0.0/8"}, cfg.Auth.Proxy.TrustedCIDRs)
+	assert.Equal(t, map[string]string{"birders": "scorer"}, cfg.Auth.Proxy.RoleMap)
+}
+
+// TestLoad_Env tests environment variables, including the older PORT
+func TestLoad_Env(t *testing.T) {
+	cfg, _, err := Load(nil, env(map[string]string{
+		"PORT":                     "3000",
+		"ANONYMOUS_ROLE":           "viewer",
+		"OIDC_ISSUER":              "https://idp.example",
+		"OIDC_CLIENT_ID":           "wingspan",
+		"OIDC_SCOPES":              "openid profile groups",
+		"OIDC_ROLE_MAP":            "admins=admin, family = scorer",
+		"OIDC_DEFAULT_ROLE":        "",
+		"PROXY_AUTH_TRUSTED_CIDRS": "10.0.0.0/8,192.168.1.5",
+		"PROXY_AUTH_USER_HEADER":   "",
+		"FEATURE_IMPORT":           "false",
+		"OTEL_TRACES_SAMPLE_RATIO": "0.1",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, ":3000", cfg.Listen)
+	assert.Equal(t, "viewer", cfg.Auth.AnonymousRole)
+	assert.Equal(t, []string{"openid", "profile", "groups"}, cfg.Auth.OIDC.Scopes)
+	assert.Equal(t, map[string]string{"admins": "admin", "family": "scorer"}, cfg.Auth.OIDC.RoleMap)
+	assert.Equal(t, "", cfg.Auth.OIDC.DefaultRole, "empty default role refuses sign-in")
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.5"}, cfg.Auth.Proxy.TrustedCIDRs)
+	assert.Equal(t, "X-Forwarded-User", cfg.Auth.Proxy.UserHeader, "empty values are ignored")
+	assert.False(t, cfg.Features.Import)
+	assert.Equal(t, 0.1, cfg.Tracing.SampleRatio)
+
+	// LISTEN_ADDR wins over PORT
+	cfg, _, err = Load(nil, env(map[string]string{"PORT": "3000", "LISTEN_ADDR": "0.0.0.0:4000"}))
+	require.NoError(t, err)
+	assert.Equal(t, "0.0.0.0:4000", cfg.Listen)
+}
+
+// TestLoad_Invalid tests that bad settings are reported
+func TestLoad_Invalid(t *testing.T) {
+	tests := map[string]struct {
+		args []string
+		env  map[string]string
+		file string
+	}{
+		"listen":               {args: []string{"-listen", "8080"}},
+		"tls key only":         {args: []string{"-tls-key", "/certs/tls.key"}},
+		"redirect without tls": {args: []string{"-tls-redirect-listen", ":80"}},
+		"redirect to itself":   {args: []string{"-tls-cert", "c", "-tls-key", "k", "-tls-redirect-listen", ":8080"}},
+		"log level":            {env: map[string]string{"LOG_LEVEL": "verbose"}},
+		"log format":           {args: []string{"-log-format", "xml"}},
+		"anonymous role":       {env: map[string]string{"ANONYMOUS_ROLE": "owner"}},
+		"oidc client":          {env: map[string]string{"OIDC_ISSUER": "https://idp.example"}},
+		"oidc role map": {env: map[string]string{
+			"OIDC_ISSUER": "https://idp.example", "OIDC_CLIENT_ID": "x", "OIDC_ROLE_MAP": "admins=owner",
+		}},
+		"role map syntax":  {env: map[string]string{"PROXY_AUTH_ROLE_MAP": "admins"}},
+		"proxy cidr":       {env: map[string]string{"PROXY_AUTH_TRUSTED_CIDRS": "proxy.local"}},
+		"feature flag":     {env: map[string]string{"FEATURE_EXPORT": "maybe"}},
+		"duration":         {env: map[string]string{"HTTP_READ_TIMEOUT": "60"}},
+		"negative timeout": {args: []string{"-config", writeFile(t, "t.yaml", "http:\n  idleTimeout: -1s\n")}},
+		"shutdown timeout": {args: []string{"-shutdown-timeout", "0s"}},
+		"body limit":       {env: map[string]string{"HTTP_MAX_BODY_BYTES": "0"}},
+		"tracing endpoint": {args: []string{"-otlp-endpoint", "otel-collector:4318"}},
+		"sample ratio":     {env: map[string]string{"OTEL_TRACES_SAMPLE_RATIO": "2"}},
+		"unknown key":      {file: "lisen: \":8080\"\n"},
+		"wrong type":       {file: "features:\n  import: \"sometimes\"\n"},
+		"extra argument":   {args: []string{"serve"}},
+		"unknown flag":     {args: []string{"-port", "8080"}},
+	}
+
+	for name, tt := range tests {
+		args := tt.args
+		if tt.file != "" {
+			args = append(args, "-config", writeFile(t, "config.yaml", tt.file))
+		}
+		_, _, err := Load(args, env(tt.env))
+		assert.Error(t, err, name)
+	}
+
+	_, _, err := Load([]string{"-config", writeFile(t, "config.json", "{}")}, env(nil))
+	assert.Error(t, err)
+	_, _, err = Load([]string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}, env(nil))
+	assert.Error(t, err)
+}
+
+// TestLoad_Help tests that -h is reported so the caller can exit cleanly
+func TestLoad_Help(t *testing.T) {
+	_, _, err := Load([]string{"-h"}, env(nil))
+	assert.True(t, errors.Is(err, flag.ErrHelp))
+}
+
+// TestWrite tests printing the configuration with secrets hidden
+func TestWrite(t *testing.T) {
+	cfg, opts, err := Load([]string{"-print-config"}, env(map[string]string{
+		"ADMIN_PASSWORD":     "hunter22",
+		"OIDC_CLIENT_SECRET": "s3cret",
+	}))
+	require.NoError(t, err)
+	assert.True(t, opts.PrintConfig)
+
+	var buf bytes.Buffer
+	require.NoError(t, cfg.Write(&buf))
+	out := buf.String()
+	assert.Contains(t, out, `listen: :8080`)
+	assert.Contains(t, out, "adminPassword: REDACTED")
+	assert.NotContains(t, out, "hunter22")
+	assert.NotContains(t, out, "s3cret")
+	assert.Equal(t, "hunter22", cfg.Auth.AdminPassword, "printing leaves the config alone")
+
+	// The output can be read back as a config file
+	path := writeFile(t, "printed.yaml", out)
+	reloaded, _, err := Load([]string{"-config", path}, env(nil))
+	require.NoError(t, err)
+	assert.Equal(t, cfg.Listen, reloaded.Listen)
+	assert.Equal(t, cfg.Features, reloaded.Features)
+	assert.Equal(t, cfg.HTTP, reloaded.HTTP)
+}
+
+// TestProxyPrefixes tests parsing trusted proxy addresses
+func TestProxyPrefixes(t *testing.T) {
+	prefixes, err := Proxy{TrustedCIDRs: []string{"10.0.0.0/8", "192.168.1.5", "fd00::/8", "::1"}}.Prefixes()
+	require.NoError(t, err)
+	require.Len(t, prefixes, 4)
+	assert.Equal(t, "192.168.1.5/32", prefixes[1].String())
+	assert.Equal(t, "::1/128", prefixes[3].String())
+
+	_, err = Proxy{TrustedCIDRs: []string{"10.0.0.0/33"}}.Prefixes()
+	assert.Error(t, err)
+}
@@ -0,0 +1,512 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+	"wingspan-scoring/scoring"
+)
+
+// achievementsJSON holds the built-in achievement rules. Adding an
+// achievement only needs a new entry here.
+//
+//go:embed achievements.json
+var achievementsJSON []byte
+
+// AchievementRules are the achievements players can earn, in display order
+var AchievementRules = mustParseAchievementRules(achievementsJSON)
+
+// achievementRulesVersion fingerprints the rules so earned achievements are
+// backfilled whenever they change
+var achievementRulesVersion = fingerprint(achievementsJSON)
+
+// AchievementCondition compares one metric of a player's game with a value
+type AchievementCondition struct {
+	Metric string `json:"metric"`
+	Op     string `json:"op"` // ==, !=, >, >=, < or <=
+	Value  int    `json:"value"`
+}
+
+// AchievementRule is earned the first time a player's game meets all of its conditions
+type AchievementRule struct {
+	ID          string                 `json:"id"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Conditions  []AchievementCondition `json:"conditions"`
+}
+
+// PlayerAchievement is an achievement a player has earned, with the game that earned it
+type PlayerAchievement struct {
+	AchievementID string    `json:"achievementId"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	GameID        int64     `json:"gameId"`
+	EarnedAt      time.Time `json:"earnedAt"`
+}
+
+// achievementMetric computes a value for a player's game. history has
+// already been updated with the game.
+type achievementMetric func(game *GameResult, p scoring.PlayerGameEnd, history *playerHistory) int
+
+// achievementMetrics are the metrics conditions can use
+var achievementMetrics = newAchievementMetrics()
+
+// newAchievementMetrics builds the metric table: every score category plus
+// per-game and history metrics
+func newAchievementMetrics() map[string]achievementMetric {
+	metrics := map[string]achievementMetric{
+		"rank": func(_ *GameResult, p scoring.PlayerGameEnd, _ *playerHistory) int {
+			return p.Rank
+		},
+		"won": func(_ *GameResult, p scoring.PlayerGameEnd, _ *playerHistory) int {
+			return boolToInt(p.Rank == 1)
+		},
+		"numPlayers": func(game *GameResult, _ scoring.PlayerGameEnd, _ *playerHistory) int {
+			return len(game.Players)
+		},
+		"nectarHabitatsWon": func(game *GameResult, p scoring.PlayerGameEnd, _ *playerHistory) int {
+			return nectarHabitatsWon(game.Players, p)
+		},
+		"gamesPlayed": func(_ *GameResult, _ scoring.PlayerGameEnd, h *playerHistory) int {
+			return h.games
+		},
+		"wins": func(_ *GameResult, _ scoring.PlayerGameEnd, h *playerHistory) int {
+			return h.wins
+		},
+		"winStreak": func(_ *GameResult, _ scoring.PlayerGameEnd, h *playerHistory) int {
+			return h.streak
+		},
+	}
+	for _, category := range ScoreCategories {
+		value := category.Value
+		metrics[category.Key] = func(_ *GameResult, p scoring.PlayerGameEnd, _ *playerHistory) int {
+			return value(p)
+		}
+	}
+	return metrics
+}
+
+// playerHistory tracks a player's games up to and including the current one
+type playerHistory struct {
+	games  int
+	wins   int
+	streak int // Consecutive wins ending with the current game
+}
+
+// add counts a game the player finished at rank
+func (h *playerHistory) add(rank int) {
+	h.games++
+	if rank == 1 {
+		h.wins++
+		h.streak++
+	} else {
+		h.streak = 0
+	}
+}
+
+// mustParseAchievementRules parses the embedded rules, panicking if they're invalid
+func mustParseAchievementRules(data []byte) []AchievementRule {
+	rules, err := ParseAchievementRules(data)
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}
+
+// ParseAchievementRules parses and validates a JSON list of achievement rules
+func ParseAchievementRules(data []byte) ([]AchievementRule, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var rules []AchievementRule
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("failed to parse achievement rules: %w", err)
+	}
+
+	seen := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if rule.ID == "" || rule.Name == "" {
+			return nil, fmt.Errorf("achievement rules need an id and name")
+		}
+		if seen[rule.ID] {
+			return nil, fmt.Errorf("duplicate achievement id: %s", rule.ID)
+		}
+		seen[rule.ID] = true
+
+		if len(rule.Conditions) == 0 {
+			return nil, fmt.Errorf("achievement %s has no conditions", rule.ID)
+		}
+		for _, c := range rule.Conditions {
+			if _, ok := achievementMetrics[c.Metric]; !ok {
+				return nil, fmt.Errorf("achievement %s: unknown metric %s", rule.ID, c.Metric)
+			}
+			if _, err := compare(c.Op, 0, 0); err != nil {
+				return nil, fmt.Errorf("achievement %s: %w", rule.ID, err)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// matches reports whether a player's game meets every condition of the rule
+func (r AchievementRule) matches(game *GameResult, p scoring.PlayerGameEnd, h *playerHistory) bool {
+	for _, c := range r.Conditions {
+		ok, err := compare(c.Op, achievementMetrics[c.Metric](game, p, h), c.Value)
+		if err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// compare applies a comparison operator
+func compare(op string, a, b int) (bool, error) {
+	switch op {
+	case "==":
+		return a == b, nil
+	case "!=":
+		return a != b, nil
+	case ">":
+		return a > b, nil
+	case ">=":
+		return a >= b, nil
+	case "<":
+		return a < b, nil
+	case "<=":
+		return a <= b, nil
+	}
+	return false, fmt.Errorf("unknown operator %s", op)
+}
+
+// nectarHabitatsWon counts the habitats where the player has the most
+// nectar, including ties
+func nectarHabitatsWon(players []scoring.PlayerGameEnd, player scoring.PlayerGameEnd) int {
+	habitats := []func(scoring.PlayerGameEnd) int{
+		func(p scoring.PlayerGameEnd) int { return p.NectarForest },
+		func(p scoring.PlayerGameEnd) int { return p.NectarGrassland },
+		func(p scoring.PlayerGameEnd) int { return p.NectarWetland },
+	}
+
+	won := 0
+	for _, nectar := range habitats {
+		mine, most := nectar(player), 0
+		for _, p := range players {
+			if n := nectar(p); n > most {
+				most = n
+			}
+		}
+		if mine > 0 && mine == most {
+			won++
+		}
+	}
+	return won
+}
+
+// boolToInt returns 1 for true and 0 for false
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// fingerprint returns a short hash of data
+func fingerprint(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// EvaluateAchievements replays a group's games in date order and returns the
+// achievements each player earned, keyed by player name
+func EvaluateAchievements(ctx context.Context, group string, rules []AchievementRule) (map[string][]PlayerAchievement, error) {
+	ctx = named(ctx, "EvaluateAchievements")
+	return evaluateAchievements(ctx, DB, group, rules)
+}
+
+// evaluateAchievements is EvaluateAchievements reading games with q, which
+// may be a transaction
+func evaluateAchievements(ctx context.Context, q querier, group string, rules []AchievementRule) (map[string][]PlayerAchievement, error) {
+	it, err := iterateGameResults(ctx, q, GameFilter{Group: group}, chronological)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	histories := make(map[string]*playerHistory)
+	earned := make(map[string][]PlayerAchievement)
+	has := make(map[string]map[string]bool)
+	for it.Next() {
+		game := it.GameResult()
+		for _, p := range game.Players {
+			h := histories[p.PlayerName]
+			if h == nil {
+				h = &playerHistory{}
+				histories[p.PlayerName] = h
+				has[p.PlayerName] = make(map[string]bool)
+			}
+			h.add(p.Rank)
+			if won := earnAchievements(rules, game, p, h, has[p.PlayerName]); len(won) > 0 {
+				earned[p.PlayerName] = append(earned[p.PlayerName], won...)
+			}
+		}
+	}
+	return earned, it.Err()
+}
+
+// earnAchievements returns the rules a player's game meets that aren't in
+// has yet, adding them to it
+func earnAchievements(rules []AchievementRule, game *GameResult, p scoring.PlayerGameEnd, h *playerHistory, has map[string]bool) []PlayerAchievement {
+	var earned []PlayerAchievement
+	for _, rule := range rules {
+		if has[rule.ID] || !rule.matches(game, p, h) {
+			continue
+		}
+		has[rule.ID] = true
+		earned = append(earned, PlayerAchievement{
+			AchievementID: rule.ID,
+			Name:          rule.Name,
+			Description:   rule.Description,
+			GameID:        game.ID,
+			EarnedAt:      game.CreatedAt,
+		})
+	}
+	return earned
+}
+
+// awardAchievements records the achievements earned in a game just saved in
+// tx. Only a game played after every other in its group can be evaluated on
+// its own; for any other, the group is added to replays, to be replayed once
+// the transaction's games are saved.
+func awardAchievements(ctx context.Context, tx *sql.Tx, game *GameResult, replays map[string]bool) error {
+	group := groupOrDefault(game.Group)
+	if replays[group] {
+		return nil
+	}
+	latest, err := isLatestGame(ctx, tx, game.ID)
+	if err != nil {
+		return err
+	}
+	if !latest {
+		replays[group] = true
+		return nil
+	}
+
+	for _, p := range game.Players {
+		h, err := loadPlayerHistory(ctx, tx, group, p.PlayerName)
+		if err != nil {
+			return err
+		}
+		has, err := loadEarned(ctx, tx, group, p.PlayerName)
+		if err != nil {
+			return err
+		}
+		earned := map[string][]PlayerAchievement{p.PlayerName: earnAchievements(AchievementRules, game, p, h, has)}
+		if err := insertAchievements(ctx, tx, group, earned); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPlayerHistory tallies a player's saved games in a group, oldest first
+func loadPlayerHistory(ctx context.Context, tx *sql.Tx, group, playerName string) (*playerHistory, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT player_scores.rank
+		FROM player_scores
+		JOIN game_results ON game_results.id = player_scores.game_id
+		WHERE game_results.group_id = ? AND player_scores.player_name = ?
+		ORDER BY game_results.created_at, game_results.id
+	`, group, playerName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query player history: %w", err)
+	}
+	defer rows.Close()
+
+	h := &playerHistory{}
+	for rows.Next() {
+		var rank int
+		if err := rows.Scan(&rank); err != nil {
+			return nil, fmt.Errorf("failed to scan player history: %w", err)
+		}
+		h.add(rank)
+	}
+	return h, rows.Err()
+}
+
+// loadEarned returns the IDs of the achievements a player already has in a group
+func loadEarned(ctx context.Context, tx *sql.Tx, group, playerName string) (map[string]bool, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT achievement_id FROM player_achievements WHERE group_id = ? AND player_name = ?
+	`, group, playerName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query achievements: %w", err)
+	}
+	defer rows.Close()
+
+	has := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan achievement: %w", err)
+		}
+		has[id] = true
+	}
+	return has, rows.Err()
+}
+
+// replayAchievements re-evaluates the achievements of each group in replays
+// from its games in tx
+func replayAchievements(ctx context.Context, tx *sql.Tx, replays map[string]bool) error {
+	for group := range replays {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM player_achievements WHERE group_id = ?`, group); err != nil {
+			return fmt.Errorf("failed to clear achievements: %w", err)
+		}
+		earned, err := evaluateAchievements(ctx, tx, group, AchievementRules)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate achievements: %w", err)
+		}
+		if err := insertAchievements(ctx, tx, group, earned); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withdrawAchievements removes what a game deleted in tx earned. If it was
+// the latest game in its group, no later game's achievements depend on it and
+// its own are removed; otherwise the group is replayed.
+func withdrawAchievements(ctx context.Context, tx *sql.Tx, group string, id int64, latest bool) error {
+	if !latest {
+		return replayAchievements(ctx, tx, map[string]bool{group: true})
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM player_achievements WHERE group_id = ? AND game_id = ?`, group, id); err != nil {
+		return fmt.Errorf("failed to remove achievements: %w", err)
+	}
+	return nil
+}
+
+// insertAchievements stores achievements earned in a group, keyed by player name
+func insertAchievements(ctx context.Context, tx *sql.Tx, group string, earned map[string][]PlayerAchievement) error {
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO player_achievements (group_id, player_name, achievement_id, game_id, earned_at)
+		VALUES (?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare achievement insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for playerName, achievements := range earned {
+		for _, a := range achievements {
+			earnedAt := a.EarnedAt.UTC().Format(sqliteTimeFormat)
+			if _, err := stmt.ExecContext(ctx, group, playerName, a.AchievementID, a.GameID, earnedAt); err != nil {
+				return fmt.Errorf("failed to insert achievement: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// backfillAchievements replays every group's games if the achievements were
+// computed with other rules, or before they were stored. It runs on startup,
+// so reads never have to.
+func backfillAchievements(ctx context.Context) error {
+	var current bool
+	err := DB.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM achievement_state WHERE rules_version = ?)`, achievementRulesVersion).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("failed to check achievements: %w", err)
+	}
+	if current {
+		return nil
+	}
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM player_achievements`); err != nil {
+		return fmt.Errorf("failed to clear achievements: %w", err)
+	}
+	groups, err := gameGroups(ctx, tx)
+	if err != nil {
+		return err
+	}
+	replays := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		replays[group] = true
+	}
+	if err := replayAchievements(ctx, tx, replays); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM achievement_state`); err != nil {
+		return fmt.Errorf("failed to reset achievement state: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO achievement_state (rules_version) VALUES (?)`, achievementRulesVersion); err != nil {
+		return fmt.Errorf("failed to save achievement state: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit achievements: %w", err)
+	}
+	return nil
+}
+
+// GetPlayerAchievements returns the achievements a player has earned in a
+// group, oldest first
+func GetPlayerAchievements(ctx context.Context, group, playerName string) ([]PlayerAchievement, error) {
+	ctx = named(ctx, "GetPlayerAchievements")
+	rows, err := DB.QueryContext(ctx, `
+		SELECT achievement_id, game_id, earned_at
+		FROM player_achievements
+		WHERE group_id = ? AND player_name = ?
+		ORDER BY earned_at, game_id
+	`, groupOrDefault(group), playerName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query achievements: %w", err)
+	}
+	defer rows.Close()
+
+	rules := make(map[string]AchievementRule, len(AchievementRules))
+	order := make(map[string]int, len(AchievementRules))
+	for i, rule := range AchievementRules {
+		rules[rule.ID] = rule
+		order[rule.ID] = i
+	}
+
+	achievements := []PlayerAchievement{}
+	for rows.Next() {
+		var a PlayerAchievement
+		if err := rows.Scan(&a.AchievementID, &a.GameID, &a.EarnedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan achievement: %w", err)
+		}
+		a.Name = rules[a.AchievementID].Name
+		a.Description = rules[a.AchievementID].Description
+		achievements = append(achievements, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Achievements from the same game follow the rules' order
+	sort.SliceStable(achievements, func(i, j int) bool {
+		a, b := achievements[i], achievements[j]
+		if !a.EarnedAt.Equal(b.EarnedAt) {
+			return a.EarnedAt.Before(b.EarnedAt)
+		}
+		if a.GameID != b.GameID {
+			return a.GameID < b.GameID
+		}
+		return order[a.AchievementID] < order[b.AchievementID]
+	})
+	return achievements, nil
+}
@@ -0,0 +1,210 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"wingspan-scoring/scoring"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// achievementIDs returns the IDs of earned achievements in order
+func achievementIDs(achievements []PlayerAchievement) []string {
+	ids := []string{}
+	for _, a := range achievements {
+		ids = append(ids, a.AchievementID)
+	}
+	return ids
+}
+
+// TestAchievementRules_Embedded tests the built-in rules parse and include the standard badges
+func TestAchievementRules_Embedded(t *testing.T) {
+	ids := make(map[string]bool)
+	for _, rule := range AchievementRules {
+		ids[rule.ID] = true
+	}
+	for _, id := range []string{"century", "no-bonus-win", "nectar-sweep", "regular", "hat-trick"} {
+		assert.True(t, ids[id], id)
+	}
+}
+
+// TestParseAchievementRules_Invalid tests rules with unknown metrics, operators or fields are rejected
+func TestParseAchievementRules_Invalid(t *testing.T) {
+	invalid := []string{
+		`[{"id":"a","name":"A","conditions":[{"metric":"luck","op":">=","value":1}]}]`,
+		`[{"id":"a","name":"A","conditions":[{"metric":"eggs","op":"~","value":1}]}]`,
+		`[{"id":"a","name":"A","conditions":[]}]`,
+		`[{"id":"a","name":"A","conditions":[{"metric":"eggs","op":">","value":1}],"points":5}]`,
+		`[{"id":"a","name":"A","conditions":[{"metric":"eggs","op":">","value":1}]},{"id":"a","name":"B","conditions":[{"metric":"eggs","op":">","value":1}]}]`,
+		`[{"name":"A","conditions":[{"metric":"eggs","op":">","value":1}]}]`,
+	}
+	for _, data := range invalid {
+		_, err := ParseAchievementRules([]byte(data))
+		assert.Error(t, err, data)
+	}
+
+	rules, err := ParseAchievementRules([]byte(`[{"id":"a","name":"A","conditions":[{"metric":"tuckedCards","op":">","value":1}]}]`))
+	require.NoError(t, err)
+	assert.Len(t, rules, 1)
+}
+
+// TestGetPlayerAchievements tests per-game and history rules are earned once, with the triggering game
+func TestGetPlayerAchievements(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	first := saveGameOn(t, "2024-01-01", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", BonusCards: 5, Total: 80, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 70, Rank: 2})
+	saveGameOn(t, "2024-01-02", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", BonusCards: 0, Total: 105, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 101, Rank: 2})
+	third := saveGameOn(t, "2024-01-03", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", BonusCards: 3, Total: 90, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 60, Rank: 2})
+
+	achievements, err := GetPlayerAchievements(t.Context(), DefaultGroup, "Alice")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first-win", "century", "no-bonus-win", "hat-trick"}, achievementIDs(achievements))
+	assert.Equal(t, first, achievements[0].GameID)
+	assert.Equal(t, "First Flight", achievements[0].Name)
+	assert.Equal(t, "2024-01-01", achievements[0].EarnedAt.Format("2006-01-02"))
+	assert.Equal(t, third, achievements[3].GameID)
+
+	bob, err := GetPlayerAchievements(t.Context(), DefaultGroup, "Bob")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"century"}, achievementIDs(bob))
+
+	none, err := GetPlayerAchievements(t.Context(), DefaultGroup, "Nobody")
+	require.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+// TestGetPlayerAchievements_FollowsChanges tests achievements are re-evaluated after games change
+func TestGetPlayerAchievements_FollowsChanges(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	for i := 0; i < 9; i++ {
+		saveGameOn(t, fmt.Sprintf("2024-01-%02d", i+1), false,
+			scoring.PlayerGameEnd{PlayerName: "Alice", Total: 50, Rank: 2},
+			scoring.PlayerGameEnd{PlayerName: "Bob", Total: 60, Rank: 1})
+	}
+
+	achievements, err := GetPlayerAchievements(t.Context(), DefaultGroup, "Alice")
+	require.NoError(t, err)
+	assert.Empty(t, achievements)
+
+	// A tenth game earns the games-played badge
+	tenth := saveGameOn(t, "2024-01-10", true,
+		scoring.PlayerGameEnd{PlayerName: "Alice", BonusCards: 4, NectarForest: 3, NectarGrassland: 2, NectarWetland: 1, Total: 70, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", NectarForest: 3, NectarGrassland: 1, Total: 60, Rank: 2})
+
+	achievements, err = GetPlayerAchievements(t.Context(), DefaultGroup, "Alice")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first-win", "nectar-sweep", "regular"}, achievementIDs(achievements))
+	assert.Equal(t, tenth, achievements[2].GameID)
+
+	require.NoError(t, DeleteGameResult(t.Context(), tenth))
+	achievements, err = GetPlayerAchievements(t.Context(), DefaultGroup, "Alice")
+	require.NoError(t, err)
+	assert.Empty(t, achievements)
+}
+
+// TestGetPlayerAchievements_RulesChange tests a new rules version backfills existing games
+func TestGetPlayerAchievements_RulesChange(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	saveGameOn(t, "2024-01-01", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Eggs: 25, Total: 80, Rank: 1})
+
+	achievements, err := GetPlayerAchievements(t.Context(), DefaultGroup, "Alice")
+	require.NoError(t, err)
+	assert.Contains(t, achievementIDs(achievements), "egg-layer")
+
+	// Simulate achievements computed by an older set of rules
+	_, err = DB.Exec(`DELETE FROM player_achievements`)
+	require.NoError(t, err)
+	_, err = DB.Exec(`UPDATE achievement_state SET rules_version = 'old'`)
+	require.NoError(t, err)
+
+	// Reads don't backfill; the next startup does
+	achievements, err = GetPlayerAchievements(t.Context(), DefaultGroup, "Alice")
+	require.NoError(t, err)
+	assert.Empty(t, achievements)
+
+	require.NoError(t, createTables())
+	achievements, err = GetPlayerAchievements(t.Context(), DefaultGroup, "Alice")
+	require.NoError(t, err)
+	assert.Contains(t, achievementIDs(achievements), "egg-layer")
+}
+
+// TestGetPlayerAchievements_EarnedOnSave tests achievements are stored with
+// the game that earns them, replaying only the group of an earlier-dated game
+func TestGetPlayerAchievements_EarnedOnSave(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+	require.NoError(t, CreateGroup(t.Context(), &Group{Slug: "club", Name: "Club"}))
+
+	earned := func(group, playerName string) []string {
+		t.Helper()
+		rows, err := DB.Query(`SELECT achievement_id FROM player_achievements WHERE group_id = ? AND player_name = ? ORDER BY earned_at, achievement_id`, group, playerName)
+		require.NoError(t, err)
+		defer rows.Close()
+		var ids []string
+		for rows.Next() {
+			var id string
+			require.NoError(t, rows.Scan(&id))
+			ids = append(ids, id)
+		}
+		require.NoError(t, rows.Err())
+		return ids
+	}
+
+	saveGameOn(t, "2024-01-02", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", BonusCards: 2, Total: 80, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 70, Rank: 2})
+	saveGameOn(t, "2024-01-03", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", BonusCards: 2, Total: 80, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 70, Rank: 2})
+	saveGroupGame(t, "club",
+		scoring.PlayerGameEnd{PlayerName: "Carol", BonusCards: 2, Total: 80, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Dave", Total: 70, Rank: 2})
+	assert.Equal(t, []string{"first-win"}, earned(DefaultGroup, "Alice"))
+	assert.Equal(t, []string{"first-win"}, earned("club", "Carol"))
+
+	// A third straight win completes the streak
+	latest := saveGameOn(t, "2024-01-04", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", BonusCards: 2, Total: 80, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 70, Rank: 2})
+	assert.Equal(t, []string{"first-win", "hat-trick"}, earned(DefaultGroup, "Alice"))
+
+	// An earlier loss breaks the streak, so the group is replayed
+	saveGameOn(t, "2024-01-01", false,
+		scoring.PlayerGameEnd{PlayerName: "Bob", BonusCards: 2, Total: 80, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 70, Rank: 2})
+	assert.Equal(t, []string{"first-win", "hat-trick"}, earned(DefaultGroup, "Alice"))
+	assert.Equal(t, []string{"first-win"}, earned(DefaultGroup, "Bob"))
+	assert.Equal(t, []string{"first-win"}, earned("club", "Carol"))
+
+	// Deleting the latest game removes only what it earned
+	require.NoError(t, DeleteGameResult(t.Context(), latest))
+	assert.Equal(t, []string{"first-win"}, earned(DefaultGroup, "Alice"))
+	assert.Equal(t, []string{"first-win"}, earned(DefaultGroup, "Bob"))
+
+	replayed, err := EvaluateAchievements(t.Context(), DefaultGroup, AchievementRules)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first-win"}, achievementIDs(replayed["Alice"]))
+}
+
+// TestNectarHabitatsWon tests counting habitats led, with ties shared
+func TestNectarHabitatsWon(t *testing.T) {
+	alice := scoring.PlayerGameEnd{PlayerName: "Alice", NectarForest: 2, NectarGrassland: 2, NectarWetland: 0}
+	bob := scoring.PlayerGameEnd{PlayerName: "Bob", NectarForest: 2, NectarGrassland: 1, NectarWetland: 0}
+	players := []scoring.PlayerGameEnd{alice, bob}
+
+	assert.Equal(t, 2, nectarHabitatsWon(players, alice))
+	assert.Equal(t, 1, nectarHabitatsWon(players, bob))
+}
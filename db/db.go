@@ -1,22 +1,25 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
-
-	_ "modernc.org/sqlite"
+	"slices"
 )
 
 var DB *sql.DB
 
-// Initialize opens the database connection and creates tables if they don't exist
-func Initialize() error {
-	// Get database path from environment variable or use default
-	dbPath := os.Getenv("DB_PATH")
+// DefaultPath is where the database is kept when no path is configured
+const DefaultPath = "./data/wingspan.db"
+
+// Initialize opens the database at dbPath, or DefaultPath when it's empty,
+// and creates tables if they don't exist
+func Initialize(dbPath string) error {
 	if dbPath == "" {
-		dbPath = "./data/wingspan.db"
+		dbPath = DefaultPath
 	}
 
 	// Resolve absolute path
@@ -27,7 +30,7 @@ func Initialize() error {
 		}
 		dbPath = absPath
 	}
-	fmt.Println("Resolved database path:", dbPath)
+	slog.Info("Resolved database path", "path", dbPath)
 
 	// Ensure the directory exists
 	dir := filepath.Dir(dbPath)
@@ -35,16 +38,15 @@ func Initialize() error {
 		return fmt.Errorf("failed to create database directory: %w", err)
 	}
 
-	// Open database connection
-	var err error
-	DB, err = sql.Open("sqlite", dbPath)
+	// Open database connection, timing every statement
+	connector, err := newObservedConnector(dbPath + dsnPragmas)
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
+	DB = sql.OpenDB(connector)
 
 	// Test the connection
 	if err := DB.Ping(); err != nil {
-		fmt.Printf("Failed to ping database: %v\n", err)
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
@@ -56,8 +58,14 @@ func Initialize() error {
 	return nil
 }
 
+// dsnPragmas set up each connection. In WAL mode a long read, such as a
+// streamed export, doesn't block saving games, and a statement waits up to
+// five seconds for another connection's write rather than failing at once.
+const dsnPragmas = "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+
 // createTables creates the necessary database tables
 func createTables() error {
+	ctx := named(context.Background(), "createTables")
 	schema := `
 	CREATE TABLE IF NOT EXISTS game_results (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -68,22 +76,388 @@ func createTables() error {
 		winner_score INTEGER NOT NULL,
 		players_json TEXT NOT NULL,
 		nectar_json TEXT,
-		round_breakdown_json TEXT
+		round_breakdown_json TEXT,
+		goals_json TEXT,
+		season_id INTEGER,
+		group_id TEXT NOT NULL DEFAULT 'default'
 	);
 
 	CREATE INDEX IF NOT EXISTS idx_created_at ON game_results(created_at DESC);
 	CREATE INDEX IF NOT EXISTS idx_winner_name ON game_results(winner_name);
+
+	-- Every game, season, tournament and derived stat belongs to a group
+	CREATE TABLE IF NOT EXISTS groups (
+		slug TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	INSERT OR IGNORE INTO groups (slug, name) VALUES ('default', 'Default');
+
+	-- Local accounts. Sessions and API tokens are stored as SHA-256 hashes.
+	CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT NOT NULL UNIQUE,
+		password_hash TEXT NOT NULL,
+		role TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS sessions (
+		token_hash TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		csrf_token TEXT NOT NULL,
+		expires_at DATETIME NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS api_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_used_at DATETIME
+	);
+
+	-- One row per player per game, kept in sync with players_json by the
+	-- triggers below so stats can be queried without decoding JSON
+	CREATE TABLE IF NOT EXISTS player_scores (
+		game_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		player_name TEXT NOT NULL,
+		rank INTEGER NOT NULL,
+		total INTEGER NOT NULL,
+		bird_points INTEGER NOT NULL,
+		bonus_cards INTEGER NOT NULL,
+		round_goals INTEGER NOT NULL,
+		eggs INTEGER NOT NULL,
+		cached_food INTEGER NOT NULL,
+		tucked_cards INTEGER NOT NULL,
+		nectar_forest INTEGER NOT NULL,
+		nectar_grassland INTEGER NOT NULL,
+		nectar_wetland INTEGER NOT NULL,
+		unused_food INTEGER NOT NULL,
+		PRIMARY KEY (game_id, position)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_player_scores_player ON player_scores(player_name);
+
+	CREATE TRIGGER IF NOT EXISTS game_results_insert_scores AFTER INSERT ON game_results
+	BEGIN
+		INSERT INTO player_scores ` + playerScoresSelect("NEW") + `;
+	END;
+	CREATE TRIGGER IF NOT EXISTS game_results_update_scores AFTER UPDATE ON game_results
+	BEGIN
+		DELETE FROM player_scores WHERE game_id = OLD.id;
+		INSERT INTO player_scores ` + playerScoresSelect("NEW") + `;
+	END;
+	CREATE TRIGGER IF NOT EXISTS game_results_delete_scores AFTER DELETE ON game_results
+	BEGIN
+		DELETE FROM player_scores WHERE game_id = OLD.id;
+	END;
+
+	-- Each game's winner, winning margin over the runner-up and combined
+	-- score, kept in sync like player_scores so records can read them in
+	-- index order. Margin is NULL for single-player games.
+	CREATE TABLE IF NOT EXISTS game_totals (
+		game_id INTEGER PRIMARY KEY,
+		position INTEGER NOT NULL,
+		player_name TEXT NOT NULL,
+		margin INTEGER,
+		combined INTEGER NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_game_totals_margin ON game_totals(margin);
+	CREATE INDEX IF NOT EXISTS idx_game_totals_combined ON game_totals(combined);
+
+	CREATE TRIGGER IF NOT EXISTS game_results_insert_totals AFTER INSERT ON game_results
+	BEGIN
+		INSERT INTO game_totals ` + gameTotalsSelect("NEW") + `;
+	END;
+	CREATE TRIGGER IF NOT EXISTS game_results_update_totals AFTER UPDATE ON game_results
+	BEGIN
+		DELETE FROM game_totals WHERE game_id = OLD.id;
+		INSERT INTO game_totals ` + gameTotalsSelect("NEW") + `;
+	END;
+	CREATE TRIGGER IF NOT EXISTS game_results_delete_totals AFTER DELETE ON game_results
+	BEGIN
+		DELETE FROM game_totals WHERE game_id = OLD.id;
+	END;
+
+	-- Games are in a season when tagged with its ID, or when untagged and
+	-- played between its start and end dates (inclusive)
+	CREATE TABLE IF NOT EXISTS seasons (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		start_date TEXT NOT NULL,
+		end_date TEXT NOT NULL,
+		players_json TEXT NOT NULL,
+		placement_points_json TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		group_id TEXT NOT NULL DEFAULT 'default'
+	);
+
+	CREATE TABLE IF NOT EXISTS tournaments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		pairing TEXT NOT NULL,
+		table_size INTEGER NOT NULL,
+		final_table_size INTEGER NOT NULL,
+		placement_points_json TEXT NOT NULL,
+		seed INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		group_id TEXT NOT NULL DEFAULT 'default'
+	);
+
+	CREATE TABLE IF NOT EXISTS tournament_players (
+		tournament_id INTEGER NOT NULL,
+		player_name TEXT NOT NULL,
+		seed INTEGER NOT NULL,
+		PRIMARY KEY (tournament_id, player_name)
+	);
+
+	CREATE TABLE IF NOT EXISTS tournament_tables (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tournament_id INTEGER NOT NULL,
+		round INTEGER NOT NULL,
+		table_number INTEGER NOT NULL,
+		is_final BOOLEAN NOT NULL,
+		players_json TEXT NOT NULL,
+		game_id INTEGER
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_tournament_tables_tournament ON tournament_tables(tournament_id, round, table_number);
+
+	-- Deleting a tournament game reopens its table for a new result
+	CREATE TRIGGER IF NOT EXISTS game_results_delete_tournament AFTER DELETE ON game_results
+	BEGIN
+		UPDATE tournament_tables SET game_id = NULL WHERE game_id = OLD.id;
+	END;
+
+	CREATE TABLE IF NOT EXISTS player_achievements (
+		group_id TEXT NOT NULL,
+		player_name TEXT NOT NULL,
+		achievement_id TEXT NOT NULL,
+		game_id INTEGER NOT NULL,
+		earned_at DATETIME NOT NULL,
+		PRIMARY KEY (group_id, player_name, achievement_id)
+	);
+
+	-- Records which rules player_achievements was computed with. Games saved
+	-- or deleted update achievements in their own transaction; a change of
+	-- rules, or a game edited outside the app, backfills them on startup.
+	CREATE TABLE IF NOT EXISTS achievement_state (
+		rules_version TEXT NOT NULL
+	);
+
+	DROP TRIGGER IF EXISTS game_results_insert_achievements;
+	DROP TRIGGER IF EXISTS game_results_update_achievements;
+	DROP TRIGGER IF EXISTS game_results_delete_achievements;
+	CREATE TRIGGER game_results_update_achievements AFTER UPDATE OF created_at, players_json, group_id ON game_results
+	BEGIN DELETE FROM achievement_state; END;
+
+	CREATE TABLE IF NOT EXISTS rating_history (
+		group_id TEXT NOT NULL,
+		seq INTEGER NOT NULL,
+		game_id INTEGER NOT NULL,
+		played_at DATETIME NOT NULL,
+		player_name TEXT NOT NULL,
+		rank INTEGER NOT NULL,
+		rating_before REAL NOT NULL,
+		rating_after REAL NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_rating_history_player ON rating_history(group_id, player_name, seq);
+
+	-- Ratings depend on every earlier game in a group. A saved game is
+	-- appended to its group's history; editing a game, or deleting one with
+	-- games after it, clears the group's history and it is recomputed on next
+	-- read. The triggers are recreated so databases get the current ones.
+	DROP TRIGGER IF EXISTS game_results_insert_ratings;
+	DROP TRIGGER IF EXISTS game_results_update_ratings;
+	DROP TRIGGER IF EXISTS game_results_delete_ratings;
+	CREATE TRIGGER game_results_update_ratings AFTER UPDATE OF created_at, players_json, group_id ON game_results
+	BEGIN DELETE FROM rating_history WHERE group_id IN (OLD.group_id, NEW.group_id); END;
+	CREATE TRIGGER game_results_delete_ratings AFTER DELETE ON game_results
+	BEGIN
+		DELETE FROM rating_history WHERE group_id = OLD.group_id AND (game_id = OLD.id OR EXISTS (
+			SELECT 1 FROM game_results later
+			WHERE later.group_id = OLD.group_id
+			AND (later.created_at > OLD.created_at OR (later.created_at = OLD.created_at AND later.id > OLD.id))
+		));
+	END;
 	`
 
-	_, err := DB.Exec(schema)
+	// Record lists read each category in index order and stop at their limit
+	for _, category := range ScoreCategories {
+		schema += `
+	CREATE INDEX IF NOT EXISTS idx_player_scores_` + category.Column + ` ON player_scores(` + category.Column + `);`
+	}
+
+	_, err := DB.ExecContext(ctx, schema)
 	if err != nil {
 		return err
 	}
 
 	// Migration for existing databases (safe to run multiple times)
 	// ALTER TABLE will fail silently if column already exists
-	_, _ = DB.Exec(`ALTER TABLE game_results ADD COLUMN round_breakdown_json TEXT`)
+	for _, c := range addedColumns {
+		if _, err := DB.ExecContext(ctx, `ALTER TABLE `+c.table+` ADD COLUMN `+c.column+` `+c.definition); err != nil || columnBackfills[c] == "" {
+			continue
+		}
+		if _, err := DB.ExecContext(ctx, columnBackfills[c]); err != nil {
+			return fmt.Errorf("failed to backfill %s.%s: %w", c.table, c.column, err)
+		}
+	}
+	if _, err := DB.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_game_results_group ON game_results(group_id, created_at)`); err != nil {
+		return fmt.Errorf("failed to create group index: %w", err)
+	}
+	if _, err := DB.ExecContext(ctx, `CREATE UNIQUE INDEX IF NOT EXISTS idx_users_oidc_subject ON users(oidc_subject) WHERE oidc_subject IS NOT NULL`); err != nil {
+		return fmt.Errorf("failed to create OIDC subject index: %w", err)
+	}
+
+	// Ratings and achievements are rebuilt on next read, so tables from
+	// before groups existed are dropped and recreated with a group column
+	for _, table := range rebuiltTables {
+		if _, err := DB.ExecContext(ctx, `SELECT group_id FROM `+table+` LIMIT 1`); err == nil {
+			continue
+		}
+		if _, err := DB.ExecContext(ctx, `DROP TABLE `+table); err != nil {
+			return fmt.Errorf("failed to migrate %s: %w", table, err)
+		}
+		if _, err := DB.ExecContext(ctx, `DELETE FROM achievement_state`); err != nil {
+			return fmt.Errorf("failed to migrate %s: %w", table, err)
+		}
+		return createTables()
+	}
+
+	if _, err := DB.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_rating_history_seq ON rating_history(group_id, seq)`); err != nil {
+		return fmt.Errorf("failed to create rating history index: %w", err)
+	}
+
+	// Backfill player_scores for games saved before the table existed
+	_, err = DB.ExecContext(ctx, `
+		INSERT INTO player_scores `+playerScoresSelect("game_results")+`
+		AND NOT EXISTS (SELECT 1 FROM player_scores WHERE player_scores.game_id = game_results.id)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to backfill player scores: %w", err)
+	}
+	_, err = DB.ExecContext(ctx, `
+		INSERT INTO game_totals `+gameTotalsSelect("game_results")+`
+		AND NOT EXISTS (SELECT 1 FROM game_totals WHERE game_totals.game_id = w.game_id)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to backfill game totals: %w", err)
+	}
 
+	return backfillAchievements(ctx)
+}
+
+// playerScoresSelect returns a SELECT that expands a game_results row's
+// players_json into player_scores rows. row is "NEW" inside a trigger or
+// "game_results" when selecting from the table.
+func playerScoresSelect(row string) string {
+	source := "json_each(" + row + ".players_json)"
+	if row == "game_results" {
+		source = "game_results, " + source
+	}
+	return `
+		SELECT ` + row + `.id, key,
+			json_extract(value, '$.playerName'),
+			IFNULL(json_extract(value, '$.rank'), 0),
+			IFNULL(json_extract(value, '$.total'), 0),
+			IFNULL(json_extract(value, '$.birdPoints'), 0),
+			IFNULL(json_extract(value, '$.bonusCards'), 0),
+			IFNULL(json_extract(value, '$.roundGoals'), 0),
+			IFNULL(json_extract(value, '$.eggs'), 0),
+			IFNULL(json_extract(value, '$.cachedFood'), 0),
+			IFNULL(json_extract(value, '$.tuckedCards'), 0),
+			IFNULL(json_extract(value, '$.nectarForest'), 0),
+			IFNULL(json_extract(value, '$.nectarGrassland'), 0),
+			IFNULL(json_extract(value, '$.nectarWetland'), 0),
+			IFNULL(json_extract(value, '$.unusedFood'), 0)
+		FROM ` + source + `
+		WHERE json_extract(value, '$.playerName') IS NOT NULL`
+}
+
+// gameTotalsSelect returns a SELECT of game_totals rows for a game_results
+// row, or for every game when row is "game_results". Players are ordered by
+// rank, then total, then seat, so the first is the winner and the second the
+// runner-up.
+func gameTotalsSelect(row string) string {
+	source := "json_each(" + row + ".players_json)"
+	if row == "game_results" {
+		source = "game_results, " + source
+	}
+	ranked := `
+		SELECT ` + row + `.id AS game_id, key AS position,
+			json_extract(value, '$.playerName') AS player_name,
+			IFNULL(json_extract(value, '$.total'), 0) AS total,
+			SUM(IFNULL(json_extract(value, '$.total'), 0)) OVER (PARTITION BY ` + row + `.id) AS combined,
+			ROW_NUMBER() OVER (PARTITION BY ` + row + `.id
+				ORDER BY IFNULL(json_extract(value, '$.rank'), 0), IFNULL(json_extract(value, '$.total'), 0) DESC, key) AS pos
+		FROM ` + source + `
+		WHERE json_extract(value, '$.playerName') IS NOT NULL`
+	return `
+		SELECT w.game_id, w.position, w.player_name, w.total - r.total, w.combined
+		FROM (` + ranked + `) w
+		LEFT JOIN (` + ranked + `) r ON r.game_id = w.game_id AND r.pos = 2
+		WHERE w.pos = 1`
+}
+
+// column is a table column and its type and constraints
+type column struct {
+	table, column, definition string
+}
+
+// addedColumns are the columns added to tables after they were first created
+var addedColumns = []column{
+	{"game_results", "round_breakdown_json", "TEXT"},
+	{"game_results", "goals_json", "TEXT"},
+	{"game_results", "season_id", "INTEGER"},
+	{"game_results", "group_id", "TEXT NOT NULL DEFAULT 'default'"},
+	{"seasons", "group_id", "TEXT NOT NULL DEFAULT 'default'"},
+	{"tournaments", "group_id", "TEXT NOT NULL DEFAULT 'default'"},
+	{"users", "player_name", "TEXT NOT NULL DEFAULT ''"},
+	{"users", "oidc_subject", "TEXT"},
+	{"users", "proxy_user", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// columnBackfills are run once, when their column is added to an existing table
+var columnBackfills = map[column]string{
+	// Accounts created from proxy headers before they were marked are the
+	// ones without a password or OpenID Connect identity
+	{"users", "proxy_user", "INTEGER NOT NULL DEFAULT 0"}: `UPDATE users SET proxy_user = 1 WHERE password_hash = '' AND oidc_subject IS NULL`,
+}
+
+// rebuiltTables are recreated with a group column rather than altered
+var rebuiltTables = []string{"rating_history", "player_achievements"}
+
+// Ready reports whether the database can be reached and has been migrated
+func Ready(ctx context.Context) error {
+	ctx = named(ctx, "Ready")
+	if DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if err := DB.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to reach database: %w", err)
+	}
+	columns := slices.Clone(addedColumns)
+	for _, table := range rebuiltTables {
+		columns = append(columns, column{table: table, column: "group_id"})
+	}
+	for _, c := range columns {
+		var found bool
+		err := DB.QueryRowContext(ctx, `SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?`, c.table, c.column).Scan(&found)
+		if err != nil {
+			return fmt.Errorf("failed to check schema: %w", err)
+		}
+		if !found {
+			return fmt.Errorf("database not migrated: %s.%s is missing", c.table, c.column)
+		}
+	}
 	return nil
 }
 
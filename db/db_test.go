@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"os"
 	"path/filepath"
@@ -26,13 +27,8 @@ func TestInitialize_DefaultPath(t *testing.T) {
 	defer os.Chdir(originalWd)
 	os.Chdir(tmpDir)
 
-	// Clear DB_PATH environment variable
-	originalDBPath := os.Getenv("DB_PATH")
-	defer os.Setenv("DB_PATH", originalDBPath)
-	os.Unsetenv("DB_PATH")
-
 	// Initialize database
-	err = Initialize()
+	err = Initialize("")
 	require.NoError(t, err)
 	require.NotNil(t, DB)
 
@@ -48,7 +44,7 @@ func TestInitialize_DefaultPath(t *testing.T) {
 	Close()
 }
 
-// TestInitialize_CustomPath tests database initialization with custom path from environment
+// TestInitialize_CustomPath tests database initialization with a custom path
 func TestInitialize_CustomPath(t *testing.T) {
 	// Save original DB and defer restore
 	originalDB := DB
@@ -59,14 +55,11 @@ func TestInitialize_CustomPath(t *testing.T) {
 	require.NoError(t, err)
 	defer os.RemoveAll(tmpDir)
 
-	// Set custom DB_PATH
+	// Use a custom path
 	customPath := filepath.Join(tmpDir, "custom", "test.db")
-	originalDBPath := os.Getenv("DB_PATH")
-	defer os.Setenv("DB_PATH", originalDBPath)
-	os.Setenv("DB_PATH", customPath)
 
 	// Initialize database
-	err = Initialize()
+	err = Initialize(customPath)
 	require.NoError(t, err)
 	require.NotNil(t, DB)
 
@@ -93,14 +86,11 @@ func TestInitialize_CreatesDirectory(t *testing.T) {
 	require.NoError(t, err)
 	defer os.RemoveAll(tmpDir)
 
-	// Set DB_PATH to a path with non-existent parent directories
+	// Use a path with non-existent parent directories
 	deepPath := filepath.Join(tmpDir, "level1", "level2", "level3", "test.db")
-	originalDBPath := os.Getenv("DB_PATH")
-	defer os.Setenv("DB_PATH", originalDBPath)
-	os.Setenv("DB_PATH", deepPath)
 
 	// Initialize database
-	err = Initialize()
+	err = Initialize(deepPath)
 	require.NoError(t, err)
 	require.NotNil(t, DB)
 
@@ -127,14 +117,11 @@ func TestInitialize_CreatesTablesAndIndexes(t *testing.T) {
 	require.NoError(t, err)
 	defer os.RemoveAll(tmpDir)
 
-	// Set custom DB_PATH
+	// Use a custom path
 	customPath := filepath.Join(tmpDir, "test.db")
-	originalDBPath := os.Getenv("DB_PATH")
-	defer os.Setenv("DB_PATH", originalDBPath)
-	os.Setenv("DB_PATH", customPath)
 
 	// Initialize database
-	err = Initialize()
+	err = Initialize(customPath)
 	require.NoError(t, err)
 	require.NotNil(t, DB)
 
@@ -176,21 +163,18 @@ func TestInitialize_IdempotentTableCreation(t *testing.T) {
 	require.NoError(t, err)
 	defer os.RemoveAll(tmpDir)
 
-	// Set custom DB_PATH
+	// Use a custom path
 	customPath := filepath.Join(tmpDir, "test.db")
-	originalDBPath := os.Getenv("DB_PATH")
-	defer os.Setenv("DB_PATH", originalDBPath)
-	os.Setenv("DB_PATH", customPath)
 
 	// Initialize database first time
-	err = Initialize()
+	err = Initialize(customPath)
 	require.NoError(t, err)
 
 	// Close the connection
 	Close()
 
 	// Initialize database second time (should not error)
-	err = Initialize()
+	err = Initialize(customPath)
 	assert.NoError(t, err)
 	assert.NotNil(t, DB)
 
@@ -209,14 +193,11 @@ func TestClose_ValidConnection(t *testing.T) {
 	require.NoError(t, err)
 	defer os.RemoveAll(tmpDir)
 
-	// Set custom DB_PATH
+	// Use a custom path
 	customPath := filepath.Join(tmpDir, "test.db")
-	originalDBPath := os.Getenv("DB_PATH")
-	defer os.Setenv("DB_PATH", originalDBPath)
-	os.Setenv("DB_PATH", customPath)
 
 	// Initialize database
-	err = Initialize()
+	err = Initialize(customPath)
 	require.NoError(t, err)
 
 	// Close should succeed
@@ -306,12 +287,9 @@ func TestInitialize_AbsolutePath(t *testing.T) {
 
 	// Use an absolute path directly
 	absolutePath := filepath.Join(tmpDir, "absolute.db")
-	originalDBPath := os.Getenv("DB_PATH")
-	defer os.Setenv("DB_PATH", originalDBPath)
-	os.Setenv("DB_PATH", absolutePath)
 
 	// Initialize database
-	err = Initialize()
+	err = Initialize(absolutePath)
 	require.NoError(t, err)
 	require.NotNil(t, DB)
 
@@ -322,3 +300,105 @@ func TestInitialize_AbsolutePath(t *testing.T) {
 	// Clean up
 	Close()
 }
+
+// TestCreateTables_MigratesToGroups tests that data from before groups
+// existed moves into the default group
+func TestCreateTables_MigratesToGroups(t *testing.T) {
+	originalDB := DB
+	defer func() { DB = originalDB }()
+
+	tmpDir, err := os.MkdirTemp("", "wingspan-test-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	DB, err = sql.Open("sqlite", filepath.Join(tmpDir, "test.db"))
+	require.NoError(t, err)
+	defer Close()
+
+	// Tables as they were before groups
+	_, err = DB.Exec(`
+		CREATE TABLE game_results (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			num_players INTEGER NOT NULL,
+			include_oceania BOOLEAN NOT NULL,
+			winner_name TEXT NOT NULL,
+			winner_score INTEGER NOT NULL,
+			players_json TEXT NOT NULL,
+			nectar_json TEXT
+		);
+		INSERT INTO game_results (num_players, include_oceania, winner_name, winner_score, players_json)
+		VALUES (2, 0, 'Alice', 90, '[{"playerName":"Alice","total":90,"rank":1},{"playerName":"Bob","total":70,"rank":2}]');
+		CREATE TABLE rating_history (
+			seq INTEGER NOT NULL,
+			game_id INTEGER NOT NULL,
+			played_at DATETIME NOT NULL,
+			player_name TEXT NOT NULL,
+			rank INTEGER NOT NULL,
+			rating_before REAL NOT NULL,
+			rating_after REAL NOT NULL
+		);
+		CREATE INDEX idx_rating_history_player ON rating_history(player_name, seq);
+		CREATE TABLE player_achievements (
+			player_name TEXT NOT NULL,
+			achievement_id TEXT NOT NULL,
+			game_id INTEGER NOT NULL,
+			earned_at DATETIME NOT NULL,
+			PRIMARY KEY (player_name, achievement_id)
+		);
+	`)
+	require.NoError(t, err)
+
+	require.NoError(t, createTables())
+
+	games, err := GetAllGameResults(t.Context(), 10, 0)
+	require.NoError(t, err)
+	require.Len(t, games, 1)
+	assert.Equal(t, DefaultGroup, games[0].Group)
+
+	ratings, err := GetRatings(t.Context(), DefaultGroup)
+	require.NoError(t, err)
+	assert.Len(t, ratings, 2)
+
+	achievements, err := GetPlayerAchievements(t.Context(), DefaultGroup, "Alice")
+	require.NoError(t, err)
+	assert.NotEmpty(t, achievements)
+}
+
+// TestProxyUserMigration tests that passwordless accounts are marked as proxy
+// users only when the column is first added
+func TestProxyUserMigration(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	_, err := DB.Exec(`ALTER TABLE users DROP COLUMN proxy_user;
+		INSERT INTO users (username, password_hash, role) VALUES ('dana', '', 'scorer')`)
+	require.NoError(t, err)
+	require.NoError(t, createTables())
+
+	user, err := GetProxyUser(t.Context(), "dana")
+	require.NoError(t, err)
+	assert.Equal(t, "dana", user.Username)
+
+	// Later accounts without a password aren't marked on restart
+	_, err = DB.Exec(`INSERT INTO users (username, password_hash, role) VALUES ('erin', '', 'scorer')`)
+	require.NoError(t, err)
+	require.NoError(t, createTables())
+	_, err = GetProxyUser(t.Context(), "erin")
+	assert.ErrorIs(t, err, ErrUsernameTaken)
+}
+
+// TestReady tests reporting whether the database is reachable and migrated
+func TestReady(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	assert.NoError(t, Ready(context.Background()))
+
+	_, err := DB.Exec(`DROP INDEX idx_users_oidc_subject; ALTER TABLE users DROP COLUMN oidc_subject`)
+	require.NoError(t, err)
+	assert.ErrorContains(t, Ready(context.Background()), "users.oidc_subject is missing")
+
+	Close()
+	assert.Error(t, Ready(context.Background()))
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// sqliteTimeFormat matches the format SQLite's CURRENT_TIMESTAMP writes to created_at
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+// GameFilter narrows a game results query. Zero values mean "no restriction".
+type GameFilter struct {
+	From       time.Time // Inclusive lower bound on created_at
+	To         time.Time // Exclusive upper bound on created_at
+	Players    []string  // Every listed player must have played in the game
+	Oceania    *bool     // Restrict to games with or without the Oceania expansion
+	NumPlayers int       // Exact player count
+	Winner     string    // Name of a player ranked first, including co-winners
+	Season     int64     // Season ID, matching tagged games and untagged games within its dates
+	Group      string    // Group slug; empty means DefaultGroup
+}
+
+// whereClause builds a SQL WHERE clause (including the keyword) and its
+// arguments for the filter. Games are always restricted to the filter's group.
+func (f GameFilter) whereClause() (string, []interface{}) {
+	conditions := []string{"game_results.group_id = ?"}
+	args := []interface{}{groupOrDefault(f.Group)}
+
+	if !f.From.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, f.From.UTC().Format(sqliteTimeFormat))
+	}
+	if !f.To.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, f.To.UTC().Format(sqliteTimeFormat))
+	}
+
+	// Match player names exactly rather than by substring of the JSON text
+	for _, name := range f.Players {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM json_each(game_results.players_json)
+			WHERE json_extract(value, '$.playerName') = ?
+		)`)
+		args = append(args, name)
+	}
+
+	if f.Oceania != nil {
+		conditions = append(conditions, "include_oceania = ?")
+		args = append(args, *f.Oceania)
+	}
+	if f.NumPlayers > 0 {
+		conditions = append(conditions, "num_players = ?")
+		args = append(args, f.NumPlayers)
+	}
+	// Any player ranked first counts, so co-winners match too
+	if f.Winner != "" {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM player_scores
+			WHERE game_id = game_results.id AND rank = 1 AND player_name = ?
+		)`)
+		args = append(args, f.Winner)
+	}
+	if f.Season != 0 {
+		conditions = append(conditions, `(game_results.season_id = ? OR (game_results.season_id IS NULL AND EXISTS (
+			SELECT 1 FROM seasons
+			WHERE seasons.id = ? AND date(game_results.created_at) BETWEEN seasons.start_date AND seasons.end_date
+		)))`)
+		args = append(args, f.Season, f.Season)
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// andWhere adds a condition to a WHERE clause built by whereClause
+func andWhere(where, condition string) string {
+	if where == "" {
+		return "WHERE " + condition
+	}
+	return where + " AND " + condition
+}
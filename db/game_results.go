@@ -1,9 +1,12 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 	"wingspan-scoring/scoring"
 )
@@ -19,10 +22,91 @@ type GameResult struct {
 	Players        []scoring.PlayerGameEnd                `json:"players"`
 	NectarScoring  *scoring.NectarScoring                 `json:"nectarScoring,omitempty"`
 	RoundBreakdown map[string]*scoring.RoundGoalBreakdown `json:"roundBreakdown,omitempty"`
+	Goals          *GameGoals                             `json:"goals,omitempty"`
+	SeasonID       *int64                                 `json:"seasonId,omitempty"` // Explicit season tag; otherwise seasons match by date
+	Group          string                                 `json:"-"`                  // Group slug; empty means DefaultGroup
+}
+
+// GameGoals records the end-of-round goals a game was played with
+type GameGoals struct {
+	Side   string    `json:"side"`   // Goal board side: "green" (ranked) or "blue" (one point per item)
+	Rounds [4]string `json:"rounds"` // goals.Goal ID for rounds 1-4, empty when unknown
 }
 
 // SaveGameResult saves a game result to the database
-func SaveGameResult(players []scoring.PlayerGameEnd, nectarScoring scoring.NectarScoring, includeOceania bool) (int64, error) {
+func SaveGameResult(ctx context.Context, players []scoring.PlayerGameEnd, nectarScoring scoring.NectarScoring, includeOceania bool) (int64, error) {
+	ctx = named(ctx, "SaveGameResult")
+	return SaveGame(ctx, &GameResult{Players: players, NectarScoring: &nectarScoring, IncludeOceania: includeOceania})
+}
+
+// SaveGame saves a game's players, nectar scoring and goals. The ID, player
+// count and winner are assigned on save, as is the date unless CreatedAt is
+// set, as it is for imported games.
+func SaveGame(ctx context.Context, game *GameResult) (int64, error) {
+	ctx = named(ctx, "SaveGame")
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	replays := make(map[string]bool)
+	id, err := insertGameResult(ctx, tx, game, replays)
+	if err != nil {
+		return 0, err
+	}
+	if err := replayAchievements(ctx, tx, replays); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit game: %w", err)
+	}
+	gamesSaved.Inc()
+	return id, nil
+}
+
+// SaveGames saves a batch of games, such as an import, in one transaction,
+// so either every game is saved or none are. It returns their IDs in order.
+func SaveGames(ctx context.Context, games []*GameResult) ([]int64, error) {
+	ctx = named(ctx, "SaveGames")
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ids := make([]int64, 0, len(games))
+	replays := make(map[string]bool)
+	for i, game := range games {
+		id, err := insertGameResult(ctx, tx, game, replays)
+		if err != nil {
+			return nil, fmt.Errorf("game %d: %w", i+1, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := replayAchievements(ctx, tx, replays); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit games: %w", err)
+	}
+	gamesSaved.Add(float64(len(ids)))
+	return ids, nil
+}
+
+// querier is satisfied by *sql.DB and *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// insertGameResult saves a game in tx, adding it to its group's ratings and
+// achievements in the same transaction. Groups whose achievements must be
+// replayed are added to replays, for the caller to replay with
+// replayAchievements once all of its games are saved.
+func insertGameResult(ctx context.Context, tx *sql.Tx, game *GameResult, replays map[string]bool) (int64, error) {
+	players := game.Players
 	if len(players) == 0 {
 		return 0, fmt.Errorf("no players provided")
 	}
@@ -68,7 +152,11 @@ func SaveGameResult(players []scoring.PlayerGameEnd, nectarScoring scoring.Necta
 
 	// Marshal nectar scoring to JSON (nullable)
 	var nectarJSON *string
-	if includeOceania {
+	if game.IncludeOceania {
+		nectarScoring := scoring.NectarScoring{}
+		if game.NectarScoring != nil {
+			nectarScoring = *game.NectarScoring
+		}
 		nectarBytes, err := json.Marshal(nectarScoring)
 		if err != nil {
 			return 0, fmt.Errorf("failed to marshal nectar scoring: %w", err)
@@ -77,13 +165,31 @@ func SaveGameResult(players []scoring.PlayerGameEnd, nectarScoring scoring.Necta
 		nectarJSON = &nectarStr
 	}
 
+	// Marshal goals to JSON (nullable)
+	var goalsJSON *string
+	if game.Goals != nil {
+		goalsBytes, err := json.Marshal(game.Goals)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal goals: %w", err)
+		}
+		goalsStr := string(goalsBytes)
+		goalsJSON = &goalsStr
+	}
+
+	// Keep the date a game was played on, defaulting to now
+	var createdAt *string
+	if !game.CreatedAt.IsZero() {
+		date := game.CreatedAt.UTC().Format(sqliteTimeFormat)
+		createdAt = &date
+	}
+
 	// Insert into database
 	query := `
-		INSERT INTO game_results (num_players, include_oceania, winner_name, winner_score, players_json, nectar_json, round_breakdown_json)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO game_results (created_at, num_players, include_oceania, winner_name, winner_score, players_json, nectar_json, round_breakdown_json, goals_json, season_id, group_id)
+		VALUES (COALESCE(?, CURRENT_TIMESTAMP), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	result, err := DB.Exec(query, len(players), includeOceania, winnerName, winnerScore, string(playersJSON), nectarJSON, roundBreakdownJSON)
+	result, err := tx.ExecContext(ctx, query, createdAt, len(players), game.IncludeOceania, winnerName, winnerScore, string(playersJSON), nectarJSON, roundBreakdownJSON, goalsJSON, game.SeasonID, groupOrDefault(game.Group))
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert game result: %w", err)
 	}
@@ -93,23 +199,122 @@ func SaveGameResult(players []scoring.PlayerGameEnd, nectarScoring scoring.Necta
 		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
 	}
 
+	// Rate the game as stored, with its ID and the date it was given
+	saved := *game
+	saved.ID = id
+	if err := tx.QueryRowContext(ctx, `SELECT created_at FROM game_results WHERE id = ?`, id).Scan(&saved.CreatedAt); err != nil {
+		return 0, fmt.Errorf("failed to read saved game: %w", err)
+	}
+	if err := rateGame(ctx, tx, &saved); err != nil {
+		return 0, err
+	}
+	if err := awardAchievements(ctx, tx, &saved, replays); err != nil {
+		return 0, err
+	}
+
 	return id, nil
 }
 
+// laterGame matches games played after the game_results row aliased n in
+// the same group, in chronological order
+const laterGame = `SELECT 1 FROM game_results later
+	WHERE later.group_id = n.group_id
+	AND (later.created_at > n.created_at OR (later.created_at = n.created_at AND later.id > n.id))`
+
+// isLatestGame reports whether no game in the group was played after game
+// id. Changes to the latest game can be applied to ratings and achievements
+// directly; any other game changes the games after it.
+func isLatestGame(ctx context.Context, tx *sql.Tx, id int64) (bool, error) {
+	var latest bool
+	err := tx.QueryRowContext(ctx, `SELECT NOT EXISTS (`+laterGame+`) FROM game_results n WHERE n.id = ?`, id).Scan(&latest)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for later games: %w", err)
+	}
+	return latest, nil
+}
+
 // GetGameResult retrieves a single game result by ID
-func GetGameResult(id int64) (*GameResult, error) {
+func GetGameResult(ctx context.Context, id int64) (*GameResult, error) {
+	ctx = named(ctx, "GetGameResult")
 	query := `
-		SELECT id, created_at, num_players, include_oceania, winner_name, winner_score, players_json, nectar_json, round_breakdown_json
+		SELECT ` + gameResultColumns + `
 		FROM game_results
 		WHERE id = ?
 	`
 
-	row := DB.QueryRow(query, id)
+	row := DB.QueryRowContext(ctx, query, id)
 
+	result, err := scanGameResult(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("game result not found")
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetAllGameResults retrieves all game results with pagination
+func GetAllGameResults(ctx context.Context, limit, offset int) ([]GameResult, error) {
+	ctx = named(ctx, "GetAllGameResults")
+	return GetFilteredGameResults(ctx, GameFilter{}, limit, offset)
+}
+
+// GetFilteredGameResults retrieves game results matching the filter with pagination
+func GetFilteredGameResults(ctx context.Context, filter GameFilter, limit, offset int) ([]GameResult, error) {
+	ctx = named(ctx, "GetFilteredGameResults")
+	if limit <= 0 {
+		limit = 50 // Default limit
+	}
+
+	where, args := filter.whereClause()
+	query := `
+		SELECT ` + gameResultColumns + `
+		FROM game_results
+		` + where + `
+		ORDER BY created_at DESC
+		LIMIT ? OFFSET ?
+	`
+
+	rows, err := DB.QueryContext(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query game results: %w", err)
+	}
+	defer rows.Close()
+
+	var results []GameResult
+	for rows.Next() {
+		result, err := scanGameResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return results, nil
+}
+
+// gameResultColumns lists the game_results columns scanGameResult reads, in order
+const gameResultColumns = `id, created_at, num_players, include_oceania, winner_name, winner_score, players_json, nectar_json, round_breakdown_json, goals_json, season_id, group_id`
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanGameResult reads a row selected with gameResultColumns
+func scanGameResult(row rowScanner) (GameResult, error) {
 	var result GameResult
 	var playersJSON string
 	var nectarJSON sql.NullString
 	var roundBreakdownJSON sql.NullString
+	var goalsJSON sql.NullString
+	var seasonID sql.NullInt64
 
 	err := row.Scan(
 		&result.ID,
@@ -121,24 +326,38 @@ func GetGameResult(id int64) (*GameResult, error) {
 		&playersJSON,
 		&nectarJSON,
 		&roundBreakdownJSON,
+		&goalsJSON,
+		&seasonID,
+		&result.Group,
 	)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("game result not found")
+		if errors.Is(err, sql.ErrNoRows) {
+			return result, err
 		}
-		return nil, fmt.Errorf("failed to query game result: %w", err)
+		return result, fmt.Errorf("failed to scan row: %w", err)
+	}
+
+	if err := decodeGameResultJSON(&result, playersJSON, nectarJSON, roundBreakdownJSON, goalsJSON); err != nil {
+		return result, err
+	}
+	if seasonID.Valid {
+		result.SeasonID = &seasonID.Int64
 	}
+	return result, nil
+}
 
+// decodeGameResultJSON unmarshals the JSON columns of a game_results row into result
+func decodeGameResultJSON(result *GameResult, playersJSON string, nectarJSON, roundBreakdownJSON, goalsJSON sql.NullString) error {
 	// Unmarshal players
 	if err := json.Unmarshal([]byte(playersJSON), &result.Players); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal players: %w", err)
+		return fmt.Errorf("failed to unmarshal players: %w", err)
 	}
 
 	// Unmarshal nectar scoring if present
 	if nectarJSON.Valid {
 		var nectar scoring.NectarScoring
 		if err := json.Unmarshal([]byte(nectarJSON.String), &nectar); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal nectar scoring: %w", err)
+			return fmt.Errorf("failed to unmarshal nectar scoring: %w", err)
 		}
 		result.NectarScoring = &nectar
 	}
@@ -147,172 +366,117 @@ func GetGameResult(id int64) (*GameResult, error) {
 	if roundBreakdownJSON.Valid {
 		var breakdown map[string]*scoring.RoundGoalBreakdown
 		if err := json.Unmarshal([]byte(roundBreakdownJSON.String), &breakdown); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal round breakdown: %w", err)
+			return fmt.Errorf("failed to unmarshal round breakdown: %w", err)
 		}
 		result.RoundBreakdown = breakdown
 	}
 
-	return &result, nil
-}
-
-// GetAllGameResults retrieves all game results with pagination
-func GetAllGameResults(limit, offset int) ([]GameResult, error) {
-	if limit <= 0 {
-		limit = 50 // Default limit
-	}
-
-	query := `
-		SELECT id, created_at, num_players, include_oceania, winner_name, winner_score, players_json, nectar_json, round_breakdown_json
-		FROM game_results
-		ORDER BY created_at DESC
-		LIMIT ? OFFSET ?
-	`
-
-	rows, err := DB.Query(query, limit, offset)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query game results: %w", err)
-	}
-	defer rows.Close()
-
-	var results []GameResult
-	for rows.Next() {
-		var result GameResult
-		var playersJSON string
-		var nectarJSON sql.NullString
-		var roundBreakdownJSON sql.NullString
-
-		err := rows.Scan(
-			&result.ID,
-			&result.CreatedAt,
-			&result.NumPlayers,
-			&result.IncludeOceania,
-			&result.WinnerName,
-			&result.WinnerScore,
-			&playersJSON,
-			&nectarJSON,
-			&roundBreakdownJSON,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
-		}
-
-		// Unmarshal players
-		if err := json.Unmarshal([]byte(playersJSON), &result.Players); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal players: %w", err)
-		}
-
-		// Unmarshal nectar scoring if present
-		if nectarJSON.Valid {
-			var nectar scoring.NectarScoring
-			if err := json.Unmarshal([]byte(nectarJSON.String), &nectar); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal nectar scoring: %w", err)
-			}
-			result.NectarScoring = &nectar
+	// Unmarshal round goals if present
+	if goalsJSON.Valid {
+		var gameGoals GameGoals
+		if err := json.Unmarshal([]byte(goalsJSON.String), &gameGoals); err != nil {
+			return fmt.Errorf("failed to unmarshal goals: %w", err)
 		}
-
-		// Unmarshal round breakdown if present
-		if roundBreakdownJSON.Valid {
-			var breakdown map[string]*scoring.RoundGoalBreakdown
-			if err := json.Unmarshal([]byte(roundBreakdownJSON.String), &breakdown); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal round breakdown: %w", err)
-			}
-			result.RoundBreakdown = breakdown
-		}
-
-		results = append(results, result)
+		result.Goals = &gameGoals
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
-	}
+	return nil
+}
 
-	return results, nil
+// GameResultIterator streams game results one row at a time so callers can
+// process the full history without loading it into memory
+type GameResultIterator struct {
+	rows    *sql.Rows
+	current GameResult
+	err     error
 }
 
-// CountGameResults returns the total number of game results
-func CountGameResults() (int, error) {
-	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM game_results").Scan(&count)
-	return count, err
+// IterateGameResults returns an iterator over game results matching the
+// filter, newest first. The caller must Close the iterator when done.
+func IterateGameResults(ctx context.Context, filter GameFilter) (*GameResultIterator, error) {
+	ctx = named(ctx, "IterateGameResults")
+	return iterateGameResults(ctx, DB, filter, "created_at DESC")
 }
 
-// GetPlayerStats returns statistics for a specific player
-func GetPlayerStats(playerName string) (map[string]interface{}, error) {
-	// Get total games played
-	var gamesPlayed int
-	err := DB.QueryRow(`
-		SELECT COUNT(*)
-		FROM game_results
-		WHERE players_json LIKE ?
-	`, "%\""+playerName+"\"%").Scan(&gamesPlayed)
-	if err != nil {
-		return nil, fmt.Errorf("failed to count games played: %w", err)
-	}
+// IterateGameResultsChronological returns an iterator over game results
+// matching the filter, oldest first, for calculations that depend on the
+// order games were played. The caller must Close the iterator when done.
+func IterateGameResultsChronological(ctx context.Context, filter GameFilter) (*GameResultIterator, error) {
+	ctx = named(ctx, "IterateGameResultsChronological")
+	return iterateGameResults(ctx, DB, filter, chronological)
+}
 
-	// Get total wins
-	var wins int
-	err = DB.QueryRow(`
-		SELECT COUNT(*)
-		FROM game_results
-		WHERE winner_name = ?
-	`, playerName).Scan(&wins)
-	if err != nil {
-		return nil, fmt.Errorf("failed to count wins: %w", err)
-	}
+// chronological orders games oldest first, breaking ties by save order
+const chronological = "created_at ASC, id ASC"
 
-	// Get average score - this requires parsing JSON, so we'll get all games and calculate
+// iterateGameResults queries games with q, which may be a transaction
+func iterateGameResults(ctx context.Context, q querier, filter GameFilter, orderBy string) (*GameResultIterator, error) {
+	where, args := filter.whereClause()
 	query := `
-		SELECT players_json
+		SELECT ` + gameResultColumns + `
 		FROM game_results
-		WHERE players_json LIKE ?
-	`
+		` + where + `
+		ORDER BY ` + orderBy
 
-	rows, err := DB.Query(query, "%\""+playerName+"\"%")
+	rows, err := q.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query player games: %w", err)
+		return nil, fmt.Errorf("failed to query game results: %w", err)
 	}
-	defer rows.Close()
 
-	totalScore := 0
-	gameCount := 0
-	for rows.Next() {
-		var playersJSON string
-		if err := rows.Scan(&playersJSON); err != nil {
-			continue
-		}
-
-		var players []scoring.PlayerGameEnd
-		if err := json.Unmarshal([]byte(playersJSON), &players); err != nil {
-			continue
-		}
+	return &GameResultIterator{rows: rows}, nil
+}
 
-		for _, p := range players {
-			if p.PlayerName == playerName {
-				totalScore += p.Total
-				gameCount++
-				break
-			}
-		}
+// Next advances to the next game result, returning false when there are no
+// more rows or an error occurred (check Err)
+func (it *GameResultIterator) Next() bool {
+	if it.err != nil || !it.rows.Next() {
+		return false
 	}
 
-	avgScore := 0.0
-	if gameCount > 0 {
-		avgScore = float64(totalScore) / float64(gameCount)
+	result, err := scanGameResult(it.rows)
+	if err != nil {
+		it.err = err
+		return false
 	}
 
-	stats := map[string]interface{}{
-		"playerName":   playerName,
-		"gamesPlayed":  gamesPlayed,
-		"wins":         wins,
-		"averageScore": avgScore,
-		"winRate":      0.0,
-	}
+	it.current = result
+	return true
+}
+
+// GameResult returns the game result at the current position
+func (it *GameResultIterator) GameResult() *GameResult {
+	return &it.current
+}
 
-	if gamesPlayed > 0 {
-		stats["winRate"] = float64(wins) / float64(gamesPlayed) * 100
+// Err returns the first error encountered during iteration
+func (it *GameResultIterator) Err() error {
+	if it.err != nil {
+		return it.err
 	}
+	if err := it.rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+	return nil
+}
 
-	return stats, nil
+// Close releases the underlying database rows
+func (it *GameResultIterator) Close() error {
+	return it.rows.Close()
+}
+
+// CountGameResults returns the total number of game results
+func CountGameResults(ctx context.Context) (int, error) {
+	ctx = named(ctx, "CountGameResults")
+	return CountFilteredGameResults(ctx, GameFilter{})
+}
+
+// CountFilteredGameResults returns the number of game results matching the filter
+func CountFilteredGameResults(ctx context.Context, filter GameFilter) (int, error) {
+	ctx = named(ctx, "CountFilteredGameResults")
+	where, args := filter.whereClause()
+	var count int
+	err := DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM game_results "+where, args...).Scan(&count)
+	return count, err
 }
 
 // CategoryLeader represents the top player in a specific scoring category
@@ -335,95 +499,66 @@ type LeaderboardStats struct {
 	NectarWetland   CategoryLeader `json:"nectarWetland"`
 }
 
-// GetLeaderboardStats returns the highest score and player name for each scoring category
-func GetLeaderboardStats() (*LeaderboardStats, error) {
-	// Query all games
-	query := `SELECT players_json FROM game_results`
+// GetLeaderboardStats returns the highest score and player name for each
+// scoring category. Ties go to the earliest game.
+func GetLeaderboardStats(ctx context.Context) (*LeaderboardStats, error) {
+	ctx = named(ctx, "GetLeaderboardStats")
+	return GetFilteredLeaderboardStats(ctx, GameFilter{})
+}
+
+// GetFilteredLeaderboardStats returns the category leaders among games matching the filter
+func GetFilteredLeaderboardStats(ctx context.Context, filter GameFilter) (*LeaderboardStats, error) {
+	ctx = named(ctx, "GetFilteredLeaderboardStats")
+	leaderboard := &LeaderboardStats{}
+	leaders := map[string]*CategoryLeader{
+		"totalScore":      &leaderboard.TotalScore,
+		"birdPoints":      &leaderboard.BirdPoints,
+		"bonusCards":      &leaderboard.BonusCards,
+		"roundGoals":      &leaderboard.RoundGoals,
+		"eggs":            &leaderboard.Eggs,
+		"cachedFood":      &leaderboard.CachedFood,
+		"tuckedCards":     &leaderboard.TuckedCards,
+		"nectarForest":    &leaderboard.NectarForest,
+		"nectarGrassland": &leaderboard.NectarGrassland,
+		"nectarWetland":   &leaderboard.NectarWetland,
+	}
 
-	rows, err := DB.Query(query)
+	// One pass over the group's scores in game order, so a tie goes to the
+	// earliest game
+	columns := make([]string, len(ScoreCategories))
+	for i, category := range ScoreCategories {
+		columns[i] = "player_scores." + category.Column
+	}
+	where, args := filter.whereClause()
+	rows, err := DB.QueryContext(ctx, `
+		SELECT player_scores.player_name, `+strings.Join(columns, ", ")+`
+		FROM player_scores
+		JOIN game_results ON game_results.id = player_scores.game_id
+		`+where+`
+		ORDER BY game_results.created_at, game_results.id, player_scores.position
+	`, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query game results: %w", err)
+		return nil, fmt.Errorf("failed to query player scores: %w", err)
 	}
 	defer rows.Close()
 
-	leaderboard := &LeaderboardStats{}
-
-	// Iterate through all games to find max scores
+	var playerName string
+	values := make([]int, len(ScoreCategories))
+	dest := []interface{}{&playerName}
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
 	for rows.Next() {
-		var playersJSON string
-		if err := rows.Scan(&playersJSON); err != nil {
-			continue
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan player score: %w", err)
 		}
-
-		var players []scoring.PlayerGameEnd
-		if err := json.Unmarshal([]byte(playersJSON), &players); err != nil {
-			continue
-		}
-
-		// Check each player's scores
-		for _, p := range players {
-			// Total Score
-			if p.Total > leaderboard.TotalScore.Score {
-				leaderboard.TotalScore.PlayerName = p.PlayerName
-				leaderboard.TotalScore.Score = p.Total
-			}
-
-			// Bird Points
-			if p.BirdPoints > leaderboard.BirdPoints.Score {
-				leaderboard.BirdPoints.PlayerName = p.PlayerName
-				leaderboard.BirdPoints.Score = p.BirdPoints
-			}
-
-			// Bonus Cards
-			if p.BonusCards > leaderboard.BonusCards.Score {
-				leaderboard.BonusCards.PlayerName = p.PlayerName
-				leaderboard.BonusCards.Score = p.BonusCards
-			}
-
-			// Round Goals
-			if p.RoundGoals > leaderboard.RoundGoals.Score {
-				leaderboard.RoundGoals.PlayerName = p.PlayerName
-				leaderboard.RoundGoals.Score = p.RoundGoals
-			}
-
-			// Eggs
-			if p.Eggs > leaderboard.Eggs.Score {
-				leaderboard.Eggs.PlayerName = p.PlayerName
-				leaderboard.Eggs.Score = p.Eggs
-			}
-
-			// Cached Food
-			if p.CachedFood > leaderboard.CachedFood.Score {
-				leaderboard.CachedFood.PlayerName = p.PlayerName
-				leaderboard.CachedFood.Score = p.CachedFood
-			}
-
-			// Tucked Cards
-			if p.TuckedCards > leaderboard.TuckedCards.Score {
-				leaderboard.TuckedCards.PlayerName = p.PlayerName
-				leaderboard.TuckedCards.Score = p.TuckedCards
-			}
-
-			// Nectar Forest
-			if p.NectarForest > leaderboard.NectarForest.Score {
-				leaderboard.NectarForest.PlayerName = p.PlayerName
-				leaderboard.NectarForest.Score = p.NectarForest
-			}
-
-			// Nectar Grassland
-			if p.NectarGrassland > leaderboard.NectarGrassland.Score {
-				leaderboard.NectarGrassland.PlayerName = p.PlayerName
-				leaderboard.NectarGrassland.Score = p.NectarGrassland
-			}
-
-			// Nectar Wetland
-			if p.NectarWetland > leaderboard.NectarWetland.Score {
-				leaderboard.NectarWetland.PlayerName = p.PlayerName
-				leaderboard.NectarWetland.Score = p.NectarWetland
+		for i, category := range ScoreCategories {
+			if leader, ok := leaders[category.Key]; ok && values[i] > leader.Score {
+				leader.PlayerName = playerName
+				leader.Score = values[i]
 			}
 		}
 	}
-
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating rows: %w", err)
 	}
@@ -432,22 +567,36 @@ func GetLeaderboardStats() (*LeaderboardStats, error) {
 }
 
 // DeleteGameResult deletes a game result by ID
-func DeleteGameResult(id int64) error {
-	query := `DELETE FROM game_results WHERE id = ?`
-
-	result, err := DB.Exec(query, id)
+func DeleteGameResult(ctx context.Context, id int64) error {
+	ctx = named(ctx, "DeleteGameResult")
+	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to delete game result: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	var group string
+	err = tx.QueryRowContext(ctx, `SELECT group_id FROM game_results WHERE id = ?`, id).Scan(&group)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("game result not found")
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return fmt.Errorf("failed to get game result: %w", err)
+	}
+	latest, err := isLatestGame(ctx, tx, id)
+	if err != nil {
+		return err
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("game result not found")
+	if _, err := tx.ExecContext(ctx, `DELETE FROM game_results WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete game result: %w", err)
+	}
+	if err := withdrawAchievements(ctx, tx, group, id, latest); err != nil {
+		return err
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit deletion: %w", err)
+	}
 	return nil
 }
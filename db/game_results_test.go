@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 	"wingspan-scoring/scoring"
 
 	"github.com/stretchr/testify/assert"
@@ -19,13 +20,11 @@ func setupTestDB(t *testing.T) func() {
 	tmpDir, err := os.MkdirTemp("", "wingspan-test-*")
 	require.NoError(t, err)
 
-	// Set custom DB_PATH
+	// Use a custom path
 	customPath := filepath.Join(tmpDir, "test.db")
-	originalDBPath := os.Getenv("DB_PATH")
-	os.Setenv("DB_PATH", customPath)
 
 	// Initialize database
-	err = Initialize()
+	err = Initialize(customPath)
 	require.NoError(t, err)
 	require.NotNil(t, DB)
 
@@ -33,7 +32,6 @@ func setupTestDB(t *testing.T) func() {
 	return func() {
 		Close()
 		DB = originalDB
-		os.Setenv("DB_PATH", originalDBPath)
 		os.RemoveAll(tmpDir)
 	}
 }
@@ -49,12 +47,12 @@ func TestSaveGameResult_WithoutOceania(t *testing.T) {
 	}
 	nectarScoring := scoring.NectarScoring{}
 
-	id, err := SaveGameResult(players, nectarScoring, false)
+	id, err := SaveGameResult(t.Context(), players, nectarScoring, false)
 	require.NoError(t, err)
 	assert.Greater(t, id, int64(0))
 
 	// Verify the game was saved correctly
-	result, err := GetGameResult(id)
+	result, err := GetGameResult(t.Context(), id)
 	require.NoError(t, err)
 	assert.Equal(t, 2, result.NumPlayers)
 	assert.False(t, result.IncludeOceania)
@@ -79,12 +77,12 @@ func TestSaveGameResult_WithOceania(t *testing.T) {
 		Wetland:   map[string]int{"Alice": 2, "Bob": 0},
 	}
 
-	id, err := SaveGameResult(players, nectarScoring, true)
+	id, err := SaveGameResult(t.Context(), players, nectarScoring, true)
 	require.NoError(t, err)
 	assert.Greater(t, id, int64(0))
 
 	// Verify the game was saved correctly
-	result, err := GetGameResult(id)
+	result, err := GetGameResult(t.Context(), id)
 	require.NoError(t, err)
 	assert.Equal(t, 2, result.NumPlayers)
 	assert.True(t, result.IncludeOceania)
@@ -103,7 +101,7 @@ func TestSaveGameResult_EmptyPlayers(t *testing.T) {
 	players := []scoring.PlayerGameEnd{}
 	nectarScoring := scoring.NectarScoring{}
 
-	id, err := SaveGameResult(players, nectarScoring, false)
+	id, err := SaveGameResult(t.Context(), players, nectarScoring, false)
 	assert.Error(t, err)
 	assert.Equal(t, int64(0), id)
 	assert.Contains(t, err.Error(), "no players provided")
@@ -120,7 +118,7 @@ func TestSaveGameResult_NoWinner(t *testing.T) {
 	}
 	nectarScoring := scoring.NectarScoring{}
 
-	id, err := SaveGameResult(players, nectarScoring, false)
+	id, err := SaveGameResult(t.Context(), players, nectarScoring, false)
 	assert.Error(t, err)
 	assert.Equal(t, int64(0), id)
 	assert.Contains(t, err.Error(), "no winner found")
@@ -139,11 +137,11 @@ func TestSaveGameResult_MultiplePlayers(t *testing.T) {
 	}
 	nectarScoring := scoring.NectarScoring{}
 
-	id, err := SaveGameResult(players, nectarScoring, false)
+	id, err := SaveGameResult(t.Context(), players, nectarScoring, false)
 	require.NoError(t, err)
 
 	// Verify all players were saved
-	result, err := GetGameResult(id)
+	result, err := GetGameResult(t.Context(), id)
 	require.NoError(t, err)
 	assert.Equal(t, 4, result.NumPlayers)
 	assert.Len(t, result.Players, 4)
@@ -156,7 +154,7 @@ func TestGetGameResult_NotFound(t *testing.T) {
 	cleanup := setupTestDB(t)
 	defer cleanup()
 
-	result, err := GetGameResult(999)
+	result, err := GetGameResult(t.Context(), 999)
 	assert.Error(t, err)
 	assert.Nil(t, result)
 	assert.Contains(t, err.Error(), "not found")
@@ -174,11 +172,11 @@ func TestGetGameResult_ValidGame(t *testing.T) {
 	}
 	nectarScoring := scoring.NectarScoring{}
 
-	id, err := SaveGameResult(players, nectarScoring, false)
+	id, err := SaveGameResult(t.Context(), players, nectarScoring, false)
 	require.NoError(t, err)
 
 	// Retrieve the game
-	result, err := GetGameResult(id)
+	result, err := GetGameResult(t.Context(), id)
 	require.NoError(t, err)
 	assert.Equal(t, id, result.ID)
 	assert.Equal(t, "Alice", result.WinnerName)
@@ -192,7 +190,7 @@ func TestGetAllGameResults_Empty(t *testing.T) {
 	cleanup := setupTestDB(t)
 	defer cleanup()
 
-	results, err := GetAllGameResults(10, 0)
+	results, err := GetAllGameResults(t.Context(), 10, 0)
 	require.NoError(t, err)
 	assert.Len(t, results, 0)
 }
@@ -208,17 +206,17 @@ func TestGetAllGameResults_Pagination(t *testing.T) {
 			{PlayerName: "Alice", Total: 100 + i, Rank: 1},
 		}
 		nectarScoring := scoring.NectarScoring{}
-		_, err := SaveGameResult(players, nectarScoring, false)
+		_, err := SaveGameResult(t.Context(), players, nectarScoring, false)
 		require.NoError(t, err)
 	}
 
 	// Get first 2 results
-	results, err := GetAllGameResults(2, 0)
+	results, err := GetAllGameResults(t.Context(), 2, 0)
 	require.NoError(t, err)
 	assert.Len(t, results, 2)
 
 	// Get next 2 results
-	results2, err := GetAllGameResults(2, 2)
+	results2, err := GetAllGameResults(t.Context(), 2, 2)
 	require.NoError(t, err)
 	assert.Len(t, results2, 2)
 
@@ -226,7 +224,7 @@ func TestGetAllGameResults_Pagination(t *testing.T) {
 	assert.NotEqual(t, results[0].ID, results2[0].ID)
 
 	// Get last result
-	results3, err := GetAllGameResults(2, 4)
+	results3, err := GetAllGameResults(t.Context(), 2, 4)
 	require.NoError(t, err)
 	assert.Len(t, results3, 1)
 }
@@ -242,12 +240,12 @@ func TestGetAllGameResults_DefaultLimit(t *testing.T) {
 			{PlayerName: "Alice", Total: 100, Rank: 1},
 		}
 		nectarScoring := scoring.NectarScoring{}
-		_, err := SaveGameResult(players, nectarScoring, false)
+		_, err := SaveGameResult(t.Context(), players, nectarScoring, false)
 		require.NoError(t, err)
 	}
 
 	// Get results with limit 0 (should use default)
-	results, err := GetAllGameResults(0, 0)
+	results, err := GetAllGameResults(t.Context(), 0, 0)
 	require.NoError(t, err)
 	assert.Len(t, results, 3) // Should get all 3 with default limit of 50
 }
@@ -265,13 +263,13 @@ func TestGetAllGameResults_OrderByCreatedAt(t *testing.T) {
 			{PlayerName: winner, Total: 100, Rank: 1},
 		}
 		nectarScoring := scoring.NectarScoring{}
-		id, err := SaveGameResult(players, nectarScoring, false)
+		id, err := SaveGameResult(t.Context(), players, nectarScoring, false)
 		require.NoError(t, err)
 		ids = append(ids, id)
 	}
 
 	// Get all results
-	results, err := GetAllGameResults(10, 0)
+	results, err := GetAllGameResults(t.Context(), 10, 0)
 	require.NoError(t, err)
 	assert.Len(t, results, 3)
 
@@ -285,12 +283,150 @@ func TestGetAllGameResults_OrderByCreatedAt(t *testing.T) {
 	}
 }
 
+// TestIterateGameResults_StreamsAllGames tests the iterator visits every game once
+func TestIterateGameResults_StreamsAllGames(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// Save more games than the default page size
+	for i := 0; i < 60; i++ {
+		players := []scoring.PlayerGameEnd{
+			{PlayerName: "Alice", Total: 100 + i, Rank: 1},
+			{PlayerName: "Bob", Total: 90, Rank: 2},
+		}
+		_, err := SaveGameResult(t.Context(), players, scoring.NectarScoring{}, false)
+		require.NoError(t, err)
+	}
+
+	it, err := IterateGameResults(t.Context(), GameFilter{})
+	require.NoError(t, err)
+	defer it.Close()
+
+	seen := make(map[int64]bool)
+	for it.Next() {
+		game := it.GameResult()
+		assert.False(t, seen[game.ID], "game %d returned twice", game.ID)
+		seen[game.ID] = true
+		assert.Len(t, game.Players, 2)
+	}
+	require.NoError(t, it.Err())
+	assert.Len(t, seen, 60)
+}
+
+// TestIterateGameResults_SaveDuringExport tests that games can be saved while
+// a streamed export holds the iterator open
+func TestIterateGameResults_SaveDuringExport(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	players := []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Total: 100, Rank: 1},
+		{PlayerName: "Bob", Total: 90, Rank: 2},
+	}
+	for i := 0; i < 3; i++ {
+		_, err := SaveGameResult(t.Context(), players, scoring.NectarScoring{}, false)
+		require.NoError(t, err)
+	}
+
+	it, err := IterateGameResults(t.Context(), GameFilter{})
+	require.NoError(t, err)
+	defer it.Close()
+	require.True(t, it.Next())
+
+	_, err = SaveGameResult(t.Context(), players, scoring.NectarScoring{}, false)
+	require.NoError(t, err)
+
+	count := 1
+	for it.Next() {
+		count++
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, 3, count, "the export reads the games as they were when it started")
+}
+
+// TestIterateGameResults_Empty tests the iterator on an empty database
+func TestIterateGameResults_Empty(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	it, err := IterateGameResults(t.Context(), GameFilter{})
+	require.NoError(t, err)
+	defer it.Close()
+
+	assert.False(t, it.Next())
+	assert.NoError(t, it.Err())
+}
+
+// TestGetFilteredGameResults tests each game filter field
+func TestGetFilteredGameResults(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	save := func(date string, oceania bool, players ...scoring.PlayerGameEnd) int64 {
+		id, err := SaveGameResult(t.Context(), players, scoring.NectarScoring{}, oceania)
+		require.NoError(t, err)
+		_, err = DB.Exec("UPDATE game_results SET created_at = ? WHERE id = ?", date+" 12:00:00", id)
+		require.NoError(t, err)
+		return id
+	}
+
+	g1 := save("2024-01-10", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 90, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 80, Rank: 2})
+	g2 := save("2024-02-10", true,
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 95, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Carol", Total: 85, Rank: 2},
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 75, Rank: 3})
+	g3 := save("2024-03-10", false,
+		scoring.PlayerGameEnd{PlayerName: "Carol", Total: 100, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Alicia", Total: 70, Rank: 2})
+	g4 := save("2024-04-10", false,
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 88, Rank: 1, UnusedFood: 2},
+		scoring.PlayerGameEnd{PlayerName: "Carol", Total: 88, Rank: 1, UnusedFood: 2})
+
+	yes, no := true, false
+	testCases := []struct {
+		name     string
+		filter   GameFilter
+		expected []int64
+	}{
+		{"No filter", GameFilter{}, []int64{g4, g3, g2, g1}},
+		{"From date", GameFilter{From: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)}, []int64{g4, g3, g2}},
+		{"To date", GameFilter{To: time.Date(2024, 2, 11, 0, 0, 0, 0, time.UTC)}, []int64{g2, g1}},
+		{"Single player exact match", GameFilter{Players: []string{"Alice"}}, []int64{g2, g1}},
+		{"All listed players", GameFilter{Players: []string{"Alice", "Carol"}}, []int64{g2}},
+		{"Oceania on", GameFilter{Oceania: &yes}, []int64{g2}},
+		{"Oceania off", GameFilter{Oceania: &no}, []int64{g4, g3, g1}},
+		{"Player count", GameFilter{NumPlayers: 3}, []int64{g2}},
+		{"Winner", GameFilter{Winner: "Carol"}, []int64{g4, g3}},
+		{"Combined", GameFilter{Players: []string{"Bob"}, Winner: "Alice"}, []int64{g1}},
+		{"Co-winner", GameFilter{Winner: "Bob"}, []int64{g4, g2}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := GetFilteredGameResults(t.Context(), tc.filter, 10, 0)
+			require.NoError(t, err)
+
+			var ids []int64
+			for _, r := range results {
+				ids = append(ids, r.ID)
+			}
+			assert.Equal(t, tc.expected, ids)
+
+			count, err := CountFilteredGameResults(t.Context(), tc.filter)
+			require.NoError(t, err)
+			assert.Equal(t, len(tc.expected), count)
+		})
+	}
+}
+
 // TestCountGameResults_Empty tests count when no games exist
 func TestCountGameResults_Empty(t *testing.T) {
 	cleanup := setupTestDB(t)
 	defer cleanup()
 
-	count, err := CountGameResults()
+	count, err := CountGameResults(t.Context())
 	require.NoError(t, err)
 	assert.Equal(t, 0, count)
 }
@@ -306,11 +442,11 @@ func TestCountGameResults_Multiple(t *testing.T) {
 			{PlayerName: "Alice", Total: 100, Rank: 1},
 		}
 		nectarScoring := scoring.NectarScoring{}
-		_, err := SaveGameResult(players, nectarScoring, false)
+		_, err := SaveGameResult(t.Context(), players, nectarScoring, false)
 		require.NoError(t, err)
 	}
 
-	count, err := CountGameResults()
+	count, err := CountGameResults(t.Context())
 	require.NoError(t, err)
 	assert.Equal(t, 7, count)
 }
@@ -320,13 +456,13 @@ func TestGetPlayerStats_NoGames(t *testing.T) {
 	cleanup := setupTestDB(t)
 	defer cleanup()
 
-	stats, err := GetPlayerStats("NonExistent")
+	stats, err := GetPlayerStats(t.Context(), "NonExistent", GameFilter{})
 	require.NoError(t, err)
-	assert.Equal(t, "NonExistent", stats["playerName"])
-	assert.Equal(t, 0, stats["gamesPlayed"])
-	assert.Equal(t, 0, stats["wins"])
-	assert.Equal(t, 0.0, stats["averageScore"])
-	assert.Equal(t, 0.0, stats["winRate"])
+	assert.Equal(t, "NonExistent", stats.PlayerName)
+	assert.Equal(t, 0, stats.GamesPlayed)
+	assert.Equal(t, 0, stats.Wins)
+	assert.Equal(t, 0.0, stats.AverageScore)
+	assert.Equal(t, 0.0, stats.WinRate)
 }
 
 // TestGetPlayerStats_WithGamesAndWins tests stats for a player with wins
@@ -352,22 +488,22 @@ func TestGetPlayerStats_WithGamesAndWins(t *testing.T) {
 
 	for _, players := range games {
 		nectarScoring := scoring.NectarScoring{}
-		_, err := SaveGameResult(players, nectarScoring, false)
+		_, err := SaveGameResult(t.Context(), players, nectarScoring, false)
 		require.NoError(t, err)
 	}
 
-	stats, err := GetPlayerStats("Alice")
+	stats, err := GetPlayerStats(t.Context(), "Alice", GameFilter{})
 	require.NoError(t, err)
-	assert.Equal(t, "Alice", stats["playerName"])
-	assert.Equal(t, 3, stats["gamesPlayed"])
-	assert.Equal(t, 2, stats["wins"])
+	assert.Equal(t, "Alice", stats.PlayerName)
+	assert.Equal(t, 3, stats.GamesPlayed)
+	assert.Equal(t, 2, stats.Wins)
 
 	// Average: (100 + 95 + 110) / 3 = 305 / 3 = 101.666...
-	avgScore := stats["averageScore"].(float64)
+	avgScore := stats.AverageScore
 	assert.InDelta(t, 101.67, avgScore, 0.01)
 
 	// Win rate: 2/3 * 100 = 66.666...%
-	winRate := stats["winRate"].(float64)
+	winRate := stats.WinRate
 	assert.InDelta(t, 66.67, winRate, 0.01)
 }
 
@@ -390,22 +526,22 @@ func TestGetPlayerStats_GamesButNoWins(t *testing.T) {
 
 	for _, players := range games {
 		nectarScoring := scoring.NectarScoring{}
-		_, err := SaveGameResult(players, nectarScoring, false)
+		_, err := SaveGameResult(t.Context(), players, nectarScoring, false)
 		require.NoError(t, err)
 	}
 
-	stats, err := GetPlayerStats("Bob")
+	stats, err := GetPlayerStats(t.Context(), "Bob", GameFilter{})
 	require.NoError(t, err)
-	assert.Equal(t, "Bob", stats["playerName"])
-	assert.Equal(t, 2, stats["gamesPlayed"])
-	assert.Equal(t, 0, stats["wins"])
+	assert.Equal(t, "Bob", stats.PlayerName)
+	assert.Equal(t, 2, stats.GamesPlayed)
+	assert.Equal(t, 0, stats.Wins)
 
 	// Average: (90 + 95) / 2 = 92.5
-	avgScore := stats["averageScore"].(float64)
+	avgScore := stats.AverageScore
 	assert.Equal(t, 92.5, avgScore)
 
 	// Win rate: 0%
-	assert.Equal(t, 0.0, stats["winRate"])
+	assert.Equal(t, 0.0, stats.WinRate)
 }
 
 // TestGetPlayerStats_MultipleGames tests accurate stat calculation across many games
@@ -428,17 +564,17 @@ func TestGetPlayerStats_MultipleGames(t *testing.T) {
 			}
 		}
 		nectarScoring := scoring.NectarScoring{}
-		_, err := SaveGameResult(players, nectarScoring, false)
+		_, err := SaveGameResult(t.Context(), players, nectarScoring, false)
 		require.NoError(t, err)
 	}
 
-	stats, err := GetPlayerStats("Alice")
+	stats, err := GetPlayerStats(t.Context(), "Alice", GameFilter{})
 	require.NoError(t, err)
-	assert.Equal(t, 10, stats["gamesPlayed"])
-	assert.Equal(t, 7, stats["wins"])
+	assert.Equal(t, 10, stats.GamesPlayed)
+	assert.Equal(t, 7, stats.Wins)
 
 	// Win rate: 7/10 * 100 = 70%
-	assert.Equal(t, 70.0, stats["winRate"])
+	assert.Equal(t, 70.0, stats.WinRate)
 }
 
 // TestDeleteGameResult_Existing tests deleting an existing game
@@ -451,20 +587,20 @@ func TestDeleteGameResult_Existing(t *testing.T) {
 		{PlayerName: "Alice", Total: 100, Rank: 1},
 	}
 	nectarScoring := scoring.NectarScoring{}
-	id, err := SaveGameResult(players, nectarScoring, false)
+	id, err := SaveGameResult(t.Context(), players, nectarScoring, false)
 	require.NoError(t, err)
 
 	// Verify it exists
-	result, err := GetGameResult(id)
+	result, err := GetGameResult(t.Context(), id)
 	require.NoError(t, err)
 	assert.NotNil(t, result)
 
 	// Delete it
-	err = DeleteGameResult(id)
+	err = DeleteGameResult(t.Context(), id)
 	assert.NoError(t, err)
 
 	// Verify it no longer exists
-	result, err = GetGameResult(id)
+	result, err = GetGameResult(t.Context(), id)
 	assert.Error(t, err)
 	assert.Nil(t, result)
 }
@@ -474,7 +610,7 @@ func TestDeleteGameResult_NotFound(t *testing.T) {
 	cleanup := setupTestDB(t)
 	defer cleanup()
 
-	err := DeleteGameResult(999)
+	err := DeleteGameResult(t.Context(), 999)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "not found")
 }
@@ -491,22 +627,22 @@ func TestDeleteGameResult_UpdatesCount(t *testing.T) {
 			{PlayerName: "Alice", Total: 100, Rank: 1},
 		}
 		nectarScoring := scoring.NectarScoring{}
-		id, err := SaveGameResult(players, nectarScoring, false)
+		id, err := SaveGameResult(t.Context(), players, nectarScoring, false)
 		require.NoError(t, err)
 		ids = append(ids, id)
 	}
 
 	// Verify count
-	count, err := CountGameResults()
+	count, err := CountGameResults(t.Context())
 	require.NoError(t, err)
 	assert.Equal(t, 3, count)
 
 	// Delete one game
-	err = DeleteGameResult(ids[1])
+	err = DeleteGameResult(t.Context(), ids[1])
 	require.NoError(t, err)
 
 	// Verify count decreased
-	count, err = CountGameResults()
+	count, err = CountGameResults(t.Context())
 	require.NoError(t, err)
 	assert.Equal(t, 2, count)
 }
@@ -556,11 +692,11 @@ func TestSaveAndRetrieve_ComplexGame(t *testing.T) {
 	}
 
 	// Save the game
-	id, err := SaveGameResult(players, nectarScoring, true)
+	id, err := SaveGameResult(t.Context(), players, nectarScoring, true)
 	require.NoError(t, err)
 
 	// Retrieve the game
-	result, err := GetGameResult(id)
+	result, err := GetGameResult(t.Context(), id)
 	require.NoError(t, err)
 
 	// Verify all fields
@@ -601,7 +737,7 @@ func TestGetLeaderboardStats_Empty(t *testing.T) {
 	cleanup := setupTestDB(t)
 	defer cleanup()
 
-	leaderboard, err := GetLeaderboardStats()
+	leaderboard, err := GetLeaderboardStats(t.Context())
 	require.NoError(t, err)
 	require.NotNil(t, leaderboard)
 
@@ -648,10 +784,10 @@ func TestGetLeaderboardStats_SingleGame(t *testing.T) {
 		},
 	}
 	nectarScoring := scoring.NectarScoring{}
-	_, err := SaveGameResult(players, nectarScoring, true)
+	_, err := SaveGameResult(t.Context(), players, nectarScoring, true)
 	require.NoError(t, err)
 
-	leaderboard, err := GetLeaderboardStats()
+	leaderboard, err := GetLeaderboardStats(t.Context())
 	require.NoError(t, err)
 	require.NotNil(t, leaderboard)
 
@@ -702,7 +838,7 @@ func TestGetLeaderboardStats_MultipleGames(t *testing.T) {
 			Rank:        2,
 		},
 	}
-	_, err := SaveGameResult(game1, scoring.NectarScoring{}, false)
+	_, err := SaveGameResult(t.Context(), game1, scoring.NectarScoring{}, false)
 	require.NoError(t, err)
 
 	// Game 2: Bob wins with high total score and high eggs
@@ -730,7 +866,7 @@ func TestGetLeaderboardStats_MultipleGames(t *testing.T) {
 			Rank:        2,
 		},
 	}
-	_, err = SaveGameResult(game2, scoring.NectarScoring{}, false)
+	_, err = SaveGameResult(t.Context(), game2, scoring.NectarScoring{}, false)
 	require.NoError(t, err)
 
 	// Game 3: Carol wins with high round goals and tucked cards
@@ -758,12 +894,14 @@ func TestGetLeaderboardStats_MultipleGames(t *testing.T) {
 			Rank:        2,
 		},
 	}
-	_, err = SaveGameResult(game3, scoring.NectarScoring{}, false)
+	_, err = SaveGameResult(t.Context(), game3, scoring.NectarScoring{}, false)
 	require.NoError(t, err)
 
-	leaderboard, err := GetLeaderboardStats()
+	queries := queryDuration.Count("GetLeaderboardStats")
+	leaderboard, err := GetLeaderboardStats(t.Context())
 	require.NoError(t, err)
 	require.NotNil(t, leaderboard)
+	assert.Equal(t, queries+1, queryDuration.Count("GetLeaderboardStats"), "the leaderboard is one query")
 
 	// Verify different leaders for different categories
 	assert.Equal(t, "Carol", leaderboard.TotalScore.PlayerName)
@@ -829,10 +967,10 @@ func TestGetLeaderboardStats_WithNectar(t *testing.T) {
 		Grassland: map[string]int{"Alice": 2, "Bob": 5},
 		Wetland:   map[string]int{"Alice": 5, "Bob": 2},
 	}
-	_, err := SaveGameResult(players, nectarScoring, true)
+	_, err := SaveGameResult(t.Context(), players, nectarScoring, true)
 	require.NoError(t, err)
 
-	leaderboard, err := GetLeaderboardStats()
+	leaderboard, err := GetLeaderboardStats(t.Context())
 	require.NoError(t, err)
 	require.NotNil(t, leaderboard)
 
@@ -867,10 +1005,10 @@ func TestGetLeaderboardStats_TiedScores(t *testing.T) {
 			Rank:       2,
 		},
 	}
-	_, err := SaveGameResult(players, scoring.NectarScoring{}, false)
+	_, err := SaveGameResult(t.Context(), players, scoring.NectarScoring{}, false)
 	require.NoError(t, err)
 
-	leaderboard, err := GetLeaderboardStats()
+	leaderboard, err := GetLeaderboardStats(t.Context())
 	require.NoError(t, err)
 	require.NotNil(t, leaderboard)
 
@@ -913,11 +1051,11 @@ func TestSaveGameResult_WithRoundBreakdown(t *testing.T) {
 	nectarScoring := scoring.NectarScoring{}
 
 	// Save game with round breakdown
-	id, err := SaveGameResult(players, nectarScoring, false)
+	id, err := SaveGameResult(t.Context(), players, nectarScoring, false)
 	require.NoError(t, err)
 
 	// Retrieve and verify breakdown
-	result, err := GetGameResult(id)
+	result, err := GetGameResult(t.Context(), id)
 	require.NoError(t, err)
 	assert.NotNil(t, result.RoundBreakdown)
 
@@ -950,11 +1088,36 @@ func TestSaveGameResult_BackwardCompatibility_NoBreakdown(t *testing.T) {
 	}
 	nectarScoring := scoring.NectarScoring{}
 
-	id, err := SaveGameResult(players, nectarScoring, false)
+	id, err := SaveGameResult(t.Context(), players, nectarScoring, false)
 	require.NoError(t, err)
 
 	// Retrieve and verify breakdown is nil
-	result, err := GetGameResult(id)
+	result, err := GetGameResult(t.Context(), id)
 	require.NoError(t, err)
 	assert.Nil(t, result.RoundBreakdown) // Should be nil for games without breakdown
 }
+
+// TestSaveGames tests that a batch of games is saved all together or not at all
+func TestSaveGames(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	players := []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Total: 90, Rank: 1},
+		{PlayerName: "Bob", Total: 80, Rank: 2},
+	}
+	saved := gamesSaved.Value()
+
+	ids, err := SaveGames(t.Context(), []*GameResult{{Players: players}, {Players: players}})
+	require.NoError(t, err)
+	assert.Len(t, ids, 2)
+	assert.Equal(t, saved+2, gamesSaved.Value())
+
+	// The second game has no winner, so neither is saved
+	_, err = SaveGames(t.Context(), []*GameResult{{Players: players}, {Players: []scoring.PlayerGameEnd{{PlayerName: "Carol"}}}})
+	assert.ErrorContains(t, err, "game 2")
+	count, err := CountGameResults(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, saved+2, gamesSaved.Value())
+}
@@ -0,0 +1,240 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"wingspan-scoring/goals"
+	"wingspan-scoring/scoring"
+)
+
+// GoalRoundStats summarises a goal's points in one round on one side of the goal board
+type GoalRoundStats struct {
+	Round         int     `json:"round"`
+	Side          string  `json:"side"`
+	Appearances   int     `json:"appearances"`
+	AveragePoints float64 `json:"averagePoints"` // Per player
+}
+
+// GoalPlayerStats summarises how one player does on a goal. Performance is
+// the average difference between their points on this goal and their usual
+// points for the same round and side, so positive means they over-perform.
+type GoalPlayerStats struct {
+	PlayerName    string  `json:"playerName"`
+	Appearances   int     `json:"appearances"`
+	AveragePoints float64 `json:"averagePoints"`
+	Performance   float64 `json:"performance"`
+}
+
+// GoalStats summarises results for one round goal
+type GoalStats struct {
+	GoalID      string            `json:"goalId"`
+	Name        string            `json:"name"`
+	Expansion   string            `json:"expansion"`
+	Appearances int               `json:"appearances"`
+	Rounds      []GoalRoundStats  `json:"rounds"`
+	Players     []GoalPlayerStats `json:"players"`
+}
+
+// goalResult is one player's points from one round goal
+type goalResult struct {
+	gameID int64
+	goalID string
+	round  int
+	side   string
+	player string
+	points int
+}
+
+// roundPoints returns a player's points for a round (1-4) from their breakdown
+func roundPoints(breakdown *scoring.RoundGoalBreakdown, round int) int {
+	switch round {
+	case 1:
+		return breakdown.Round1
+	case 2:
+		return breakdown.Round2
+	case 3:
+		return breakdown.Round3
+	default:
+		return breakdown.Round4
+	}
+}
+
+// GetGoalAnalytics summarises round goal results across games matching the
+// filter that recorded their goals. Points come from each player's per-round
+// breakdown; games without one still count towards appearances.
+func GetGoalAnalytics(ctx context.Context, filter GameFilter) ([]GoalStats, error) {
+	ctx = named(ctx, "GetGoalAnalytics")
+	it, err := IterateGameResults(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query games: %w", err)
+	}
+	defer it.Close()
+
+	appearances := make(map[string]int)
+	var results []goalResult
+	for it.Next() {
+		game := it.GameResult()
+		if game.Goals == nil {
+			continue
+		}
+
+		seen := make(map[string]bool)
+		for i, goalID := range game.Goals.Rounds {
+			if goalID == "" {
+				continue
+			}
+			if !seen[goalID] {
+				seen[goalID] = true
+				appearances[goalID]++
+			}
+
+			for _, p := range game.Players {
+				breakdown := p.RoundGoalsBreakdown
+				if breakdown == nil {
+					breakdown = game.RoundBreakdown[p.PlayerName]
+				}
+				if breakdown == nil {
+					continue
+				}
+				results = append(results, goalResult{
+					gameID: game.ID,
+					goalID: goalID,
+					round:  i + 1,
+					side:   game.Goals.Side,
+					player: p.PlayerName,
+					points: roundPoints(breakdown, i+1),
+				})
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	return summarizeGoals(appearances, results), nil
+}
+
+// summarizeGoals aggregates goal results into per-goal stats
+func summarizeGoals(appearances map[string]int, results []goalResult) []GoalStats {
+	type tally struct {
+		count  int
+		points int
+		delta  float64
+		games  map[int64]bool
+	}
+	type slot struct {
+		player string
+		round  int
+		side   string
+	}
+	type roundKey struct {
+		goalID string
+		round  int
+		side   string
+	}
+	type playerKey struct {
+		goalID string
+		player string
+	}
+
+	// Each player's usual points for a round and side, across all goals
+	baselines := make(map[slot]*tally)
+	for _, r := range results {
+		key := slot{r.player, r.round, r.side}
+		if baselines[key] == nil {
+			baselines[key] = &tally{}
+		}
+		baselines[key].count++
+		baselines[key].points += r.points
+	}
+
+	rounds := make(map[roundKey]*tally)
+	players := make(map[playerKey]*tally)
+	for _, r := range results {
+		baseline := baselines[slot{r.player, r.round, r.side}]
+		usual := float64(baseline.points) / float64(baseline.count)
+
+		rk := roundKey{r.goalID, r.round, r.side}
+		if rounds[rk] == nil {
+			rounds[rk] = &tally{games: make(map[int64]bool)}
+		}
+		rounds[rk].games[r.gameID] = true
+		rounds[rk].count++
+		rounds[rk].points += r.points
+
+		pk := playerKey{r.goalID, r.player}
+		if players[pk] == nil {
+			players[pk] = &tally{}
+		}
+		players[pk].count++
+		players[pk].points += r.points
+		players[pk].delta += float64(r.points) - usual
+	}
+
+	catalog := make(map[string]goals.Goal)
+	for _, g := range goals.GetAllGoals(true, true, true) {
+		catalog[g.ID] = g
+	}
+
+	byGoal := make(map[string]*GoalStats)
+	stats := make([]*GoalStats, 0, len(appearances))
+	for goalID, count := range appearances {
+		g := catalog[goalID]
+		s := &GoalStats{
+			GoalID:      goalID,
+			Name:        g.Name,
+			Expansion:   g.Expansion,
+			Appearances: count,
+			Rounds:      []GoalRoundStats{},
+			Players:     []GoalPlayerStats{},
+		}
+		byGoal[goalID] = s
+		stats = append(stats, s)
+	}
+
+	for rk, t := range rounds {
+		s := byGoal[rk.goalID]
+		s.Rounds = append(s.Rounds, GoalRoundStats{
+			Round:         rk.round,
+			Side:          rk.side,
+			Appearances:   len(t.games),
+			AveragePoints: float64(t.points) / float64(t.count),
+		})
+	}
+	for pk, t := range players {
+		s := byGoal[pk.goalID]
+		n := float64(t.count)
+		s.Players = append(s.Players, GoalPlayerStats{
+			PlayerName:    pk.player,
+			Appearances:   t.count,
+			AveragePoints: float64(t.points) / n,
+			Performance:   t.delta / n,
+		})
+	}
+
+	result := make([]GoalStats, 0, len(stats))
+	for _, s := range stats {
+		sort.Slice(s.Rounds, func(i, j int) bool {
+			if s.Rounds[i].Round != s.Rounds[j].Round {
+				return s.Rounds[i].Round < s.Rounds[j].Round
+			}
+			return s.Rounds[i].Side < s.Rounds[j].Side
+		})
+		sort.Slice(s.Players, func(i, j int) bool {
+			if s.Players[i].Performance != s.Players[j].Performance {
+				return s.Players[i].Performance > s.Players[j].Performance
+			}
+			return s.Players[i].PlayerName < s.Players[j].PlayerName
+		})
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Appearances != result[j].Appearances {
+			return result[i].Appearances > result[j].Appearances
+		}
+		return result[i].GoalID < result[j].GoalID
+	})
+
+	return result
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"testing"
+	"wingspan-scoring/scoring"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// saveGoalGame saves a game played with the given goals, giving each player
+// their round 1 points
+func saveGoalGame(t *testing.T, side string, rounds [4]string, alice, bob int) {
+	t.Helper()
+	_, err := SaveGame(t.Context(), &GameResult{
+		Players: []scoring.PlayerGameEnd{
+			{PlayerName: "Alice", Total: 50, Rank: 1, RoundGoalsBreakdown: &scoring.RoundGoalBreakdown{Round1: alice}},
+			{PlayerName: "Bob", Total: 40, Rank: 2, RoundGoalsBreakdown: &scoring.RoundGoalBreakdown{Round1: bob}},
+		},
+		Goals: &GameGoals{Side: side, Rounds: rounds},
+	})
+	require.NoError(t, err)
+}
+
+// TestSaveGame_Goals tests that a game's goals round-trip through the database
+func TestSaveGame_Goals(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	rounds := [4]string{"base-birds-forest", "base-birds-grassland", "", "base-birds-wetland"}
+	saveGoalGame(t, "green", rounds, 4, 1)
+
+	games, err := GetAllGameResults(t.Context(), 0, 0)
+	require.NoError(t, err)
+	require.Len(t, games, 1)
+	require.NotNil(t, games[0].Goals)
+	assert.Equal(t, "green", games[0].Goals.Side)
+	assert.Equal(t, rounds, games[0].Goals.Rounds)
+
+	game, err := GetGameResult(t.Context(), games[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, rounds, game.Goals.Rounds)
+}
+
+// TestGetGoalAnalytics tests appearances, per-round averages and player performance
+func TestGetGoalAnalytics(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	saveGoalGame(t, "green", [4]string{"base-birds-forest", "base-birds-grassland", "base-birds-wetland", ""}, 4, 1)
+	saveGoalGame(t, "green", [4]string{"base-birds-grassland", "base-birds-forest", "base-birds-wetland", ""}, 2, 3)
+	// Games without goals are skipped
+	saveGameOn(t, "2024-01-01", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 50, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 40, Rank: 2})
+
+	analytics, err := GetGoalAnalytics(t.Context(), GameFilter{})
+	require.NoError(t, err)
+	require.Len(t, analytics, 3)
+	assert.Equal(t, "base-birds-forest", analytics[0].GoalID)
+	assert.Equal(t, "base", analytics[0].Expansion)
+	assert.NotEmpty(t, analytics[0].Name)
+
+	forest := analytics[0]
+	assert.Equal(t, 2, forest.Appearances)
+	require.Len(t, forest.Rounds, 2)
+	assert.Equal(t, GoalRoundStats{Round: 1, Side: "green", Appearances: 1, AveragePoints: 2.5}, forest.Rounds[0])
+	assert.Equal(t, GoalRoundStats{Round: 2, Side: "green", Appearances: 1, AveragePoints: 0}, forest.Rounds[1])
+
+	// Alice usually scores 3 in round 1 and scored 4 on forest; Bob usually 2 and scored 1
+	require.Len(t, forest.Players, 2)
+	assert.Equal(t, "Alice", forest.Players[0].PlayerName)
+	assert.Equal(t, 2, forest.Players[0].Appearances)
+	assert.Equal(t, 2.0, forest.Players[0].AveragePoints)
+	assert.Equal(t, 0.5, forest.Players[0].Performance)
+	assert.Equal(t, "Bob", forest.Players[1].PlayerName)
+	assert.Equal(t, -0.5, forest.Players[1].Performance)
+
+	wetland := analytics[2]
+	assert.Equal(t, "base-birds-wetland", wetland.GoalID)
+	assert.Equal(t, 0.0, wetland.Players[0].Performance)
+}
+
+// TestGetGoalAnalytics_NoGoals tests that games without goals give empty analytics
+func TestGetGoalAnalytics_NoGoals(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	saveGameOn(t, "2024-01-01", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 50, Rank: 1})
+
+	analytics, err := GetGoalAnalytics(t.Context(), GameFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, analytics)
+}
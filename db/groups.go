@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultGroup is the group games belong to when none is selected. Databases
+// from before groups existed have all their data in it.
+const DefaultGroup = "default"
+
+// ErrGroupNotFound is returned when a group slug doesn't exist
+var ErrGroupNotFound = errors.New("group not found")
+
+// groupSlugPattern is the format of group slugs, which appear in URLs
+var groupSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,39}$`)
+
+// Group is a tenant, such as a family or club, whose games, players,
+// seasons and stats are kept apart from every other group's
+type Group struct {
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ValidGroupSlug reports whether slug can name a group: 1-40 lowercase
+// letters, digits or hyphens, not starting with a hyphen
+func ValidGroupSlug(slug string) bool {
+	return groupSlugPattern.MatchString(slug)
+}
+
+// Validate checks the group's slug and name
+func (g *Group) Validate() error {
+	g.Slug = strings.TrimSpace(g.Slug)
+	g.Name = strings.TrimSpace(g.Name)
+	if !ValidGroupSlug(g.Slug) {
+		return fmt.Errorf("invalid group slug: %s", g.Slug)
+	}
+	if g.Name == "" {
+		return fmt.Errorf("group name is required")
+	}
+	return nil
+}
+
+// CreateGroup validates and saves a new group
+func CreateGroup(ctx context.Context, group *Group) error {
+	ctx = named(ctx, "CreateGroup")
+	if err := group.Validate(); err != nil {
+		return err
+	}
+
+	exists, err := GroupExists(ctx, group.Slug)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("group already exists: %s", group.Slug)
+	}
+
+	if _, err := DB.ExecContext(ctx, `INSERT INTO groups (slug, name) VALUES (?, ?)`, group.Slug, group.Name); err != nil {
+		return fmt.Errorf("failed to insert group: %w", err)
+	}
+	return nil
+}
+
+// GetGroup retrieves a group by slug
+func GetGroup(ctx context.Context, slug string) (*Group, error) {
+	ctx = named(ctx, "GetGroup")
+	var group Group
+	err := DB.QueryRowContext(ctx, `SELECT slug, name, created_at FROM groups WHERE slug = ?`, slug).
+		Scan(&group.Slug, &group.Name, &group.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrGroupNotFound
+		}
+		return nil, fmt.Errorf("failed to query group: %w", err)
+	}
+	return &group, nil
+}
+
+// GroupExists reports whether a group with the slug exists
+func GroupExists(ctx context.Context, slug string) (bool, error) {
+	ctx = named(ctx, "GroupExists")
+	var exists bool
+	err := DB.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM groups WHERE slug = ?)`, slug).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check group: %w", err)
+	}
+	return exists, nil
+}
+
+// GetGroups lists every group by slug
+func GetGroups(ctx context.Context) ([]Group, error) {
+	ctx = named(ctx, "GetGroups")
+	rows, err := DB.QueryContext(ctx, `SELECT slug, name, created_at FROM groups ORDER BY slug`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query groups: %w", err)
+	}
+	defer rows.Close()
+
+	groups := []Group{}
+	for rows.Next() {
+		var group Group
+		if err := rows.Scan(&group.Slug, &group.Name, &group.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan group: %w", err)
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+// gameGroups lists the groups that have saved games
+func gameGroups(ctx context.Context, q querier) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `SELECT DISTINCT group_id FROM game_results ORDER BY group_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query game groups: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []string
+	for rows.Next() {
+		var group string
+		if err := rows.Scan(&group); err != nil {
+			return nil, fmt.Errorf("failed to scan game group: %w", err)
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+// groupOrDefault returns group, or DefaultGroup when it's empty
+func groupOrDefault(group string) string {
+	if group == "" {
+		return DefaultGroup
+	}
+	return group
+}
@@ -0,0 +1,201 @@
+package db
+
+import (
+	"testing"
+	"wingspan-scoring/scoring"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// saveGroupGame saves a game to a group
+func saveGroupGame(t *testing.T, group string, players ...scoring.PlayerGameEnd) int64 {
+	t.Helper()
+	id, err := SaveGame(t.Context(), &GameResult{Players: players, Group: group})
+	require.NoError(t, err)
+	return id
+}
+
+// TestCreateGroup tests creating, listing and validating groups
+func TestCreateGroup(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	groups, err := GetGroups(t.Context())
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	assert.Equal(t, DefaultGroup, groups[0].Slug)
+
+	require.NoError(t, CreateGroup(t.Context(), &Group{Slug: "club", Name: " Bird Club "}))
+
+	group, err := GetGroup(t.Context(), "club")
+	require.NoError(t, err)
+	assert.Equal(t, "Bird Club", group.Name)
+	assert.False(t, group.CreatedAt.IsZero())
+
+	assert.Error(t, CreateGroup(t.Context(), &Group{Slug: "club", Name: "Again"}))
+	assert.Error(t, CreateGroup(t.Context(), &Group{Slug: "Not Valid", Name: "Bad"}))
+	assert.Error(t, CreateGroup(t.Context(), &Group{Slug: "-club", Name: "Bad"}))
+	assert.Error(t, CreateGroup(t.Context(), &Group{Slug: "family"}))
+
+	_, err = GetGroup(t.Context(), "missing")
+	assert.ErrorIs(t, err, ErrGroupNotFound)
+
+	exists, err := GroupExists(t.Context(), "club")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+// TestGroupIsolation tests that games and everything derived from them stay
+// within their group
+func TestGroupIsolation(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	require.NoError(t, CreateGroup(t.Context(), &Group{Slug: "club", Name: "Club"}))
+
+	saveGroupGame(t, "",
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 90, Rank: 1, BirdPoints: 50},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 70, Rank: 2})
+	clubGame := saveGroupGame(t, "club",
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 120, Rank: 1, BirdPoints: 80},
+		scoring.PlayerGameEnd{PlayerName: "Carol", Total: 60, Rank: 2})
+
+	game, err := GetGameResult(t.Context(), clubGame)
+	require.NoError(t, err)
+	assert.Equal(t, "club", game.Group)
+
+	defaultGames, err := GetAllGameResults(t.Context(), 10, 0)
+	require.NoError(t, err)
+	require.Len(t, defaultGames, 1)
+	assert.Equal(t, DefaultGroup, defaultGames[0].Group)
+
+	clubCount, err := CountFilteredGameResults(t.Context(), GameFilter{Group: "club"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, clubCount)
+
+	leaderboard, err := GetLeaderboardStats(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 90, leaderboard.TotalScore.Score)
+	clubLeaderboard, err := GetFilteredLeaderboardStats(t.Context(), GameFilter{Group: "club"})
+	require.NoError(t, err)
+	assert.Equal(t, 120, clubLeaderboard.TotalScore.Score)
+
+	stats, err := GetPlayerStats(t.Context(), "Alice", GameFilter{Group: "club"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.GamesPlayed)
+	assert.Equal(t, 120.0, stats.AverageScore)
+
+	stats, err = GetPlayerStats(t.Context(), "Carol", GameFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.GamesPlayed)
+
+	// Ratings are computed per group, so Alice has played once in each
+	ratings, err := GetRatings(t.Context(), DefaultGroup)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Alice", "Bob"}, ratingNames(ratings))
+	clubRatings, err := GetRatings(t.Context(), "club")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Alice", "Carol"}, ratingNames(clubRatings))
+	for _, r := range append(ratings, clubRatings...) {
+		assert.Equal(t, 1, r.GamesPlayed)
+	}
+
+	history, err := GetRatingHistory(t.Context(), "club", "Alice")
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, clubGame, history[0].GameID)
+	assert.Equal(t, InitialRating, history[0].RatingBefore)
+
+	achievements, err := GetPlayerAchievements(t.Context(), "club", "Carol")
+	require.NoError(t, err)
+	assert.Empty(t, achievements)
+	achievements, err = GetPlayerAchievements(t.Context(), "club", "Alice")
+	require.NoError(t, err)
+	require.NotEmpty(t, achievements)
+	for _, a := range achievements {
+		assert.Equal(t, clubGame, a.GameID)
+	}
+}
+
+// TestGroupIsolation_SeasonsAndTournaments tests that seasons and
+// tournaments are listed per group and can't take in other groups' games
+func TestGroupIsolation_SeasonsAndTournaments(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	require.NoError(t, CreateGroup(t.Context(), &Group{Slug: "club", Name: "Club"}))
+
+	clubSeason := &Season{Name: "Club League", StartDate: "2000-01-01", EndDate: "2100-12-31", Group: "club"}
+	_, err := CreateSeason(t.Context(), clubSeason)
+	require.NoError(t, err)
+
+	saveGroupGame(t, "",
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 90, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 70, Rank: 2})
+	defaultGame := saveGroupGame(t, "",
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 80, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 75, Rank: 2})
+	saveGroupGame(t, "club",
+		scoring.PlayerGameEnd{PlayerName: "Dan", Total: 100, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Erin", Total: 95, Rank: 2})
+
+	seasons, err := GetSeasons(t.Context(), DefaultGroup)
+	require.NoError(t, err)
+	assert.Empty(t, seasons)
+	seasons, err = GetSeasons(t.Context(), "club")
+	require.NoError(t, err)
+	require.Len(t, seasons, 1)
+
+	// The season covers every date but only the club's game
+	standings, err := GetSeasonStandings(t.Context(), clubSeason.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, standings.Games)
+	require.Len(t, standings.Standings, 2)
+	assert.Equal(t, "Dan", standings.Standings[0].PlayerName)
+
+	assert.ErrorIs(t, SetGameSeason(t.Context(), defaultGame, &clubSeason.ID), ErrSeasonNotFound)
+
+	tournament := &Tournament{Name: "Club Cup", TableSize: 3, Group: "club"}
+	_, err = CreateTournament(t.Context(), tournament)
+	require.NoError(t, err)
+
+	tournaments, err := GetTournaments(t.Context(), DefaultGroup)
+	require.NoError(t, err)
+	assert.Empty(t, tournaments)
+	tournaments, err = GetTournaments(t.Context(), "club")
+	require.NoError(t, err)
+	require.Len(t, tournaments, 1)
+
+	for _, name := range []string{"Dan", "Erin", "Fay"} {
+		_, err := RegisterTournamentPlayer(t.Context(), tournament.ID, name)
+		require.NoError(t, err)
+	}
+	tables, err := StartTournamentRound(t.Context(), tournament.ID)
+	require.NoError(t, err)
+	require.Len(t, tables, 1)
+
+	players := make([]scoring.PlayerGameEnd, len(tables[0].Players))
+	for i, name := range tables[0].Players {
+		players[i] = scoring.PlayerGameEnd{PlayerName: name, Total: 100 - i, Rank: i + 1}
+	}
+	gameID, err := RecordTournamentResult(t.Context(), tournament.ID, tables[0].ID, &GameResult{Players: players})
+	require.NoError(t, err)
+
+	game, err := GetGameResult(t.Context(), gameID)
+	require.NoError(t, err)
+	assert.Equal(t, "club", game.Group)
+
+	count, err := CountGameResults(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+// ratingNames lists the players in a set of ratings
+func ratingNames(ratings []PlayerRating) []string {
+	names := make([]string, len(ratings))
+	for i, r := range ratings {
+		names[i] = r.PlayerName
+	}
+	return names
+}
@@ -0,0 +1,202 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+	"wingspan-scoring/scoring"
+)
+
+// recentHeadToHeadGames is how many of the latest shared games a head-to-head includes
+const recentHeadToHeadGames = 10
+
+// CategoryAdvantage compares two players in one scoring category across their shared games
+type CategoryAdvantage struct {
+	AAhead        int     `json:"aAhead"`
+	BAhead        int     `json:"bAhead"`
+	Ties          int     `json:"ties"`
+	AverageMargin float64 `json:"averageMargin"` // A minus B
+}
+
+// HeadToHeadGame is one game two players both played
+type HeadToHeadGame struct {
+	GameID   int64     `json:"gameId"`
+	PlayedAt time.Time `json:"playedAt"`
+	AScore   int       `json:"aScore"`
+	BScore   int       `json:"bScore"`
+	ARank    int       `json:"aRank"`
+	BRank    int       `json:"bRank"`
+	Ahead    string    `json:"ahead"` // Name of the player who finished ahead, empty on a shared rank
+}
+
+// HeadToHead compares two players across the games they played together
+type HeadToHead struct {
+	PlayerA       string                       `json:"playerA"`
+	PlayerB       string                       `json:"playerB"`
+	GamesTogether int                          `json:"gamesTogether"`
+	AAhead        int                          `json:"aAhead"`
+	BAhead        int                          `json:"bAhead"`
+	Ties          int                          `json:"ties"`
+	AverageMargin float64                      `json:"averageMargin"` // A's total minus B's
+	Categories    map[string]CategoryAdvantage `json:"categories"`
+	RecentResults []HeadToHeadGame             `json:"recentResults"`
+}
+
+// HeadToHeadMatrix holds every pairing in the group. Ahead[i][j] counts the
+// games Players[i] finished ahead of Players[j]; Games[i][j] counts the games
+// they played together.
+type HeadToHeadMatrix struct {
+	Players []string `json:"players"`
+	Ahead   [][]int  `json:"ahead"`
+	Games   [][]int  `json:"games"`
+}
+
+// findPlayer returns the named player's result in a game
+func findPlayer(players []scoring.PlayerGameEnd, name string) (scoring.PlayerGameEnd, bool) {
+	for _, p := range players {
+		if p.PlayerName == name {
+			return p, true
+		}
+	}
+	return scoring.PlayerGameEnd{}, false
+}
+
+// GetHeadToHead compares two players across games matching the filter in
+// which both appeared. Finishing ahead is decided by rank.
+func GetHeadToHead(ctx context.Context, playerA, playerB string, filter GameFilter) (*HeadToHead, error) {
+	ctx = named(ctx, "GetHeadToHead")
+	filter.Players = append(append([]string{}, filter.Players...), playerA, playerB)
+
+	it, err := IterateGameResults(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shared games: %w", err)
+	}
+	defer it.Close()
+
+	h2h := &HeadToHead{
+		PlayerA:       playerA,
+		PlayerB:       playerB,
+		Categories:    make(map[string]CategoryAdvantage),
+		RecentResults: []HeadToHeadGame{},
+	}
+	marginTotals := make(map[string]int)
+	totalMargin := 0
+
+	for it.Next() {
+		game := it.GameResult()
+		a, okA := findPlayer(game.Players, playerA)
+		b, okB := findPlayer(game.Players, playerB)
+		if !okA || !okB {
+			continue
+		}
+
+		h2h.GamesTogether++
+		totalMargin += a.Total - b.Total
+
+		result := HeadToHeadGame{
+			GameID:   game.ID,
+			PlayedAt: game.CreatedAt,
+			AScore:   a.Total,
+			BScore:   b.Total,
+			ARank:    a.Rank,
+			BRank:    b.Rank,
+		}
+		switch {
+		case a.Rank < b.Rank:
+			h2h.AAhead++
+			result.Ahead = playerA
+		case b.Rank < a.Rank:
+			h2h.BAhead++
+			result.Ahead = playerB
+		default:
+			h2h.Ties++
+		}
+		// Games arrive newest first
+		if len(h2h.RecentResults) < recentHeadToHeadGames {
+			h2h.RecentResults = append(h2h.RecentResults, result)
+		}
+
+		for _, category := range ScoreCategories {
+			adv := h2h.Categories[category.Key]
+			valueA, valueB := category.Value(a), category.Value(b)
+			switch {
+			case valueA > valueB:
+				adv.AAhead++
+			case valueB > valueA:
+				adv.BAhead++
+			default:
+				adv.Ties++
+			}
+			marginTotals[category.Key] += valueA - valueB
+			h2h.Categories[category.Key] = adv
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	if h2h.GamesTogether > 0 {
+		n := float64(h2h.GamesTogether)
+		h2h.AverageMargin = float64(totalMargin) / n
+		for key, adv := range h2h.Categories {
+			adv.AverageMargin = float64(marginTotals[key]) / n
+			h2h.Categories[key] = adv
+		}
+	}
+
+	return h2h, nil
+}
+
+// GetHeadToHeadMatrix compares every pair of players across games matching the filter
+func GetHeadToHeadMatrix(ctx context.Context, filter GameFilter) (*HeadToHeadMatrix, error) {
+	ctx = named(ctx, "GetHeadToHeadMatrix")
+	it, err := IterateGameResults(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query games: %w", err)
+	}
+	defer it.Close()
+
+	type pair struct{ a, b string }
+	ahead := make(map[pair]int)
+	together := make(map[pair]int)
+	seen := make(map[string]bool)
+
+	for it.Next() {
+		players := it.GameResult().Players
+		for _, a := range players {
+			seen[a.PlayerName] = true
+			for _, b := range players {
+				if a.PlayerName == b.PlayerName {
+					continue
+				}
+				together[pair{a.PlayerName, b.PlayerName}]++
+				if a.Rank < b.Rank {
+					ahead[pair{a.PlayerName, b.PlayerName}]++
+				}
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	matrix := &HeadToHeadMatrix{Players: make([]string, 0, len(seen))}
+	for name := range seen {
+		matrix.Players = append(matrix.Players, name)
+	}
+	sort.Strings(matrix.Players)
+
+	matrix.Ahead = make([][]int, len(matrix.Players))
+	matrix.Games = make([][]int, len(matrix.Players))
+	for i, a := range matrix.Players {
+		matrix.Ahead[i] = make([]int, len(matrix.Players))
+		matrix.Games[i] = make([]int, len(matrix.Players))
+		for j, b := range matrix.Players {
+			matrix.Ahead[i][j] = ahead[pair{a, b}]
+			matrix.Games[i][j] = together[pair{a, b}]
+		}
+	}
+
+	return matrix, nil
+}
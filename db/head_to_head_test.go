@@ -0,0 +1,113 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"wingspan-scoring/scoring"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetHeadToHead_NoSharedGames tests two players who never played together
+func TestGetHeadToHead_NoSharedGames(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	saveGameOn(t, "2024-01-01", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 90, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Carol", Total: 80, Rank: 2})
+
+	h2h, err := GetHeadToHead(t.Context(), "Alice", "Bob", GameFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, h2h.GamesTogether)
+	assert.Equal(t, 0.0, h2h.AverageMargin)
+	assert.Empty(t, h2h.RecentResults)
+}
+
+// TestGetHeadToHead_SharedGames tests finishing order, margins and category advantages
+func TestGetHeadToHead_SharedGames(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	g1 := saveGameOn(t, "2024-01-01", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", BirdPoints: 40, Eggs: 10, Total: 100, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", BirdPoints: 30, Eggs: 12, Total: 90, Rank: 2})
+	g2 := saveGameOn(t, "2024-01-02", false,
+		scoring.PlayerGameEnd{PlayerName: "Carol", Total: 110, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", BirdPoints: 35, Eggs: 8, Total: 95, Rank: 2},
+		scoring.PlayerGameEnd{PlayerName: "Alice", BirdPoints: 35, Eggs: 5, Total: 80, Rank: 3})
+	g3 := saveGameOn(t, "2024-01-03", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", BirdPoints: 20, Eggs: 6, Total: 85, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", BirdPoints: 25, Eggs: 6, Total: 85, Rank: 1})
+	// Alicia is not Alice, so this game doesn't count
+	saveGameOn(t, "2024-01-04", false,
+		scoring.PlayerGameEnd{PlayerName: "Alicia", Total: 100, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 90, Rank: 2})
+
+	h2h, err := GetHeadToHead(t.Context(), "Alice", "Bob", GameFilter{})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, h2h.GamesTogether)
+	assert.Equal(t, 1, h2h.AAhead)
+	assert.Equal(t, 1, h2h.BAhead)
+	assert.Equal(t, 1, h2h.Ties)
+	// (10 - 15 + 0) / 3
+	assert.InDelta(t, -1.667, h2h.AverageMargin, 0.001)
+
+	birds := h2h.Categories["birdPoints"]
+	assert.Equal(t, 1, birds.AAhead)
+	assert.Equal(t, 1, birds.BAhead)
+	assert.Equal(t, 1, birds.Ties)
+	assert.InDelta(t, 1.667, birds.AverageMargin, 0.001)
+
+	eggs := h2h.Categories["eggs"]
+	assert.Equal(t, 0, eggs.AAhead)
+	assert.Equal(t, 2, eggs.BAhead)
+
+	require.Len(t, h2h.RecentResults, 3)
+	assert.Equal(t, []int64{g3, g2, g1}, []int64{h2h.RecentResults[0].GameID, h2h.RecentResults[1].GameID, h2h.RecentResults[2].GameID})
+	assert.Equal(t, "", h2h.RecentResults[0].Ahead)
+	assert.Equal(t, "Bob", h2h.RecentResults[1].Ahead)
+	assert.Equal(t, 95, h2h.RecentResults[1].BScore)
+	assert.Equal(t, "Alice", h2h.RecentResults[2].Ahead)
+}
+
+// TestGetHeadToHead_RecentResultsLimit tests only the latest shared games are listed
+func TestGetHeadToHead_RecentResultsLimit(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	for i := 0; i < recentHeadToHeadGames+2; i++ {
+		saveGameOn(t, fmt.Sprintf("2024-01-%02d", i+1), false,
+			scoring.PlayerGameEnd{PlayerName: "Alice", Total: 90, Rank: 1},
+			scoring.PlayerGameEnd{PlayerName: "Bob", Total: 80, Rank: 2})
+	}
+
+	h2h, err := GetHeadToHead(t.Context(), "Alice", "Bob", GameFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, recentHeadToHeadGames+2, h2h.GamesTogether)
+	assert.Len(t, h2h.RecentResults, recentHeadToHeadGames)
+	assert.Equal(t, 12, h2h.RecentResults[0].PlayedAt.Day())
+}
+
+// TestGetHeadToHeadMatrix tests finishing-ahead counts for every pairing
+func TestGetHeadToHeadMatrix(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	saveGameOn(t, "2024-01-01", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 100, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 90, Rank: 2},
+		scoring.PlayerGameEnd{PlayerName: "Carol", Total: 80, Rank: 3})
+	saveGameOn(t, "2024-01-02", false,
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 100, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 90, Rank: 2})
+
+	matrix, err := GetHeadToHeadMatrix(t.Context(), GameFilter{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Alice", "Bob", "Carol"}, matrix.Players)
+	assert.Equal(t, [][]int{{0, 1, 1}, {1, 0, 1}, {0, 0, 0}}, matrix.Ahead)
+	assert.Equal(t, [][]int{{0, 2, 1}, {2, 0, 1}, {1, 1, 0}}, matrix.Games)
+}
@@ -0,0 +1,235 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"time"
+	"wingspan-scoring/metrics"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+	"modernc.org/sqlite"
+)
+
+var (
+	queryDuration = metrics.NewHistogram("wingspan_db_query_duration_seconds",
+		"Time spent running database statements, including reading their rows, by db function.",
+		metrics.DefaultBuckets, "query")
+	queryErrors = metrics.NewCounter("wingspan_db_query_errors_total",
+		"Database statements that failed, by db function.", "query")
+	gamesSaved = metrics.NewCounter("wingspan_games_saved_total",
+		"Games saved, whether entered or imported.")
+)
+
+// tracer creates a span per statement; it does nothing until a provider is set up
+var tracer = otel.Tracer("wingspan-scoring/db")
+
+// observedConnector opens SQLite connections whose statements are timed and traced
+type observedConnector struct {
+	driver.Connector
+}
+
+// newObservedConnector returns a connector for the database at dsn
+func newObservedConnector(dsn string) (driver.Connector, error) {
+	connector, err := sqlite.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return observedConnector{connector}, nil
+}
+
+// Connect opens a connection and wraps it
+func (c observedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &observedConn{conn.(sqliteConn)}, nil
+}
+
+// sqliteConn is what the SQLite driver's connections implement and
+// database/sql looks for
+type sqliteConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+// observedConn times the statements run on a connection
+type observedConn struct {
+	sqliteConn
+}
+
+// Prepare prepares a statement whose runs are timed
+func (c *observedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext prepares a statement whose runs are timed
+func (c *observedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.sqliteConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &observedStmt{stmt.(sqliteStmt), queryName(ctx), query}, nil
+}
+
+// Begin starts a transaction
+func (c *observedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// ExecContext runs a statement and records how long it took
+func (c *observedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start, name := time.Now(), queryName(ctx)
+	ctx, span := startSpan(ctx, name, query)
+	result, err := c.sqliteConn.ExecContext(ctx, query, args)
+	observe(span, name, start, err)
+	return result, err
+}
+
+// QueryContext runs a query, recording how long it took once its rows are closed
+func (c *observedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start, name := time.Now(), queryName(ctx)
+	ctx, span := startSpan(ctx, name, query)
+	rows, err := c.sqliteConn.QueryContext(ctx, query, args)
+	if err != nil {
+		observe(span, name, start, err)
+		return nil, err
+	}
+	return &observedRows{Rows: rows, name: name, start: start, span: span}, nil
+}
+
+// sqliteStmt is what the SQLite driver's statements implement
+type sqliteStmt interface {
+	driver.Stmt
+	driver.StmtExecContext
+	driver.StmtQueryContext
+}
+
+// observedStmt times the runs of a prepared statement under the name of
+// the function that prepared it
+type observedStmt struct {
+	sqliteStmt
+	name  string
+	query string
+}
+
+// Exec runs the statement
+func (s *observedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+// Query runs the statement
+func (s *observedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+// ExecContext runs the statement and records how long it took
+func (s *observedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	ctx, span := startSpan(ctx, s.name, s.query)
+	result, err := s.sqliteStmt.ExecContext(ctx, args)
+	observe(span, s.name, start, err)
+	return result, err
+}
+
+// QueryContext runs the statement, recording how long it took once its rows are closed
+func (s *observedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	ctx, span := startSpan(ctx, s.name, s.query)
+	rows, err := s.sqliteStmt.QueryContext(ctx, args)
+	if err != nil {
+		observe(span, s.name, start, err)
+		return nil, err
+	}
+	return &observedRows{Rows: rows, name: s.name, start: start, span: span}, nil
+}
+
+// namedValues converts positional arguments for the context methods
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+// observedRows records the query's time and ends its span when the rows
+// are closed, since SQLite does much of its work while they're read
+type observedRows struct {
+	driver.Rows
+	name  string
+	start time.Time
+	span  trace.Span
+	err   error
+}
+
+// Next reads the next row, remembering any error
+func (r *observedRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err != nil && !errors.Is(err, io.EOF) {
+		r.err = err
+	}
+	return err
+}
+
+// Close closes the rows and records the query
+func (r *observedRows) Close() error {
+	err := r.Rows.Close()
+	observe(r.span, r.name, r.start, errors.Join(r.err, err))
+	return err
+}
+
+// startSpan starts a span named after the db function running query. Only
+// statements run within a trace, such as a request's, get one.
+func startSpan(ctx context.Context, name, query string) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return tracer.Start(ctx, "db."+name, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemNameSQLite, semconv.DBOperationName(name), semconv.DBQueryText(query)))
+}
+
+// observe records a statement that started at start and ends its span
+func observe(span trace.Span, name string, start time.Time, err error) {
+	queryDuration.Observe(time.Since(start).Seconds(), name)
+	if err != nil {
+		queryErrors.Inc(name)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// queryNameKey keys the db function name in a statement's context
+type queryNameKey struct{}
+
+// named labels the statements run with ctx with the name of the db function
+// running them, such as GetFilteredLeaderboardStats. Every exported function
+// names its context first thing. A name already set by a db function further
+// out is kept, so statements are counted under the function called from
+// outside the package.
+func named(ctx context.Context, name string) context.Context {
+	if _, ok := ctx.Value(queryNameKey{}).(string); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, queryNameKey{}, name)
+}
+
+// queryName returns the db function name ctx was labelled with
+func queryName(ctx context.Context) string {
+	if name, ok := ctx.Value(queryNameKey{}).(string); ok {
+		return name
+	}
+	return "unknown"
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"sync"
+	"testing"
+	"wingspan-scoring/scoring"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	spanExporter    = tracetest.NewInMemoryExporter()
+	installExporter sync.Once
+)
+
+// TestObservedQueries tests timing statements under the db function that ran them
+func TestObservedQueries(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	saves, reads := queryDuration.Count("SaveGame"), queryDuration.Count("GetAllGameResults")
+	filtered := queryDuration.Count("GetFilteredGameResults")
+	saved := gamesSaved.Value()
+
+	saveGroupGame(t, DefaultGroup,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 90, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 70, Rank: 2})
+	games, err := GetAllGameResults(t.Context(), 10, 0)
+	require.NoError(t, err)
+	require.Len(t, games, 1)
+
+	assert.Greater(t, queryDuration.Count("SaveGame"), saves)
+	assert.Greater(t, queryDuration.Count("GetAllGameResults"), reads)
+	assert.Equal(t, filtered, queryDuration.Count("GetFilteredGameResults"), "statements are named after the outermost db function")
+	assert.Equal(t, saved+1, gamesSaved.Value())
+
+	errors := queryErrors.Value("unknown")
+	_, err = DB.Exec(`SELECT * FROM missing_table`)
+	assert.Error(t, err)
+	assert.Equal(t, errors+1, queryErrors.Value("unknown"), "statements run outside a db function are unknown")
+}
+
+// TestQuerySpans tests that statements run within a trace get child spans
+// named after the db function that ran them
+func TestQuerySpans(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+	installExporter.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spanExporter)))
+	})
+	spanExporter.Reset()
+
+	saveGroupGame(t, DefaultGroup,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 90, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 70, Rank: 2})
+	assert.Empty(t, spanExporter.GetSpans(), "statements outside a trace aren't traced")
+
+	ctx, parent := otel.Tracer("test").Start(t.Context(), "request")
+	_, err := GetAllGameResults(ctx, 10, 0)
+	require.NoError(t, err)
+	_, err = DB.ExecContext(ctx, `SELECT * FROM missing_table`)
+	assert.Error(t, err)
+	parent.End()
+
+	spans := spanExporter.GetSpans()
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name)
+		if span.Name != "request" {
+			assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID(), span.Name)
+		}
+	}
+	assert.Contains(t, names, "db.GetAllGameResults")
+	assert.Contains(t, names, "db.unknown")
+	for _, span := range spans {
+		if span.Name == "db.unknown" {
+			assert.Equal(t, codes.Error, span.Status.Code)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"wingspan-scoring/scoring"
+)
+
+// ScoreCategory is a per-player scoring category recorded for every game
+type ScoreCategory struct {
+	Key    string // JSON key, matching the LeaderboardStats field names
+	Column string // player_scores column
+	Value  func(scoring.PlayerGameEnd) int
+}
+
+// ScoreCategories lists the categories stats are broken down by
+var ScoreCategories = []ScoreCategory{
+	{"totalScore", "total", func(p scoring.PlayerGameEnd) int { return p.Total }},
+	{"birdPoints", "bird_points", func(p scoring.PlayerGameEnd) int { return p.BirdPoints }},
+	{"bonusCards", "bonus_cards", func(p scoring.PlayerGameEnd) int { return p.BonusCards }},
+	{"roundGoals", "round_goals", func(p scoring.PlayerGameEnd) int { return p.RoundGoals }},
+	{"eggs", "eggs", func(p scoring.PlayerGameEnd) int { return p.Eggs }},
+	{"cachedFood", "cached_food", func(p scoring.PlayerGameEnd) int { return p.CachedFood }},
+	{"tuckedCards", "tucked_cards", func(p scoring.PlayerGameEnd) int { return p.TuckedCards }},
+	{"nectarForest", "nectar_forest", func(p scoring.PlayerGameEnd) int { return p.NectarForest }},
+	{"nectarGrassland", "nectar_grassland", func(p scoring.PlayerGameEnd) int { return p.NectarGrassland }},
+	{"nectarWetland", "nectar_wetland", func(p scoring.PlayerGameEnd) int { return p.NectarWetland }},
+}
+
+// CategoryStats summarises a player's results in one scoring category
+type CategoryStats struct {
+	Average float64 `json:"average"`
+	Median  float64 `json:"median"`
+	Best    int     `json:"best"`
+	Worst   int     `json:"worst"`
+	StdDev  float64 `json:"stdDev"`
+}
+
+// StatsSplit summarises a subset of a player's games
+type StatsSplit struct {
+	GamesPlayed  int     `json:"gamesPlayed"`
+	Wins         int     `json:"wins"`
+	WinRate      float64 `json:"winRate"`
+	AverageScore float64 `json:"averageScore"`
+	AverageRank  float64 `json:"averageRank"`
+}
+
+// OceaniaSplit separates a player's games with and without the Oceania expansion
+type OceaniaSplit struct {
+	Included StatsSplit `json:"included"`
+	Excluded StatsSplit `json:"excluded"`
+}
+
+// PlayerStats holds statistics for a single player. Rates are percentages.
+// Podium rate only counts games of 4 or more players, since every player in
+// a smaller game finishes in the top 3.
+type PlayerStats struct {
+	PlayerName       string                   `json:"playerName"`
+	GamesPlayed      int                      `json:"gamesPlayed"`
+	Wins             int                      `json:"wins"`
+	WinRate          float64                  `json:"winRate"`
+	AverageScore     float64                  `json:"averageScore"`
+	AverageRank      float64                  `json:"averageRank"`
+	PodiumRate       float64                  `json:"podiumRate"`
+	CurrentWinStreak int                      `json:"currentWinStreak"`
+	LongestWinStreak int                      `json:"longestWinStreak"`
+	Categories       map[string]CategoryStats `json:"categories"`
+	ByPlayerCount    map[int]StatsSplit       `json:"byPlayerCount"`
+	ByOceania        OceaniaSplit             `json:"byOceania"`
+}
+
+// playerGame is one player's result in a game, with the game context stats split on
+type playerGame struct {
+	player         scoring.PlayerGameEnd
+	numPlayers     int
+	includeOceania bool
+}
+
+// GetPlayerStats returns statistics for a specific player across games
+// matching the filter. Games are matched on exact player name, and wins are
+// counted from the player's own rank so games played and wins always agree.
+func GetPlayerStats(ctx context.Context, playerName string, filter GameFilter) (*PlayerStats, error) {
+	ctx = named(ctx, "GetPlayerStats")
+	filter.Players = append(append([]string{}, filter.Players...), playerName)
+
+	it, err := IterateGameResultsChronological(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query player games: %w", err)
+	}
+	defer it.Close()
+
+	var games []playerGame
+	for it.Next() {
+		game := it.GameResult()
+		if p, ok := findPlayer(game.Players, playerName); ok {
+			games = append(games, playerGame{player: p, numPlayers: game.NumPlayers, includeOceania: game.IncludeOceania})
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	return calculatePlayerStats(playerName, games), nil
+}
+
+// calculatePlayerStats computes stats from a player's games in chronological order
+func calculatePlayerStats(playerName string, games []playerGame) *PlayerStats {
+	stats := &PlayerStats{
+		PlayerName:    playerName,
+		GamesPlayed:   len(games),
+		Categories:    make(map[string]CategoryStats),
+		ByPlayerCount: make(map[int]StatsSplit),
+	}
+
+	stats.ByOceania.Included = splitStats(games, func(g playerGame) bool { return g.includeOceania })
+	stats.ByOceania.Excluded = splitStats(games, func(g playerGame) bool { return !g.includeOceania })
+	overall := splitStats(games, func(playerGame) bool { return true })
+	stats.Wins = overall.Wins
+	stats.WinRate = overall.WinRate
+	stats.AverageScore = overall.AverageScore
+	stats.AverageRank = overall.AverageRank
+
+	if len(games) == 0 {
+		return stats
+	}
+
+	podiums, podiumGames := 0, 0
+	streak := 0
+	for _, g := range games {
+		if g.numPlayers >= 4 {
+			podiumGames++
+			if g.player.Rank <= 3 {
+				podiums++
+			}
+		}
+		if g.player.Rank == 1 {
+			streak++
+			if streak > stats.LongestWinStreak {
+				stats.LongestWinStreak = streak
+			}
+		} else {
+			streak = 0
+		}
+	}
+	stats.CurrentWinStreak = streak
+	if podiumGames > 0 {
+		stats.PodiumRate = float64(podiums) / float64(podiumGames) * 100
+	}
+
+	for _, category := range ScoreCategories {
+		values := make([]int, len(games))
+		for i, g := range games {
+			values[i] = category.Value(g.player)
+		}
+		stats.Categories[category.Key] = summarize(values)
+	}
+
+	for numPlayers := 1; numPlayers <= 5; numPlayers++ {
+		split := splitStats(games, func(g playerGame) bool { return g.numPlayers == numPlayers })
+		if split.GamesPlayed > 0 {
+			stats.ByPlayerCount[numPlayers] = split
+		}
+	}
+
+	return stats
+}
+
+// splitStats summarises the games that match include
+func splitStats(games []playerGame, include func(playerGame) bool) StatsSplit {
+	var split StatsSplit
+	totalScore, totalRank := 0, 0
+	for _, g := range games {
+		if !include(g) {
+			continue
+		}
+		split.GamesPlayed++
+		totalScore += g.player.Total
+		totalRank += g.player.Rank
+		if g.player.Rank == 1 {
+			split.Wins++
+		}
+	}
+
+	if split.GamesPlayed > 0 {
+		n := float64(split.GamesPlayed)
+		split.WinRate = float64(split.Wins) / n * 100
+		split.AverageScore = float64(totalScore) / n
+		split.AverageRank = float64(totalRank) / n
+	}
+	return split
+}
+
+// summarize computes average, median, best, worst and population standard deviation
+func summarize(values []int) CategoryStats {
+	if len(values) == 0 {
+		return CategoryStats{}
+	}
+
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+
+	sum := 0
+	for _, v := range sorted {
+		sum += v
+	}
+	n := float64(len(sorted))
+	mean := float64(sum) / n
+
+	variance := 0.0
+	for _, v := range sorted {
+		d := float64(v) - mean
+		variance += d * d
+	}
+	variance /= n
+
+	mid := len(sorted) / 2
+	median := float64(sorted[mid])
+	if len(sorted)%2 == 0 {
+		median = float64(sorted[mid-1]+sorted[mid]) / 2
+	}
+
+	return CategoryStats{
+		Average: mean,
+		Median:  median,
+		Best:    sorted[len(sorted)-1],
+		Worst:   sorted[0],
+		StdDev:  math.Sqrt(variance),
+	}
+}
@@ -0,0 +1,215 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	"wingspan-scoring/scoring"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// saveGameOn saves a game played at noon on the given day
+func saveGameOn(t *testing.T, date string, oceania bool, players ...scoring.PlayerGameEnd) int64 {
+	t.Helper()
+	playedAt, err := time.Parse(sqliteTimeFormat, date+" 12:00:00")
+	require.NoError(t, err)
+	id, err := SaveGame(t.Context(), &GameResult{Players: players, NectarScoring: &scoring.NectarScoring{}, IncludeOceania: oceania, CreatedAt: playedAt})
+	require.NoError(t, err)
+	return id
+}
+
+// TestGetPlayerStats_ExactNameMatch tests that similar names don't count as games played
+func TestGetPlayerStats_ExactNameMatch(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	saveGameOn(t, "2024-01-01", false,
+		scoring.PlayerGameEnd{PlayerName: "Alicia", Total: 100, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 90, Rank: 2})
+	saveGameOn(t, "2024-01-02", false,
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 100, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 80, Rank: 2})
+
+	stats, err := GetPlayerStats(t.Context(), "Alice", GameFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.GamesPlayed)
+	assert.Equal(t, 0, stats.Wins)
+	assert.Equal(t, 80.0, stats.AverageScore)
+}
+
+// TestGetPlayerStats_Categories tests per-category average, median, best, worst and stddev
+func TestGetPlayerStats_Categories(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	for i, eggs := range []int{2, 4, 4, 10} {
+		saveGameOn(t, fmt.Sprintf("2024-01-%02d", i+1), false,
+			scoring.PlayerGameEnd{PlayerName: "Alice", Eggs: eggs, BirdPoints: 30, Total: 60 + eggs, Rank: 1},
+			scoring.PlayerGameEnd{PlayerName: "Bob", Total: 50, Rank: 2})
+	}
+
+	stats, err := GetPlayerStats(t.Context(), "Alice", GameFilter{})
+	require.NoError(t, err)
+
+	eggs := stats.Categories["eggs"]
+	assert.Equal(t, 5.0, eggs.Average)
+	assert.Equal(t, 4.0, eggs.Median)
+	assert.Equal(t, 10, eggs.Best)
+	assert.Equal(t, 2, eggs.Worst)
+	assert.InDelta(t, 3.0, eggs.StdDev, 0.001)
+
+	birds := stats.Categories["birdPoints"]
+	assert.Equal(t, 30.0, birds.Average)
+	assert.Equal(t, 0.0, birds.StdDev)
+
+	assert.Equal(t, 70, stats.Categories["totalScore"].Best)
+	assert.Len(t, stats.Categories, len(ScoreCategories))
+}
+
+// TestGetPlayerStats_RanksAndStreaks tests average rank, podium rate and win streaks
+func TestGetPlayerStats_RanksAndStreaks(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// Alice's ranks in date order: 1, 1, 1, 4, 1, 1
+	ranks := []int{1, 1, 1, 4, 1, 1}
+	for i, rank := range ranks {
+		players := []scoring.PlayerGameEnd{
+			{PlayerName: "Bob", Total: 80, Rank: 2},
+			{PlayerName: "Carol", Total: 70, Rank: 3},
+		}
+		if rank == 1 {
+			players = append(players,
+				scoring.PlayerGameEnd{PlayerName: "Alice", Total: 90, Rank: 1},
+				scoring.PlayerGameEnd{PlayerName: "Dave", Total: 60, Rank: 4})
+		} else {
+			players = append(players,
+				scoring.PlayerGameEnd{PlayerName: "Dave", Total: 90, Rank: 1},
+				scoring.PlayerGameEnd{PlayerName: "Alice", Total: 60, Rank: 4})
+		}
+		saveGameOn(t, fmt.Sprintf("2024-02-%02d", i+1), false, players...)
+	}
+
+	stats, err := GetPlayerStats(t.Context(), "Alice", GameFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 6, stats.GamesPlayed)
+	assert.Equal(t, 5, stats.Wins)
+	assert.InDelta(t, 1.5, stats.AverageRank, 0.001)
+	assert.InDelta(t, 83.33, stats.PodiumRate, 0.01)
+	assert.Equal(t, 3, stats.LongestWinStreak)
+	assert.Equal(t, 2, stats.CurrentWinStreak)
+}
+
+// TestGetPlayerStats_PodiumRate tests that games of under 4 players don't
+// count towards the podium rate
+func TestGetPlayerStats_PodiumRate(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	saveGameOn(t, "2024-02-01", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 60, Rank: 2},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 80, Rank: 1})
+	saveGameOn(t, "2024-02-02", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 60, Rank: 3},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 80, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Carol", Total: 70, Rank: 2})
+
+	stats, err := GetPlayerStats(t.Context(), "Alice", GameFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, stats.PodiumRate, "small games aren't podium games")
+
+	saveGameOn(t, "2024-02-03", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 50, Rank: 4},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 80, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Carol", Total: 70, Rank: 2},
+		scoring.PlayerGameEnd{PlayerName: "Dave", Total: 60, Rank: 3})
+	saveGameOn(t, "2024-02-04", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 65, Rank: 3},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 80, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Carol", Total: 70, Rank: 2},
+		scoring.PlayerGameEnd{PlayerName: "Dave", Total: 60, Rank: 4})
+
+	stats, err = GetPlayerStats(t.Context(), "Alice", GameFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.GamesPlayed)
+	assert.InDelta(t, 50.0, stats.PodiumRate, 0.01)
+}
+
+// TestGetPlayerStats_Splits tests the player count and Oceania splits
+func TestGetPlayerStats_Splits(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	saveGameOn(t, "2024-03-01", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 100, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 90, Rank: 2})
+	saveGameOn(t, "2024-03-02", true,
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 100, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 80, Rank: 2})
+	saveGameOn(t, "2024-03-03", true,
+		scoring.PlayerGameEnd{PlayerName: "Carol", Total: 100, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 90, Rank: 2},
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 70, Rank: 3})
+
+	stats, err := GetPlayerStats(t.Context(), "Alice", GameFilter{})
+	require.NoError(t, err)
+
+	require.Len(t, stats.ByPlayerCount, 2)
+	two := stats.ByPlayerCount[2]
+	assert.Equal(t, 2, two.GamesPlayed)
+	assert.Equal(t, 1, two.Wins)
+	assert.Equal(t, 50.0, two.WinRate)
+	assert.Equal(t, 90.0, two.AverageScore)
+	assert.Equal(t, 1.5, two.AverageRank)
+
+	three := stats.ByPlayerCount[3]
+	assert.Equal(t, 1, three.GamesPlayed)
+	assert.Equal(t, 3.0, three.AverageRank)
+
+	assert.Equal(t, 2, stats.ByOceania.Included.GamesPlayed)
+	assert.Equal(t, 75.0, stats.ByOceania.Included.AverageScore)
+	assert.Equal(t, 1, stats.ByOceania.Excluded.GamesPlayed)
+	assert.Equal(t, 100.0, stats.ByOceania.Excluded.WinRate)
+}
+
+// TestGetPlayerStats_DateRange tests restricting stats to a date range
+func TestGetPlayerStats_DateRange(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	saveGameOn(t, "2024-01-15", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 100, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 90, Rank: 2})
+	saveGameOn(t, "2024-02-15", false,
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 100, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 80, Rank: 2})
+	saveGameOn(t, "2024-03-15", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 110, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 70, Rank: 2})
+
+	stats, err := GetPlayerStats(t.Context(), "Alice", GameFilter{
+		From: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.GamesPlayed)
+	assert.Equal(t, 1, stats.Wins)
+	assert.Equal(t, 95.0, stats.AverageScore)
+	assert.Equal(t, 1, stats.CurrentWinStreak)
+}
+
+// TestSummarize tests category summaries for odd, even and empty inputs
+func TestSummarize(t *testing.T) {
+	assert.Equal(t, CategoryStats{}, summarize(nil))
+
+	odd := summarize([]int{5, 1, 3})
+	assert.Equal(t, 3.0, odd.Median)
+	assert.Equal(t, 5, odd.Best)
+	assert.Equal(t, 1, odd.Worst)
+
+	even := summarize([]int{1, 2, 3, 10})
+	assert.Equal(t, 2.5, even.Median)
+	assert.Equal(t, 4.0, even.Average)
+}
@@ -0,0 +1,257 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// balancedLift is how far above the group's share a category must be before
+// a player is classified by it rather than as balanced
+const balancedLift = 0.1
+
+// strategyNames describes a player whose points lean on a category
+var strategyNames = map[string]string{
+	"birdPoints":      "bird-points heavy",
+	"bonusCards":      "bonus-card hunter",
+	"roundGoals":      "round-goal racer",
+	"eggs":            "egg engine",
+	"cachedFood":      "food cacher",
+	"tuckedCards":     "tucking engine",
+	"nectarForest":    "nectar collector",
+	"nectarGrassland": "nectar collector",
+	"nectarWetland":   "nectar collector",
+}
+
+// CategoryShare compares how much of a player's score comes from a category
+// with the group. Shares are percentages of the total score.
+type CategoryShare struct {
+	Share          float64 `json:"share"`
+	GroupShare     float64 `json:"groupShare"`
+	Lift           float64 `json:"lift"`           // Share relative to the group's, e.g. 0.25 = 25% more
+	WinCorrelation float64 `json:"winCorrelation"` // Pearson correlation between the category's points and winning
+}
+
+// PlayerProfile classifies a player's typical point distribution
+type PlayerProfile struct {
+	PlayerName      string                   `json:"playerName"`
+	GamesPlayed     int                      `json:"gamesPlayed"`
+	Strategy        string                   `json:"strategy"`        // e.g. "egg engine", or "balanced"
+	StrategyFocus   string                   `json:"strategyFocus"`   // Category the strategy is based on
+	WinningCategory string                   `json:"winningCategory"` // Category most correlated with wins
+	Categories      map[string]CategoryShare `json:"categories"`
+}
+
+// profileCategory is a category of points that makes up a total, with the
+// SQL expression giving a player_scores row's points in it
+type profileCategory struct {
+	Key  string
+	Expr string
+}
+
+// nectarHabitats maps the nectar categories to their habitat in nectar_json
+var nectarHabitats = map[string]string{
+	"nectarForest":    "forest",
+	"nectarGrassland": "grassland",
+	"nectarWetland":   "wetland",
+}
+
+// profileCategories are the categories that make up a total. player_scores
+// holds nectar token counts, so the nectar categories use the points each
+// player won per habitat instead.
+func profileCategories() []profileCategory {
+	var categories []profileCategory
+	for _, category := range ScoreCategories {
+		if category.Key == "totalScore" {
+			continue
+		}
+		expr := "player_scores." + category.Column
+		if habitat, ok := nectarHabitats[category.Key]; ok {
+			expr = `IFNULL((SELECT value FROM json_each(game_results.nectar_json, '$.` + habitat + `')
+				WHERE key = player_scores.player_name), 0)`
+		}
+		categories = append(categories, profileCategory{category.Key, expr})
+	}
+	return categories
+}
+
+// GetPlayerProfile builds a player's scoring profile from games matching the
+// filter, compared with every player in those games
+func GetPlayerProfile(ctx context.Context, playerName string, filter GameFilter) (*PlayerProfile, error) {
+	ctx = named(ctx, "GetPlayerProfile")
+	categories := profileCategories()
+
+	groupSums, err := sumCategories(ctx, categories, filter, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum group scores: %w", err)
+	}
+	playerSums, err := sumCategories(ctx, categories, filter, playerName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum player scores: %w", err)
+	}
+
+	values, wins, err := playerCategoryValues(ctx, categories, filter, playerName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query player scores: %w", err)
+	}
+
+	profile := &PlayerProfile{
+		PlayerName:  playerName,
+		GamesPlayed: len(wins),
+		Strategy:    "balanced",
+		Categories:  make(map[string]CategoryShare, len(categories)),
+	}
+
+	groupTotal, playerTotal := sumTotals(groupSums), sumTotals(playerSums)
+	bestLift, bestCorrelation := balancedLift, 0.0
+	for i, category := range categories {
+		share := CategoryShare{
+			Share:          percentOf(playerSums[i], playerTotal),
+			GroupShare:     percentOf(groupSums[i], groupTotal),
+			WinCorrelation: correlation(values[i], wins),
+		}
+		if share.GroupShare > 0 {
+			share.Lift = share.Share/share.GroupShare - 1
+		}
+		profile.Categories[category.Key] = share
+
+		if share.Lift > bestLift {
+			bestLift = share.Lift
+			profile.StrategyFocus = category.Key
+			profile.Strategy = strategyNames[category.Key]
+		}
+		if share.WinCorrelation > bestCorrelation {
+			bestCorrelation = share.WinCorrelation
+			profile.WinningCategory = category.Key
+		}
+	}
+
+	return profile, nil
+}
+
+// sumCategories totals each category over games matching the filter, for one
+// player or, when playerName is empty, everyone
+func sumCategories(ctx context.Context, categories []profileCategory, filter GameFilter, playerName string) ([]int64, error) {
+	where, args := filter.whereClause()
+	if playerName != "" {
+		where = andWhere(where, "player_scores.player_name = ?")
+		args = append(args, playerName)
+	}
+
+	columns := make([]string, len(categories))
+	for i, category := range categories {
+		columns[i] = "IFNULL(SUM(" + category.Expr + "), 0)"
+	}
+
+	query := `
+		SELECT ` + strings.Join(columns, ", ") + `
+		FROM player_scores
+		JOIN game_results ON game_results.id = player_scores.game_id
+		` + where
+
+	sums := make([]int64, len(categories))
+	dest := make([]interface{}, len(categories))
+	for i := range sums {
+		dest[i] = &sums[i]
+	}
+	if err := DB.QueryRowContext(ctx, query, args...).Scan(dest...); err != nil {
+		return nil, err
+	}
+	return sums, nil
+}
+
+// playerCategoryValues returns a player's points per category for each game
+// (indexed by category, then game) and whether they won each game
+func playerCategoryValues(ctx context.Context, categories []profileCategory, filter GameFilter, playerName string) ([][]float64, []float64, error) {
+	where, args := filter.whereClause()
+	where = andWhere(where, "player_scores.player_name = ?")
+	args = append(args, playerName)
+
+	columns := make([]string, len(categories))
+	for i, category := range categories {
+		columns[i] = category.Expr
+	}
+
+	query := `
+		SELECT player_scores.rank, ` + strings.Join(columns, ", ") + `
+		FROM player_scores
+		JOIN game_results ON game_results.id = player_scores.game_id
+		` + where
+
+	rows, err := DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	values := make([][]float64, len(categories))
+	var wins []float64
+	for rows.Next() {
+		var rank int
+		row := make([]int, len(categories))
+		dest := []interface{}{&rank}
+		for i := range row {
+			dest = append(dest, &row[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan player score: %w", err)
+		}
+
+		won := 0.0
+		if rank == 1 {
+			won = 1
+		}
+		wins = append(wins, won)
+		for i, v := range row {
+			values[i] = append(values[i], float64(v))
+		}
+	}
+
+	return values, wins, rows.Err()
+}
+
+// correlation returns the Pearson correlation of x and y, or 0 when either
+// doesn't vary
+func correlation(x, y []float64) float64 {
+	n := float64(len(x))
+	if len(x) < 2 || len(x) != len(y) {
+		return 0
+	}
+
+	var sumX, sumY float64
+	for i := range x {
+		sumX += x[i]
+		sumY += y[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var cov, varX, varY float64
+	for i := range x {
+		dx, dy := x[i]-meanX, y[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varX*varY)
+}
+
+// sumTotals adds up a slice of totals
+func sumTotals(values []int64) int64 {
+	var total int64
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+// percentOf returns part as a percentage of whole, or 0 for an empty whole
+func percentOf(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole) * 100
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"testing"
+	"wingspan-scoring/scoring"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetPlayerProfile_Strategies tests classification, shares and win correlation
+func TestGetPlayerProfile_Strategies(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	saveGameOn(t, "2024-01-01", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", BirdPoints: 20, Eggs: 20, Total: 40, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", BirdPoints: 36, Eggs: 4, Total: 40, Rank: 2})
+	saveGameOn(t, "2024-01-02", false,
+		scoring.PlayerGameEnd{PlayerName: "Bob", BirdPoints: 30, Eggs: 8, Total: 38, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Alice", BirdPoints: 24, Eggs: 10, Total: 34, Rank: 2})
+
+	alice, err := GetPlayerProfile(t.Context(), "Alice", GameFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, alice.GamesPlayed)
+	assert.Equal(t, "egg engine", alice.Strategy)
+	assert.Equal(t, "eggs", alice.StrategyFocus)
+	assert.Equal(t, "eggs", alice.WinningCategory)
+
+	eggs := alice.Categories["eggs"]
+	// Alice: 30 of 74 points; group: 42 of 152
+	assert.InDelta(t, 40.54, eggs.Share, 0.01)
+	assert.InDelta(t, 27.63, eggs.GroupShare, 0.01)
+	assert.InDelta(t, 0.467, eggs.Lift, 0.001)
+	assert.InDelta(t, 1.0, eggs.WinCorrelation, 0.001)
+	assert.InDelta(t, -1.0, alice.Categories["birdPoints"].WinCorrelation, 0.001)
+	assert.Equal(t, 0.0, alice.Categories["tuckedCards"].WinCorrelation)
+
+	bob, err := GetPlayerProfile(t.Context(), "Bob", GameFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, "bird-points heavy", bob.Strategy)
+	assert.Equal(t, "birdPoints", bob.StrategyFocus)
+}
+
+// TestGetPlayerProfile_NectarPoints tests that nectar shares use the points
+// won per habitat rather than the tokens collected
+func TestGetPlayerProfile_NectarPoints(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	players, nectar := scoring.CalculateGameEndScores([]scoring.PlayerGameEnd{
+		{PlayerName: "Alice", BirdPoints: 30, Eggs: 10, NectarForest: 3, NectarWetland: 1},
+		{PlayerName: "Bob", BirdPoints: 35, Eggs: 5, NectarForest: 1, NectarWetland: 2},
+	}, true)
+	_, err := SaveGameResult(t.Context(), players, nectar, true)
+	require.NoError(t, err)
+
+	alice, err := GetPlayerProfile(t.Context(), "Alice", GameFilter{})
+	require.NoError(t, err)
+	require.Equal(t, 47, players[0].Total)
+
+	// Alice wins the forest (5 points) and is second in the wetland (2 points)
+	assert.InDelta(t, 5.0/47*100, alice.Categories["nectarForest"].Share, 0.01)
+	assert.InDelta(t, 2.0/47*100, alice.Categories["nectarWetland"].Share, 0.01)
+	assert.Equal(t, 0.0, alice.Categories["nectarGrassland"].Share)
+
+	var shares float64
+	for _, category := range alice.Categories {
+		shares += category.Share
+	}
+	assert.InDelta(t, 100.0, shares, 0.01, "shares add up to the total")
+}
+
+// TestGetPlayerProfile_Balanced tests a player whose distribution matches the group
+func TestGetPlayerProfile_Balanced(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	saveGameOn(t, "2024-01-01", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", BirdPoints: 30, Eggs: 10, Total: 40, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", BirdPoints: 27, Eggs: 9, Total: 36, Rank: 2})
+
+	profile, err := GetPlayerProfile(t.Context(), "Alice", GameFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, "balanced", profile.Strategy)
+	assert.Empty(t, profile.StrategyFocus)
+	assert.Empty(t, profile.WinningCategory, "one game can't show a correlation")
+	assert.InDelta(t, 75.0, profile.Categories["birdPoints"].Share, 0.001)
+}
+
+// TestGetPlayerProfile_NoGames tests a profile for an unknown player
+func TestGetPlayerProfile_NoGames(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	profile, err := GetPlayerProfile(t.Context(), "Nobody", GameFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, profile.GamesPlayed)
+	assert.Equal(t, "balanced", profile.Strategy)
+	assert.Len(t, profile.Categories, len(ScoreCategories)-1)
+}
+
+// TestCorrelation tests the Pearson correlation helper
+func TestCorrelation(t *testing.T) {
+	assert.InDelta(t, 1.0, correlation([]float64{1, 2, 3}, []float64{2, 4, 6}), 0.0001)
+	assert.InDelta(t, -1.0, correlation([]float64{1, 2, 3}, []float64{3, 2, 1}), 0.0001)
+	assert.Equal(t, 0.0, correlation([]float64{1, 1, 1}, []float64{0, 1, 0}))
+	assert.Equal(t, 0.0, correlation([]float64{1}, []float64{1}))
+}
@@ -0,0 +1,362 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+	"wingspan-scoring/scoring"
+)
+
+const (
+	// InitialRating is every player's rating before their first game
+	InitialRating = 1500.0
+	// ratingK is the most a player's rating can move in a single game
+	ratingK = 32.0
+)
+
+// ratingsMu stops concurrent reads rebuilding the rating history at once.
+// Writers are kept out by the rebuild's transaction.
+var ratingsMu sync.Mutex
+
+// RatingChange is one player's rating movement from a single game
+type RatingChange struct {
+	GameID       int64     `json:"gameId"`
+	PlayedAt     time.Time `json:"playedAt"`
+	PlayerName   string    `json:"playerName"`
+	Rank         int       `json:"rank"`
+	RatingBefore float64   `json:"ratingBefore"`
+	RatingAfter  float64   `json:"ratingAfter"`
+	Change       float64   `json:"change"`
+}
+
+// PlayerRating is a player's current rating
+type PlayerRating struct {
+	PlayerName  string    `json:"playerName"`
+	Rating      float64   `json:"rating"`
+	Peak        float64   `json:"peak"`
+	GamesPlayed int       `json:"gamesPlayed"`
+	LastPlayed  time.Time `json:"lastPlayed"`
+}
+
+// UpdateRatings applies one game to the ratings map and returns each player's
+// change. A multiplayer game is scored as a round robin of two-player Elo
+// matches: every pair is compared by rank (a shared rank is a draw) and the
+// summed adjustment is scaled by the number of opponents, so a player's
+// rating moves by at most ratingK per game whatever the player count.
+func UpdateRatings(ratings map[string]float64, players []scoring.PlayerGameEnd) map[string]float64 {
+	before := make(map[string]float64, len(players))
+	for _, p := range players {
+		rating, ok := ratings[p.PlayerName]
+		if !ok {
+			rating = InitialRating
+		}
+		before[p.PlayerName] = rating
+	}
+
+	changes := make(map[string]float64, len(players))
+	if len(players) < 2 {
+		return changes
+	}
+
+	opponents := float64(len(players) - 1)
+	for _, p := range players {
+		delta := 0.0
+		for _, o := range players {
+			if o.PlayerName == p.PlayerName {
+				continue
+			}
+			expected := 1 / (1 + math.Pow(10, (before[o.PlayerName]-before[p.PlayerName])/400))
+			actual := 0.5
+			if p.Rank < o.Rank {
+				actual = 1
+			} else if p.Rank > o.Rank {
+				actual = 0
+			}
+			delta += actual - expected
+		}
+		changes[p.PlayerName] = ratingK * delta / opponents
+	}
+
+	for name, change := range changes {
+		ratings[name] = before[name] + change
+	}
+	return changes
+}
+
+// RecomputeRatings replays every saved game in chronological order and
+// replaces the stored rating history. Each group is rated separately.
+func RecomputeRatings(ctx context.Context) error {
+	ctx = named(ctx, "RecomputeRatings")
+	ratingsMu.Lock()
+	defer ratingsMu.Unlock()
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM rating_history`); err != nil {
+		return fmt.Errorf("failed to clear rating history: %w", err)
+	}
+	groups, err := gameGroups(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		if err := replaceRatings(ctx, tx, group); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rating history: %w", err)
+	}
+	return nil
+}
+
+// recomputeRatings replays a group's games and writes its history in one
+// transaction. Clearing the history first takes the write lock, so no game
+// can be saved or deleted between reading the games and committing.
+func recomputeRatings(ctx context.Context, group string) error {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM rating_history WHERE group_id = ?`, group); err != nil {
+		return fmt.Errorf("failed to clear rating history: %w", err)
+	}
+	if err := replaceRatings(ctx, tx, group); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rating history: %w", err)
+	}
+	return nil
+}
+
+// replaceRatings replays a group's games into its cleared history
+func replaceRatings(ctx context.Context, tx *sql.Tx, group string) error {
+	history, err := replayRatings(ctx, tx, group)
+	if err != nil {
+		return err
+	}
+	return insertRatingHistory(ctx, tx, group, 0, history)
+}
+
+// insertRatingHistory stores a group's rating changes, numbering them from seq
+func insertRatingHistory(ctx context.Context, tx *sql.Tx, group string, seq int, history []RatingChange) error {
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO rating_history (group_id, seq, game_id, played_at, player_name, rank, rating_before, rating_after)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare rating insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for i, change := range history {
+		playedAt := change.PlayedAt.UTC().Format(sqliteTimeFormat)
+		if _, err := stmt.ExecContext(ctx, group, seq+i, change.GameID, playedAt, change.PlayerName, change.Rank, change.RatingBefore, change.RatingAfter); err != nil {
+			return fmt.Errorf("failed to insert rating history: %w", err)
+		}
+	}
+	return nil
+}
+
+// replayRatings rates a group's games in chronological order, returning every change
+func replayRatings(ctx context.Context, q querier, group string) ([]RatingChange, error) {
+	it, err := iterateGameResults(ctx, q, GameFilter{Group: group}, chronological)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	ratings := make(map[string]float64)
+	var history []RatingChange
+	for it.Next() {
+		history = append(history, rateGamePlayers(ratings, it.GameResult())...)
+	}
+	return history, it.Err()
+}
+
+// rateGamePlayers applies a game to the ratings map and returns each
+// player's change, in seat order
+func rateGamePlayers(ratings map[string]float64, game *GameResult) []RatingChange {
+	before := make(map[string]float64, len(game.Players))
+	for _, p := range game.Players {
+		before[p.PlayerName] = InitialRating
+		if rating, ok := ratings[p.PlayerName]; ok {
+			before[p.PlayerName] = rating
+		}
+	}
+
+	changes := UpdateRatings(ratings, game.Players)
+	history := make([]RatingChange, 0, len(game.Players))
+	for _, p := range game.Players {
+		history = append(history, RatingChange{
+			GameID:       game.ID,
+			PlayedAt:     game.CreatedAt,
+			PlayerName:   p.PlayerName,
+			Rank:         p.Rank,
+			RatingBefore: before[p.PlayerName],
+			RatingAfter:  before[p.PlayerName] + changes[p.PlayerName],
+			Change:       changes[p.PlayerName],
+		})
+	}
+	return history
+}
+
+// rateGame adds a game just saved in tx to its group's rating history. A
+// game played after every other in the group is rated from its players'
+// current ratings and appended. One played earlier changes every rating
+// after it, so the group's history is cleared and recomputed on next read.
+func rateGame(ctx context.Context, tx *sql.Tx, game *GameResult) error {
+	group := groupOrDefault(game.Group)
+	latest, err := isLatestGame(ctx, tx, game.ID)
+	if err != nil {
+		return err
+	}
+	if !latest {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM rating_history WHERE group_id = ?`, group); err != nil {
+			return fmt.Errorf("failed to clear rating history: %w", err)
+		}
+		return nil
+	}
+
+	// A history already cleared by an earlier change is left to be recomputed
+	var stale bool
+	var seq int
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM game_results WHERE group_id = ? AND id != ?)
+			AND NOT EXISTS (SELECT 1 FROM rating_history WHERE group_id = ?),
+			IFNULL((SELECT MAX(seq) + 1 FROM rating_history WHERE group_id = ?), 0)
+	`, group, game.ID, group, group).Scan(&stale, &seq)
+	if err != nil {
+		return fmt.Errorf("failed to check rating history: %w", err)
+	}
+	if stale {
+		return nil
+	}
+
+	ratings := make(map[string]float64, len(game.Players))
+	for _, p := range game.Players {
+		var rating float64
+		err := tx.QueryRowContext(ctx, `
+			SELECT rating_after FROM rating_history
+			WHERE group_id = ? AND player_name = ?
+			ORDER BY seq DESC LIMIT 1
+		`, group, p.PlayerName).Scan(&rating)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read rating: %w", err)
+		}
+		ratings[p.PlayerName] = rating
+	}
+
+	return insertRatingHistory(ctx, tx, group, seq, rateGamePlayers(ratings, game))
+}
+
+// ensureRatings recomputes a group's rating history if a change to one of
+// its past games has cleared it
+func ensureRatings(ctx context.Context, group string) error {
+	ratingsMu.Lock()
+	defer ratingsMu.Unlock()
+
+	var stale bool
+	err := DB.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM game_results WHERE group_id = ?)
+			AND NOT EXISTS (SELECT 1 FROM rating_history WHERE group_id = ?)
+	`, group, group).Scan(&stale)
+	if err != nil {
+		return fmt.Errorf("failed to check rating history: %w", err)
+	}
+
+	if !stale {
+		return nil
+	}
+	return recomputeRatings(ctx, group)
+}
+
+// GetRatings returns the current rating of every player in a group, highest first
+func GetRatings(ctx context.Context, group string) ([]PlayerRating, error) {
+	ctx = named(ctx, "GetRatings")
+	group = groupOrDefault(group)
+	if err := ensureRatings(ctx, group); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT h.player_name, h.rating_after, latest.peak, latest.games, h.played_at
+		FROM rating_history h
+		JOIN (
+			SELECT player_name, MAX(seq) AS seq, MAX(rating_after) AS peak, COUNT(*) AS games
+			FROM rating_history
+			WHERE group_id = ?
+			GROUP BY player_name
+		) latest ON latest.player_name = h.player_name AND latest.seq = h.seq
+		WHERE h.group_id = ?
+		ORDER BY h.rating_after DESC, h.player_name
+	`
+
+	rows, err := DB.QueryContext(ctx, query, group, group)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ratings: %w", err)
+	}
+	defer rows.Close()
+
+	ratings := []PlayerRating{}
+	for rows.Next() {
+		var r PlayerRating
+		if err := rows.Scan(&r.PlayerName, &r.Rating, &r.Peak, &r.GamesPlayed, &r.LastPlayed); err != nil {
+			return nil, fmt.Errorf("failed to scan rating: %w", err)
+		}
+		ratings = append(ratings, r)
+	}
+
+	return ratings, rows.Err()
+}
+
+// GetRatingHistory returns a player's rating changes in a group, in the order
+// games were played
+func GetRatingHistory(ctx context.Context, group, playerName string) ([]RatingChange, error) {
+	ctx = named(ctx, "GetRatingHistory")
+	group = groupOrDefault(group)
+	if err := ensureRatings(ctx, group); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT game_id, played_at, player_name, rank, rating_before, rating_after
+		FROM rating_history
+		WHERE group_id = ? AND player_name = ?
+		ORDER BY seq
+	`
+
+	rows, err := DB.QueryContext(ctx, query, group, playerName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rating history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []RatingChange{}
+	for rows.Next() {
+		var c RatingChange
+		if err := rows.Scan(&c.GameID, &c.PlayedAt, &c.PlayerName, &c.Rank, &c.RatingBefore, &c.RatingAfter); err != nil {
+			return nil, fmt.Errorf("failed to scan rating history: %w", err)
+		}
+		c.Change = c.RatingAfter - c.RatingBefore
+		history = append(history, c)
+	}
+
+	return history, rows.Err()
+}
@@ -0,0 +1,215 @@
+package db
+
+import (
+	"testing"
+	"time"
+	"wingspan-scoring/scoring"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUpdateRatings_TwoPlayers tests a standard Elo update between equal players
+func TestUpdateRatings_TwoPlayers(t *testing.T) {
+	ratings := map[string]float64{}
+	changes := UpdateRatings(ratings, []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Rank: 1},
+		{PlayerName: "Bob", Rank: 2},
+	})
+
+	assert.InDelta(t, 16.0, changes["Alice"], 0.001)
+	assert.InDelta(t, -16.0, changes["Bob"], 0.001)
+	assert.InDelta(t, 1516.0, ratings["Alice"], 0.001)
+	assert.InDelta(t, 1484.0, ratings["Bob"], 0.001)
+}
+
+// TestUpdateRatings_SharedRankIsDraw tests that tied ranks between equal players change nothing
+func TestUpdateRatings_SharedRankIsDraw(t *testing.T) {
+	ratings := map[string]float64{}
+	changes := UpdateRatings(ratings, []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Rank: 1},
+		{PlayerName: "Bob", Rank: 1},
+	})
+
+	assert.InDelta(t, 0.0, changes["Alice"], 0.001)
+	assert.InDelta(t, 0.0, changes["Bob"], 0.001)
+}
+
+// TestUpdateRatings_Multiplayer tests the pairwise decomposition is zero-sum and bounded by K
+func TestUpdateRatings_Multiplayer(t *testing.T) {
+	ratings := map[string]float64{"Alice": 1600, "Dave": 1400}
+	changes := UpdateRatings(ratings, []scoring.PlayerGameEnd{
+		{PlayerName: "Dave", Rank: 1},
+		{PlayerName: "Bob", Rank: 2},
+		{PlayerName: "Carol", Rank: 2},
+		{PlayerName: "Alice", Rank: 4},
+	})
+
+	sum := 0.0
+	for _, change := range changes {
+		sum += change
+		assert.LessOrEqual(t, change, ratingK)
+		assert.GreaterOrEqual(t, change, -ratingK)
+	}
+	assert.InDelta(t, 0.0, sum, 0.001)
+
+	// The underdog winning gains more than the favourite would have
+	assert.Greater(t, changes["Dave"], 16.0)
+	assert.Less(t, changes["Alice"], -16.0)
+	assert.InDelta(t, changes["Bob"], changes["Carol"], 0.001)
+}
+
+// TestGetRatings_Empty tests ratings with no games
+func TestGetRatings_Empty(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ratings, err := GetRatings(t.Context(), DefaultGroup)
+	require.NoError(t, err)
+	assert.Empty(t, ratings)
+}
+
+// TestGetRatings_ChronologicalOrder tests games are replayed by date, not by ID
+func TestGetRatings_ChronologicalOrder(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// Saved out of order: the later-dated game is inserted first
+	later := saveGameOn(t, "2024-02-01", false,
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 90, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 80, Rank: 2})
+	earlier := saveGameOn(t, "2024-01-01", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 90, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 80, Rank: 2})
+
+	history, err := GetRatingHistory(t.Context(), DefaultGroup, "Alice")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, earlier, history[0].GameID)
+	assert.Equal(t, later, history[1].GameID)
+	assert.Equal(t, InitialRating, history[0].RatingBefore)
+	assert.InDelta(t, 16.0, history[0].Change, 0.001)
+	assert.Equal(t, history[0].RatingAfter, history[1].RatingBefore)
+	assert.Equal(t, 2024, history[0].PlayedAt.Year())
+
+	ratings, err := GetRatings(t.Context(), DefaultGroup)
+	require.NoError(t, err)
+	require.Len(t, ratings, 2)
+	// Bob won the later game against a higher-rated Alice, so he gains more
+	assert.Equal(t, "Bob", ratings[0].PlayerName)
+	assert.Equal(t, 2, ratings[0].GamesPlayed)
+	assert.InDelta(t, InitialRating+16, ratings[1].Peak, 0.001)
+}
+
+// TestGetRatings_RecomputedOnDelete tests that deleting a game rewrites the history
+func TestGetRatings_RecomputedOnDelete(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	first := saveGameOn(t, "2024-01-01", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 90, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 80, Rank: 2})
+	saveGameOn(t, "2024-01-02", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 90, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 80, Rank: 2})
+
+	history, err := GetRatingHistory(t.Context(), DefaultGroup, "Alice")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+
+	require.NoError(t, DeleteGameResult(t.Context(), first))
+
+	history, err = GetRatingHistory(t.Context(), DefaultGroup, "Alice")
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, InitialRating, history[0].RatingBefore)
+}
+
+// TestGetRatings_RecomputedOnEdit tests that editing a past game rewrites the history
+func TestGetRatings_RecomputedOnEdit(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	id := saveGameOn(t, "2024-01-01", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 90, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 80, Rank: 2})
+
+	ratings, err := GetRatings(t.Context(), DefaultGroup)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", ratings[0].PlayerName)
+
+	_, err = DB.Exec(`UPDATE game_results SET players_json = ?, winner_name = 'Bob' WHERE id = ?`,
+		`[{"playerName":"Alice","total":80,"rank":2},{"playerName":"Bob","total":90,"rank":1}]`, id)
+	require.NoError(t, err)
+
+	ratings, err = GetRatings(t.Context(), DefaultGroup)
+	require.NoError(t, err)
+	assert.Equal(t, "Bob", ratings[0].PlayerName)
+}
+
+// TestGetRatingHistory_UnknownPlayer tests history for a player with no games
+func TestGetRatingHistory_UnknownPlayer(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	saveGameOn(t, "2024-01-01", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 90, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 80, Rank: 2})
+
+	history, err := GetRatingHistory(t.Context(), DefaultGroup, "Nobody")
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+// TestGetRatings_SavedGamesAppend tests that a group's latest game is appended
+// to its history, and that earlier games only clear their own group's
+func TestGetRatings_SavedGamesAppend(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+	require.NoError(t, CreateGroup(t.Context(), &Group{Slug: "club", Name: "Club"}))
+
+	save := func(group, date, winner, loser string) int64 {
+		t.Helper()
+		playedAt, err := time.Parse("2006-01-02", date)
+		require.NoError(t, err)
+		id, err := SaveGame(t.Context(), &GameResult{Group: group, CreatedAt: playedAt, Players: []scoring.PlayerGameEnd{
+			{PlayerName: winner, Total: 90, Rank: 1},
+			{PlayerName: loser, Total: 80, Rank: 2},
+		}})
+		require.NoError(t, err)
+		return id
+	}
+	historyRows := func(group string) int {
+		t.Helper()
+		var n int
+		require.NoError(t, DB.QueryRow(`SELECT COUNT(*) FROM rating_history WHERE group_id = ?`, group).Scan(&n))
+		return n
+	}
+
+	save(DefaultGroup, "2024-01-01", "Alice", "Bob")
+	save("club", "2024-01-01", "Carol", "Dave")
+	save(DefaultGroup, "2024-01-02", "Bob", "Alice")
+	assert.Equal(t, 4, historyRows(DefaultGroup))
+	assert.Equal(t, 2, historyRows("club"))
+
+	appended, err := GetRatingHistory(t.Context(), DefaultGroup, "Bob")
+	require.NoError(t, err)
+	require.NoError(t, RecomputeRatings(t.Context()))
+	replayed, err := GetRatingHistory(t.Context(), DefaultGroup, "Bob")
+	require.NoError(t, err)
+	assert.Equal(t, replayed, appended)
+
+	// A game played before the latest one is replayed on next read
+	save(DefaultGroup, "2023-12-31", "Alice", "Bob")
+	assert.Equal(t, 0, historyRows(DefaultGroup))
+	assert.Equal(t, 2, historyRows("club"))
+	history, err := GetRatingHistory(t.Context(), DefaultGroup, "Bob")
+	require.NoError(t, err)
+	assert.Len(t, history, 3)
+
+	// Deleting the latest game removes only its changes
+	latest := save(DefaultGroup, "2024-01-03", "Alice", "Bob")
+	assert.Equal(t, 8, historyRows(DefaultGroup))
+	require.NoError(t, DeleteGameResult(t.Context(), latest))
+	assert.Equal(t, 6, historyRows(DefaultGroup))
+}
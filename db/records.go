@@ -0,0 +1,143 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultRecordLimit is how many entries each record list holds by default
+const DefaultRecordLimit = 5
+
+// Record is one entry in a record list. Entries with the same value share a
+// place; among them the earliest game comes first.
+type Record struct {
+	Place          int       `json:"place"`
+	GameID         int64     `json:"gameId"`
+	PlayedAt       time.Time `json:"playedAt"`
+	PlayerName     string    `json:"playerName,omitempty"` // Empty for whole-table records
+	Value          int       `json:"value"`
+	NumPlayers     int       `json:"numPlayers"`
+	IncludeOceania bool      `json:"includeOceania"`
+}
+
+// RecordBook holds the top entries for each record
+type RecordBook struct {
+	Categories           map[string][]Record `json:"categories"`           // Highest value per scoring category
+	LowestWinningScore   []Record            `json:"lowestWinningScore"`   // Winner's total
+	BiggestMargin        []Record            `json:"biggestMargin"`        // Winner's total minus runner-up's
+	ClosestGame          []Record            `json:"closestGame"`          // Smallest winning margin
+	MostPointsInLoss     []Record            `json:"mostPointsInLoss"`     // Best total by a non-winner
+	HighestCombinedScore []Record            `json:"highestCombinedScore"` // Sum of all totals at the table
+}
+
+// winningMargins holds the winner's lead over the runner-up for each game
+// of two or more players
+const winningMargins = `SELECT game_id, position, player_name, margin AS value
+	FROM game_totals INDEXED BY idx_game_totals_margin WHERE margin IS NOT NULL`
+
+// recordList is one list in the record book. inner must select game_id,
+// position, player_name and value; cond is an extra condition on its rows
+// (aliased x) and order is the direction that ranks the best value first.
+type recordList struct {
+	name  string
+	inner string
+	cond  string
+	order string
+	set   func([]Record)
+}
+
+// recordLists returns the lists that make up book. Each reads value from an
+// indexed column, named so SQLite walks that index in order and stops once it
+// has enough records.
+func recordLists(book *RecordBook) []recordList {
+	var lists []recordList
+	for _, category := range ScoreCategories {
+		lists = append(lists, recordList{category.Key,
+			`SELECT game_id, position, player_name, ` + category.Column + ` AS value
+				FROM player_scores INDEXED BY idx_player_scores_` + category.Column,
+			"x.value > 0", "DESC", func(records []Record) { book.Categories[category.Key] = records }})
+	}
+	return append(lists,
+		recordList{"lowest winning score",
+			`SELECT game_id, position, player_name, total AS value
+				FROM player_scores INDEXED BY idx_player_scores_total WHERE rank = 1`,
+			"", "ASC", func(records []Record) { book.LowestWinningScore = records }},
+		recordList{"biggest margin", winningMargins, "", "DESC", func(records []Record) { book.BiggestMargin = records }},
+		recordList{"closest game", winningMargins, "", "ASC", func(records []Record) { book.ClosestGame = records }},
+		recordList{"most points in a loss",
+			`SELECT game_id, position, player_name, total AS value
+				FROM player_scores INDEXED BY idx_player_scores_total WHERE rank > 1`,
+			"", "DESC", func(records []Record) { book.MostPointsInLoss = records }},
+		recordList{"highest combined score",
+			`SELECT game_id, 0 AS position, '' AS player_name, combined AS value
+				FROM game_totals INDEXED BY idx_game_totals_combined`,
+			"", "DESC", func(records []Record) { book.HighestCombinedScore = records }},
+	)
+}
+
+// GetRecords returns the record book for games matching the filter, with up
+// to limit entries per record
+func GetRecords(ctx context.Context, filter GameFilter, limit int) (*RecordBook, error) {
+	ctx = named(ctx, "GetRecords")
+	if limit <= 0 {
+		limit = DefaultRecordLimit
+	}
+
+	book := &RecordBook{Categories: make(map[string][]Record)}
+	for _, list := range recordLists(book) {
+		records, err := queryRecords(ctx, filter, limit, list)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query %s records: %w", list.name, err)
+		}
+		list.set(records)
+	}
+
+	return book, nil
+}
+
+// recordQuery returns the query for a record list and its arguments. The
+// CROSS JOIN makes SQLite read the list's rows first and look up their
+// games, rather than sorting every game in the group.
+func recordQuery(filter GameFilter, limit int, list recordList) (string, []interface{}) {
+	where, args := filter.whereClause()
+	if list.cond != "" {
+		where = andWhere(where, list.cond)
+	}
+
+	query := `
+		SELECT game_results.id, game_results.created_at, game_results.num_players, game_results.include_oceania, x.player_name, x.value
+		FROM (` + list.inner + `) x
+		CROSS JOIN game_results ON game_results.id = x.game_id
+		` + where + `
+		ORDER BY x.value ` + list.order + `, game_results.created_at, game_results.id, x.position
+		LIMIT ?
+	`
+	return query, append(args, limit)
+}
+
+// queryRecords runs a record list's query
+func queryRecords(ctx context.Context, filter GameFilter, limit int, list recordList) ([]Record, error) {
+	query, args := recordQuery(filter, limit, list)
+	rows, err := DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []Record{}
+	for rows.Next() {
+		var r Record
+		if err := rows.Scan(&r.GameID, &r.PlayedAt, &r.NumPlayers, &r.IncludeOceania, &r.PlayerName, &r.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan record: %w", err)
+		}
+
+		r.Place = len(records) + 1
+		if prev := len(records) - 1; prev >= 0 && records[prev].Value == r.Value {
+			r.Place = records[prev].Place
+		}
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
@@ -0,0 +1,236 @@
+package db
+
+import (
+	"testing"
+	"time"
+	"wingspan-scoring/scoring"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seedRecordGames saves a small set of games with distinct record holders
+func seedRecordGames(t *testing.T) (g1, g2, g3 int64) {
+	g1 = saveGameOn(t, "2024-01-01", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", BirdPoints: 50, Eggs: 10, Total: 120, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", BirdPoints: 30, Eggs: 15, Total: 80, Rank: 2})
+	g2 = saveGameOn(t, "2024-01-02", true,
+		scoring.PlayerGameEnd{PlayerName: "Bob", BirdPoints: 40, Eggs: 8, NectarForest: 5, Total: 90, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Carol", BirdPoints: 45, Eggs: 8, Total: 88, Rank: 2},
+		scoring.PlayerGameEnd{PlayerName: "Alice", BirdPoints: 35, Eggs: 6, Total: 70, Rank: 3})
+	g3 = saveGameOn(t, "2024-01-03", false,
+		scoring.PlayerGameEnd{PlayerName: "Carol", BirdPoints: 50, Eggs: 4, Total: 100, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Alice", BirdPoints: 20, Eggs: 4, Total: 99, Rank: 2})
+	return g1, g2, g3
+}
+
+// TestGetRecords_Empty tests the record book with no games
+func TestGetRecords_Empty(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	book, err := GetRecords(t.Context(), GameFilter{}, 0)
+	require.NoError(t, err)
+	assert.Len(t, book.Categories, len(ScoreCategories))
+	assert.Empty(t, book.Categories["totalScore"])
+	assert.Empty(t, book.BiggestMargin)
+}
+
+// TestGetRecords_Categories tests top-N category records with shared places
+func TestGetRecords_Categories(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	g1, g2, g3 := seedRecordGames(t)
+
+	book, err := GetRecords(t.Context(), GameFilter{}, 3)
+	require.NoError(t, err)
+
+	birds := book.Categories["birdPoints"]
+	require.Len(t, birds, 3)
+	// Tied at 50: the earlier game comes first and both share first place
+	assert.Equal(t, Record{Place: 1, GameID: g1, PlayedAt: birds[0].PlayedAt, PlayerName: "Alice", Value: 50, NumPlayers: 2}, birds[0])
+	assert.Equal(t, 1, birds[1].Place)
+	assert.Equal(t, g3, birds[1].GameID)
+	assert.Equal(t, "Carol", birds[1].PlayerName)
+	assert.Equal(t, 3, birds[2].Place)
+	assert.Equal(t, 45, birds[2].Value)
+	assert.Equal(t, 2024, birds[0].PlayedAt.Year())
+
+	nectar := book.Categories["nectarForest"]
+	require.Len(t, nectar, 1, "zero values aren't records")
+	assert.Equal(t, g2, nectar[0].GameID)
+	assert.True(t, nectar[0].IncludeOceania)
+}
+
+// TestGetRecords_GameRecords tests the whole-game records
+func TestGetRecords_GameRecords(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	g1, g2, g3 := seedRecordGames(t)
+
+	book, err := GetRecords(t.Context(), GameFilter{}, 5)
+	require.NoError(t, err)
+
+	require.Len(t, book.LowestWinningScore, 3)
+	assert.Equal(t, g2, book.LowestWinningScore[0].GameID)
+	assert.Equal(t, "Bob", book.LowestWinningScore[0].PlayerName)
+	assert.Equal(t, 90, book.LowestWinningScore[0].Value)
+
+	require.Len(t, book.BiggestMargin, 3)
+	assert.Equal(t, g1, book.BiggestMargin[0].GameID)
+	assert.Equal(t, "Alice", book.BiggestMargin[0].PlayerName)
+	assert.Equal(t, 40, book.BiggestMargin[0].Value)
+
+	require.Len(t, book.ClosestGame, 3)
+	assert.Equal(t, g3, book.ClosestGame[0].GameID)
+	assert.Equal(t, 1, book.ClosestGame[0].Value)
+
+	require.Len(t, book.MostPointsInLoss, 4)
+	assert.Equal(t, g3, book.MostPointsInLoss[0].GameID)
+	assert.Equal(t, "Alice", book.MostPointsInLoss[0].PlayerName)
+	assert.Equal(t, 99, book.MostPointsInLoss[0].Value)
+
+	require.Len(t, book.HighestCombinedScore, 3)
+	assert.Equal(t, g2, book.HighestCombinedScore[0].GameID)
+	assert.Equal(t, 248, book.HighestCombinedScore[0].Value)
+	assert.Empty(t, book.HighestCombinedScore[0].PlayerName)
+	assert.Equal(t, 3, book.HighestCombinedScore[0].NumPlayers)
+}
+
+// TestGetRecords_Filtered tests records restricted by player count and expansion
+func TestGetRecords_Filtered(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	_, g2, g3 := seedRecordGames(t)
+
+	book, err := GetRecords(t.Context(), GameFilter{NumPlayers: 3}, 5)
+	require.NoError(t, err)
+	require.Len(t, book.Categories["totalScore"], 3)
+	assert.Equal(t, 90, book.Categories["totalScore"][0].Value)
+	require.Len(t, book.BiggestMargin, 1)
+	assert.Equal(t, g2, book.BiggestMargin[0].GameID)
+
+	no := false
+	book, err = GetRecords(t.Context(), GameFilter{Oceania: &no, From: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)}, 5)
+	require.NoError(t, err)
+	require.Len(t, book.HighestCombinedScore, 1)
+	assert.Equal(t, g3, book.HighestCombinedScore[0].GameID)
+	assert.Empty(t, book.Categories["nectarForest"])
+}
+
+// TestPlayerScores_StayInSync tests that player_scores follows inserts, edits and deletes
+func TestPlayerScores_StayInSync(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	countRows := func(id int64) int {
+		var n int
+		require.NoError(t, DB.QueryRow(`SELECT COUNT(*) FROM player_scores WHERE game_id = ?`, id).Scan(&n))
+		return n
+	}
+
+	id, err := SaveGameResult(t.Context(), []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Eggs: 7, Total: 90, Rank: 1},
+		{PlayerName: "Bob", Total: 80, Rank: 2},
+	}, scoring.NectarScoring{}, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, countRows(id))
+
+	var eggs int
+	require.NoError(t, DB.QueryRow(`SELECT eggs FROM player_scores WHERE game_id = ? AND player_name = 'Alice'`, id).Scan(&eggs))
+	assert.Equal(t, 7, eggs)
+
+	_, err = DB.Exec(`UPDATE game_results SET players_json = ? WHERE id = ?`,
+		`[{"playerName":"Alice","total":90,"rank":1},{"playerName":"Bob","total":80,"rank":2},{"playerName":"Carol","total":70,"rank":3}]`, id)
+	require.NoError(t, err)
+	assert.Equal(t, 3, countRows(id))
+
+	require.NoError(t, DeleteGameResult(t.Context(), id))
+	assert.Equal(t, 0, countRows(id))
+}
+
+// TestPlayerScores_Backfill tests that games saved before player_scores existed are backfilled
+func TestPlayerScores_Backfill(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	seedRecordGames(t)
+	_, err := DB.Exec(`DELETE FROM player_scores`)
+	require.NoError(t, err)
+
+	require.NoError(t, createTables())
+
+	var n int
+	require.NoError(t, DB.QueryRow(`SELECT COUNT(*) FROM player_scores`).Scan(&n))
+	assert.Equal(t, 7, n)
+}
+
+// TestGameTotals_StayInSync tests that game_totals follows inserts, edits,
+// deletes and is backfilled for older games
+func TestGameTotals_StayInSync(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	totals := func(id int64) (winner string, margin *int, combined int) {
+		require.NoError(t, DB.QueryRow(`SELECT player_name, margin, combined FROM game_totals WHERE game_id = ?`, id).
+			Scan(&winner, &margin, &combined))
+		return winner, margin, combined
+	}
+
+	g1, g2, _ := seedRecordGames(t)
+	winner, margin, combined := totals(g2)
+	assert.Equal(t, "Bob", winner)
+	require.NotNil(t, margin)
+	assert.Equal(t, 2, *margin)
+	assert.Equal(t, 248, combined)
+
+	_, err := DB.Exec(`UPDATE game_results SET players_json = ? WHERE id = ?`, `[{"playerName":"Dave","total":75,"rank":1}]`, g1)
+	require.NoError(t, err)
+	winner, margin, combined = totals(g1)
+	assert.Equal(t, "Dave", winner)
+	assert.Nil(t, margin, "single-player games have no margin")
+	assert.Equal(t, 75, combined)
+
+	require.NoError(t, DeleteGameResult(t.Context(), g1))
+	var n int
+	require.NoError(t, DB.QueryRow(`SELECT COUNT(*) FROM game_totals`).Scan(&n))
+	assert.Equal(t, 2, n)
+
+	_, err = DB.Exec(`DELETE FROM game_totals`)
+	require.NoError(t, err)
+	require.NoError(t, createTables())
+	require.NoError(t, DB.QueryRow(`SELECT COUNT(*) FROM game_totals`).Scan(&n))
+	assert.Equal(t, 2, n)
+}
+
+// TestRecordQueries_UseIndexes tests that every record list reads its rows
+// in index order and stops at the limit, rather than sorting every game
+func TestRecordQueries_UseIndexes(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	seedRecordGames(t)
+	no := false
+	for _, filter := range []GameFilter{{}, {NumPlayers: 3, Oceania: &no, Players: []string{"Alice"}}} {
+		for _, list := range recordLists(&RecordBook{Categories: make(map[string][]Record)}) {
+			query, args := recordQuery(filter, 5, list)
+			rows, err := DB.Query(`EXPLAIN QUERY PLAN `+query, args...)
+			require.NoError(t, err)
+			var plan []string
+			for rows.Next() {
+				var id, parent, notUsed int
+				var detail string
+				require.NoError(t, rows.Scan(&id, &parent, &notUsed, &detail))
+				plan = append(plan, detail)
+			}
+			require.NoError(t, rows.Close())
+
+			require.NotEmpty(t, plan, list.name)
+			assert.Regexp(t, `^(SEARCH|SCAN) (player_scores|game_totals) USING (COVERING )?INDEX idx_`, plan[0], list.name)
+			assert.NotContains(t, plan, "USE TEMP B-TREE FOR ORDER BY", list.name)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// seasonDateFormat is the format of season start and end dates
+const seasonDateFormat = "2006-01-02"
+
+// DefaultPlacementPoints are the points for 1st, 2nd and 3rd place when a
+// season doesn't set its own
+var DefaultPlacementPoints = []int{3, 2, 1}
+
+// ErrSeasonNotFound is returned when a season ID doesn't exist
+var ErrSeasonNotFound = errors.New("season not found")
+
+// Season is a league covering games between two dates, inclusive, plus any
+// games tagged with it explicitly
+type Season struct {
+	ID              int64     `json:"id"`
+	Name            string    `json:"name"`
+	StartDate       string    `json:"startDate"` // YYYY-MM-DD
+	EndDate         string    `json:"endDate"`   // YYYY-MM-DD
+	Players         []string  `json:"players"`   // Players in the standings; empty means everyone
+	PlacementPoints []int     `json:"placementPoints"`
+	CreatedAt       time.Time `json:"createdAt"`
+	Group           string    `json:"-"` // Group slug; empty means DefaultGroup
+}
+
+// Validate checks the season's name and dates, and fills in default placement points
+func (s *Season) Validate() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return fmt.Errorf("season name is required")
+	}
+
+	start, err := time.Parse(seasonDateFormat, s.StartDate)
+	if err != nil {
+		return fmt.Errorf("invalid start date: %s", s.StartDate)
+	}
+	end, err := time.Parse(seasonDateFormat, s.EndDate)
+	if err != nil {
+		return fmt.Errorf("invalid end date: %s", s.EndDate)
+	}
+	if end.Before(start) {
+		return fmt.Errorf("end date must not be before start date")
+	}
+
+	if len(s.PlacementPoints) == 0 {
+		s.PlacementPoints = append([]int(nil), DefaultPlacementPoints...)
+	}
+	for _, points := range s.PlacementPoints {
+		if points < 0 {
+			return fmt.Errorf("placement points must not be negative")
+		}
+	}
+
+	if s.Players == nil {
+		s.Players = []string{}
+	}
+	return nil
+}
+
+// CreateSeason validates and saves a new season, returning its ID
+func CreateSeason(ctx context.Context, season *Season) (int64, error) {
+	ctx = named(ctx, "CreateSeason")
+	if err := season.Validate(); err != nil {
+		return 0, err
+	}
+
+	playersJSON, pointsJSON, err := marshalSeason(season)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := DB.ExecContext(ctx, `
+		INSERT INTO seasons (name, start_date, end_date, players_json, placement_points_json, group_id)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, season.Name, season.StartDate, season.EndDate, playersJSON, pointsJSON, groupOrDefault(season.Group))
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert season: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	season.ID = id
+	season.Group = groupOrDefault(season.Group)
+	return id, nil
+}
+
+// UpdateSeason validates and saves changes to an existing season
+func UpdateSeason(ctx context.Context, season *Season) error {
+	ctx = named(ctx, "UpdateSeason")
+	if err := season.Validate(); err != nil {
+		return err
+	}
+
+	playersJSON, pointsJSON, err := marshalSeason(season)
+	if err != nil {
+		return err
+	}
+
+	result, err := DB.ExecContext(ctx, `
+		UPDATE seasons
+		SET name = ?, start_date = ?, end_date = ?, players_json = ?, placement_points_json = ?
+		WHERE id = ?
+	`, season.Name, season.StartDate, season.EndDate, playersJSON, pointsJSON, season.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update season: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrSeasonNotFound
+	}
+	return nil
+}
+
+// DeleteSeason deletes a season and untags its games
+func DeleteSeason(ctx context.Context, id int64) error {
+	ctx = named(ctx, "DeleteSeason")
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM seasons WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete season: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrSeasonNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE game_results SET season_id = NULL WHERE season_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to untag games: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetSeason retrieves a season by ID
+func GetSeason(ctx context.Context, id int64) (*Season, error) {
+	ctx = named(ctx, "GetSeason")
+	row := DB.QueryRowContext(ctx, `SELECT `+seasonColumns+` FROM seasons WHERE id = ?`, id)
+	season, err := scanSeason(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSeasonNotFound
+		}
+		return nil, err
+	}
+	return &season, nil
+}
+
+// GetSeasons lists a group's seasons, most recent first
+func GetSeasons(ctx context.Context, group string) ([]Season, error) {
+	ctx = named(ctx, "GetSeasons")
+	rows, err := DB.QueryContext(ctx, `SELECT `+seasonColumns+` FROM seasons WHERE group_id = ? ORDER BY start_date DESC, id DESC`, groupOrDefault(group))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query seasons: %w", err)
+	}
+	defer rows.Close()
+
+	seasons := []Season{}
+	for rows.Next() {
+		season, err := scanSeason(rows)
+		if err != nil {
+			return nil, err
+		}
+		seasons = append(seasons, season)
+	}
+	return seasons, rows.Err()
+}
+
+// SetGameSeason tags a game with a season, or clears the tag when seasonID
+// is nil so the game is matched by date again. The season must belong to the
+// game's group.
+func SetGameSeason(ctx context.Context, gameID int64, seasonID *int64) error {
+	ctx = named(ctx, "SetGameSeason")
+	if seasonID != nil {
+		season, err := GetSeason(ctx, *seasonID)
+		if err != nil {
+			return err
+		}
+		game, err := GetGameResult(ctx, gameID)
+		if err != nil {
+			return err
+		}
+		if game.Group != season.Group {
+			return ErrSeasonNotFound
+		}
+	}
+
+	result, err := DB.ExecContext(ctx, `UPDATE game_results SET season_id = ? WHERE id = ?`, seasonID, gameID)
+	if err != nil {
+		return fmt.Errorf("failed to tag game: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("game result not found")
+	}
+	return nil
+}
+
+// seasonColumns lists the seasons columns scanSeason reads, in order
+const seasonColumns = `id, name, start_date, end_date, players_json, placement_points_json, created_at, group_id`
+
+// scanSeason reads a row selected with seasonColumns
+func scanSeason(row rowScanner) (Season, error) {
+	var season Season
+	var playersJSON, pointsJSON string
+
+	err := row.Scan(&season.ID, &season.Name, &season.StartDate, &season.EndDate, &playersJSON, &pointsJSON, &season.CreatedAt, &season.Group)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return season, err
+		}
+		return season, fmt.Errorf("failed to scan season: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(playersJSON), &season.Players); err != nil {
+		return season, fmt.Errorf("failed to unmarshal season players: %w", err)
+	}
+	if err := json.Unmarshal([]byte(pointsJSON), &season.PlacementPoints); err != nil {
+		return season, fmt.Errorf("failed to unmarshal placement points: %w", err)
+	}
+	return season, nil
+}
+
+// marshalSeason encodes a season's JSON columns
+func marshalSeason(season *Season) (string, string, error) {
+	playersJSON, err := json.Marshal(season.Players)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal season players: %w", err)
+	}
+	pointsJSON, err := json.Marshal(season.PlacementPoints)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal placement points: %w", err)
+	}
+	return string(playersJSON), string(pointsJSON), nil
+}
+
+// SeasonStanding is one player's position in a season
+type SeasonStanding struct {
+	Place        int     `json:"place"` // Shared when points and wins are equal
+	PlayerName   string  `json:"playerName"`
+	Points       int     `json:"points"`
+	GamesPlayed  int     `json:"gamesPlayed"`
+	Wins         int     `json:"wins"`
+	AverageScore float64 `json:"averageScore"`
+	AverageRank  float64 `json:"averageRank"`
+}
+
+// SeasonStandings holds a season's table
+type SeasonStandings struct {
+	Season    Season           `json:"season"`
+	Games     int              `json:"games"`
+	Standings []SeasonStanding `json:"standings"`
+}
+
+// GetSeasonStandings builds a season's table. Each game awards the season's
+// placement points by rank, so players sharing a rank get the same points.
+// Ties on points are broken by wins, then average score.
+func GetSeasonStandings(ctx context.Context, id int64) (*SeasonStandings, error) {
+	ctx = named(ctx, "GetSeasonStandings")
+	season, err := GetSeason(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := GameFilter{Season: id, Group: season.Group}
+	games, err := CountFilteredGameResults(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count season games: %w", err)
+	}
+
+	where, args := filter.whereClause()
+	if len(season.Players) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(season.Players)), ", ")
+		where = andWhere(where, "player_scores.player_name IN ("+placeholders+")")
+		for _, name := range season.Players {
+			args = append(args, name)
+		}
+	}
+
+	rows, err := DB.QueryContext(ctx, `
+		SELECT player_scores.player_name, player_scores.rank, COUNT(*), SUM(player_scores.total)
+		FROM player_scores
+		JOIN game_results ON game_results.id = player_scores.game_id
+		`+where+`
+		GROUP BY player_scores.player_name, player_scores.rank
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query season scores: %w", err)
+	}
+	defer rows.Close()
+
+	type totals struct {
+		standing SeasonStanding
+		score    int
+		rankSum  int
+	}
+	byPlayer := make(map[string]*totals)
+	for rows.Next() {
+		var name string
+		var rank, count, score int
+		if err := rows.Scan(&name, &rank, &count, &score); err != nil {
+			return nil, fmt.Errorf("failed to scan season score: %w", err)
+		}
+
+		t := byPlayer[name]
+		if t == nil {
+			t = &totals{standing: SeasonStanding{PlayerName: name}}
+			byPlayer[name] = t
+		}
+		t.standing.GamesPlayed += count
+		t.score += score
+		t.rankSum += rank * count
+		if rank == 1 {
+			t.standing.Wins += count
+		}
+		if rank >= 1 && rank <= len(season.PlacementPoints) {
+			t.standing.Points += season.PlacementPoints[rank-1] * count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	standings := make([]SeasonStanding, 0, len(byPlayer))
+	for _, t := range byPlayer {
+		t.standing.AverageScore = float64(t.score) / float64(t.standing.GamesPlayed)
+		t.standing.AverageRank = float64(t.rankSum) / float64(t.standing.GamesPlayed)
+		standings = append(standings, t.standing)
+	}
+
+	sort.Slice(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		if a.AverageScore != b.AverageScore {
+			return a.AverageScore > b.AverageScore
+		}
+		return a.PlayerName < b.PlayerName
+	})
+	for i := range standings {
+		standings[i].Place = i + 1
+		if i > 0 && standings[i].Points == standings[i-1].Points && standings[i].Wins == standings[i-1].Wins {
+			standings[i].Place = standings[i-1].Place
+		}
+	}
+
+	return &SeasonStandings{Season: *season, Games: games, Standings: standings}, nil
+}
@@ -0,0 +1,183 @@
+package db
+
+import (
+	"testing"
+	"wingspan-scoring/scoring"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCreateSeason tests saving, reading and defaulting a season
+func TestCreateSeason(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	id, err := CreateSeason(t.Context(), &Season{Name: " Spring League ", StartDate: "2024-01-01", EndDate: "2024-03-31"})
+	require.NoError(t, err)
+
+	season, err := GetSeason(t.Context(), id)
+	require.NoError(t, err)
+	assert.Equal(t, "Spring League", season.Name)
+	assert.Equal(t, "2024-01-01", season.StartDate)
+	assert.Equal(t, DefaultPlacementPoints, season.PlacementPoints)
+	assert.Empty(t, season.Players)
+	assert.False(t, season.CreatedAt.IsZero())
+
+	seasons, err := GetSeasons(t.Context(), DefaultGroup)
+	require.NoError(t, err)
+	assert.Len(t, seasons, 1)
+
+	_, err = GetSeason(t.Context(), id+1)
+	assert.ErrorIs(t, err, ErrSeasonNotFound)
+}
+
+// TestSeason_Validate tests that invalid seasons are rejected
+func TestSeason_Validate(t *testing.T) {
+	invalid := []Season{
+		{Name: "", StartDate: "2024-01-01", EndDate: "2024-03-31"},
+		{Name: "Q1", StartDate: "01/01/2024", EndDate: "2024-03-31"},
+		{Name: "Q1", StartDate: "2024-01-01", EndDate: "2024-13-01"},
+		{Name: "Q1", StartDate: "2024-03-31", EndDate: "2024-01-01"},
+		{Name: "Q1", StartDate: "2024-01-01", EndDate: "2024-03-31", PlacementPoints: []int{3, -1}},
+	}
+	for _, season := range invalid {
+		assert.Error(t, season.Validate(), "%+v", season)
+	}
+
+	valid := Season{Name: "Q1", StartDate: "2024-01-01", EndDate: "2024-01-01"}
+	assert.NoError(t, valid.Validate())
+}
+
+// TestUpdateAndDeleteSeason tests editing a season and that deleting it untags games
+func TestUpdateAndDeleteSeason(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	season := &Season{Name: "Q1", StartDate: "2024-01-01", EndDate: "2024-03-31"}
+	id, err := CreateSeason(t.Context(), season)
+	require.NoError(t, err)
+
+	season.Name = "Winter"
+	season.PlacementPoints = []int{5, 3}
+	require.NoError(t, UpdateSeason(t.Context(), season))
+	updated, err := GetSeason(t.Context(), id)
+	require.NoError(t, err)
+	assert.Equal(t, "Winter", updated.Name)
+	assert.Equal(t, []int{5, 3}, updated.PlacementPoints)
+
+	gameID := saveGameOn(t, "2023-06-01", false, scoring.PlayerGameEnd{PlayerName: "Alice", Total: 50, Rank: 1})
+	require.NoError(t, SetGameSeason(t.Context(), gameID, &id))
+
+	require.NoError(t, DeleteSeason(t.Context(), id))
+	game, err := GetGameResult(t.Context(), gameID)
+	require.NoError(t, err)
+	assert.Nil(t, game.SeasonID)
+
+	assert.ErrorIs(t, DeleteSeason(t.Context(), id), ErrSeasonNotFound)
+	assert.ErrorIs(t, UpdateSeason(t.Context(), season), ErrSeasonNotFound)
+	assert.ErrorIs(t, SetGameSeason(t.Context(), gameID, &id), ErrSeasonNotFound)
+}
+
+// TestGameFilter_Season tests games are matched by date or explicit tag
+func TestGameFilter_Season(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	q1, err := CreateSeason(t.Context(), &Season{Name: "Q1", StartDate: "2024-01-01", EndDate: "2024-03-31"})
+	require.NoError(t, err)
+	q2, err := CreateSeason(t.Context(), &Season{Name: "Q2", StartDate: "2024-04-01", EndDate: "2024-06-30"})
+	require.NoError(t, err)
+
+	alice := scoring.PlayerGameEnd{PlayerName: "Alice", Total: 50, Rank: 1}
+	saveGameOn(t, "2024-01-01", false, alice)
+	saveGameOn(t, "2024-03-31", false, alice)
+	moved := saveGameOn(t, "2024-02-15", false, alice)
+	saveGameOn(t, "2024-04-01", false, alice)
+	tagged := saveGameOn(t, "2023-12-01", false, alice)
+
+	require.NoError(t, SetGameSeason(t.Context(), moved, &q2))
+	require.NoError(t, SetGameSeason(t.Context(), tagged, &q1))
+
+	count, err := CountFilteredGameResults(t.Context(), GameFilter{Season: q1})
+	require.NoError(t, err)
+	assert.Equal(t, 3, count, "both end dates count, plus the tagged game")
+
+	count, err = CountFilteredGameResults(t.Context(), GameFilter{Season: q2})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	require.NoError(t, SetGameSeason(t.Context(), moved, nil))
+	count, err = CountFilteredGameResults(t.Context(), GameFilter{Season: q1})
+	require.NoError(t, err)
+	assert.Equal(t, 4, count, "untagged games match by date again")
+}
+
+// TestGetSeasonStandings tests placement points, tiebreakers and shared places
+func TestGetSeasonStandings(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	id, err := CreateSeason(t.Context(), &Season{Name: "Q1", StartDate: "2024-01-01", EndDate: "2024-03-31"})
+	require.NoError(t, err)
+
+	saveGameOn(t, "2024-01-05", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 90, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 80, Rank: 2},
+		scoring.PlayerGameEnd{PlayerName: "Carol", Total: 70, Rank: 3},
+		scoring.PlayerGameEnd{PlayerName: "Dave", Total: 60, Rank: 4})
+	saveGameOn(t, "2024-02-05", false,
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 100, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 100, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Carol", Total: 50, Rank: 3})
+	// Outside the season
+	saveGameOn(t, "2024-05-01", false,
+		scoring.PlayerGameEnd{PlayerName: "Dave", Total: 120, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 60, Rank: 2})
+
+	result, err := GetSeasonStandings(t.Context(), id)
+	require.NoError(t, err)
+	assert.Equal(t, "Q1", result.Season.Name)
+	assert.Equal(t, 2, result.Games)
+	require.Len(t, result.Standings, 4)
+
+	alice := result.Standings[0]
+	assert.Equal(t, SeasonStanding{Place: 1, PlayerName: "Alice", Points: 6, GamesPlayed: 2, Wins: 2, AverageScore: 95, AverageRank: 1}, alice)
+	assert.Equal(t, "Bob", result.Standings[1].PlayerName)
+	assert.Equal(t, 5, result.Standings[1].Points)
+	assert.Equal(t, "Carol", result.Standings[2].PlayerName)
+	assert.Equal(t, 2, result.Standings[2].Points)
+	// Fourth place earns nothing by default
+	assert.Equal(t, SeasonStanding{Place: 4, PlayerName: "Dave", Points: 0, GamesPlayed: 1, AverageScore: 60, AverageRank: 4}, result.Standings[3])
+}
+
+// TestGetSeasonStandings_Players tests that a season's player list limits the standings
+func TestGetSeasonStandings_Players(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	id, err := CreateSeason(t.Context(), &Season{
+		Name:            "League",
+		StartDate:       "2024-01-01",
+		EndDate:         "2024-12-31",
+		Players:         []string{"Alice", "Bob"},
+		PlacementPoints: []int{2, 2},
+	})
+	require.NoError(t, err)
+
+	saveGameOn(t, "2024-01-05", false,
+		scoring.PlayerGameEnd{PlayerName: "Carol", Total: 90, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Total: 80, Rank: 2},
+		scoring.PlayerGameEnd{PlayerName: "Alice", Total: 70, Rank: 3})
+
+	result, err := GetSeasonStandings(t.Context(), id)
+	require.NoError(t, err)
+	require.Len(t, result.Standings, 2)
+	assert.Equal(t, "Bob", result.Standings[0].PlayerName)
+	assert.Equal(t, 2, result.Standings[0].Points)
+	assert.Equal(t, 0, result.Standings[1].Points)
+	assert.Equal(t, 2, result.Standings[1].Place)
+
+	_, err = GetSeasonStandings(t.Context(), id+1)
+	assert.ErrorIs(t, err, ErrSeasonNotFound)
+}
@@ -0,0 +1,229 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SessionDuration is how long a login lasts
+const SessionDuration = 14 * 24 * time.Hour
+
+// APITokenPrefix starts every API token, so they're easy to recognise in config and logs
+const APITokenPrefix = "wst_"
+
+var (
+	// ErrSessionNotFound is returned for unknown or expired session tokens
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrAPITokenNotFound is returned for unknown or revoked API tokens
+	ErrAPITokenNotFound = errors.New("API token not found")
+)
+
+// Session is a signed-in browser. Only a hash of its token is stored.
+type Session struct {
+	User      User
+	CSRFToken string // Must accompany requests that change data
+	ExpiresAt time.Time
+}
+
+// APIToken is a personal token for API clients. Only a hash of it is stored.
+type APIToken struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// newToken returns a random URL-safe token
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the stored form of a session or API token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateSession signs a user in, returning the session token for their cookie
+func CreateSession(ctx context.Context, userID int64) (string, *Session, error) {
+	ctx = named(ctx, "CreateSession")
+	token, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+	csrfToken, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+	expiresAt := time.Now().Add(SessionDuration).UTC()
+
+	_, err = DB.ExecContext(ctx, `
+		INSERT INTO sessions (token_hash, user_id, csrf_token, expires_at)
+		VALUES (?, ?, ?, ?)
+	`, hashToken(token), userID, csrfToken, expiresAt.Format(sqliteTimeFormat))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to insert session: %w", err)
+	}
+
+	session, err := GetSession(ctx, token)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, session, nil
+}
+
+// GetSession looks up an unexpired session by its token
+func GetSession(ctx context.Context, token string) (*Session, error) {
+	ctx = named(ctx, "GetSession")
+	var session Session
+	err := DB.QueryRowContext(ctx, `
+		SELECT users.id, users.username, users.role, users.player_name, users.created_at, sessions.csrf_token, sessions.expires_at
+		FROM sessions
+		JOIN users ON users.id = sessions.user_id
+		WHERE sessions.token_hash = ? AND sessions.expires_at > ?
+	`, hashToken(token), time.Now().UTC().Format(sqliteTimeFormat)).Scan(
+		&session.User.ID, &session.User.Username, &session.User.Role, &session.User.PlayerName, &session.User.CreatedAt,
+		&session.CSRFToken, &session.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to query session: %w", err)
+	}
+	return &session, nil
+}
+
+// DeleteSession signs a session out
+func DeleteSession(ctx context.Context, token string) error {
+	ctx = named(ctx, "DeleteSession")
+	if _, err := DB.ExecContext(ctx, `DELETE FROM sessions WHERE token_hash = ?`, hashToken(token)); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredSessions removes sessions past their expiry
+func DeleteExpiredSessions(ctx context.Context) error {
+	ctx = named(ctx, "DeleteExpiredSessions")
+	if _, err := DB.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at <= ?`, time.Now().UTC().Format(sqliteTimeFormat)); err != nil {
+		return fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+	return nil
+}
+
+// CreateAPIToken issues a named API token for a user. The token is only
+// returned here; afterwards just its hash is kept.
+func CreateAPIToken(ctx context.Context, userID int64, name string) (string, *APIToken, error) {
+	ctx = named(ctx, "CreateAPIToken")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, fmt.Errorf("token name is required")
+	}
+
+	secret, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+	token := APITokenPrefix + secret
+
+	result, err := DB.ExecContext(ctx, `INSERT INTO api_tokens (user_id, name, token_hash) VALUES (?, ?, ?)`, userID, name, hashToken(token))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to insert API token: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+
+	tokens, err := GetAPITokens(ctx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+	for i := range tokens {
+		if tokens[i].ID == id {
+			return token, &tokens[i], nil
+		}
+	}
+	return "", nil, ErrAPITokenNotFound
+}
+
+// GetAPITokens lists a user's API tokens, newest first
+func GetAPITokens(ctx context.Context, userID int64) ([]APIToken, error) {
+	ctx = named(ctx, "GetAPITokens")
+	rows, err := DB.QueryContext(ctx, `
+		SELECT id, name, created_at, last_used_at
+		FROM api_tokens
+		WHERE user_id = ?
+		ORDER BY created_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []APIToken{}
+	for rows.Next() {
+		var t APIToken
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&t.ID, &t.Name, &t.CreatedAt, &lastUsed); err != nil {
+			return nil, fmt.Errorf("failed to scan API token: %w", err)
+		}
+		if lastUsed.Valid {
+			t.LastUsedAt = &lastUsed.Time
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteAPIToken revokes one of a user's API tokens
+func DeleteAPIToken(ctx context.Context, userID, id int64) error {
+	ctx = named(ctx, "DeleteAPIToken")
+	result, err := DB.ExecContext(ctx, `DELETE FROM api_tokens WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete API token: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrAPITokenNotFound
+	}
+	return nil
+}
+
+// AuthenticateAPIToken returns the user an API token belongs to and records its use
+func AuthenticateAPIToken(ctx context.Context, token string) (*User, error) {
+	ctx = named(ctx, "AuthenticateAPIToken")
+	if !strings.HasPrefix(token, APITokenPrefix) {
+		return nil, ErrAPITokenNotFound
+	}
+
+	var id, userID int64
+	err := DB.QueryRowContext(ctx, `SELECT id, user_id FROM api_tokens WHERE token_hash = ?`, hashToken(token)).Scan(&id, &userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAPITokenNotFound
+		}
+		return nil, fmt.Errorf("failed to query API token: %w", err)
+	}
+
+	now := time.Now().UTC().Format(sqliteTimeFormat)
+	if _, err := DB.ExecContext(ctx, `UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, now, id); err != nil {
+		return nil, fmt.Errorf("failed to record API token use: %w", err)
+	}
+	return GetUser(ctx, userID)
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSessions tests signing in and out
+func TestSessions(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	user, err := CreateUser(t.Context(), "alice", "password1", RoleViewer)
+	require.NoError(t, err)
+
+	token, session, err := CreateSession(t.Context(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", session.User.Username)
+	assert.NotEmpty(t, session.CSRFToken)
+	assert.True(t, session.ExpiresAt.After(time.Now()))
+
+	// Only a hash of the token is stored
+	var stored int
+	require.NoError(t, DB.QueryRow(`SELECT COUNT(*) FROM sessions WHERE token_hash = ?`, token).Scan(&stored))
+	assert.Equal(t, 0, stored)
+
+	found, err := GetSession(t.Context(), token)
+	require.NoError(t, err)
+	assert.Equal(t, session.CSRFToken, found.CSRFToken)
+
+	_, err = GetSession(t.Context(), "unknown")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+
+	require.NoError(t, DeleteSession(t.Context(), token))
+	_, err = GetSession(t.Context(), token)
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+}
+
+// TestSessions_Expired tests that expired sessions are rejected and cleaned up
+func TestSessions_Expired(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	user, err := CreateUser(t.Context(), "alice", "password1", RoleViewer)
+	require.NoError(t, err)
+	token, _, err := CreateSession(t.Context(), user.ID)
+	require.NoError(t, err)
+
+	_, err = DB.Exec(`UPDATE sessions SET expires_at = '2000-01-01 00:00:00'`)
+	require.NoError(t, err)
+
+	_, err = GetSession(t.Context(), token)
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+
+	require.NoError(t, DeleteExpiredSessions(t.Context()))
+	var count int
+	require.NoError(t, DB.QueryRow(`SELECT COUNT(*) FROM sessions`).Scan(&count))
+	assert.Equal(t, 0, count)
+}
+
+// TestAPITokens tests issuing, using and revoking API tokens
+func TestAPITokens(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	alice, err := CreateUser(t.Context(), "alice", "password1", RoleScorer)
+	require.NoError(t, err)
+	bob, err := CreateUser(t.Context(), "bob", "password1", RoleViewer)
+	require.NoError(t, err)
+
+	token, info, err := CreateAPIToken(t.Context(), alice.ID, "scripts")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, APITokenPrefix))
+	assert.Equal(t, "scripts", info.Name)
+	assert.Nil(t, info.LastUsedAt)
+
+	_, _, err = CreateAPIToken(t.Context(), alice.ID, " ")
+	assert.Error(t, err)
+
+	user, err := AuthenticateAPIToken(t.Context(), token)
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, user.ID)
+
+	tokens, err := GetAPITokens(t.Context(), alice.ID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	assert.NotNil(t, tokens[0].LastUsedAt)
+
+	_, err = AuthenticateAPIToken(t.Context(), APITokenPrefix+"unknown")
+	assert.ErrorIs(t, err, ErrAPITokenNotFound)
+
+	// Users can only revoke their own tokens
+	assert.ErrorIs(t, DeleteAPIToken(t.Context(), bob.ID, info.ID), ErrAPITokenNotFound)
+	require.NoError(t, DeleteAPIToken(t.Context(), alice.ID, info.ID))
+	_, err = AuthenticateAPIToken(t.Context(), token)
+	assert.ErrorIs(t, err, ErrAPITokenNotFound)
+
+	// Deleting a user revokes their tokens
+	token, _, err = CreateAPIToken(t.Context(), bob.ID, "phone")
+	require.NoError(t, err)
+	require.NoError(t, DeleteUser(t.Context(), bob.ID))
+	_, err = AuthenticateAPIToken(t.Context(), token)
+	assert.ErrorIs(t, err, ErrAPITokenNotFound)
+}
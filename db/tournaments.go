@@ -0,0 +1,722 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tournament pairing methods
+const (
+	PairingSwiss  = "swiss"  // Tables are filled in standings order
+	PairingRandom = "random" // Tables are shuffled each round
+)
+
+// Tournament table sizes
+const (
+	MinTableSize     = 3
+	MaxTableSize     = 5
+	DefaultTableSize = 4
+)
+
+// Tournament statuses, derived from its tables
+const (
+	TournamentRegistration = "registration"
+	TournamentInProgress   = "in progress"
+	TournamentFinal        = "final"
+	TournamentFinished     = "finished"
+)
+
+// DefaultTournamentPoints are the points for each place at a table when a
+// tournament doesn't set its own
+var DefaultTournamentPoints = []int{5, 3, 2, 1, 0}
+
+var (
+	// ErrTournamentNotFound is returned when a tournament ID doesn't exist
+	ErrTournamentNotFound = errors.New("tournament not found")
+	// ErrTournamentTableNotFound is returned when a table isn't part of the tournament
+	ErrTournamentTableNotFound = errors.New("tournament table not found")
+	// ErrTournamentState is returned for actions the tournament isn't ready for
+	ErrTournamentState = errors.New("not allowed at this stage of the tournament")
+	// ErrTournamentResult is returned when a result's players don't match the table
+	ErrTournamentResult = errors.New("result doesn't match the table")
+)
+
+// Tournament is an event played over rounds of tables, finishing with a
+// final table for the top seeds
+type Tournament struct {
+	ID              int64              `json:"id"`
+	Name            string             `json:"name"`
+	Pairing         string             `json:"pairing"`         // PairingSwiss or PairingRandom
+	TableSize       int                `json:"tableSize"`       // Preferred players per table
+	FinalTableSize  int                `json:"finalTableSize"`  // Players in the final
+	PlacementPoints []int              `json:"placementPoints"` // Tournament points for 1st, 2nd, ... at a table
+	Seed            int64              `json:"seed"`            // Random seed, so random rounds can be reproduced
+	Status          string             `json:"status"`
+	Players         []TournamentPlayer `json:"players"`
+	Tables          []TournamentTable  `json:"tables"`
+	CreatedAt       time.Time          `json:"createdAt"`
+	Group           string             `json:"-"` // Group slug; empty means DefaultGroup
+}
+
+// TournamentPlayer is a registered player. Seeds follow registration order.
+type TournamentPlayer struct {
+	PlayerName string `json:"playerName"`
+	Seed       int    `json:"seed"`
+}
+
+// TournamentTable is one game in a round. GameID is set once its result is recorded.
+type TournamentTable struct {
+	ID          int64    `json:"id"`
+	Round       int      `json:"round"`
+	TableNumber int      `json:"tableNumber"`
+	Final       bool     `json:"final"`
+	Players     []string `json:"players"`
+	GameID      *int64   `json:"gameId,omitempty"`
+}
+
+// TournamentStanding is one player's position. OpponentPoints (Buchholz) is
+// the sum of every opponent's tournament points, counted once per table
+// played against them.
+type TournamentStanding struct {
+	Place          int    `json:"place"`
+	PlayerName     string `json:"playerName"`
+	Seed           int    `json:"seed"`
+	Points         int    `json:"points"`
+	OpponentPoints int    `json:"opponentPoints"`
+	TotalScore     int    `json:"totalScore"` // Sum of Wingspan scores
+	GamesPlayed    int    `json:"gamesPlayed"`
+	Wins           int    `json:"wins"`
+	FinalRank      int    `json:"finalRank,omitempty"` // Rank at the final table, once played
+}
+
+// Validate checks the tournament's settings and fills in defaults
+func (t *Tournament) Validate() error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return fmt.Errorf("tournament name is required")
+	}
+
+	if t.Pairing == "" {
+		t.Pairing = PairingSwiss
+	}
+	if t.Pairing != PairingSwiss && t.Pairing != PairingRandom {
+		return fmt.Errorf("invalid pairing: %s (must be swiss or random)", t.Pairing)
+	}
+
+	if t.TableSize == 0 {
+		t.TableSize = DefaultTableSize
+	}
+	if t.TableSize < MinTableSize || t.TableSize > MaxTableSize {
+		return fmt.Errorf("table size must be between %d and %d", MinTableSize, MaxTableSize)
+	}
+	if t.FinalTableSize == 0 {
+		t.FinalTableSize = t.TableSize
+	}
+	if t.FinalTableSize < MinTableSize || t.FinalTableSize > MaxTableSize {
+		return fmt.Errorf("final table size must be between %d and %d", MinTableSize, MaxTableSize)
+	}
+
+	if len(t.PlacementPoints) == 0 {
+		t.PlacementPoints = append([]int(nil), DefaultTournamentPoints...)
+	}
+	for _, points := range t.PlacementPoints {
+		if points < 0 {
+			return fmt.Errorf("placement points must not be negative")
+		}
+	}
+	return nil
+}
+
+// CreateTournament validates and saves a new tournament, returning its ID.
+// A random seed is chosen when none is set.
+func CreateTournament(ctx context.Context, t *Tournament) (int64, error) {
+	ctx = named(ctx, "CreateTournament")
+	if err := t.Validate(); err != nil {
+		return 0, err
+	}
+	if t.Seed == 0 {
+		t.Seed = time.Now().UnixNano()
+	}
+
+	pointsJSON, err := json.Marshal(t.PlacementPoints)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal placement points: %w", err)
+	}
+
+	result, err := DB.ExecContext(ctx, `
+		INSERT INTO tournaments (name, pairing, table_size, final_table_size, placement_points_json, seed, group_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, t.Name, t.Pairing, t.TableSize, t.FinalTableSize, string(pointsJSON), t.Seed, groupOrDefault(t.Group))
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert tournament: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	t.ID = id
+	t.Group = groupOrDefault(t.Group)
+	t.Status = TournamentRegistration
+	t.Players = []TournamentPlayer{}
+	t.Tables = []TournamentTable{}
+	return id, nil
+}
+
+// GetTournaments lists a group's tournaments, newest first
+func GetTournaments(ctx context.Context, group string) ([]Tournament, error) {
+	ctx = named(ctx, "GetTournaments")
+	rows, err := DB.QueryContext(ctx, `SELECT id FROM tournaments WHERE group_id = ? ORDER BY created_at DESC, id DESC`, groupOrDefault(group))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tournaments: %w", err)
+	}
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan tournament: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tournaments := make([]Tournament, 0, len(ids))
+	for _, id := range ids {
+		t, err := GetTournament(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		tournaments = append(tournaments, *t)
+	}
+	return tournaments, nil
+}
+
+// GetTournament retrieves a tournament with its players and tables
+func GetTournament(ctx context.Context, id int64) (*Tournament, error) {
+	ctx = named(ctx, "GetTournament")
+	t := &Tournament{ID: id}
+	var pointsJSON string
+	err := DB.QueryRowContext(ctx, `
+		SELECT name, pairing, table_size, final_table_size, placement_points_json, seed, created_at, group_id
+		FROM tournaments WHERE id = ?
+	`, id).Scan(&t.Name, &t.Pairing, &t.TableSize, &t.FinalTableSize, &pointsJSON, &t.Seed, &t.CreatedAt, &t.Group)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTournamentNotFound
+		}
+		return nil, fmt.Errorf("failed to query tournament: %w", err)
+	}
+	if err := json.Unmarshal([]byte(pointsJSON), &t.PlacementPoints); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal placement points: %w", err)
+	}
+
+	if t.Players, err = getTournamentPlayers(ctx, id); err != nil {
+		return nil, err
+	}
+	if t.Tables, err = getTournamentTables(ctx, id); err != nil {
+		return nil, err
+	}
+
+	t.Status = TournamentRegistration
+	for _, table := range t.Tables {
+		t.Status = TournamentInProgress
+		if table.Final {
+			t.Status = TournamentFinal
+			if table.GameID != nil {
+				t.Status = TournamentFinished
+			}
+		}
+	}
+	return t, nil
+}
+
+// getTournamentPlayers lists a tournament's players in seed order
+func getTournamentPlayers(ctx context.Context, id int64) ([]TournamentPlayer, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT player_name, seed FROM tournament_players WHERE tournament_id = ? ORDER BY seed`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tournament players: %w", err)
+	}
+	defer rows.Close()
+
+	players := []TournamentPlayer{}
+	for rows.Next() {
+		var p TournamentPlayer
+		if err := rows.Scan(&p.PlayerName, &p.Seed); err != nil {
+			return nil, fmt.Errorf("failed to scan tournament player: %w", err)
+		}
+		players = append(players, p)
+	}
+	return players, rows.Err()
+}
+
+// getTournamentTables lists a tournament's tables in round and table order
+func getTournamentTables(ctx context.Context, id int64) ([]TournamentTable, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT id, round, table_number, is_final, players_json, game_id
+		FROM tournament_tables
+		WHERE tournament_id = ?
+		ORDER BY round, table_number
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tournament tables: %w", err)
+	}
+	defer rows.Close()
+
+	tables := []TournamentTable{}
+	for rows.Next() {
+		var table TournamentTable
+		var playersJSON string
+		var gameID sql.NullInt64
+		if err := rows.Scan(&table.ID, &table.Round, &table.TableNumber, &table.Final, &playersJSON, &gameID); err != nil {
+			return nil, fmt.Errorf("failed to scan tournament table: %w", err)
+		}
+		if err := json.Unmarshal([]byte(playersJSON), &table.Players); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal table players: %w", err)
+		}
+		if gameID.Valid {
+			table.GameID = &gameID.Int64
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// DeleteTournament deletes a tournament with its players and tables. Games
+// played in it are kept.
+func DeleteTournament(ctx context.Context, id int64) error {
+	ctx = named(ctx, "DeleteTournament")
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM tournaments WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete tournament: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrTournamentNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tournament_players WHERE tournament_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete tournament players: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tournament_tables WHERE tournament_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete tournament tables: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// RegisterTournamentPlayer adds a player before the first round, seeded
+// after everyone already registered
+func RegisterTournamentPlayer(ctx context.Context, id int64, playerName string) (*TournamentPlayer, error) {
+	ctx = named(ctx, "RegisterTournamentPlayer")
+	playerName = strings.TrimSpace(playerName)
+	if playerName == "" {
+		return nil, fmt.Errorf("player name is required")
+	}
+
+	t, err := GetTournament(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if t.Status != TournamentRegistration {
+		return nil, fmt.Errorf("%w: registration is closed", ErrTournamentState)
+	}
+
+	player := &TournamentPlayer{PlayerName: playerName, Seed: 1}
+	for _, p := range t.Players {
+		if p.PlayerName == playerName {
+			return nil, fmt.Errorf("%w: %s is already registered", ErrTournamentState, playerName)
+		}
+		if p.Seed >= player.Seed {
+			player.Seed = p.Seed + 1
+		}
+	}
+
+	_, err = DB.ExecContext(ctx, `INSERT INTO tournament_players (tournament_id, player_name, seed) VALUES (?, ?, ?)`, id, playerName, player.Seed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register player: %w", err)
+	}
+	return player, nil
+}
+
+// WithdrawTournamentPlayer removes a player before the first round
+func WithdrawTournamentPlayer(ctx context.Context, id int64, playerName string) error {
+	ctx = named(ctx, "WithdrawTournamentPlayer")
+	t, err := GetTournament(ctx, id)
+	if err != nil {
+		return err
+	}
+	if t.Status != TournamentRegistration {
+		return fmt.Errorf("%w: registration is closed", ErrTournamentState)
+	}
+
+	result, err := DB.ExecContext(ctx, `DELETE FROM tournament_players WHERE tournament_id = ? AND player_name = ?`, id, playerName)
+	if err != nil {
+		return fmt.Errorf("failed to withdraw player: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: %s is not registered", ErrTournamentState, playerName)
+	}
+	return nil
+}
+
+// StartTournamentRound seats every player for the next round once all
+// earlier tables have results. Swiss rounds fill tables in standings order,
+// so the first round follows seeds; random rounds shuffle the players.
+func StartTournamentRound(ctx context.Context, id int64) ([]TournamentTable, error) {
+	ctx = named(ctx, "StartTournamentRound")
+	t, err := GetTournament(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if t.Status == TournamentFinal || t.Status == TournamentFinished {
+		return nil, fmt.Errorf("%w: the final has started", ErrTournamentState)
+	}
+	if err := checkTablesComplete(t); err != nil {
+		return nil, err
+	}
+	if len(t.Players) < MinTableSize {
+		return nil, fmt.Errorf("%w: at least %d players are needed", ErrTournamentState, MinTableSize)
+	}
+
+	round := nextRound(t)
+	var order []string
+	switch t.Pairing {
+	case PairingRandom:
+		for _, p := range t.Players {
+			order = append(order, p.PlayerName)
+		}
+		rng := rand.New(rand.NewSource(t.Seed + int64(round)))
+		rng.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+	default:
+		standings, err := tournamentStandings(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range standings {
+			order = append(order, s.PlayerName)
+		}
+	}
+
+	var tables []TournamentTable
+	for i, size := range tableSizes(len(order), t.TableSize) {
+		tables = append(tables, TournamentTable{Round: round, TableNumber: i + 1, Players: order[:size]})
+		order = order[size:]
+	}
+
+	if err := insertTournamentTables(ctx, id, tables); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// StartTournamentFinal seats the top of the standings at a final table once
+// every round table has a result
+func StartTournamentFinal(ctx context.Context, id int64) (*TournamentTable, error) {
+	ctx = named(ctx, "StartTournamentFinal")
+	t, err := GetTournament(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch t.Status {
+	case TournamentRegistration:
+		return nil, fmt.Errorf("%w: no rounds have been played", ErrTournamentState)
+	case TournamentFinal, TournamentFinished:
+		return nil, fmt.Errorf("%w: the final has already started", ErrTournamentState)
+	}
+	if err := checkTablesComplete(t); err != nil {
+		return nil, err
+	}
+
+	standings, err := tournamentStandings(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	size := t.FinalTableSize
+	if size > len(standings) {
+		size = len(standings)
+	}
+
+	table := TournamentTable{Round: nextRound(t), TableNumber: 1, Final: true}
+	for _, s := range standings[:size] {
+		table.Players = append(table.Players, s.PlayerName)
+	}
+
+	tables := []TournamentTable{table}
+	if err := insertTournamentTables(ctx, id, tables); err != nil {
+		return nil, err
+	}
+	return &tables[0], nil
+}
+
+// checkTablesComplete returns an error if any table is waiting for a result
+func checkTablesComplete(t *Tournament) error {
+	for _, table := range t.Tables {
+		if table.GameID == nil {
+			return fmt.Errorf("%w: round %d table %d has no result", ErrTournamentState, table.Round, table.TableNumber)
+		}
+	}
+	return nil
+}
+
+// nextRound returns the number of the round after the latest one
+func nextRound(t *Tournament) int {
+	round := 1
+	for _, table := range t.Tables {
+		if table.Round >= round {
+			round = table.Round + 1
+		}
+	}
+	return round
+}
+
+// insertTournamentTables saves new tables, filling in their IDs
+func insertTournamentTables(ctx context.Context, id int64, tables []TournamentTable) error {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i := range tables {
+		playersJSON, err := json.Marshal(tables[i].Players)
+		if err != nil {
+			return fmt.Errorf("failed to marshal table players: %w", err)
+		}
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO tournament_tables (tournament_id, round, table_number, is_final, players_json)
+			VALUES (?, ?, ?, ?, ?)
+		`, id, tables[i].Round, tables[i].TableNumber, tables[i].Final, string(playersJSON))
+		if err != nil {
+			return fmt.Errorf("failed to insert tournament table: %w", err)
+		}
+		if tables[i].ID, err = result.LastInsertId(); err != nil {
+			return fmt.Errorf("failed to get last insert ID: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// tableSizes splits n players into tables of MinTableSize to MaxTableSize,
+// as close to the preferred size as possible, larger tables first
+func tableSizes(n, preferred int) []int {
+	count := (n + preferred/2) / preferred
+	if least := (n + MaxTableSize - 1) / MaxTableSize; count < least {
+		count = least
+	}
+	if most := n / MinTableSize; count > most {
+		count = most
+	}
+	if count < 1 {
+		count = 1
+	}
+
+	sizes := make([]int, count)
+	for i := range sizes {
+		sizes[i] = n / count
+		if i < n%count {
+			sizes[i]++
+		}
+	}
+	return sizes
+}
+
+// RecordTournamentResult saves a scored game for a table in the tournament's
+// group. The game's players must be exactly the players seated at the table.
+func RecordTournamentResult(ctx context.Context, id, tableID int64, game *GameResult) (int64, error) {
+	ctx = named(ctx, "RecordTournamentResult")
+	t, err := GetTournament(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	var table *TournamentTable
+	for i := range t.Tables {
+		if t.Tables[i].ID == tableID {
+			table = &t.Tables[i]
+		}
+	}
+	if table == nil {
+		return 0, ErrTournamentTableNotFound
+	}
+	if table.GameID != nil {
+		return 0, fmt.Errorf("%w: table already has a result", ErrTournamentState)
+	}
+
+	seated := make(map[string]bool, len(table.Players))
+	for _, name := range table.Players {
+		seated[name] = true
+	}
+	if len(game.Players) != len(table.Players) {
+		return 0, fmt.Errorf("%w: expected %d players", ErrTournamentResult, len(table.Players))
+	}
+	for _, p := range game.Players {
+		if !seated[p.PlayerName] {
+			return 0, fmt.Errorf("%w: %s is not seated at this table", ErrTournamentResult, p.PlayerName)
+		}
+		delete(seated, p.PlayerName)
+	}
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	game.Group = t.Group
+	replays := make(map[string]bool)
+	gameID, err := insertGameResult(ctx, tx, game, replays)
+	if err != nil {
+		return 0, err
+	}
+	if err := replayAchievements(ctx, tx, replays); err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE tournament_tables SET game_id = ? WHERE id = ? AND game_id IS NULL`, gameID, tableID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record table result: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return 0, fmt.Errorf("%w: table already has a result", ErrTournamentState)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit result: %w", err)
+	}
+	gamesSaved.Inc()
+	return gameID, nil
+}
+
+// GetTournamentStandings ranks a tournament's players
+func GetTournamentStandings(ctx context.Context, id int64) ([]TournamentStanding, error) {
+	ctx = named(ctx, "GetTournamentStandings")
+	t, err := GetTournament(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return tournamentStandings(ctx, t)
+}
+
+// tournamentStandings ranks players by tournament points from round tables,
+// then opponents' points, total score and seed. Once the final is played its
+// players move to the top in the order they finished there.
+func tournamentStandings(ctx context.Context, t *Tournament) ([]TournamentStanding, error) {
+	rows, err := DB.QueryContext(ctx, `
+		SELECT tournament_tables.id, tournament_tables.is_final, player_scores.player_name, player_scores.rank, player_scores.total
+		FROM tournament_tables
+		JOIN player_scores ON player_scores.game_id = tournament_tables.game_id
+		WHERE tournament_tables.tournament_id = ?
+	`, t.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tournament scores: %w", err)
+	}
+	defer rows.Close()
+
+	byPlayer := make(map[string]*TournamentStanding, len(t.Players))
+	standings := make([]*TournamentStanding, 0, len(t.Players))
+	for _, p := range t.Players {
+		s := &TournamentStanding{PlayerName: p.PlayerName, Seed: p.Seed}
+		byPlayer[p.PlayerName] = s
+		standings = append(standings, s)
+	}
+
+	tablePlayers := make(map[int64][]string)
+	for rows.Next() {
+		var tableID int64
+		var final bool
+		var name string
+		var rank, total int
+		if err := rows.Scan(&tableID, &final, &name, &rank, &total); err != nil {
+			return nil, fmt.Errorf("failed to scan tournament score: %w", err)
+		}
+
+		s := byPlayer[name]
+		if s == nil {
+			continue
+		}
+		if final {
+			s.FinalRank = rank
+			continue
+		}
+
+		tablePlayers[tableID] = append(tablePlayers[tableID], name)
+		s.GamesPlayed++
+		s.TotalScore += total
+		if rank == 1 {
+			s.Wins++
+		}
+		if rank >= 1 && rank <= len(t.PlacementPoints) {
+			s.Points += t.PlacementPoints[rank-1]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, names := range tablePlayers {
+		for _, name := range names {
+			for _, opponent := range names {
+				if opponent != name {
+					byPlayer[name].OpponentPoints += byPlayer[opponent].Points
+				}
+			}
+		}
+	}
+
+	sort.Slice(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if (a.FinalRank > 0) != (b.FinalRank > 0) {
+			return a.FinalRank > 0
+		}
+		if a.FinalRank != b.FinalRank {
+			return a.FinalRank < b.FinalRank
+		}
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.OpponentPoints != b.OpponentPoints {
+			return a.OpponentPoints > b.OpponentPoints
+		}
+		if a.TotalScore != b.TotalScore {
+			return a.TotalScore > b.TotalScore
+		}
+		return a.Seed < b.Seed
+	})
+
+	result := make([]TournamentStanding, len(standings))
+	for i, s := range standings {
+		s.Place = i + 1
+		result[i] = *s
+	}
+	return result, nil
+}
@@ -0,0 +1,274 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"wingspan-scoring/scoring"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestTournament creates a tournament and registers the players in seed order
+func createTestTournament(t *testing.T, tournament *Tournament, players ...string) int64 {
+	t.Helper()
+	id, err := CreateTournament(t.Context(), tournament)
+	require.NoError(t, err)
+	for _, name := range players {
+		_, err := RegisterTournamentPlayer(t.Context(), id, name)
+		require.NoError(t, err)
+	}
+	return id
+}
+
+// playTable records a result where the table's players finish in the given order
+func playTable(t *testing.T, id int64, table TournamentTable, order ...string) int64 {
+	t.Helper()
+	var players []scoring.PlayerGameEnd
+	for i, name := range order {
+		players = append(players, scoring.PlayerGameEnd{PlayerName: name, Total: 100 - 10*i, Rank: i + 1})
+	}
+	gameID, err := RecordTournamentResult(t.Context(), id, table.ID, &GameResult{Players: players})
+	require.NoError(t, err)
+	return gameID
+}
+
+// TestTableSizes tests splitting players into tables of 3 to 5
+func TestTableSizes(t *testing.T) {
+	testCases := []struct {
+		players   int
+		preferred int
+		expected  []int
+	}{
+		{3, 4, []int{3}},
+		{5, 4, []int{5}},
+		{6, 4, []int{3, 3}},
+		{7, 4, []int{4, 3}},
+		{8, 4, []int{4, 4}},
+		{10, 4, []int{4, 3, 3}},
+		{6, 5, []int{3, 3}},
+		{11, 5, []int{4, 4, 3}},
+		{12, 3, []int{3, 3, 3, 3}},
+		{13, 5, []int{5, 4, 4}},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, tableSizes(tc.players, tc.preferred), "%d players, preferring %d", tc.players, tc.preferred)
+	}
+}
+
+// TestTournament_Validate tests tournament defaults and invalid settings
+func TestTournament_Validate(t *testing.T) {
+	tournament := Tournament{Name: "Open"}
+	require.NoError(t, tournament.Validate())
+	assert.Equal(t, PairingSwiss, tournament.Pairing)
+	assert.Equal(t, DefaultTableSize, tournament.TableSize)
+	assert.Equal(t, DefaultTableSize, tournament.FinalTableSize)
+	assert.Equal(t, DefaultTournamentPoints, tournament.PlacementPoints)
+
+	invalid := []Tournament{
+		{Name: " "},
+		{Name: "Open", Pairing: "knockout"},
+		{Name: "Open", TableSize: 2},
+		{Name: "Open", TableSize: 6},
+		{Name: "Open", FinalTableSize: 6},
+		{Name: "Open", PlacementPoints: []int{-1}},
+	}
+	for _, tournament := range invalid {
+		assert.Error(t, tournament.Validate(), "%+v", tournament)
+	}
+}
+
+// TestTournament_Registration tests registering and withdrawing players
+func TestTournament_Registration(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	id := createTestTournament(t, &Tournament{Name: "Open"}, "Alice", "Bob")
+
+	_, err := RegisterTournamentPlayer(t.Context(), id, "Alice")
+	assert.ErrorIs(t, err, ErrTournamentState)
+	_, err = RegisterTournamentPlayer(t.Context(), id+1, "Carol")
+	assert.ErrorIs(t, err, ErrTournamentNotFound)
+
+	_, err = StartTournamentRound(t.Context(), id)
+	assert.ErrorIs(t, err, ErrTournamentState, "two players can't fill a table")
+
+	require.NoError(t, WithdrawTournamentPlayer(t.Context(), id, "Alice"))
+	assert.ErrorIs(t, WithdrawTournamentPlayer(t.Context(), id, "Alice"), ErrTournamentState)
+
+	player, err := RegisterTournamentPlayer(t.Context(), id, "Carol")
+	require.NoError(t, err)
+	assert.Equal(t, 3, player.Seed)
+	_, err = RegisterTournamentPlayer(t.Context(), id, "Dave")
+	require.NoError(t, err)
+
+	tournament, err := GetTournament(t.Context(), id)
+	require.NoError(t, err)
+	assert.Equal(t, TournamentRegistration, tournament.Status)
+	assert.Equal(t, []TournamentPlayer{{"Bob", 2}, {"Carol", 3}, {"Dave", 4}}, tournament.Players)
+
+	_, err = StartTournamentRound(t.Context(), id)
+	require.NoError(t, err)
+	_, err = RegisterTournamentPlayer(t.Context(), id, "Eve")
+	assert.ErrorIs(t, err, ErrTournamentState, "registration closes once play starts")
+}
+
+// TestTournament_SwissRoundsAndFinal tests a full event: seeded and Swiss rounds, standings and the final
+func TestTournament_SwissRoundsAndFinal(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	players := []string{"P1", "P2", "P3", "P4", "P5", "P6", "P7"}
+	id := createTestTournament(t, &Tournament{Name: "Open", PlacementPoints: []int{4, 2, 1}, FinalTableSize: 3}, players...)
+
+	_, err := StartTournamentFinal(t.Context(), id)
+	assert.ErrorIs(t, err, ErrTournamentState, "no final before any rounds")
+
+	// Round 1 follows seeds
+	round1, err := StartTournamentRound(t.Context(), id)
+	require.NoError(t, err)
+	require.Len(t, round1, 2)
+	assert.Equal(t, []string{"P1", "P2", "P3", "P4"}, round1[0].Players)
+	assert.Equal(t, []string{"P5", "P6", "P7"}, round1[1].Players)
+	assert.Equal(t, 1, round1[0].Round)
+
+	_, err = StartTournamentRound(t.Context(), id)
+	assert.ErrorIs(t, err, ErrTournamentState, "round 1 tables are still open")
+
+	playTable(t, id, round1[0], "P4", "P3", "P2", "P1")
+	playTable(t, id, round1[1], "P7", "P6", "P5")
+
+	standings, err := GetTournamentStandings(t.Context(), id)
+	require.NoError(t, err)
+	// P4 and P7 both have 4 points; P4's opponents have 3, P7's have 3 too, so total score (100 each) and seed decide
+	assert.Equal(t, "P4", standings[0].PlayerName)
+	assert.Equal(t, 4, standings[0].Points)
+	assert.Equal(t, 3, standings[0].OpponentPoints)
+	assert.Equal(t, "P7", standings[1].PlayerName)
+	assert.Equal(t, 1, standings[1].Wins)
+	assert.Equal(t, 90, standings[2].TotalScore)
+
+	// Swiss round 2 seats players in standings order
+	round2, err := StartTournamentRound(t.Context(), id)
+	require.NoError(t, err)
+	assert.Equal(t, 2, round2[0].Round)
+	assert.Equal(t, []string{"P4", "P7", "P3", "P6"}, round2[0].Players)
+	assert.Equal(t, []string{"P2", "P5", "P1"}, round2[1].Players)
+
+	playTable(t, id, round2[0], "P3", "P4", "P7", "P6")
+	playTable(t, id, round2[1], "P2", "P5", "P1")
+
+	final, err := StartTournamentFinal(t.Context(), id)
+	require.NoError(t, err)
+	assert.True(t, final.Final)
+	assert.Equal(t, 3, final.Round)
+	// P3 and P4 have 6 points; P7 and P2 have 5 but P7 faced stronger opponents
+	assert.ElementsMatch(t, []string{"P4", "P3", "P7"}, final.Players)
+
+	tournament, err := GetTournament(t.Context(), id)
+	require.NoError(t, err)
+	assert.Equal(t, TournamentFinal, tournament.Status)
+	_, err = StartTournamentRound(t.Context(), id)
+	assert.ErrorIs(t, err, ErrTournamentState)
+
+	playTable(t, id, *final, "P7", "P4", "P3")
+
+	standings, err = GetTournamentStandings(t.Context(), id)
+	require.NoError(t, err)
+	assert.Equal(t, "P7", standings[0].PlayerName)
+	assert.Equal(t, 1, standings[0].FinalRank)
+	assert.Equal(t, "P4", standings[1].PlayerName)
+	assert.Equal(t, "P3", standings[2].PlayerName)
+	assert.Equal(t, 0, standings[3].FinalRank)
+	assert.Equal(t, 4, standings[3].Place)
+	// The final doesn't add tournament points
+	assert.Equal(t, 5, standings[0].Points)
+
+	tournament, err = GetTournament(t.Context(), id)
+	require.NoError(t, err)
+	assert.Equal(t, TournamentFinished, tournament.Status)
+}
+
+// TestTournament_RandomPairing tests random rounds seat everyone and are reproducible from the seed
+func TestTournament_RandomPairing(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	var players []string
+	for i := 1; i <= 10; i++ {
+		players = append(players, fmt.Sprintf("P%d", i))
+	}
+
+	seat := func() [][]string {
+		id := createTestTournament(t, &Tournament{Name: "Random", Pairing: PairingRandom, TableSize: 5, Seed: 42}, players...)
+		tables, err := StartTournamentRound(t.Context(), id)
+		require.NoError(t, err)
+		var seating [][]string
+		for _, table := range tables {
+			seating = append(seating, table.Players)
+		}
+		return seating
+	}
+
+	first := seat()
+	require.Len(t, first, 2)
+	assert.ElementsMatch(t, players, append(append([]string{}, first[0]...), first[1]...))
+	assert.Equal(t, first, seat())
+}
+
+// TestRecordTournamentResult_Validation tests results must match an open table
+func TestRecordTournamentResult_Validation(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	id := createTestTournament(t, &Tournament{Name: "Open"}, "Alice", "Bob", "Carol")
+	tables, err := StartTournamentRound(t.Context(), id)
+	require.NoError(t, err)
+	table := tables[0]
+
+	wrong := &GameResult{Players: []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Rank: 1}, {PlayerName: "Bob", Rank: 2}, {PlayerName: "Dave", Rank: 3},
+	}}
+	_, err = RecordTournamentResult(t.Context(), id, table.ID, wrong)
+	assert.ErrorIs(t, err, ErrTournamentResult)
+
+	short := &GameResult{Players: []scoring.PlayerGameEnd{{PlayerName: "Alice", Rank: 1}}}
+	_, err = RecordTournamentResult(t.Context(), id, table.ID, short)
+	assert.ErrorIs(t, err, ErrTournamentResult)
+
+	_, err = RecordTournamentResult(t.Context(), id, table.ID+1, short)
+	assert.ErrorIs(t, err, ErrTournamentTableNotFound)
+
+	gameID := playTable(t, id, table, "Carol", "Bob", "Alice")
+	_, err = RecordTournamentResult(t.Context(), id, table.ID, &GameResult{Players: []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Rank: 1}, {PlayerName: "Bob", Rank: 2}, {PlayerName: "Carol", Rank: 3},
+	}})
+	assert.ErrorIs(t, err, ErrTournamentState)
+
+	// Deleting the game reopens the table
+	require.NoError(t, DeleteGameResult(t.Context(), gameID))
+	tournament, err := GetTournament(t.Context(), id)
+	require.NoError(t, err)
+	assert.Nil(t, tournament.Tables[0].GameID)
+	playTable(t, id, table, "Alice", "Bob", "Carol")
+}
+
+// TestDeleteTournament tests deleting a tournament keeps its games
+func TestDeleteTournament(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	id := createTestTournament(t, &Tournament{Name: "Open"}, "Alice", "Bob", "Carol")
+	tables, err := StartTournamentRound(t.Context(), id)
+	require.NoError(t, err)
+	gameID := playTable(t, id, tables[0], "Alice", "Bob", "Carol")
+
+	require.NoError(t, DeleteTournament(t.Context(), id))
+	_, err = GetTournament(t.Context(), id)
+	assert.ErrorIs(t, err, ErrTournamentNotFound)
+	assert.ErrorIs(t, DeleteTournament(t.Context(), id), ErrTournamentNotFound)
+
+	_, err = GetGameResult(t.Context(), gameID)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,203 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// DefaultTrendWindow is how many periods the smoothed averages span by default
+const DefaultTrendWindow = 3
+
+// quarterBucket labels a game's calendar quarter by year and number, e.g. 2024-Q1
+const quarterBucket = `strftime('%Y', game_results.created_at) || '-Q' || ((CAST(strftime('%m', game_results.created_at) AS INTEGER) + 2) / 3)`
+
+// trendPeriods maps each supported period to the SQL expression that labels
+// a game's bucket. Weeks are labelled by their Monday. season is the
+// original name for quarter and is still accepted for existing clients.
+var trendPeriods = map[string]string{
+	"week":    `date(game_results.created_at, 'weekday 0', '-6 days')`,
+	"month":   `strftime('%Y-%m', game_results.created_at)`,
+	"quarter": quarterBucket,
+	"season":  quarterBucket,
+}
+
+// TrendPoint holds average scores for one period. Smoothed averages cover
+// this period and the ones before it, up to the trend window, weighted by
+// the number of scores in each.
+type TrendPoint struct {
+	Period   string             `json:"period"`
+	Games    int                `json:"games"`
+	Scores   int                `json:"scores"` // Player results counted; equals Games for a single player
+	Averages map[string]float64 `json:"averages"`
+	Smoothed map[string]float64 `json:"smoothed"`
+}
+
+// TrendSeries is the list of periods for one player, or the whole group
+type TrendSeries struct {
+	PlayerName string       `json:"playerName,omitempty"`
+	Points     []TrendPoint `json:"points"`
+}
+
+// Trends holds score trends for the group and for individual players
+type Trends struct {
+	Period  string        `json:"period"`
+	Window  int           `json:"window"`
+	Group   TrendSeries   `json:"group"`
+	Players []TrendSeries `json:"players"`
+}
+
+// ValidTrendPeriod reports whether period is a supported trend bucket
+func ValidTrendPeriod(period string) bool {
+	_, ok := trendPeriods[period]
+	return ok
+}
+
+// GetTrends returns average scores per period for games matching the
+// filter. The filter's Players select which players get their own series
+// rather than restricting the games; with none, every player gets one.
+// Sums are aggregated in SQL from player_scores.
+func GetTrends(ctx context.Context, period string, window int, filter GameFilter) (*Trends, error) {
+	ctx = named(ctx, "GetTrends")
+	bucket, ok := trendPeriods[period]
+	if !ok {
+		return nil, fmt.Errorf("unknown trend period: %s", period)
+	}
+	if window <= 0 {
+		window = DefaultTrendWindow
+	}
+
+	players := filter.Players
+	filter.Players = nil
+
+	trends := &Trends{Period: period, Window: window, Players: []TrendSeries{}}
+
+	groupRows, err := queryTrendBuckets(ctx, bucket, "", filter, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query group trends: %w", err)
+	}
+	trends.Group.Points = smoothTrend(groupRows, window)
+
+	playerRows, err := queryTrendBuckets(ctx, bucket, "player_scores.player_name", filter, players)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query player trends: %w", err)
+	}
+
+	byPlayer := make(map[string][]trendBucket)
+	var order []string
+	for _, row := range playerRows {
+		if _, ok := byPlayer[row.player]; !ok {
+			order = append(order, row.player)
+		}
+		byPlayer[row.player] = append(byPlayer[row.player], row)
+	}
+	for _, name := range order {
+		trends.Players = append(trends.Players, TrendSeries{
+			PlayerName: name,
+			Points:     smoothTrend(byPlayer[name], window),
+		})
+	}
+
+	return trends, nil
+}
+
+// trendBucket holds the summed scores for one period (and player)
+type trendBucket struct {
+	period string
+	player string
+	games  int
+	scores int
+	sums   []int64 // In ScoreCategories order
+}
+
+// queryTrendBuckets sums scores per period, and per player when groupBy names
+// the player column. players, when set, limits which players are summed.
+func queryTrendBuckets(ctx context.Context, bucket, groupBy string, filter GameFilter, players []string) ([]trendBucket, error) {
+	where, args := filter.whereClause()
+	if len(players) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(players)), ", ")
+		where = andWhere(where, "player_scores.player_name IN ("+placeholders+")")
+		for _, name := range players {
+			args = append(args, name)
+		}
+	}
+
+	playerColumn := "''"
+	groupColumns := "period"
+	if groupBy != "" {
+		playerColumn = groupBy
+		groupColumns = groupBy + ", period"
+	}
+
+	sums := make([]string, len(ScoreCategories))
+	for i, category := range ScoreCategories {
+		sums[i] = "SUM(player_scores." + category.Column + ")"
+	}
+
+	query := `
+		SELECT ` + bucket + ` AS period, ` + playerColumn + `, COUNT(DISTINCT game_results.id), COUNT(*), ` + strings.Join(sums, ", ") + `
+		FROM player_scores
+		JOIN game_results ON game_results.id = player_scores.game_id
+		` + where + `
+		GROUP BY ` + groupColumns + `
+		ORDER BY ` + groupColumns + `
+	`
+
+	rows, err := DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []trendBucket
+	for rows.Next() {
+		b := trendBucket{sums: make([]int64, len(ScoreCategories))}
+		dest := []interface{}{&b.period, &b.player, &b.games, &b.scores}
+		for i := range b.sums {
+			dest = append(dest, &b.sums[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan trend row: %w", err)
+		}
+		buckets = append(buckets, b)
+	}
+
+	return buckets, rows.Err()
+}
+
+// smoothTrend turns consecutive buckets into points with per-period and
+// moving-window averages
+func smoothTrend(buckets []trendBucket, window int) []TrendPoint {
+	points := make([]TrendPoint, 0, len(buckets))
+	for i, b := range buckets {
+		point := TrendPoint{
+			Period:   b.period,
+			Games:    b.games,
+			Scores:   b.scores,
+			Averages: make(map[string]float64, len(ScoreCategories)),
+			Smoothed: make(map[string]float64, len(ScoreCategories)),
+		}
+
+		start := i - window + 1
+		if start < 0 {
+			start = 0
+		}
+		windowScores := 0
+		for _, w := range buckets[start : i+1] {
+			windowScores += w.scores
+		}
+
+		for c, category := range ScoreCategories {
+			point.Averages[category.Key] = float64(b.sums[c]) / float64(b.scores)
+
+			var windowSum int64
+			for _, w := range buckets[start : i+1] {
+				windowSum += w.sums[c]
+			}
+			point.Smoothed[category.Key] = float64(windowSum) / float64(windowScores)
+		}
+
+		points = append(points, point)
+	}
+	return points
+}
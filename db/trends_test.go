@@ -0,0 +1,139 @@
+package db
+
+import (
+	"testing"
+	"wingspan-scoring/scoring"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seedTrendGames saves games across three months
+func seedTrendGames(t *testing.T) {
+	saveGameOn(t, "2024-01-05", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Eggs: 4, Total: 80, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Eggs: 2, Total: 60, Rank: 2})
+	saveGameOn(t, "2024-01-20", false,
+		scoring.PlayerGameEnd{PlayerName: "Alice", Eggs: 6, Total: 90, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Bob", Eggs: 4, Total: 70, Rank: 2})
+	saveGameOn(t, "2024-02-10", false,
+		scoring.PlayerGameEnd{PlayerName: "Bob", Eggs: 8, Total: 100, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Alice", Eggs: 2, Total: 70, Rank: 2})
+	saveGameOn(t, "2024-04-01", true,
+		scoring.PlayerGameEnd{PlayerName: "Carol", Eggs: 10, Total: 110, Rank: 1},
+		scoring.PlayerGameEnd{PlayerName: "Alice", Eggs: 0, Total: 100, Rank: 2})
+}
+
+// TestGetTrends_Monthly tests group and player averages per month with smoothing
+func TestGetTrends_Monthly(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	seedTrendGames(t)
+
+	trends, err := GetTrends(t.Context(), "month", 2, GameFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, "month", trends.Period)
+	assert.Equal(t, 2, trends.Window)
+
+	group := trends.Group.Points
+	require.Len(t, group, 3)
+	assert.Equal(t, "2024-01", group[0].Period)
+	assert.Equal(t, 2, group[0].Games)
+	assert.Equal(t, 4, group[0].Scores)
+	assert.Equal(t, 75.0, group[0].Averages["totalScore"])
+	assert.Equal(t, 75.0, group[0].Smoothed["totalScore"])
+	assert.Equal(t, 4.0, group[0].Averages["eggs"])
+
+	assert.Equal(t, "2024-02", group[1].Period)
+	assert.Equal(t, 85.0, group[1].Averages["totalScore"])
+	// (300 + 170) / 6
+	assert.InDelta(t, 78.33, group[1].Smoothed["totalScore"], 0.01)
+
+	// The window drops January
+	assert.Equal(t, "2024-04", group[2].Period)
+	assert.Equal(t, 95.0, group[2].Smoothed["totalScore"])
+
+	require.Len(t, trends.Players, 3)
+	alice := trends.Players[0]
+	assert.Equal(t, "Alice", alice.PlayerName)
+	require.Len(t, alice.Points, 3)
+	assert.Equal(t, 85.0, alice.Points[0].Averages["totalScore"])
+	assert.Equal(t, 2, alice.Points[0].Games)
+	assert.Equal(t, 5.0, alice.Points[0].Averages["eggs"])
+	assert.InDelta(t, 80.0, alice.Points[1].Smoothed["totalScore"], 0.01)
+}
+
+// TestGetTrends_WeekAndQuarter tests week and quarter bucket labels
+func TestGetTrends_WeekAndQuarter(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	seedTrendGames(t)
+
+	weekly, err := GetTrends(t.Context(), "week", 0, GameFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultTrendWindow, weekly.Window)
+	require.Len(t, weekly.Group.Points, 4)
+	// 2024-01-05 is a Friday; 2024-01-20 a Saturday; 2024-04-01 a Monday
+	assert.Equal(t, "2024-01-01", weekly.Group.Points[0].Period)
+	assert.Equal(t, "2024-01-15", weekly.Group.Points[1].Period)
+	assert.Equal(t, "2024-04-01", weekly.Group.Points[3].Period)
+
+	quarterly, err := GetTrends(t.Context(), "quarter", 0, GameFilter{})
+	require.NoError(t, err)
+	require.Len(t, quarterly.Group.Points, 2)
+	assert.Equal(t, "2024-Q1", quarterly.Group.Points[0].Period)
+	assert.Equal(t, 3, quarterly.Group.Points[0].Games)
+	assert.Equal(t, "2024-Q2", quarterly.Group.Points[1].Period)
+}
+
+// TestGetTrends_SelectedPlayers tests that listed players pick series without narrowing the group
+func TestGetTrends_SelectedPlayers(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	seedTrendGames(t)
+
+	yes := true
+	trends, err := GetTrends(t.Context(), "month", 0, GameFilter{Players: []string{"Carol"}})
+	require.NoError(t, err)
+	require.Len(t, trends.Players, 1)
+	assert.Equal(t, "Carol", trends.Players[0].PlayerName)
+	assert.Len(t, trends.Group.Points, 3)
+
+	trends, err = GetTrends(t.Context(), "month", 0, GameFilter{Oceania: &yes})
+	require.NoError(t, err)
+	require.Len(t, trends.Group.Points, 1)
+	assert.Equal(t, "2024-04", trends.Group.Points[0].Period)
+}
+
+// TestGetTrends_InvalidPeriod tests unknown periods are rejected
+func TestGetTrends_InvalidPeriod(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	_, err := GetTrends(t.Context(), "fortnight", 0, GameFilter{})
+	assert.Error(t, err)
+	assert.False(t, ValidTrendPeriod("fortnight"))
+	assert.True(t, ValidTrendPeriod("quarter"))
+}
+
+// TestGetTrends_SeasonAlias tests season still buckets games by quarter
+func TestGetTrends_SeasonAlias(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	assert.True(t, ValidTrendPeriod("season"))
+	seedTrendGames(t)
+
+	seasonal, err := GetTrends(t.Context(), "season", 0, GameFilter{})
+	require.NoError(t, err)
+	quarterly, err := GetTrends(t.Context(), "quarter", 0, GameFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, quarterly.Group, seasonal.Group)
+	assert.Equal(t, quarterly.Players, seasonal.Players)
+	require.Len(t, seasonal.Group.Points, 2)
+	assert.Equal(t, "2024-Q1", seasonal.Group.Points[0].Period)
+	assert.Equal(t, "2024-Q2", seasonal.Group.Points[1].Period)
+}
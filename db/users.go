@@ -0,0 +1,398 @@
+package db
+
+import (
+	"context"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// User roles, from least to most access
+const (
+	RoleViewer = "viewer" // Can view games and stats
+	RoleScorer = "scorer" // Can also save games and manage seasons and tournaments
+	RoleAdmin  = "admin"  // Can also delete, import and manage users
+)
+
+// MinPasswordLength is the shortest password accepted for a local account
+const MinPasswordLength = 8
+
+// passwordIterations is the PBKDF2-SHA256 work factor for new password hashes.
+// Stored hashes record their own count, so it can be raised later.
+const passwordIterations = 600_000
+
+var (
+	// ErrUserNotFound is returned when a user doesn't exist
+	ErrUserNotFound = errors.New("user not found")
+	// ErrInvalidCredentials is returned when a username or password is wrong
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrUsernameTaken is returned when another account has the username
+	ErrUsernameTaken = errors.New("username already taken")
+)
+
+// User is an account, signed in with a password or an OpenID Connect provider
+type User struct {
+	ID         int64     `json:"id"`
+	Username   string    `json:"username"`
+	Role       string    `json:"role"`
+	PlayerName string    `json:"playerName,omitempty"` // The player whose games are this user's
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// ValidRole reports whether role is viewer, scorer or admin
+func ValidRole(role string) bool {
+	return roleLevel(role) > 0
+}
+
+// HasRole reports whether role grants at least the access of required
+func HasRole(role, required string) bool {
+	return roleLevel(role) >= roleLevel(required)
+}
+
+// roleLevel orders roles by access, with 0 for unknown roles
+func roleLevel(role string) int {
+	switch role {
+	case RoleViewer:
+		return 1
+	case RoleScorer:
+		return 2
+	case RoleAdmin:
+		return 3
+	}
+	return 0
+}
+
+// HashPassword hashes a password with PBKDF2-SHA256 and a random salt
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, 32)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// CheckPassword reports whether password matches a hash from HashPassword
+func CheckPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// validateUser checks a username, role and, when set, password
+func validateUser(username, role, password string, requirePassword bool) error {
+	if strings.TrimSpace(username) == "" || strings.TrimSpace(username) != username {
+		return fmt.Errorf("username is required and can't start or end with spaces")
+	}
+	if !ValidRole(role) {
+		return fmt.Errorf("invalid role: %s (must be viewer, scorer or admin)", role)
+	}
+	if (requirePassword || password != "") && len(password) < MinPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+	return nil
+}
+
+// CreateUser validates and saves a new account
+func CreateUser(ctx context.Context, username, password, role string) (*User, error) {
+	ctx = named(ctx, "CreateUser")
+	if err := validateUser(username, role, password, true); err != nil {
+		return nil, err
+	}
+	if _, err := GetUserByUsername(ctx, username); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUsernameTaken, username)
+	} else if !errors.Is(err, ErrUserNotFound) {
+		return nil, err
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := DB.ExecContext(ctx, `INSERT INTO users (username, password_hash, role) VALUES (?, ?, ?)`, username, hash, role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert user: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	return GetUser(ctx, id)
+}
+
+// CreateExternalUser saves an account for a user authenticated by a reverse
+// proxy, marked so GetProxyUser finds it. It has no password, so it can't
+// sign in with one until an admin sets it.
+func CreateExternalUser(ctx context.Context, username, role string) (*User, error) {
+	ctx = named(ctx, "CreateExternalUser")
+	if err := validateUser(username, role, "", false); err != nil {
+		return nil, err
+	}
+
+	result, err := DB.ExecContext(ctx, `INSERT INTO users (username, password_hash, role, proxy_user) VALUES (?, '', ?, 1)`, username, role)
+	if err != nil {
+		if _, lookupErr := GetUserByUsername(ctx, username); lookupErr == nil {
+			return nil, fmt.Errorf("%w: %s", ErrUsernameTaken, username)
+		}
+		return nil, fmt.Errorf("failed to insert user: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	return GetUser(ctx, id)
+}
+
+// UpdateUser changes an account's role, and its password when one is given.
+// Changing the password signs the user out everywhere.
+func UpdateUser(ctx context.Context, id int64, role, password string) (*User, error) {
+	ctx = named(ctx, "UpdateUser")
+	user, err := GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateUser(user.Username, role, password, false); err != nil {
+		return nil, err
+	}
+
+	if _, err := DB.ExecContext(ctx, `UPDATE users SET role = ? WHERE id = ?`, role, id); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	if password != "" {
+		hash, err := HashPassword(password)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := DB.ExecContext(ctx, `UPDATE users SET password_hash = ? WHERE id = ?`, hash, id); err != nil {
+			return nil, fmt.Errorf("failed to update password: %w", err)
+		}
+		if _, err := DB.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = ?`, id); err != nil {
+			return nil, fmt.Errorf("failed to end sessions: %w", err)
+		}
+	}
+	return GetUser(ctx, id)
+}
+
+// DeleteUser deletes an account with its sessions and API tokens
+func DeleteUser(ctx context.Context, id int64) error {
+	ctx = named(ctx, "DeleteUser")
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM api_tokens WHERE user_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete API tokens: %w", err)
+	}
+	return tx.Commit()
+}
+
+// SetUserPlayerName links an account to a player, or unlinks it when playerName is empty
+func SetUserPlayerName(ctx context.Context, id int64, playerName string) (*User, error) {
+	ctx = named(ctx, "SetUserPlayerName")
+	result, err := DB.ExecContext(ctx, `UPDATE users SET player_name = ? WHERE id = ?`, strings.TrimSpace(playerName), id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update player name: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, ErrUserNotFound
+	}
+	return GetUser(ctx, id)
+}
+
+// UpsertOIDCUser returns the account for an OpenID Connect identity, creating
+// it on first sign-in. subject must identify the user across sign-ins, such
+// as the issuer and sub claim. The role and player name are refreshed from
+// the provider every time; the username is only set on creation.
+func UpsertOIDCUser(ctx context.Context, subject, username, role, playerName string) (*User, error) {
+	ctx = named(ctx, "UpsertOIDCUser")
+	if err := validateUser(username, role, "", false); err != nil {
+		return nil, err
+	}
+	playerName = strings.TrimSpace(playerName)
+
+	var id int64
+	err := DB.QueryRowContext(ctx, `SELECT id FROM users WHERE oidc_subject = ?`, subject).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := GetUserByUsername(ctx, username); err == nil {
+			return nil, fmt.Errorf("%w: %s", ErrUsernameTaken, username)
+		} else if !errors.Is(err, ErrUserNotFound) {
+			return nil, err
+		}
+
+		// The empty password hash never matches, so these accounts can't
+		// sign in with a password until an admin sets one
+		result, err := DB.ExecContext(ctx, `
+			INSERT INTO users (username, password_hash, role, player_name, oidc_subject)
+			VALUES (?, '', ?, ?, ?)
+		`, username, role, playerName, subject)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert user: %w", err)
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			return nil, fmt.Errorf("failed to get last insert ID: %w", err)
+		}
+		return GetUser(ctx, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+
+	if _, err := DB.ExecContext(ctx, `UPDATE users SET role = ?, player_name = ? WHERE id = ?`, role, playerName, id); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	return GetUser(ctx, id)
+}
+
+// GetUser retrieves an account by ID
+func GetUser(ctx context.Context, id int64) (*User, error) {
+	ctx = named(ctx, "GetUser")
+	return scanUser(DB.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+}
+
+// GetUserByUsername retrieves an account by username
+func GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	ctx = named(ctx, "GetUserByUsername")
+	return scanUser(DB.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE username = ?`, username))
+}
+
+// GetProxyUser retrieves an account created by CreateExternalUser. Other
+// accounts with the username, such as local password accounts, give
+// ErrUsernameTaken, so a proxy can't sign anyone in as them.
+func GetProxyUser(ctx context.Context, username string) (*User, error) {
+	ctx = named(ctx, "GetProxyUser")
+	var proxyUser bool
+	err := DB.QueryRowContext(ctx, `SELECT proxy_user FROM users WHERE username = ?`, username).Scan(&proxyUser)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	if !proxyUser {
+		return nil, fmt.Errorf("%w: %s", ErrUsernameTaken, username)
+	}
+	return GetUserByUsername(ctx, username)
+}
+
+// GetUsers lists every account by username
+func GetUsers(ctx context.Context) ([]User, error) {
+	ctx = named(ctx, "GetUsers")
+	rows, err := DB.QueryContext(ctx, `SELECT `+userColumns+` FROM users ORDER BY username`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, rows.Err()
+}
+
+// CountUsers returns the number of accounts
+func CountUsers(ctx context.Context) (int, error) {
+	ctx = named(ctx, "CountUsers")
+	var count int
+	err := DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&count)
+	return count, err
+}
+
+// Authenticate checks a username and password, returning the account
+func Authenticate(ctx context.Context, username, password string) (*User, error) {
+	ctx = named(ctx, "Authenticate")
+	var id int64
+	var hash string
+	err := DB.QueryRowContext(ctx, `SELECT id, password_hash FROM users WHERE username = ?`, username).Scan(&id, &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Spend as long as a real check so usernames can't be probed by timing
+		CheckPassword(dummyPasswordHash(), password)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	if !CheckPassword(hash, password) {
+		return nil, ErrInvalidCredentials
+	}
+	return GetUser(ctx, id)
+}
+
+// dummyPasswordHash is checked against when a username doesn't exist
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := HashPassword("")
+	return hash
+})
+
+// userColumns lists the users columns scanUser reads, in order
+const userColumns = `id, username, role, player_name, created_at`
+
+// scanUser reads a row selected with userColumns
+func scanUser(row rowScanner) (*User, error) {
+	var user User
+	if err := row.Scan(&user.ID, &user.Username, &user.Role, &user.PlayerName, &user.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to scan user: %w", err)
+	}
+	return &user, nil
+}
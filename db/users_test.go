@@ -0,0 +1,180 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHashPassword tests hashing and checking passwords
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "pbkdf2-sha256$"))
+	assert.NotContains(t, hash, "correct horse")
+
+	assert.True(t, CheckPassword(hash, "correct horse"))
+	assert.False(t, CheckPassword(hash, "wrong horse"))
+	assert.False(t, CheckPassword("not a hash", "correct horse"))
+
+	// Salts differ, so the same password hashes differently
+	again, err := HashPassword("correct horse")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, again)
+}
+
+// TestHasRole tests that roles grant the access of lower roles
+func TestHasRole(t *testing.T) {
+	assert.True(t, HasRole(RoleAdmin, RoleScorer))
+	assert.True(t, HasRole(RoleScorer, RoleScorer))
+	assert.False(t, HasRole(RoleViewer, RoleScorer))
+	assert.False(t, HasRole("", RoleViewer))
+	assert.False(t, ValidRole("owner"))
+}
+
+// TestUsers tests creating, authenticating, updating and deleting accounts
+func TestUsers(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	user, err := CreateUser(t.Context(), "alice", "password1", RoleScorer)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+	assert.Equal(t, RoleScorer, user.Role)
+
+	_, err = CreateUser(t.Context(), "alice", "password2", RoleViewer)
+	assert.Error(t, err)
+	_, err = CreateUser(t.Context(), "bob", "short", RoleViewer)
+	assert.Error(t, err)
+	_, err = CreateUser(t.Context(), "bob", "password1", "owner")
+	assert.Error(t, err)
+	_, err = CreateUser(t.Context(), " bob", "password1", RoleViewer)
+	assert.Error(t, err)
+
+	authed, err := Authenticate(t.Context(), "alice", "password1")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, authed.ID)
+	_, err = Authenticate(t.Context(), "alice", "password2")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = Authenticate(t.Context(), "nobody", "password1")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	token, _, err := CreateSession(t.Context(), user.ID)
+	require.NoError(t, err)
+
+	// Changing the password ends existing sessions
+	user, err = UpdateUser(t.Context(), user.ID, RoleAdmin, "password2")
+	require.NoError(t, err)
+	assert.Equal(t, RoleAdmin, user.Role)
+	_, err = Authenticate(t.Context(), "alice", "password2")
+	assert.NoError(t, err)
+	_, err = GetSession(t.Context(), token)
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+
+	// Role-only updates keep the password
+	_, err = UpdateUser(t.Context(), user.ID, RoleViewer, "")
+	require.NoError(t, err)
+	_, err = Authenticate(t.Context(), "alice", "password2")
+	assert.NoError(t, err)
+
+	count, err := CountUsers(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	require.NoError(t, DeleteUser(t.Context(), user.ID))
+	assert.ErrorIs(t, DeleteUser(t.Context(), user.ID), ErrUserNotFound)
+	_, err = GetUser(t.Context(), user.ID)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	users, err := GetUsers(t.Context())
+	require.NoError(t, err)
+	assert.Empty(t, users)
+}
+
+// TestUpsertOIDCUser tests creating and refreshing accounts from an OpenID Connect provider
+func TestUpsertOIDCUser(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	user, err := UpsertOIDCUser(t.Context(), "https://idp.example#123", "alice", RoleScorer, "Alice")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+	assert.Equal(t, RoleScorer, user.Role)
+	assert.Equal(t, "Alice", user.PlayerName)
+
+	// No password works for provider accounts
+	_, err = Authenticate(t.Context(), "alice", "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// Later sign-ins refresh the role and player but keep the account
+	again, err := UpsertOIDCUser(t.Context(), "https://idp.example#123", "alice-renamed", RoleViewer, "")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, again.ID)
+	assert.Equal(t, "alice", again.Username)
+	assert.Equal(t, RoleViewer, again.Role)
+	assert.Empty(t, again.PlayerName)
+
+	_, err = UpsertOIDCUser(t.Context(), "https://idp.example#456", "alice", RoleViewer, "")
+	assert.ErrorIs(t, err, ErrUsernameTaken)
+	_, err = UpsertOIDCUser(t.Context(), "https://idp.example#456", "bob", "owner", "")
+	assert.Error(t, err)
+}
+
+// TestSetUserPlayerName tests linking accounts to players
+func TestSetUserPlayerName(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	user, err := CreateUser(t.Context(), "bob", "password1", RoleViewer)
+	require.NoError(t, err)
+	assert.Empty(t, user.PlayerName)
+
+	user, err = SetUserPlayerName(t.Context(), user.ID, " Bob ")
+	require.NoError(t, err)
+	assert.Equal(t, "Bob", user.PlayerName)
+
+	token, _, err := CreateSession(t.Context(), user.ID)
+	require.NoError(t, err)
+	session, err := GetSession(t.Context(), token)
+	require.NoError(t, err)
+	assert.Equal(t, "Bob", session.User.PlayerName)
+
+	_, err = SetUserPlayerName(t.Context(), user.ID+1, "Bob")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+// TestCreateExternalUser tests accounts without a password
+func TestCreateExternalUser(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	user, err := CreateExternalUser(t.Context(), "dana@example.com", RoleScorer)
+	require.NoError(t, err)
+	assert.Equal(t, RoleScorer, user.Role)
+
+	_, err = Authenticate(t.Context(), "dana@example.com", "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = CreateExternalUser(t.Context(), "dana@example.com", RoleScorer)
+	assert.ErrorIs(t, err, ErrUsernameTaken)
+	_, err = CreateExternalUser(t.Context(), "erin", "owner")
+	assert.Error(t, err)
+
+	found, err := GetProxyUser(t.Context(), "dana@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+	_, err = GetProxyUser(t.Context(), "erin")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	// Local and OpenID Connect accounts aren't proxy users
+	_, err = CreateUser(t.Context(), "frank", "password123", RoleAdmin)
+	require.NoError(t, err)
+	_, err = GetProxyUser(t.Context(), "frank")
+	assert.ErrorIs(t, err, ErrUsernameTaken)
+	_, err = UpsertOIDCUser(t.Context(), "https://idp#gail", "gail", RoleViewer, "")
+	require.NoError(t, err)
+	_, err = GetProxyUser(t.Context(), "gail")
+	assert.ErrorIs(t, err, ErrUsernameTaken)
+}
@@ -1,11 +1,12 @@
 package export
 
 import (
-	"bytes"
 	"encoding/csv"
 	"fmt"
+	"io"
 	"strconv"
 	"wingspan-scoring/db"
+	"wingspan-scoring/scoring"
 )
 
 // CSV header matching the import format
@@ -28,54 +29,239 @@ var csvHeader = []string{
 	"Rank",
 }
 
-// ExportGamesToCSV converts game results to CSV format matching the import format.
-// Each player in each game becomes one row in the CSV.
-func ExportGamesToCSV(games []db.GameResult) ([]byte, error) {
-	var buf bytes.Buffer
-	writer := csv.NewWriter(&buf)
+// GameIterator yields game results one at a time. *db.GameResultIterator
+// satisfies it, which lets exports stream straight from the database.
+type GameIterator interface {
+	Next() bool
+	GameResult() *db.GameResult
+	Err() error
+}
 
-	// Write header
-	if err := writer.Write(csvHeader); err != nil {
-		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+// Exporter writes a stream of games in a particular file format
+type Exporter interface {
+	// ContentType is the MIME type of the exported file
+	ContentType() string
+	// FileExtension is the filename extension, without the leading dot
+	FileExtension() string
+	// Export writes all games from the iterator to w and returns the number of games written
+	Export(w io.Writer, games GameIterator) (int, error)
+}
+
+// NewExporter returns the exporter for a format query parameter
+// ("csv", "json", "ndjson" or "xlsx", defaulting to CSV). The row shape only
+// applies to CSV, since the other formats carry the full game structure.
+func NewExporter(format string, shape RowShape) (Exporter, error) {
+	switch format {
+	case "", "csv":
+		return CSVExporter{Shape: shape}, nil
+	case "json":
+		return JSONExporter{}, nil
+	case "ndjson":
+		return NDJSONExporter{}, nil
+	case "xlsx":
+		return XLSXExporter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown export format: %s", format)
 	}
+}
 
-	// Write data rows
-	for _, game := range games {
-		gameID := strconv.FormatInt(game.ID, 10)
-		date := game.CreatedAt.Format("2006-01-02")
-		includeOceania := strconv.FormatBool(game.IncludeOceania)
-
-		for _, player := range game.Players {
-			row := []string{
-				gameID,
-				date,
-				includeOceania,
-				player.PlayerName,
-				strconv.Itoa(player.BirdPoints),
-				strconv.Itoa(player.BonusCards),
-				strconv.Itoa(player.RoundGoals),
-				strconv.Itoa(player.Eggs),
-				strconv.Itoa(player.CachedFood),
-				strconv.Itoa(player.TuckedCards),
-				strconv.Itoa(player.NectarForest),
-				strconv.Itoa(player.NectarGrassland),
-				strconv.Itoa(player.NectarWetland),
-				strconv.Itoa(player.UnusedFood),
-				strconv.Itoa(player.Total),
-				strconv.Itoa(player.Rank),
+// CSVExporter writes games as CSV using a row shape
+type CSVExporter struct {
+	Shape RowShape
+}
+
+func (CSVExporter) ContentType() string   { return "text/csv" }
+func (CSVExporter) FileExtension() string { return "csv" }
+
+func (e CSVExporter) Export(w io.Writer, games GameIterator) (int, error) {
+	return WriteCSV(w, games, e.Shape)
+}
+
+// RowShape selects how games are laid out as CSV rows
+type RowShape string
+
+const (
+	// ShapePlayer writes one row per player per game, matching the import format
+	ShapePlayer RowShape = "player"
+	// ShapeGame writes one row per game with wide per-player columns
+	ShapeGame RowShape = "game"
+	// ShapeCategory writes one row per player per scoring category (long format)
+	ShapeCategory RowShape = "category"
+)
+
+// maxPlayers is the number of player column groups in the wide game shape
+const maxPlayers = 5
+
+// playerColumns are the per-player fields repeated in the wide game shape
+var playerColumns = []string{
+	"Name",
+	"BirdPoints",
+	"BonusCards",
+	"RoundGoals",
+	"Eggs",
+	"CachedFood",
+	"TuckedCards",
+	"NectarForest",
+	"NectarGrassland",
+	"NectarWetland",
+	"UnusedFood",
+	"Total",
+	"Rank",
+}
+
+// categoryHeader is the header for the long per-category shape
+var categoryHeader = []string{
+	"GameID",
+	"Date",
+	"IncludeOceania",
+	"PlayerName",
+	"Rank",
+	"Category",
+	"Value",
+}
+
+// ParseRowShape converts a query parameter into a RowShape, defaulting to ShapePlayer
+func ParseRowShape(s string) (RowShape, error) {
+	switch RowShape(s) {
+	case "", ShapePlayer:
+		return ShapePlayer, nil
+	case ShapeGame, ShapeCategory:
+		return RowShape(s), nil
+	default:
+		return "", fmt.Errorf("unknown row shape: %s", s)
+	}
+}
+
+// header returns the CSV header row for the shape
+func (shape RowShape) header() []string {
+	switch shape {
+	case ShapeGame:
+		header := []string{"GameID", "Date", "IncludeOceania", "NumPlayers", "WinnerName", "WinnerScore"}
+		for i := 1; i <= maxPlayers; i++ {
+			for _, col := range playerColumns {
+				header = append(header, fmt.Sprintf("Player%d%s", i, col))
+			}
+		}
+		return header
+	case ShapeCategory:
+		return categoryHeader
+	default:
+		return csvHeader
+	}
+}
+
+// rows converts a single game into the CSV rows for the shape
+func (shape RowShape) rows(game *db.GameResult) [][]string {
+	gameID := strconv.FormatInt(game.ID, 10)
+	date := game.CreatedAt.Format("2006-01-02")
+	includeOceania := strconv.FormatBool(game.IncludeOceania)
+
+	switch shape {
+	case ShapeGame:
+		row := []string{
+			gameID,
+			date,
+			includeOceania,
+			strconv.Itoa(game.NumPlayers),
+			game.WinnerName,
+			strconv.Itoa(game.WinnerScore),
+		}
+		for i := 0; i < maxPlayers; i++ {
+			if i < len(game.Players) {
+				p := game.Players[i]
+				row = append(row, p.PlayerName)
+				for _, value := range playerValues(p) {
+					row = append(row, strconv.Itoa(value))
+				}
+			} else {
+				// Pad empty seats so every row has the same width
+				row = append(row, make([]string, len(playerColumns))...)
+			}
+		}
+		return [][]string{row}
+
+	case ShapeCategory:
+		var rows [][]string
+		for _, p := range game.Players {
+			rank := strconv.Itoa(p.Rank)
+			values := playerValues(p)
+			// Skip Rank itself; it is already a column on every row
+			for i, category := range playerColumns[1 : len(playerColumns)-1] {
+				rows = append(rows, []string{
+					gameID,
+					date,
+					includeOceania,
+					p.PlayerName,
+					rank,
+					category,
+					strconv.Itoa(values[i]),
+				})
 			}
+		}
+		return rows
+
+	default:
+		rows := make([][]string, 0, len(game.Players))
+		for _, p := range game.Players {
+			row := []string{gameID, date, includeOceania, p.PlayerName}
+			for _, value := range playerValues(p) {
+				row = append(row, strconv.Itoa(value))
+			}
+			rows = append(rows, row)
+		}
+		return rows
+	}
+}
+
+// playerValues returns a player's numeric fields in playerColumns order (excluding Name)
+func playerValues(p scoring.PlayerGameEnd) []int {
+	return []int{
+		p.BirdPoints,
+		p.BonusCards,
+		p.RoundGoals,
+		p.Eggs,
+		p.CachedFood,
+		p.TuckedCards,
+		p.NectarForest,
+		p.NectarGrassland,
+		p.NectarWetland,
+		p.UnusedFood,
+		p.Total,
+		p.Rank,
+	}
+}
+
+// WriteCSV streams games from the iterator to w using the given row shape and
+// returns the number of games written. Rows are buffered only by the
+// csv.Writer, so memory use does not grow with history size.
+func WriteCSV(w io.Writer, games GameIterator, shape RowShape) (int, error) {
+	writer := csv.NewWriter(w)
+
+	// Write header
+	if err := writer.Write(shape.header()); err != nil {
+		return 0, fmt.Errorf("failed to write CSV header: %w", err)
+	}
 
+	// Write data rows
+	count := 0
+	for games.Next() {
+		game := games.GameResult()
+		for _, row := range shape.rows(game) {
 			if err := writer.Write(row); err != nil {
-				return nil, fmt.Errorf("failed to write CSV row for game %d, player %s: %w",
-					game.ID, player.PlayerName, err)
+				return count, fmt.Errorf("failed to write CSV row for game %d: %w", game.ID, err)
 			}
 		}
+		count++
+	}
+
+	if err := games.Err(); err != nil {
+		return count, fmt.Errorf("failed to read games: %w", err)
 	}
 
 	writer.Flush()
 	if err := writer.Error(); err != nil {
-		return nil, fmt.Errorf("CSV writer error: %w", err)
+		return count, fmt.Errorf("CSV writer error: %w", err)
 	}
 
-	return buf.Bytes(), nil
+	return count, nil
 }
@@ -2,6 +2,9 @@ package export
 
 import (
 	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -12,23 +15,60 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestExportGamesToCSV_EmptyList(t *testing.T) {
-	csvData, err := ExportGamesToCSV([]db.GameResult{})
+// iterateSaved saves games to a temporary database and returns an iterator
+// over them in the order they were played, as the export handler streams them.
+// Games are assigned IDs from 1 in the order given.
+func iterateSaved(t *testing.T, games []db.GameResult) *db.GameResultIterator {
+	t.Helper()
+	originalDB := db.DB
+	tmpDir, err := os.MkdirTemp("", "wingspan-export-*")
 	require.NoError(t, err)
+	require.NoError(t, db.Initialize(filepath.Join(tmpDir, "test.db")))
+	t.Cleanup(func() {
+		db.Close()
+		db.DB = originalDB
+		os.RemoveAll(tmpDir)
+	})
 
-	// Should only have header row
-	reader := csv.NewReader(strings.NewReader(string(csvData)))
-	records, err := reader.ReadAll()
+	for i := range games {
+		_, err := db.SaveGame(t.Context(), &games[i])
+		require.NoError(t, err)
+	}
+
+	it, err := db.IterateGameResultsChronological(t.Context(), db.GameFilter{})
+	require.NoError(t, err)
+	t.Cleanup(func() { it.Close() })
+	return it
+}
+
+// exportCSV exports saved games with the default CSV exporter and returns the parsed records
+func exportCSV(t *testing.T, games []db.GameResult) [][]string {
+	t.Helper()
+	exporter, err := NewExporter("csv", ShapePlayer)
 	require.NoError(t, err)
 
+	var buf strings.Builder
+	count, err := exporter.Export(&buf, iterateSaved(t, games))
+	require.NoError(t, err)
+	assert.Equal(t, len(games), count)
+
+	records, err := csv.NewReader(strings.NewReader(buf.String())).ReadAll()
+	require.NoError(t, err)
+	return records
+}
+
+func TestCSVExporter_EmptyList(t *testing.T) {
+	records := exportCSV(t, nil)
+
+	// Should only have header row
+
 	assert.Len(t, records, 1, "Should have only header row")
 	assert.Equal(t, csvHeader, records[0])
 }
 
-func TestExportGamesToCSV_SingleGameMultiplePlayers(t *testing.T) {
+func TestCSVExporter_SingleGameMultiplePlayers(t *testing.T) {
 	games := []db.GameResult{
 		{
-			ID:             1,
 			CreatedAt:      time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
 			NumPlayers:     2,
 			IncludeOceania: false,
@@ -63,12 +103,7 @@ func TestExportGamesToCSV_SingleGameMultiplePlayers(t *testing.T) {
 		},
 	}
 
-	csvData, err := ExportGamesToCSV(games)
-	require.NoError(t, err)
-
-	reader := csv.NewReader(strings.NewReader(string(csvData)))
-	records, err := reader.ReadAll()
-	require.NoError(t, err)
+	records := exportCSV(t, games)
 
 	// Header + 2 player rows
 	assert.Len(t, records, 3)
@@ -101,10 +136,9 @@ func TestExportGamesToCSV_SingleGameMultiplePlayers(t *testing.T) {
 	assert.Equal(t, "2", records[2][15])  // Rank
 }
 
-func TestExportGamesToCSV_MultipleGames(t *testing.T) {
+func TestCSVExporter_MultipleGames(t *testing.T) {
 	games := []db.GameResult{
 		{
-			ID:             1,
 			CreatedAt:      time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
 			NumPlayers:     2,
 			IncludeOceania: false,
@@ -116,7 +150,6 @@ func TestExportGamesToCSV_MultipleGames(t *testing.T) {
 			},
 		},
 		{
-			ID:             2,
 			CreatedAt:      time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC),
 			NumPlayers:     2,
 			IncludeOceania: false,
@@ -129,12 +162,7 @@ func TestExportGamesToCSV_MultipleGames(t *testing.T) {
 		},
 	}
 
-	csvData, err := ExportGamesToCSV(games)
-	require.NoError(t, err)
-
-	reader := csv.NewReader(strings.NewReader(string(csvData)))
-	records, err := reader.ReadAll()
-	require.NoError(t, err)
+	records := exportCSV(t, games)
 
 	// Header + 2 games * 2 players = 5 rows
 	assert.Len(t, records, 5)
@@ -150,10 +178,9 @@ func TestExportGamesToCSV_MultipleGames(t *testing.T) {
 	assert.Equal(t, "2024-01-16", records[3][1])
 }
 
-func TestExportGamesToCSV_WithOceaniaExpansion(t *testing.T) {
+func TestCSVExporter_WithOceaniaExpansion(t *testing.T) {
 	games := []db.GameResult{
 		{
-			ID:             1,
 			CreatedAt:      time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
 			NumPlayers:     2,
 			IncludeOceania: true,
@@ -199,12 +226,7 @@ func TestExportGamesToCSV_WithOceaniaExpansion(t *testing.T) {
 		},
 	}
 
-	csvData, err := ExportGamesToCSV(games)
-	require.NoError(t, err)
-
-	reader := csv.NewReader(strings.NewReader(string(csvData)))
-	records, err := reader.ReadAll()
-	require.NoError(t, err)
+	records := exportCSV(t, games)
 
 	assert.Len(t, records, 3) // Header + 2 players
 
@@ -223,7 +245,7 @@ func TestExportGamesToCSV_WithOceaniaExpansion(t *testing.T) {
 	assert.Equal(t, "2", records[2][12]) // NectarWetland
 }
 
-func TestExportGamesToCSV_HeaderMatchesExpectedFormat(t *testing.T) {
+func TestCSVExporter_HeaderMatchesExpectedFormat(t *testing.T) {
 	expectedHeader := []string{
 		"GameID",
 		"Date",
@@ -247,10 +269,9 @@ func TestExportGamesToCSV_HeaderMatchesExpectedFormat(t *testing.T) {
 	assert.Len(t, csvHeader, 16, "CSV should have 16 columns")
 }
 
-func TestExportGamesToCSV_SpecialCharactersInPlayerName(t *testing.T) {
+func TestCSVExporter_SpecialCharactersInPlayerName(t *testing.T) {
 	games := []db.GameResult{
 		{
-			ID:             1,
 			CreatedAt:      time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
 			NumPlayers:     1,
 			IncludeOceania: false,
@@ -266,13 +287,150 @@ func TestExportGamesToCSV_SpecialCharactersInPlayerName(t *testing.T) {
 		},
 	}
 
-	csvData, err := ExportGamesToCSV(games)
+	// CSV should properly escape the comma in the name
+	records := exportCSV(t, games)
+
+	assert.Equal(t, "Alice, Jr.", records[1][3])
+}
+
+func TestWriteCSV_StreamsToWriter(t *testing.T) {
+	games := []db.GameResult{
+		{
+			CreatedAt: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+			Players: []scoring.PlayerGameEnd{
+				{PlayerName: "Alice", Total: 92, Rank: 1},
+				{PlayerName: "Bob", Total: 79, Rank: 2},
+			},
+		},
+		{
+			CreatedAt: time.Date(2024, 1, 16, 10, 30, 0, 0, time.UTC),
+			Players: []scoring.PlayerGameEnd{
+				{PlayerName: "Carol", Total: 101, Rank: 1},
+				{PlayerName: "Dave", Total: 88, Rank: 2},
+			},
+		},
+	}
+
+	var buf strings.Builder
+	count, err := WriteCSV(&buf, iterateSaved(t, games), ShapePlayer)
 	require.NoError(t, err)
+	assert.Equal(t, 2, count)
 
-	// CSV should properly escape the comma in the name
-	reader := csv.NewReader(strings.NewReader(string(csvData)))
-	records, err := reader.ReadAll()
+	records, err := csv.NewReader(strings.NewReader(buf.String())).ReadAll()
 	require.NoError(t, err)
+	assert.Len(t, records, 5, "Should have header + 4 player rows")
+	assert.Equal(t, "Carol", records[3][3])
+}
 
-	assert.Equal(t, "Alice, Jr.", records[1][3])
+// failingIterator reports an error after yielding its games
+type failingIterator struct {
+	GameIterator
+}
+
+func (it *failingIterator) Err() error {
+	return errors.New("database went away")
+}
+
+func TestWriteCSV_IteratorError(t *testing.T) {
+	it := &failingIterator{iterateSaved(t, []db.GameResult{
+		{Players: []scoring.PlayerGameEnd{{PlayerName: "Alice", Rank: 1}}},
+	})}
+
+	var buf strings.Builder
+	count, err := WriteCSV(&buf, it, ShapePlayer)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "database went away")
+	assert.Equal(t, 1, count)
+}
+
+func TestParseRowShape(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected RowShape
+		wantErr  bool
+	}{
+		{"", ShapePlayer, false},
+		{"player", ShapePlayer, false},
+		{"game", ShapeGame, false},
+		{"category", ShapeCategory, false},
+		{"wide", "", true},
+	}
+
+	for _, tc := range testCases {
+		shape, err := ParseRowShape(tc.input)
+		if tc.wantErr {
+			assert.Error(t, err, "input %q", tc.input)
+			continue
+		}
+		require.NoError(t, err, "input %q", tc.input)
+		assert.Equal(t, tc.expected, shape)
+	}
+}
+
+func TestWriteCSV_GameShape(t *testing.T) {
+	games := []db.GameResult{
+		{
+			CreatedAt:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			NumPlayers:  2,
+			WinnerName:  "Alice",
+			WinnerScore: 92,
+			Players: []scoring.PlayerGameEnd{
+				{PlayerName: "Alice", BirdPoints: 45, Eggs: 9, Total: 92, Rank: 1},
+				{PlayerName: "Bob", BirdPoints: 40, Eggs: 8, Total: 79, Rank: 2},
+			},
+		},
+	}
+
+	var buf strings.Builder
+	_, err := WriteCSV(&buf, iterateSaved(t, games), ShapeGame)
+	require.NoError(t, err)
+
+	records, err := csv.NewReader(strings.NewReader(buf.String())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 2, "Should have header + 1 game row")
+
+	header, row := records[0], records[1]
+	assert.Len(t, header, 6+maxPlayers*len(playerColumns))
+	assert.Len(t, row, len(header))
+
+	col := func(name string) string {
+		for i, h := range header {
+			if h == name {
+				return row[i]
+			}
+		}
+		t.Fatalf("column %s not found", name)
+		return ""
+	}
+	assert.Equal(t, "1", col("GameID"))
+	assert.Equal(t, "Alice", col("WinnerName"))
+	assert.Equal(t, "Alice", col("Player1Name"))
+	assert.Equal(t, "45", col("Player1BirdPoints"))
+	assert.Equal(t, "Bob", col("Player2Name"))
+	assert.Equal(t, "8", col("Player2Eggs"))
+	assert.Equal(t, "", col("Player3Name"), "Empty seats should be blank")
+}
+
+func TestWriteCSV_CategoryShape(t *testing.T) {
+	games := []db.GameResult{
+		{
+			CreatedAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			Players: []scoring.PlayerGameEnd{
+				{PlayerName: "Alice", BirdPoints: 45, BonusCards: 12, Total: 57, Rank: 1},
+			},
+		},
+	}
+
+	var buf strings.Builder
+	_, err := WriteCSV(&buf, iterateSaved(t, games), ShapeCategory)
+	require.NoError(t, err)
+
+	records, err := csv.NewReader(strings.NewReader(buf.String())).ReadAll()
+	require.NoError(t, err)
+
+	assert.Equal(t, categoryHeader, records[0])
+	// One row per category from BirdPoints through Total
+	assert.Len(t, records, 1+len(playerColumns)-2)
+	assert.Equal(t, []string{"1", "2024-03-01", "false", "Alice", "1", "BirdPoints", "45"}, records[1])
+	assert.Equal(t, []string{"1", "2024-03-01", "false", "Alice", "1", "Total", "57"}, records[len(records)-1])
 }
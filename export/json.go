@@ -0,0 +1,84 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// JSONExporter writes games as a single JSON array of db.GameResult objects.
+// The array is written element by element so it never holds the full history.
+type JSONExporter struct{}
+
+func (JSONExporter) ContentType() string   { return "application/json" }
+func (JSONExporter) FileExtension() string { return "json" }
+
+func (JSONExporter) Export(w io.Writer, games GameIterator) (int, error) {
+	bw := bufio.NewWriter(w)
+
+	if _, err := bw.WriteString("["); err != nil {
+		return 0, fmt.Errorf("failed to write JSON: %w", err)
+	}
+
+	count := 0
+	for games.Next() {
+		game := games.GameResult()
+		data, err := json.Marshal(game)
+		if err != nil {
+			return count, fmt.Errorf("failed to marshal game %d: %w", game.ID, err)
+		}
+		if count > 0 {
+			if _, err := bw.WriteString(","); err != nil {
+				return count, fmt.Errorf("failed to write JSON: %w", err)
+			}
+		}
+		if _, err := bw.Write(data); err != nil {
+			return count, fmt.Errorf("failed to write JSON: %w", err)
+		}
+		count++
+	}
+
+	if err := games.Err(); err != nil {
+		return count, fmt.Errorf("failed to read games: %w", err)
+	}
+
+	if _, err := bw.WriteString("]\n"); err != nil {
+		return count, fmt.Errorf("failed to write JSON: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return count, fmt.Errorf("failed to write JSON: %w", err)
+	}
+
+	return count, nil
+}
+
+// NDJSONExporter writes one db.GameResult JSON object per line
+type NDJSONExporter struct{}
+
+func (NDJSONExporter) ContentType() string   { return "application/x-ndjson" }
+func (NDJSONExporter) FileExtension() string { return "ndjson" }
+
+func (NDJSONExporter) Export(w io.Writer, games GameIterator) (int, error) {
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+
+	count := 0
+	for games.Next() {
+		game := games.GameResult()
+		if err := encoder.Encode(game); err != nil {
+			return count, fmt.Errorf("failed to encode game %d: %w", game.ID, err)
+		}
+		count++
+	}
+
+	if err := games.Err(); err != nil {
+		return count, fmt.Errorf("failed to read games: %w", err)
+	}
+
+	if err := bw.Flush(); err != nil {
+		return count, fmt.Errorf("failed to write NDJSON: %w", err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,104 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+	"wingspan-scoring/db"
+	"wingspan-scoring/scoring"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleGames() []db.GameResult {
+	return []db.GameResult{
+		{
+			CreatedAt:   time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+			NumPlayers:  2,
+			WinnerName:  "Alice",
+			WinnerScore: 92,
+			Players: []scoring.PlayerGameEnd{
+				{PlayerName: "Alice", BirdPoints: 45, Total: 92, Rank: 1},
+				{PlayerName: "Bob", BirdPoints: 40, Total: 79, Rank: 2},
+			},
+		},
+		{
+			CreatedAt:      time.Date(2024, 1, 16, 10, 30, 0, 0, time.UTC),
+			NumPlayers:     2,
+			IncludeOceania: true,
+			WinnerName:     "Carol",
+			WinnerScore:    111,
+			Players: []scoring.PlayerGameEnd{
+				{PlayerName: "Carol", NectarForest: 3, Total: 111, Rank: 1},
+				{PlayerName: "Dave", NectarForest: 1, Total: 102, Rank: 2},
+			},
+			NectarScoring: &scoring.NectarScoring{
+				Forest: map[string]int{"Carol": 5, "Dave": 2},
+			},
+		},
+	}
+}
+
+func TestJSONExporter_WritesArray(t *testing.T) {
+	var buf strings.Builder
+	count, err := JSONExporter{}.Export(&buf, iterateSaved(t, sampleGames()))
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	var decoded []db.GameResult
+	require.NoError(t, json.Unmarshal([]byte(buf.String()), &decoded))
+	require.Len(t, decoded, 2)
+	assert.Equal(t, "Alice", decoded[0].WinnerName)
+	require.NotNil(t, decoded[1].NectarScoring)
+	assert.Equal(t, 5, decoded[1].NectarScoring.Forest["Carol"])
+}
+
+func TestJSONExporter_EmptyList(t *testing.T) {
+	var buf strings.Builder
+	count, err := JSONExporter{}.Export(&buf, iterateSaved(t, nil))
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, "[]\n", buf.String())
+}
+
+func TestNDJSONExporter_OneGamePerLine(t *testing.T) {
+	var buf strings.Builder
+	count, err := NDJSONExporter{}.Export(&buf, iterateSaved(t, sampleGames()))
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	scanner := bufio.NewScanner(strings.NewReader(buf.String()))
+	var ids []int64
+	for scanner.Scan() {
+		var game db.GameResult
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &game))
+		ids = append(ids, game.ID)
+	}
+	assert.Equal(t, []int64{1, 2}, ids)
+}
+
+func TestNewExporter(t *testing.T) {
+	testCases := []struct {
+		format    string
+		extension string
+	}{
+		{"", "csv"},
+		{"csv", "csv"},
+		{"json", "json"},
+		{"ndjson", "ndjson"},
+		{"xlsx", "xlsx"},
+	}
+
+	for _, tc := range testCases {
+		exporter, err := NewExporter(tc.format, ShapePlayer)
+		require.NoError(t, err, "format %q", tc.format)
+		assert.Equal(t, tc.extension, exporter.FileExtension())
+		assert.NotEmpty(t, exporter.ContentType())
+	}
+
+	_, err := NewExporter("parquet", ShapePlayer)
+	assert.Error(t, err)
+}
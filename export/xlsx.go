@@ -0,0 +1,244 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"wingspan-scoring/db"
+)
+
+// XLSXExporter writes an Excel workbook with a summary sheet listing every
+// game followed by one sheet per game. The workbook is assembled with
+// archive/zip, writing each game's sheet as soon as it is read.
+type XLSXExporter struct{}
+
+func (XLSXExporter) ContentType() string {
+	return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+}
+
+func (XLSXExporter) FileExtension() string { return "xlsx" }
+
+// xlsxSummaryHeader is the header row of the summary sheet
+var xlsxSummaryHeader = []interface{}{
+	"GameID", "Date", "IncludeOceania", "NumPlayers", "WinnerName", "WinnerScore", "Sheet",
+}
+
+// xlsxGameHeader is the header row of each per-game sheet. Unlike the CSV it
+// includes the nectar points awarded and the per-round round goal breakdown.
+var xlsxGameHeader = []interface{}{
+	"PlayerName", "Rank", "Total",
+	"BirdPoints", "BonusCards", "RoundGoals",
+	"Round1", "Round2", "Round3", "Round4",
+	"Eggs", "CachedFood", "TuckedCards",
+	"NectarForest", "NectarGrassland", "NectarWetland",
+	"NectarForestPoints", "NectarGrasslandPoints", "NectarWetlandPoints",
+	"UnusedFood",
+}
+
+const (
+	xlsxMainNS  = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	xlsxRelNS   = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+	xlsxPkgRels = "http://schemas.openxmlformats.org/package/2006/relationships"
+	xmlDecl     = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+)
+
+// xlsxSheet records a worksheet part for the workbook manifest
+type xlsxSheet struct {
+	name string
+	file string
+}
+
+func (XLSXExporter) Export(w io.Writer, games GameIterator) (int, error) {
+	zw := zip.NewWriter(w)
+
+	var sheets []xlsxSheet
+	var summary [][]interface{}
+
+	count := 0
+	for games.Next() {
+		game := games.GameResult()
+		sheet := xlsxSheet{
+			name: fmt.Sprintf("Game %d", game.ID),
+			file: fmt.Sprintf("sheet%d.xml", count+2), // sheet1 is the summary
+		}
+
+		if err := writeXLSXSheet(zw, sheet.file, gameSheetRows(game)); err != nil {
+			return count, fmt.Errorf("failed to write sheet for game %d: %w", game.ID, err)
+		}
+
+		sheets = append(sheets, sheet)
+		summary = append(summary, []interface{}{
+			game.ID,
+			game.CreatedAt.Format("2006-01-02"),
+			strconv.FormatBool(game.IncludeOceania),
+			game.NumPlayers,
+			game.WinnerName,
+			game.WinnerScore,
+			sheet.name,
+		})
+		count++
+	}
+
+	if err := games.Err(); err != nil {
+		return count, fmt.Errorf("failed to read games: %w", err)
+	}
+
+	// The summary goes first in the workbook even though it is written last
+	summarySheet := xlsxSheet{name: "Summary", file: "sheet1.xml"}
+	if err := writeXLSXSheet(zw, summarySheet.file, append([][]interface{}{xlsxSummaryHeader}, summary...)); err != nil {
+		return count, fmt.Errorf("failed to write summary sheet: %w", err)
+	}
+	sheets = append([]xlsxSheet{summarySheet}, sheets...)
+
+	if err := writeXLSXManifest(zw, sheets); err != nil {
+		return count, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return count, fmt.Errorf("failed to finish workbook: %w", err)
+	}
+
+	return count, nil
+}
+
+// gameSheetRows builds the header and per-player rows for a game's sheet
+func gameSheetRows(game *db.GameResult) [][]interface{} {
+	rows := [][]interface{}{xlsxGameHeader}
+
+	for _, p := range game.Players {
+		breakdown := p.RoundGoalsBreakdown
+		if breakdown == nil {
+			breakdown = game.RoundBreakdown[p.PlayerName]
+		}
+		rounds := []interface{}{nil, nil, nil, nil}
+		if breakdown != nil {
+			rounds = []interface{}{breakdown.Round1, breakdown.Round2, breakdown.Round3, breakdown.Round4}
+		}
+
+		var forestPts, grasslandPts, wetlandPts interface{}
+		if game.NectarScoring != nil {
+			forestPts = game.NectarScoring.Forest[p.PlayerName]
+			grasslandPts = game.NectarScoring.Grassland[p.PlayerName]
+			wetlandPts = game.NectarScoring.Wetland[p.PlayerName]
+		}
+
+		row := []interface{}{p.PlayerName, p.Rank, p.Total, p.BirdPoints, p.BonusCards, p.RoundGoals}
+		row = append(row, rounds...)
+		row = append(row,
+			p.Eggs, p.CachedFood, p.TuckedCards,
+			p.NectarForest, p.NectarGrassland, p.NectarWetland,
+			forestPts, grasslandPts, wetlandPts,
+			p.UnusedFood,
+		)
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// writeXLSXSheet writes a worksheet part. Cells may be strings, ints, int64s
+// or nil for an empty cell; strings are written inline so no shared string
+// table is needed.
+func writeXLSXSheet(zw *zip.Writer, file string, rows [][]interface{}) error {
+	fw, err := zw.Create("xl/worksheets/" + file)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(fw)
+
+	bw.WriteString(xmlDecl)
+	bw.WriteString(`<worksheet xmlns="` + xlsxMainNS + `"><sheetData>`)
+	for r, row := range rows {
+		rowNum := strconv.Itoa(r + 1)
+		bw.WriteString(`<row r="` + rowNum + `">`)
+		for c, value := range row {
+			ref := xlsxColumn(c) + rowNum
+			switch v := value.(type) {
+			case nil:
+				continue
+			case int:
+				bw.WriteString(`<c r="` + ref + `"><v>` + strconv.Itoa(v) + `</v></c>`)
+			case int64:
+				bw.WriteString(`<c r="` + ref + `"><v>` + strconv.FormatInt(v, 10) + `</v></c>`)
+			default:
+				bw.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t>`)
+				xml.EscapeText(bw, []byte(fmt.Sprint(v)))
+				bw.WriteString(`</t></is></c>`)
+			}
+		}
+		bw.WriteString(`</row>`)
+	}
+	bw.WriteString(`</sheetData></worksheet>`)
+
+	return bw.Flush()
+}
+
+// writeXLSXManifest writes the workbook, relationship and content type parts
+func writeXLSXManifest(zw *zip.Writer, sheets []xlsxSheet) error {
+	parts := []struct {
+		name  string
+		write func(w *bufio.Writer)
+	}{
+		{"[Content_Types].xml", func(w *bufio.Writer) {
+			w.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+			w.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+			w.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+			w.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+			for _, s := range sheets {
+				w.WriteString(`<Override PartName="/xl/worksheets/` + s.file + `" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`)
+			}
+			w.WriteString(`</Types>`)
+		}},
+		{"_rels/.rels", func(w *bufio.Writer) {
+			w.WriteString(`<Relationships xmlns="` + xlsxPkgRels + `">`)
+			w.WriteString(`<Relationship Id="rId1" Type="` + xlsxRelNS + `/officeDocument" Target="xl/workbook.xml"/>`)
+			w.WriteString(`</Relationships>`)
+		}},
+		{"xl/workbook.xml", func(w *bufio.Writer) {
+			w.WriteString(`<workbook xmlns="` + xlsxMainNS + `" xmlns:r="` + xlsxRelNS + `"><sheets>`)
+			for i, s := range sheets {
+				id := strconv.Itoa(i + 1)
+				w.WriteString(`<sheet name="`)
+				xml.EscapeText(w, []byte(s.name))
+				w.WriteString(`" sheetId="` + id + `" r:id="rId` + id + `"/>`)
+			}
+			w.WriteString(`</sheets></workbook>`)
+		}},
+		{"xl/_rels/workbook.xml.rels", func(w *bufio.Writer) {
+			w.WriteString(`<Relationships xmlns="` + xlsxPkgRels + `">`)
+			for i, s := range sheets {
+				id := strconv.Itoa(i + 1)
+				w.WriteString(`<Relationship Id="rId` + id + `" Type="` + xlsxRelNS + `/worksheet" Target="worksheets/` + s.file + `"/>`)
+			}
+			w.WriteString(`</Relationships>`)
+		}},
+	}
+
+	for _, part := range parts {
+		fw, err := zw.Create(part.name)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", part.name, err)
+		}
+		bw := bufio.NewWriter(fw)
+		bw.WriteString(xmlDecl)
+		part.write(bw)
+		if err := bw.Flush(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", part.name, err)
+		}
+	}
+
+	return nil
+}
+
+// xlsxColumn converts a zero-based column index to a spreadsheet column name (A, B, ..., AA)
+func xlsxColumn(i int) string {
+	name := ""
+	for i >= 0 {
+		name = string(rune('A'+i%26)) + name
+		i = i/26 - 1
+	}
+	return name
+}
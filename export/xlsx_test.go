@@ -0,0 +1,78 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readZipFile returns the contents of a named file in a zip archive
+func readZipFile(t *testing.T, zr *zip.Reader, name string) string {
+	t.Helper()
+	f, err := zr.Open(name)
+	require.NoError(t, err, "missing %s", name)
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestXLSXExporter_SummaryAndGameSheets(t *testing.T) {
+	var buf bytes.Buffer
+	count, err := XLSXExporter{}.Export(&buf, iterateSaved(t, sampleGames()))
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	workbook := readZipFile(t, zr, "xl/workbook.xml")
+	summaryPos := strings.Index(workbook, `name="Summary"`)
+	game1Pos := strings.Index(workbook, `name="Game 1"`)
+	game2Pos := strings.Index(workbook, `name="Game 2"`)
+	assert.True(t, summaryPos >= 0 && summaryPos < game1Pos && game1Pos < game2Pos,
+		"Summary should be the first sheet, followed by each game")
+
+	contentTypes := readZipFile(t, zr, "[Content_Types].xml")
+	assert.Contains(t, contentTypes, "/xl/worksheets/sheet3.xml")
+	readZipFile(t, zr, "_rels/.rels")
+	readZipFile(t, zr, "xl/_rels/workbook.xml.rels")
+
+	summary := readZipFile(t, zr, "xl/worksheets/sheet1.xml")
+	assert.Contains(t, summary, "<t>WinnerName</t>")
+	assert.Contains(t, summary, "<t>Carol</t>")
+
+	// Game 2 includes nectar points the CSV export omits
+	game2 := readZipFile(t, zr, "xl/worksheets/sheet3.xml")
+	assert.Contains(t, game2, "<t>NectarForestPoints</t>")
+	assert.Contains(t, game2, "<t>Round1</t>")
+	assert.Contains(t, game2, `<c r="Q2"><v>5</v></c>`, "Carol's forest nectar points")
+	assert.Contains(t, game2, `<c r="Q3"><v>2</v></c>`, "Dave's forest nectar points")
+}
+
+func TestXLSXExporter_EscapesText(t *testing.T) {
+	games := sampleGames()[:1]
+	games[0].Players[0].PlayerName = `Alice <"&">`
+
+	var buf bytes.Buffer
+	_, err := XLSXExporter{}.Export(&buf, iterateSaved(t, games))
+	require.NoError(t, err)
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	sheet := readZipFile(t, zr, "xl/worksheets/sheet2.xml")
+	assert.Contains(t, sheet, "Alice &lt;&#34;&amp;&#34;&gt;")
+}
+
+func TestXLSXColumn(t *testing.T) {
+	assert.Equal(t, "A", xlsxColumn(0))
+	assert.Equal(t, "Z", xlsxColumn(25))
+	assert.Equal(t, "AA", xlsxColumn(26))
+	assert.Equal(t, "AZ", xlsxColumn(51))
+	assert.Equal(t, "BA", xlsxColumn(52))
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"wingspan-scoring/db"
+)
+
+// handleHealthz answers as long as the process is serving requests
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// handleReadyz answers once the database can be reached and is migrated,
+// so no traffic is sent before the app can serve it
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if err := db.Ready(r.Context()); err != nil {
+		slog.WarnContext(r.Context(), "Not ready", "error", err)
+		http.Error(w, "Database not ready", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
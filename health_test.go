@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"wingspan-scoring/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHealthz tests that liveness doesn't need the database or signing in
+func TestHealthz(t *testing.T) {
+	originalDB := db.DB
+	defer func() { db.DB = originalDB }()
+	db.DB = nil
+
+	w := httptest.NewRecorder()
+	newAuthTestServer("").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ok\n", w.Body.String())
+}
+
+// TestReadyz tests that readiness waits for the database
+func TestReadyz(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+	handler := newAuthTestServer("")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	_, err := db.DB.Exec(`ALTER TABLE game_results DROP COLUMN goals_json`)
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "migrations not applied")
+
+	db.Close()
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "database unreachable")
+}
+
+// TestMetrics tests that requests, queries, saves, imports and exports are counted
+func TestMetrics(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+	handler := newAuthTestServer(db.RoleAdmin)
+
+	requests := httpRequests.Value("/api/games", http.MethodGet, "200")
+	saved := httpRequests.Value("/api/calculate-game-end", http.MethodPost, "200")
+	unknown := httpRequests.Value("/api/games/", http.MethodGet, "404")
+	csvExports := exports.Value("csv", "success")
+	csvImports := imports.Value("csv", "failure")
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/api/calculate-game-end", bytes.NewBufferString(testGame)),
+		httptest.NewRequest(http.MethodGet, "/api/games", nil),
+		httptest.NewRequest(http.MethodGet, "/g/default/api/games", nil),
+		httptest.NewRequest(http.MethodGet, "/api/games/999", nil),
+		httptest.NewRequest(http.MethodGet, "/api/export?format=csv", nil),
+	} {
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("csvFile", "games.csv")
+	require.NoError(t, err)
+	part.Write([]byte("Date,Player,Score\n"))
+	form.WriteField("format", "csv")
+	form.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, requests+2, httpRequests.Value("/api/games", http.MethodGet, "200"), "group paths count under their route")
+	assert.Equal(t, saved+1, httpRequests.Value("/api/calculate-game-end", http.MethodPost, "200"))
+	assert.Equal(t, unknown+1, httpRequests.Value("/api/games/", http.MethodGet, "404"))
+	assert.Equal(t, csvExports+1, exports.Value("csv", "success"))
+	assert.Equal(t, csvImports+1, imports.Value("csv", "failure"))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `wingspan_http_request_duration_seconds_count{route="/api/games",method="GET"}`)
+	assert.Contains(t, w.Body.String(), `wingspan_db_query_duration_seconds_count{query="SaveGame"}`)
+	assert.Contains(t, w.Body.String(), "wingspan_games_saved_total ")
+	assert.Contains(t, w.Body.String(), `wingspan_exported_games_total{format="csv"} `)
+
+	// Metrics need signing in like the rest of the app
+	w = httptest.NewRecorder()
+	newAuthTestServer("").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+}
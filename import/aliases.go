@@ -0,0 +1,350 @@
+package importgames
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"wingspan-scoring/scoring"
+)
+
+// columnAliases maps normalised column or row labels used by other score
+// trackers (and the printed scorepad) to the native CSV field names
+var columnAliases = map[string]string{
+	"gameid":     "GameID",
+	"game":       "GameID",
+	"gamenumber": "GameID",
+	"playid":     "GameID",
+
+	"date":     "Date",
+	"played":   "Date",
+	"playedat": "Date",
+	"datetime": "Date",
+
+	"includeoceania":   "IncludeOceania",
+	"oceania":          "IncludeOceania",
+	"oceaniaexpansion": "IncludeOceania",
+
+	"playername": "PlayerName",
+	"player":     "PlayerName",
+	"name":       "PlayerName",
+
+	"birdpoints": "BirdPoints",
+	"birds":      "BirdPoints",
+	"bird":       "BirdPoints",
+	"birdcards":  "BirdPoints",
+
+	"bonuscards": "BonusCards",
+	"bonus":      "BonusCards",
+	"bonuscard":  "BonusCards",
+
+	"roundgoals":      "RoundGoals",
+	"roundgoal":       "RoundGoals",
+	"goals":           "RoundGoals",
+	"endofroundgoals": "RoundGoals",
+
+	"eggs": "Eggs",
+	"egg":  "Eggs",
+
+	"cachedfood":  "CachedFood",
+	"cached":      "CachedFood",
+	"food":        "CachedFood",
+	"foodoncards": "CachedFood",
+
+	"tuckedcards": "TuckedCards",
+	"tucked":      "TuckedCards",
+
+	"nectarforest":    "NectarForest",
+	"forestnectar":    "NectarForest",
+	"nectargrassland": "NectarGrassland",
+	"grasslandnectar": "NectarGrassland",
+	"nectarwetland":   "NectarWetland",
+	"wetlandnectar":   "NectarWetland",
+
+	"unusedfood":   "UnusedFood",
+	"leftoverfood": "UnusedFood",
+	"foodtokens":   "UnusedFood",
+
+	"total":      "Total",
+	"totalscore": "Total",
+	"score":      "Total",
+
+	"rank":     "Rank",
+	"place":    "Rank",
+	"position": "Rank",
+}
+
+// normalizeLabel lowercases a label and strips everything but letters and digits,
+// so "End-of-round goals" and "endOfRoundGoals" compare equal
+func normalizeLabel(label string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(label) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// canonicalField returns the native field name for a column or row label
+func canonicalField(label string) (string, bool) {
+	field, ok := columnAliases[normalizeLabel(label)]
+	return field, ok
+}
+
+// isNectarField reports whether the native field is an Oceania nectar count
+func isNectarField(field string) bool {
+	return field == "NectarForest" || field == "NectarGrassland" || field == "NectarWetland"
+}
+
+// setPlayerScore sets a numeric PlayerGameEnd field by its native field name.
+// Empty values leave the field at zero.
+func setPlayerScore(p *scoring.PlayerGameEnd, field, value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+
+	n, err := parseInt(value, field)
+	if err != nil {
+		return err
+	}
+
+	switch field {
+	case "BirdPoints":
+		p.BirdPoints = n
+	case "BonusCards":
+		p.BonusCards = n
+	case "RoundGoals":
+		p.RoundGoals = n
+	case "Eggs":
+		p.Eggs = n
+	case "CachedFood":
+		p.CachedFood = n
+	case "TuckedCards":
+		p.TuckedCards = n
+	case "NectarForest":
+		p.NectarForest = n
+	case "NectarGrassland":
+		p.NectarGrassland = n
+	case "NectarWetland":
+		p.NectarWetland = n
+	case "UnusedFood":
+		p.UnusedFood = n
+	case "Total":
+		p.Total = n
+	case "Rank":
+		if n < 1 {
+			return fmt.Errorf("rank must be >= 1")
+		}
+		p.Rank = n
+	default:
+		return fmt.Errorf("%s is not a score field", field)
+	}
+	return nil
+}
+
+// firstCSVRecord parses the first CSV record from a sample
+func firstCSVRecord(sample []byte) ([]string, bool) {
+	reader := csv.NewReader(bytes.NewReader(sample))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	record, err := reader.Read()
+	if err != nil {
+		return nil, false
+	}
+	return record, true
+}
+
+// AliasCSVImporter reads CSV exports from other score trackers: one row per
+// player, columns in any order, with headers matched against columnAliases.
+// Rows are grouped into games by GameID, or by Date when there is no game
+// column; files with neither are refused. Ranks are computed from totals
+// when the file doesn't provide them.
+type AliasCSVImporter struct{}
+
+func (AliasCSVImporter) Name() string { return "csv-aliases" }
+
+func (AliasCSVImporter) Detect(sample []byte) bool {
+	header, ok := firstCSVRecord(sample)
+	if !ok {
+		return false
+	}
+
+	hasPlayer, hasScore := false, false
+	for _, h := range header {
+		field, ok := canonicalField(h)
+		if !ok {
+			continue
+		}
+		switch field {
+		case "PlayerName":
+			hasPlayer = true
+		case "GameID", "Date", "IncludeOceania":
+		default:
+			hasScore = true
+		}
+	}
+	return hasPlayer && hasScore
+}
+
+func (AliasCSVImporter) Parse(reader io.Reader) (*ParseResult, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+	csvReader.FieldsPerRecord = -1
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %v", err)
+	}
+
+	result := &ParseResult{}
+
+	// Map column index -> native field
+	columns := make(map[int]string)
+	seen := make(map[string]bool)
+	for i, h := range header {
+		field, ok := canonicalField(h)
+		if !ok {
+			result.Unmapped = addUnmapped(result.Unmapped, strings.TrimSpace(h))
+			continue
+		}
+		if seen[field] {
+			result.Errors = append(result.Errors, ImportError{Line: 1, Message: fmt.Sprintf("column %q duplicates %s", h, field)})
+			continue
+		}
+		seen[field] = true
+		columns[i] = field
+	}
+	if !seen["PlayerName"] {
+		result.Errors = append(result.Errors, ImportError{Line: 1, Message: "no player name column found"})
+		return result, nil
+	}
+	if !seen["GameID"] && !seen["Date"] {
+		result.Errors = append(result.Errors, ImportError{Line: 1, Message: "no game or date column found to group players into games"})
+		return result, nil
+	}
+
+	type pendingGame struct {
+		key      string
+		date     string
+		oceania  string
+		firstRow int
+		players  []scoring.PlayerGameEnd
+	}
+	games := make(map[string]*pendingGame)
+	var order []string
+
+	// Rows without a game ID are grouped by date. A game is a run of
+	// consecutive rows on one date, and ends early when a player appears
+	// again, so two games played the same day stay apart.
+	var run *pendingGame
+	dateGames := make(map[string]int)
+
+	lineNum := 1
+	for {
+		lineNum++
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, ImportError{Line: lineNum, Message: fmt.Sprintf("failed to read row: %v", err)})
+			continue
+		}
+
+		var gameID, date, oceania string
+		var player scoring.PlayerGameEnd
+		rowErr := false
+		for i, field := range columns {
+			if i >= len(row) {
+				continue
+			}
+			value := strings.TrimSpace(row[i])
+			switch field {
+			case "GameID":
+				gameID = value
+			case "Date":
+				date = value
+			case "IncludeOceania":
+				oceania = value
+			case "PlayerName":
+				player.PlayerName = value
+			default:
+				if err := setPlayerScore(&player, field, value); err != nil {
+					result.Errors = append(result.Errors, ImportError{Line: lineNum, GameID: gameID, Message: err.Error()})
+					rowErr = true
+				}
+			}
+		}
+		if rowErr {
+			continue
+		}
+
+		key := gameID
+		if key == "" && date != "" {
+			samePlayer := func(p scoring.PlayerGameEnd) bool { return p.PlayerName == player.PlayerName }
+			if run != nil && run.date == date && !slices.ContainsFunc(run.players, samePlayer) {
+				key = run.key
+			} else {
+				dateGames[date]++
+				key = date
+				if n := dateGames[date]; n > 1 {
+					key = fmt.Sprintf("%s #%d", date, n)
+				}
+			}
+		}
+		if key == "" {
+			result.Errors = append(result.Errors, ImportError{Line: lineNum, Message: "row has no game or date to group it by"})
+			continue
+		}
+		g, ok := games[key]
+		if !ok {
+			g = &pendingGame{key: key, date: date, oceania: oceania, firstRow: lineNum}
+			games[key] = g
+			order = append(order, key)
+		}
+		g.players = append(g.players, player)
+		run = nil
+		if gameID == "" {
+			run = g
+		}
+	}
+
+	for _, key := range order {
+		g := games[key]
+
+		createdAt := time.Now()
+		if g.date != "" {
+			createdAt, err = parseDate(g.date)
+			if err != nil {
+				result.Errors = append(result.Errors, ImportError{Line: g.firstRow, GameID: key, Message: fmt.Sprintf("invalid date format: %v", err)})
+				continue
+			}
+		}
+
+		// Without an explicit column, any nectar column implies Oceania
+		includeOceania := seen["NectarForest"] || seen["NectarGrassland"] || seen["NectarWetland"]
+		if g.oceania != "" {
+			includeOceania, err = parseBool(g.oceania)
+			if err != nil {
+				result.Errors = append(result.Errors, ImportError{Line: g.firstRow, GameID: key, Message: fmt.Sprintf("invalid IncludeOceania value: %v", err)})
+				continue
+			}
+		}
+
+		game, err := buildGame(createdAt, includeOceania, g.players)
+		if err != nil {
+			result.Errors = append(result.Errors, ImportError{Line: g.firstRow, GameID: key, Message: err.Error()})
+			continue
+		}
+		result.Games = append(result.Games, game)
+	}
+
+	return result, nil
+}
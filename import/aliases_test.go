@@ -0,0 +1,136 @@
+package importgames
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalField(t *testing.T) {
+	testCases := map[string]string{
+		"PlayerName":         "PlayerName",
+		"player":             "PlayerName",
+		"End-of-round goals": "RoundGoals",
+		"Food on cards":      "CachedFood",
+		" bird_points ":      "BirdPoints",
+		"Nectar (Forest)":    "NectarForest",
+		"SCORE":              "Total",
+		"Place":              "Rank",
+	}
+
+	for label, expected := range testCases {
+		field, ok := canonicalField(label)
+		assert.True(t, ok, "label %q", label)
+		assert.Equal(t, expected, field, "label %q", label)
+	}
+
+	_, ok := canonicalField("Colour")
+	assert.False(t, ok)
+}
+
+func TestAliasCSVImporter_ReorderedColumns(t *testing.T) {
+	data := `Score,Eggs,Birds,Player,Date,Game,Colour
+92,9,45,Alice,2024-01-15,1,blue
+79,8,40,Bob,2024-01-15,1,red
+85,7,50,Carol,2024-01-16,2,green
+95,10,48,Dave,2024-01-16,2,yellow`
+
+	result, err := AliasCSVImporter{}.Parse(strings.NewReader(data))
+	require.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	assert.Equal(t, []string{"Colour"}, result.Unmapped)
+	require.Len(t, result.Games, 2)
+
+	game := result.Games[0]
+	assert.Equal(t, "Alice", game.WinnerName)
+	assert.Equal(t, 92, game.WinnerScore)
+	assert.False(t, game.IncludeOceania)
+	require.Len(t, game.Players, 2)
+	assert.Equal(t, 45, game.Players[0].BirdPoints)
+	assert.Equal(t, 9, game.Players[0].Eggs)
+
+	// Ranks are computed from totals when not provided
+	assert.Equal(t, "Dave", result.Games[1].WinnerName)
+	assert.Equal(t, 2, result.Games[1].Players[0].Rank)
+}
+
+func TestAliasCSVImporter_NectarImpliesOceania(t *testing.T) {
+	data := `game,player,birds,forest nectar,grassland nectar,wetland nectar
+1,Alice,40,3,0,1
+1,Bob,38,1,2,1`
+
+	result, err := AliasCSVImporter{}.Parse(strings.NewReader(data))
+	require.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	require.Len(t, result.Games, 1)
+
+	game := result.Games[0]
+	assert.True(t, game.IncludeOceania)
+	require.NotNil(t, game.NectarScoring)
+	assert.Equal(t, 5, game.NectarScoring.Forest["Alice"])
+	assert.Equal(t, 5, game.NectarScoring.Grassland["Bob"])
+}
+
+func TestAliasCSVImporter_Errors(t *testing.T) {
+	result, err := AliasCSVImporter{}.Parse(strings.NewReader("Colour,Score\nblue,10\n"))
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 1)
+	assert.Contains(t, result.Errors[0].Message, "no player name column")
+
+	result, err = AliasCSVImporter{}.Parse(strings.NewReader("Date,Player,Score\n2024-01-15,Alice,-1\n2024-01-15,Bob,10\n"))
+	require.NoError(t, err)
+	require.NotEmpty(t, result.Errors)
+	assert.Equal(t, 2, result.Errors[0].Line)
+	assert.Contains(t, result.Errors[0].Message, "non-negative")
+}
+
+func TestAliasCSVImporter_SameDateGames(t *testing.T) {
+	// Two games on one evening: a repeated player starts the second game
+	data := `Date,Player,Score
+2024-01-15,Alice,90
+2024-01-15,Bob,80
+2024-01-15,Alice,70
+2024-01-15,Bob,75
+2024-01-15,Carol,60
+2024-01-16,Alice,88
+2024-01-16,Dave,77
+2024-01-15,Carol,50
+2024-01-15,Bob,55`
+
+	result, err := AliasCSVImporter{}.Parse(strings.NewReader(data))
+	require.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	require.Len(t, result.Games, 4)
+
+	assert.Len(t, result.Games[0].Players, 2)
+	assert.Equal(t, "Alice", result.Games[0].WinnerName)
+	assert.Len(t, result.Games[1].Players, 3)
+	assert.Equal(t, "Bob", result.Games[1].WinnerName)
+	assert.Len(t, result.Games[2].Players, 2)
+
+	// Rows on a date seen earlier, but not right after it, are a new game
+	assert.Len(t, result.Games[3].Players, 2)
+	assert.Equal(t, "Bob", result.Games[3].WinnerName)
+	assert.Equal(t, 15, result.Games[3].CreatedAt.Day())
+}
+
+func TestAliasCSVImporter_NoGameColumn(t *testing.T) {
+	// Without a game or date column, rows can't be split into games
+	data := "Player,Score\nAlice,90\nBob,80\nCarol,85\nDave,70\nAlice,60\nBob,95\n"
+	result, err := AliasCSVImporter{}.Parse(strings.NewReader(data))
+	require.NoError(t, err)
+	assert.Empty(t, result.Games)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, 1, result.Errors[0].Line)
+	assert.Contains(t, result.Errors[0].Message, "no game or date column")
+
+	// Rows with neither value are errors rather than a game of their own
+	data = "Game,Date,Player,Score\n1,,Alice,90\n1,,Bob,80\n,,Carol,85\n"
+	result, err = AliasCSVImporter{}.Parse(strings.NewReader(data))
+	require.NoError(t, err)
+	require.Len(t, result.Games, 1)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, 4, result.Errors[0].Line)
+}
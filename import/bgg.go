@@ -0,0 +1,177 @@
+package importgames
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"wingspan-scoring/db"
+	"wingspan-scoring/scoring"
+)
+
+// wingspanObjectID is Wingspan's BoardGameGeek thing ID
+const wingspanObjectID = "266192"
+
+// bggPlay is a <play> element from a BoardGameGeek plays export
+type bggPlay struct {
+	ID       string `xml:"id,attr"`
+	Date     string `xml:"date,attr"`
+	Location string `xml:"location,attr"`
+	Length   string `xml:"length,attr"`
+	Item     struct {
+		Name     string `xml:"name,attr"`
+		ObjectID string `xml:"objectid,attr"`
+	} `xml:"item"`
+	Comments string      `xml:"comments"`
+	Players  []bggPlayer `xml:"players>player"`
+}
+
+// bggPlayer is a <player> element within a play
+type bggPlayer struct {
+	Username      string `xml:"username,attr"`
+	Name          string `xml:"name,attr"`
+	Score         string `xml:"score,attr"`
+	Win           string `xml:"win,attr"`
+	Color         string `xml:"color,attr"`
+	StartPosition string `xml:"startposition,attr"`
+	Rating        string `xml:"rating,attr"`
+}
+
+// BGGImporter reads BoardGameGeek play-log XML (the <plays> document from
+// the BGG XML API or a play export). BGG only records a total score and a win
+// flag per player, so category breakdowns are left at zero. Plays of other
+// games are skipped, and plays with a player count that can't be scored are
+// skipped with a warning.
+type BGGImporter struct{}
+
+func (BGGImporter) Name() string { return "bgg" }
+
+func (BGGImporter) Detect(sample []byte) bool {
+	trimmed := bytes.TrimSpace(sample)
+	return bytes.HasPrefix(trimmed, []byte("<")) && bytes.Contains(trimmed, []byte("<plays"))
+}
+
+func (BGGImporter) Parse(reader io.Reader) (*ParseResult, error) {
+	decoder := xml.NewDecoder(reader)
+	result := &ParseResult{}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read XML: %v", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "play" {
+			continue
+		}
+
+		var play bggPlay
+		if err := decoder.DecodeElement(&play, &start); err != nil {
+			return nil, fmt.Errorf("failed to decode play: %v", err)
+		}
+
+		if play.Item.ObjectID != wingspanObjectID && !strings.Contains(strings.ToLower(play.Item.Name), "wingspan") {
+			continue
+		}
+
+		result.Unmapped = bggUnmapped(result.Unmapped, play)
+
+		game, err := convertBGGPlay(play)
+		if errors.Is(err, errPlayerCount) {
+			// Solo and Automa plays, or ones too large to score, don't stop the rest
+			result.Warnings = append(result.Warnings, ImportError{GameID: play.ID, Message: "skipped: " + err.Error()})
+			continue
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, ImportError{GameID: play.ID, Message: err.Error()})
+			continue
+		}
+		result.Games = append(result.Games, game)
+	}
+
+	return result, nil
+}
+
+// convertBGGPlay maps a BGG play onto a game. Winners are ranked first,
+// then players by score. Co-winners share first place, and other players
+// with the same score share a rank, so ties count as draws.
+func convertBGGPlay(play bggPlay) (*db.GameResult, error) {
+	createdAt, err := parseDate(play.Date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date format: %v", err)
+	}
+
+	type rankedPlayer struct {
+		player scoring.PlayerGameEnd
+		win    bool
+	}
+	ranked := make([]rankedPlayer, 0, len(play.Players))
+	for _, p := range play.Players {
+		name := strings.TrimSpace(p.Name)
+		if name == "" {
+			name = strings.TrimSpace(p.Username)
+		}
+
+		player := scoring.PlayerGameEnd{PlayerName: name}
+		if score := strings.TrimSpace(p.Score); score != "" {
+			total, err := strconv.Atoi(score)
+			if err != nil || total < 0 {
+				return nil, fmt.Errorf("player %s: invalid score %q", name, p.Score)
+			}
+			player.Total = total
+		}
+		ranked = append(ranked, rankedPlayer{player: player, win: p.Win == "1"})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].win != ranked[j].win {
+			return ranked[i].win
+		}
+		return ranked[i].player.Total > ranked[j].player.Total
+	})
+
+	players := make([]scoring.PlayerGameEnd, len(ranked))
+	for i, rp := range ranked {
+		players[i] = rp.player
+		players[i].Rank = i + 1
+		if i > 0 && rp.win == ranked[i-1].win && (rp.win || rp.player.Total == ranked[i-1].player.Total) {
+			players[i].Rank = players[i-1].Rank
+		}
+	}
+
+	return buildGame(createdAt, false, players)
+}
+
+// bggUnmapped records BGG fields present in a play that have no equivalent here
+func bggUnmapped(list []string, play bggPlay) []string {
+	if play.Location != "" {
+		list = addUnmapped(list, "play.location")
+	}
+	if play.Length != "" && play.Length != "0" {
+		list = addUnmapped(list, "play.length")
+	}
+	if strings.TrimSpace(play.Comments) != "" {
+		list = addUnmapped(list, "play.comments")
+	}
+	for _, p := range play.Players {
+		if p.Color != "" {
+			list = addUnmapped(list, "player.color")
+		}
+		if p.StartPosition != "" {
+			list = addUnmapped(list, "player.startposition")
+		}
+		if p.Rating != "" && p.Rating != "0" {
+			list = addUnmapped(list, "player.rating")
+		}
+	}
+	return list
+}
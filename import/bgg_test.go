@@ -0,0 +1,100 @@
+package importgames
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const bggPlays = `<?xml version="1.0" encoding="utf-8"?>
+<plays username="alice" userid="1" total="3" page="1">
+	<play id="101" date="2024-03-02" quantity="1" length="75" incomplete="0" nowinstats="0" location="Cafe">
+		<item name="Wingspan" objecttype="thing" objectid="266192">
+			<subtypes><subtype value="boardgame" /></subtypes>
+		</item>
+		<players>
+			<player username="alice" userid="1" name="Alice" startposition="1" color="blue" score="88" new="0" rating="0" win="0" />
+			<player username="" userid="0" name="Bob" startposition="2" color="red" score="91" new="0" rating="0" win="1" />
+		</players>
+	</play>
+	<play id="102" date="2024-03-03" quantity="1" length="0" incomplete="0" nowinstats="0" location="">
+		<item name="Azul" objecttype="thing" objectid="230802" />
+		<players>
+			<player name="Alice" score="60" win="1" />
+			<player name="Bob" score="55" win="0" />
+		</players>
+	</play>
+	<play id="103" date="2024-03-04" quantity="1" length="0" incomplete="0" nowinstats="0" location="">
+		<item name="Wingspan" objecttype="thing" objectid="266192" />
+		<players>
+			<player username="carol" name="" score="70" win="0" />
+			<player name="Dave" score="82" win="1" />
+			<player name="Erin" score="75" win="0" />
+		</players>
+	</play>
+</plays>`
+
+func TestBGGImporter_ParsesWingspanPlays(t *testing.T) {
+	result, err := BGGImporter{}.Parse(strings.NewReader(bggPlays))
+	require.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	require.Len(t, result.Games, 2, "Non-Wingspan plays are skipped")
+
+	first := result.Games[0]
+	assert.Equal(t, time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), first.CreatedAt)
+	assert.Equal(t, "Bob", first.WinnerName)
+	assert.Equal(t, 91, first.WinnerScore)
+	assert.Equal(t, "Alice", first.Players[1].PlayerName)
+	assert.Equal(t, 2, first.Players[1].Rank)
+
+	second := result.Games[1]
+	assert.Equal(t, 3, second.NumPlayers)
+	assert.Equal(t, "Dave", second.WinnerName)
+	assert.Equal(t, "carol", second.Players[2].PlayerName, "Falls back to username")
+
+	assert.ElementsMatch(t, []string{"play.location", "play.length", "player.color", "player.startposition"}, result.Unmapped)
+}
+
+func TestBGGImporter_TiesShareRanks(t *testing.T) {
+	data := `<plays>
+		<play id="1" date="2024-01-01"><item name="Wingspan" objectid="266192"/><players>
+			<player name="Alice" score="80" win="1"/><player name="Bob" score="85" win="1"/><player name="Carol" score="70" win="0"/>
+		</players></play>
+		<play id="2" date="2024-01-02"><item name="Wingspan" objectid="266192"/><players>
+			<player name="Alice" score="90" win="1"/><player name="Bob" score="75" win="0"/><player name="Carol" score="75" win="0"/><player name="Dave" score="60" win="0"/>
+		</players></play>
+	</plays>`
+
+	result, err := BGGImporter{}.Parse(strings.NewReader(data))
+	require.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	require.Len(t, result.Games, 2)
+
+	ranks := func(game int) map[string]int {
+		ranks := make(map[string]int)
+		for _, p := range result.Games[game].Players {
+			ranks[p.PlayerName] = p.Rank
+		}
+		return ranks
+	}
+	assert.Equal(t, map[string]int{"Alice": 1, "Bob": 1, "Carol": 3}, ranks(0), "co-winners share first place")
+	assert.Equal(t, map[string]int{"Alice": 1, "Bob": 2, "Carol": 2, "Dave": 4}, ranks(1), "equal scores share a rank")
+}
+
+func TestBGGImporter_InvalidScore(t *testing.T) {
+	data := `<plays><play id="9" date="2024-01-01"><item name="Wingspan" objectid="266192"/>
+		<players><player name="Alice" score="lots"/><player name="Bob" score="5"/></players></play></plays>`
+
+	result, err := BGGImporter{}.Parse(strings.NewReader(data))
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "9", result.Errors[0].GameID)
+}
+
+func TestBGGImporter_MalformedXML(t *testing.T) {
+	_, err := BGGImporter{}.Parse(strings.NewReader("<plays><play id=\"1\">"))
+	assert.Error(t, err)
+}
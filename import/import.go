@@ -1,9 +1,11 @@
 package importgames
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
 	"io"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -36,34 +38,96 @@ type CSVRecord struct {
 type ImportError struct {
 	Line    int
 	GameID  string
+	Column  string // Header column the error refers to, if any
 	Message string
 }
 
 func (e ImportError) Error() string {
+	if e.Column != "" {
+		return fmt.Sprintf("line %d (column %s): %s", e.Line, e.Column, e.Message)
+	}
 	return fmt.Sprintf("line %d (game %s): %s", e.Line, e.GameID, e.Message)
 }
 
 // ImportResult contains the results of an import operation
 type ImportResult struct {
 	GamesImported int
+	Format        string   // Name of the importer used
+	Unmapped      []string // Source columns that were ignored
 	Errors        []ImportError
+	Warnings      []ImportError // Entries skipped, such as solo plays
+}
+
+// expectedHeaders is the native CSV header, matching the export format
+var expectedHeaders = []string{"GameID", "Date", "IncludeOceania", "PlayerName", "BirdPoints", "BonusCards", "RoundGoals", "Eggs", "CachedFood", "TuckedCards", "NectarForest", "NectarGrassland", "NectarWetland", "UnusedFood", "Total", "Rank"}
+
+// optionalColumns may be left out of a native CSV; missing values are
+// treated as zero, and a missing Total is scored as for an entered game
+var optionalColumns = map[string]bool{
+	"NectarForest":    true,
+	"NectarGrassland": true,
+	"NectarWetland":   true,
+	"UnusedFood":      true,
+	"Total":           true,
+}
+
+// ParseCSV reads a CSV file and returns grouped game data. Columns are
+// matched by header name, case-insensitively and with the aliases in
+// columnAliases, so they may appear in any order. Unknown columns are
+// rejected unless lenient is true, in which case they are ignored.
+func ParseCSV(reader io.Reader, lenient bool) (map[string][]*CSVRecord, []ImportError) {
+	gameRecords, _, errors := parseCSV(reader, lenient)
+	return gameRecords, errors
 }
 
-// ParseCSV reads a CSV file and returns grouped game data
-func ParseCSV(reader io.Reader) (map[string][]*CSVRecord, []ImportError) {
+// mapHeader resolves header names to native fields, returning the column
+// index of each field, any unknown columns and structured header errors
+func mapHeader(header []string, lenient bool) (map[string]int, []string, []ImportError) {
+	columns := make(map[string]int)
+	var unknown []string
+	var errors []ImportError
+
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		field, ok := canonicalField(name)
+		if !ok {
+			unknown = append(unknown, name)
+			if !lenient {
+				errors = append(errors, ImportError{Line: 1, Column: name, Message: fmt.Sprintf("invalid header: unknown column %q (use lenient=true to ignore it)", name)})
+			}
+			continue
+		}
+		if prev, dup := columns[field]; dup {
+			errors = append(errors, ImportError{Line: 1, Column: name, Message: fmt.Sprintf("invalid header: column %q duplicates %q", name, strings.TrimSpace(header[prev]))})
+			continue
+		}
+		columns[field] = i
+	}
+
+	for _, field := range expectedHeaders {
+		if _, ok := columns[field]; !ok && !optionalColumns[field] {
+			errors = append(errors, ImportError{Line: 1, Column: field, Message: fmt.Sprintf("invalid header: missing required column %q", field)})
+		}
+	}
+
+	return columns, unknown, errors
+}
+
+// parseCSV implements ParseCSV and also returns the ignored column names
+func parseCSV(reader io.Reader, lenient bool) (map[string][]*CSVRecord, []string, []ImportError) {
 	csvReader := csv.NewReader(reader)
 	csvReader.TrimLeadingSpace = true
 
 	// Read header
 	header, err := csvReader.Read()
 	if err != nil {
-		return nil, []ImportError{{Line: 1, Message: fmt.Sprintf("failed to read header: %v", err)}}
+		return nil, nil, []ImportError{{Line: 1, Message: fmt.Sprintf("failed to read header: %v", err)}}
 	}
 
 	// Validate header
-	expectedHeaders := []string{"GameID", "Date", "IncludeOceania", "PlayerName", "BirdPoints", "BonusCards", "RoundGoals", "Eggs", "CachedFood", "TuckedCards", "NectarForest", "NectarGrassland", "NectarWetland", "UnusedFood", "Total", "Rank"}
-	if len(header) != len(expectedHeaders) {
-		return nil, []ImportError{{Line: 1, Message: fmt.Sprintf("invalid header: expected %d columns, got %d", len(expectedHeaders), len(header))}}
+	columns, unknown, headerErrors := mapHeader(header, lenient)
+	if len(headerErrors) > 0 {
+		return nil, unknown, headerErrors
 	}
 
 	// Group records by GameID
@@ -82,28 +146,31 @@ func ParseCSV(reader io.Reader) (map[string][]*CSVRecord, []ImportError) {
 			continue
 		}
 
-		if len(row) != len(expectedHeaders) {
-			errors = append(errors, ImportError{Line: lineNum, Message: fmt.Sprintf("invalid column count: expected %d, got %d", len(expectedHeaders), len(row))})
-			continue
+		// Optional columns that are absent read as empty
+		get := func(field string) string {
+			if i, ok := columns[field]; ok {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
 		}
 
 		record := &CSVRecord{
-			GameID:          strings.TrimSpace(row[0]),
-			Date:            strings.TrimSpace(row[1]),
-			IncludeOceania:  strings.TrimSpace(row[2]),
-			PlayerName:      strings.TrimSpace(row[3]),
-			BirdPoints:      strings.TrimSpace(row[4]),
-			BonusCards:      strings.TrimSpace(row[5]),
-			RoundGoals:      strings.TrimSpace(row[6]),
-			Eggs:            strings.TrimSpace(row[7]),
-			CachedFood:      strings.TrimSpace(row[8]),
-			TuckedCards:     strings.TrimSpace(row[9]),
-			NectarForest:    strings.TrimSpace(row[10]),
-			NectarGrassland: strings.TrimSpace(row[11]),
-			NectarWetland:   strings.TrimSpace(row[12]),
-			UnusedFood:      strings.TrimSpace(row[13]),
-			Total:           strings.TrimSpace(row[14]),
-			Rank:            strings.TrimSpace(row[15]),
+			GameID:          get("GameID"),
+			Date:            get("Date"),
+			IncludeOceania:  get("IncludeOceania"),
+			PlayerName:      get("PlayerName"),
+			BirdPoints:      get("BirdPoints"),
+			BonusCards:      get("BonusCards"),
+			RoundGoals:      get("RoundGoals"),
+			Eggs:            get("Eggs"),
+			CachedFood:      get("CachedFood"),
+			TuckedCards:     get("TuckedCards"),
+			NectarForest:    get("NectarForest"),
+			NectarGrassland: get("NectarGrassland"),
+			NectarWetland:   get("NectarWetland"),
+			UnusedFood:      get("UnusedFood"),
+			Total:           get("Total"),
+			Rank:            get("Rank"),
 		}
 
 		if record.GameID == "" {
@@ -114,7 +181,7 @@ func ParseCSV(reader io.Reader) (map[string][]*CSVRecord, []ImportError) {
 		gameRecords[record.GameID] = append(gameRecords[record.GameID], record)
 	}
 
-	return gameRecords, errors
+	return gameRecords, unknown, errors
 }
 
 // ValidateAndConvertGame validates a group of CSV records and converts them to a GameResult
@@ -145,6 +212,7 @@ func ValidateAndConvertGame(gameID string, records []*CSVRecord) (*db.GameResult
 
 	// Convert players
 	players := make([]scoring.PlayerGameEnd, 0, len(records))
+	seenNames := make(map[string]bool)
 	seenRanks := make(map[int]bool)
 	hasWinner := false
 
@@ -161,6 +229,10 @@ func ValidateAndConvertGame(gameID string, records []*CSVRecord) (*db.GameResult
 		if err != nil {
 			return nil, fmt.Errorf("player %s: %v", record.PlayerName, err)
 		}
+		if seenNames[player.PlayerName] {
+			return nil, fmt.Errorf("duplicate player %s", player.PlayerName)
+		}
+		seenNames[player.PlayerName] = true
 
 		// Validate rank uniqueness
 		if seenRanks[player.Rank] {
@@ -186,10 +258,32 @@ func ValidateAndConvertGame(gameID string, records []*CSVRecord) (*db.GameResult
 		return nil, fmt.Errorf("no player with rank 1 (winner)")
 	}
 
-	// Calculate nectar scoring if Oceania expansion is included
+	// Score the game as an entered game is scored, so a missing total
+	// includes nectar points
+	scored, nectar := scoring.CalculateGameEndScores(slices.Clone(players), includeOceania)
+	totals := make(map[string]int, len(scored))
+	for _, p := range scored {
+		totals[p.PlayerName] = p.Total
+	}
+	for i, record := range records {
+		if record.Total == "" {
+			players[i].Total = totals[players[i].PlayerName]
+		}
+	}
+
+	// A higher total can't finish below a lower one
+	for _, a := range players {
+		for _, b := range players {
+			if a.Total > b.Total && a.Rank > b.Rank {
+				return nil, fmt.Errorf("rank %d for %s doesn't match total %d, above %s's %d at rank %d",
+					a.Rank, a.PlayerName, a.Total, b.PlayerName, b.Total, b.Rank)
+			}
+		}
+	}
+
 	var nectarScoring *scoring.NectarScoring
 	if includeOceania {
-		nectarScoring = calculateNectarScoring(players)
+		nectarScoring = &nectar
 	}
 
 	// Determine winner
@@ -259,23 +353,18 @@ func convertPlayer(record *CSVRecord, includeOceania bool) (*scoring.PlayerGameE
 	}
 
 	// Parse optional fields
-	unusedFood := 0
-	if record.UnusedFood != "" {
-		unusedFood, err = parseInt(record.UnusedFood, "UnusedFood")
-		if err != nil {
-			return nil, err
-		}
+	unusedFood, err := parseOptionalInt(record.UnusedFood, "UnusedFood")
+	if err != nil {
+		return nil, err
 	}
 
-	// Calculate total if not provided
+	// A missing total is left at zero for the game to be scored
 	total := 0
 	if record.Total != "" {
 		total, err = parseInt(record.Total, "Total")
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		total = birdPoints + bonusCards + roundGoals + eggs + cachedFood + tuckedCards
 	}
 
 	player := &scoring.PlayerGameEnd{
@@ -291,17 +380,17 @@ func convertPlayer(record *CSVRecord, includeOceania bool) (*scoring.PlayerGameE
 		Rank:        rank,
 	}
 
-	// Parse nectar fields if Oceania is included
+	// Parse nectar fields if Oceania is included (optional, default 0)
 	if includeOceania {
-		nectarForest, err := parseInt(record.NectarForest, "NectarForest")
+		nectarForest, err := parseOptionalInt(record.NectarForest, "NectarForest")
 		if err != nil {
 			return nil, err
 		}
-		nectarGrassland, err := parseInt(record.NectarGrassland, "NectarGrassland")
+		nectarGrassland, err := parseOptionalInt(record.NectarGrassland, "NectarGrassland")
 		if err != nil {
 			return nil, err
 		}
-		nectarWetland, err := parseInt(record.NectarWetland, "NectarWetland")
+		nectarWetland, err := parseOptionalInt(record.NectarWetland, "NectarWetland")
 		if err != nil {
 			return nil, err
 		}
@@ -314,83 +403,6 @@ func convertPlayer(record *CSVRecord, includeOceania bool) (*scoring.PlayerGameE
 	return player, nil
 }
 
-// calculateNectarScoring calculates nectar scoring from player nectar counts
-func calculateNectarScoring(players []scoring.PlayerGameEnd) *scoring.NectarScoring {
-	type nectarCount struct {
-		playerName string
-		count      int
-	}
-
-	// Helper to find top 2 players
-	findTopTwo := func(counts []nectarCount) (first string, second string) {
-		if len(counts) == 0 {
-			return "", ""
-		}
-
-		// Sort by count (descending)
-		for i := 0; i < len(counts)-1; i++ {
-			for j := i + 1; j < len(counts); j++ {
-				if counts[j].count > counts[i].count {
-					counts[i], counts[j] = counts[j], counts[i]
-				}
-			}
-		}
-
-		first = counts[0].playerName
-		if len(counts) > 1 && counts[1].count > 0 {
-			second = counts[1].playerName
-		}
-
-		return first, second
-	}
-
-	// Collect nectar counts
-	forestCounts := make([]nectarCount, 0, len(players))
-	grasslandCounts := make([]nectarCount, 0, len(players))
-	wetlandCounts := make([]nectarCount, 0, len(players))
-
-	for _, p := range players {
-		forestCounts = append(forestCounts, nectarCount{p.PlayerName, p.NectarForest})
-		grasslandCounts = append(grasslandCounts, nectarCount{p.PlayerName, p.NectarGrassland})
-		wetlandCounts = append(wetlandCounts, nectarCount{p.PlayerName, p.NectarWetland})
-	}
-
-	// Find winners
-	forestFirst, forestSecond := findTopTwo(forestCounts)
-	grasslandFirst, grasslandSecond := findTopTwo(grasslandCounts)
-	wetlandFirst, wetlandSecond := findTopTwo(wetlandCounts)
-
-	// Build scoring maps
-	nectarScoring := &scoring.NectarScoring{
-		Forest:    make(map[string]int),
-		Grassland: make(map[string]int),
-		Wetland:   make(map[string]int),
-	}
-
-	if forestFirst != "" {
-		nectarScoring.Forest[forestFirst] = 5
-	}
-	if forestSecond != "" {
-		nectarScoring.Forest[forestSecond] = 2
-	}
-
-	if grasslandFirst != "" {
-		nectarScoring.Grassland[grasslandFirst] = 5
-	}
-	if grasslandSecond != "" {
-		nectarScoring.Grassland[grasslandSecond] = 2
-	}
-
-	if wetlandFirst != "" {
-		nectarScoring.Wetland[wetlandFirst] = 5
-	}
-	if wetlandSecond != "" {
-		nectarScoring.Wetland[wetlandSecond] = 2
-	}
-
-	return nectarScoring
-}
-
 // parseInt parses a string to an integer with validation
 func parseInt(s, fieldName string) (int, error) {
 	if s == "" {
@@ -409,6 +421,14 @@ func parseInt(s, fieldName string) (int, error) {
 	return val, nil
 }
 
+// parseOptionalInt parses an optional integer column, treating empty as zero
+func parseOptionalInt(s, fieldName string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return parseInt(s, fieldName)
+}
+
 // parseBool parses a string to a boolean
 func parseBool(s string) (bool, error) {
 	lower := strings.ToLower(s)
@@ -445,45 +465,7 @@ func parseDate(s string) (time.Time, error) {
 	return time.Time{}, fmt.Errorf("unable to parse date: %s", s)
 }
 
-// ImportGames imports games from CSV data
-func ImportGames(reader io.Reader) (*ImportResult, error) {
-	gameRecords, parseErrors := ParseCSV(reader)
-
-	result := &ImportResult{
-		Errors: parseErrors,
-	}
-
-	// Convert and validate each game
-	games := make([]*db.GameResult, 0, len(gameRecords))
-	for gameID, records := range gameRecords {
-		game, err := ValidateAndConvertGame(gameID, records)
-		if err != nil {
-			result.Errors = append(result.Errors, ImportError{
-				GameID:  gameID,
-				Message: err.Error(),
-			})
-			continue
-		}
-		games = append(games, game)
-	}
-
-	// If there were any validation errors, don't import anything
-	if len(result.Errors) > 0 {
-		return result, fmt.Errorf("import failed: %d errors found", len(result.Errors))
-	}
-
-	// Import all games
-	for _, game := range games {
-		nectarScoring := scoring.NectarScoring{}
-		if game.NectarScoring != nil {
-			nectarScoring = *game.NectarScoring
-		}
-
-		if _, err := db.SaveGameResult(game.Players, nectarScoring, game.IncludeOceania); err != nil {
-			return result, fmt.Errorf("failed to save game: %v", err)
-		}
-		result.GamesImported++
-	}
-
-	return result, nil
+// ImportGames imports games from CSV data in the native export format
+func ImportGames(ctx context.Context, reader io.Reader) (*ImportResult, error) {
+	return ImportWith(ctx, reader, NativeCSVImporter{}.Name(), false)
 }
@@ -6,7 +6,6 @@ import (
 	"testing"
 
 	"wingspan-scoring/db"
-	"wingspan-scoring/scoring"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -18,7 +17,7 @@ func TestParseCSV_ValidData(t *testing.T) {
 1,2024-01-15,false,Bob,40,10,15,8,4,2,0,0,0,1,79,2`
 
 	reader := strings.NewReader(csvData)
-	gameRecords, errors := ParseCSV(reader)
+	gameRecords, errors := ParseCSV(reader, false)
 
 	assert.Empty(t, errors)
 	assert.Len(t, gameRecords, 1)
@@ -35,7 +34,7 @@ func TestParseCSV_MultipleGames(t *testing.T) {
 2,2024-01-16,true,Dave,48,12,18,9,5,3,2,3,2,2,102,2`
 
 	reader := strings.NewReader(csvData)
-	gameRecords, errors := ParseCSV(reader)
+	gameRecords, errors := ParseCSV(reader, false)
 
 	assert.Empty(t, errors)
 	assert.Len(t, gameRecords, 2)
@@ -48,7 +47,7 @@ func TestParseCSV_InvalidHeader(t *testing.T) {
 1,2024-01-15,Alice`
 
 	reader := strings.NewReader(csvData)
-	_, errors := ParseCSV(reader)
+	_, errors := ParseCSV(reader, false)
 
 	assert.NotEmpty(t, errors)
 	assert.Contains(t, errors[0].Error(), "invalid header")
@@ -59,7 +58,7 @@ func TestParseCSV_InvalidColumnCount(t *testing.T) {
 1,2024-01-15,false,Alice`
 
 	reader := strings.NewReader(csvData)
-	_, errors := ParseCSV(reader)
+	_, errors := ParseCSV(reader, false)
 
 	assert.NotEmpty(t, errors)
 	// The CSV reader returns a different error message, so just check that there's an error
@@ -71,12 +70,113 @@ func TestParseCSV_EmptyGameID(t *testing.T) {
 ,2024-01-15,false,Alice,45,12,18,9,5,3,0,0,0,2,92,1`
 
 	reader := strings.NewReader(csvData)
-	_, errors := ParseCSV(reader)
+	_, errors := ParseCSV(reader, false)
 
 	assert.NotEmpty(t, errors)
 	assert.Contains(t, errors[0].Error(), "GameID cannot be empty")
 }
 
+func TestParseCSV_SwappedColumnsMappedByName(t *testing.T) {
+	// Eggs and BirdPoints are swapped relative to the export order
+	csvData := `GameID,Date,IncludeOceania,PlayerName,Eggs,BonusCards,RoundGoals,BirdPoints,CachedFood,TuckedCards,NectarForest,NectarGrassland,NectarWetland,UnusedFood,Total,Rank
+1,2024-01-15,false,Alice,9,12,18,45,5,3,0,0,0,2,92,1`
+
+	gameRecords, errors := ParseCSV(strings.NewReader(csvData), false)
+
+	assert.Empty(t, errors)
+	require.Len(t, gameRecords["1"], 1)
+	assert.Equal(t, "45", gameRecords["1"][0].BirdPoints)
+	assert.Equal(t, "9", gameRecords["1"][0].Eggs)
+}
+
+func TestParseCSV_CaseInsensitiveAliases(t *testing.T) {
+	csvData := `game id,DATE,oceania,Player,birds,bonus,round goals,eggs,food on cards,tucked,place
+1,2024-01-15,false,Alice,45,12,18,9,5,3,1`
+
+	gameRecords, errors := ParseCSV(strings.NewReader(csvData), false)
+
+	assert.Empty(t, errors)
+	require.Len(t, gameRecords["1"], 1)
+	record := gameRecords["1"][0]
+	assert.Equal(t, "Alice", record.PlayerName)
+	assert.Equal(t, "45", record.BirdPoints)
+	assert.Equal(t, "5", record.CachedFood)
+	assert.Equal(t, "1", record.Rank)
+}
+
+func TestParseCSV_OptionalColumnsOmitted(t *testing.T) {
+	csvData := `GameID,Date,IncludeOceania,PlayerName,BirdPoints,BonusCards,RoundGoals,Eggs,CachedFood,TuckedCards,Rank
+1,2024-01-15,true,Alice,45,12,18,9,5,3,1
+1,2024-01-15,true,Bob,40,10,15,8,4,2,2`
+
+	gameRecords, errors := ParseCSV(strings.NewReader(csvData), false)
+	require.Empty(t, errors)
+
+	game, err := ValidateAndConvertGame("1", gameRecords["1"])
+	require.NoError(t, err)
+	assert.True(t, game.IncludeOceania)
+	assert.Equal(t, 0, game.Players[0].NectarForest)
+	assert.Equal(t, 0, game.Players[0].UnusedFood)
+	assert.Equal(t, 92, game.Players[0].Total, "Total is scored when the column is omitted")
+}
+
+func TestParseCSV_OceaniaWithoutTotal(t *testing.T) {
+	csvData := `GameID,Date,IncludeOceania,PlayerName,BirdPoints,BonusCards,RoundGoals,Eggs,CachedFood,TuckedCards,NectarForest,NectarGrassland,NectarWetland,Rank
+1,2024-01-15,true,Alice,30,10,10,10,5,5,3,3,3,1
+1,2024-01-15,true,Bob,35,10,10,10,5,5,0,0,0,2`
+
+	gameRecords, errors := ParseCSV(strings.NewReader(csvData), false)
+	require.Empty(t, errors)
+
+	game, err := ValidateAndConvertGame("1", gameRecords["1"])
+	require.NoError(t, err)
+	assert.Equal(t, 85, game.Players[0].Total, "70 from the categories plus 15 for leading every habitat")
+	assert.Equal(t, 75, game.Players[1].Total)
+	assert.Equal(t, 85, game.WinnerScore)
+	require.NotNil(t, game.NectarScoring)
+	assert.Equal(t, 5, game.NectarScoring.Wetland["Alice"])
+
+	// Without nectar, Bob's higher total can't rank second
+	csvData = strings.ReplaceAll(csvData, "3,3,3,1", "0,0,0,1")
+	gameRecords, errors = ParseCSV(strings.NewReader(csvData), false)
+	require.Empty(t, errors)
+	_, err = ValidateAndConvertGame("1", gameRecords["1"])
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "doesn't match total")
+}
+
+func TestParseCSV_UnknownColumnRejectedUnlessLenient(t *testing.T) {
+	csvData := `GameID,Date,IncludeOceania,PlayerName,BirdPoints,BonusCards,RoundGoals,Eggs,CachedFood,TuckedCards,Rank,Colour
+1,2024-01-15,false,Alice,45,12,18,9,5,3,1,blue`
+
+	_, errors := ParseCSV(strings.NewReader(csvData), false)
+	require.Len(t, errors, 1)
+	assert.Equal(t, 1, errors[0].Line)
+	assert.Equal(t, "Colour", errors[0].Column)
+	assert.Contains(t, errors[0].Message, "unknown column")
+
+	gameRecords, errors := ParseCSV(strings.NewReader(csvData), true)
+	assert.Empty(t, errors)
+	assert.Len(t, gameRecords["1"], 1)
+}
+
+func TestParseCSV_StructuredHeaderErrors(t *testing.T) {
+	csvData := `GameID,Date,IncludeOceania,PlayerName,Player,BirdPoints,BonusCards,RoundGoals,Eggs,CachedFood
+1,2024-01-15,false,Alice,Alice,45,12,18,9,5`
+
+	_, errors := ParseCSV(strings.NewReader(csvData), false)
+
+	columns := make(map[string]string)
+	for _, e := range errors {
+		assert.Equal(t, 1, e.Line)
+		columns[e.Column] = e.Message
+	}
+	assert.Contains(t, columns["Player"], "duplicates")
+	assert.Contains(t, columns["TuckedCards"], "missing required column")
+	assert.Contains(t, columns["Rank"], "missing required column")
+	assert.NotContains(t, columns, "Total", "Total is optional")
+}
+
 func TestValidateAndConvertGame_ValidGame(t *testing.T) {
 	records := []*CSVRecord{
 		{
@@ -293,7 +393,7 @@ func TestConvertPlayer_ValidPlayer(t *testing.T) {
 	assert.Equal(t, 1, player.Rank)
 }
 
-func TestConvertPlayer_AutoCalculateTotal(t *testing.T) {
+func TestConvertPlayer_MissingTotal(t *testing.T) {
 	record := &CSVRecord{
 		PlayerName: "Alice", BirdPoints: "45", BonusCards: "12", RoundGoals: "18",
 		Eggs: "9", CachedFood: "5", TuckedCards: "3",
@@ -304,7 +404,7 @@ func TestConvertPlayer_AutoCalculateTotal(t *testing.T) {
 	player, err := convertPlayer(record, false)
 
 	require.NoError(t, err)
-	assert.Equal(t, 92, player.Total) // 45 + 12 + 18 + 9 + 5 + 3 = 92
+	assert.Equal(t, 0, player.Total, "the total is scored with the rest of the game")
 }
 
 func TestConvertPlayer_EmptyPlayerName(t *testing.T) {
@@ -422,14 +522,17 @@ func TestParseDate_InvalidFormat(t *testing.T) {
 	assert.Contains(t, err.Error(), "unable to parse date")
 }
 
-func TestCalculateNectarScoring(t *testing.T) {
-	players := []scoring.PlayerGameEnd{
-		{PlayerName: "Alice", NectarForest: 5, NectarGrassland: 3, NectarWetland: 2},
-		{PlayerName: "Bob", NectarForest: 3, NectarGrassland: 5, NectarWetland: 4},
-		{PlayerName: "Carol", NectarForest: 2, NectarGrassland: 2, NectarWetland: 5},
+func TestValidateAndConvertGame_NectarScoring(t *testing.T) {
+	records := []*CSVRecord{
+		{GameID: "1", Date: "2024-01-15", IncludeOceania: "true", PlayerName: "Alice", BirdPoints: "40", BonusCards: "0", RoundGoals: "0", Eggs: "0", CachedFood: "0", TuckedCards: "0", NectarForest: "5", NectarGrassland: "3", NectarWetland: "2", Rank: "1"},
+		{GameID: "1", Date: "2024-01-15", IncludeOceania: "true", PlayerName: "Bob", BirdPoints: "30", BonusCards: "0", RoundGoals: "0", Eggs: "0", CachedFood: "0", TuckedCards: "0", NectarForest: "3", NectarGrassland: "5", NectarWetland: "4", Rank: "2"},
+		{GameID: "1", Date: "2024-01-15", IncludeOceania: "true", PlayerName: "Carol", BirdPoints: "20", BonusCards: "0", RoundGoals: "0", Eggs: "0", CachedFood: "0", TuckedCards: "0", NectarForest: "2", NectarGrassland: "2", NectarWetland: "5", Rank: "3"},
 	}
 
-	nectar := calculateNectarScoring(players)
+	game, err := ValidateAndConvertGame("1", records)
+	require.NoError(t, err)
+	require.NotNil(t, game.NectarScoring)
+	nectar := game.NectarScoring
 
 	// Alice should win Forest (5 > 3 > 2)
 	assert.Equal(t, 5, nectar.Forest["Alice"])
@@ -457,22 +560,20 @@ func TestImportGames_Integration(t *testing.T) {
 	defer os.Remove(tmpDB.Name())
 
 	// Initialize the database with the temporary path
-	os.Setenv("DB_PATH", tmpDB.Name())
-	defer os.Unsetenv("DB_PATH")
 
-	err = db.Initialize()
+	err = db.Initialize(tmpDB.Name())
 	require.NoError(t, err)
 	defer db.Close()
 
 	reader := strings.NewReader(csvData)
-	result, err := ImportGames(reader)
+	result, err := ImportGames(t.Context(), reader)
 
 	require.NoError(t, err)
 	assert.Equal(t, 2, result.GamesImported)
 	assert.Empty(t, result.Errors)
 
 	// Verify games were saved
-	count, err := db.CountGameResults()
+	count, err := db.CountGameResults(t.Context())
 	require.NoError(t, err)
 	assert.Equal(t, 2, count)
 }
@@ -487,15 +588,13 @@ func TestImportGames_WithErrors(t *testing.T) {
 	defer os.Remove(tmpDB.Name())
 
 	// Initialize the database with the temporary path
-	os.Setenv("DB_PATH", tmpDB.Name())
-	defer os.Unsetenv("DB_PATH")
 
-	err = db.Initialize()
+	err = db.Initialize(tmpDB.Name())
 	require.NoError(t, err)
 	defer db.Close()
 
 	reader := strings.NewReader(csvData)
-	result, err := ImportGames(reader)
+	result, err := ImportGames(t.Context(), reader)
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "import failed")
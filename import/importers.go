@@ -0,0 +1,296 @@
+package importgames
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"time"
+
+	"wingspan-scoring/db"
+	"wingspan-scoring/scoring"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates a span for each import stage; it does nothing until the
+// app sets up tracing
+var tracer = otel.Tracer("wingspan-scoring/import")
+
+// sniffSize is how much of a file importers get to inspect when detecting its format
+const sniffSize = 4096
+
+// Importer converts a score sheet from this app or another score-tracking
+// tool into games
+type Importer interface {
+	// Name identifies the format, e.g. "csv" or "bgg"
+	Name() string
+	// Detect reports whether the importer recognises the start of a file
+	Detect(sample []byte) bool
+	// Parse reads and validates all games from the reader
+	Parse(reader io.Reader) (*ParseResult, error)
+}
+
+// ParseResult holds the games an Importer parsed along with any problems
+type ParseResult struct {
+	Games    []*db.GameResult
+	Unmapped []string // Source columns or fields with no PlayerGameEnd equivalent
+	Errors   []ImportError
+	Warnings []ImportError // Entries skipped without failing the import
+}
+
+// Importers lists the available importers in detection order. More specific
+// formats come first so the alias CSV importer only catches what's left.
+var Importers = []Importer{
+	BGGImporter{},
+	NativeCSVImporter{},
+	ScorepadImporter{},
+	AliasCSVImporter{},
+}
+
+// FindImporter returns the importer with the given name
+func FindImporter(name string) (Importer, error) {
+	for _, imp := range Importers {
+		if imp.Name() == name {
+			return imp, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown import format: %s", name)
+}
+
+// DetectImporter returns the first importer that recognises the sample
+func DetectImporter(sample []byte) (Importer, error) {
+	for _, imp := range Importers {
+		if imp.Detect(sample) {
+			return imp, nil
+		}
+	}
+	return nil, fmt.Errorf("unrecognised import format")
+}
+
+// ImportWith parses games with the named importer, or detects the format when
+// name is empty, then saves them. Like ImportGames, nothing is saved if any
+// game fails validation. lenient lets the native CSV importer ignore unknown
+// columns instead of rejecting the file. Games are saved to the default group.
+func ImportWith(ctx context.Context, reader io.Reader, name string, lenient bool) (*ImportResult, error) {
+	return ImportIntoGroup(ctx, reader, db.DefaultGroup, name, lenient)
+}
+
+// ImportIntoGroup is ImportWith, saving the games to a group
+func ImportIntoGroup(ctx context.Context, reader io.Reader, group, name string, lenient bool) (*ImportResult, error) {
+	buffered := bufio.NewReaderSize(reader, sniffSize)
+
+	var imp Importer
+	var err error
+	if name == "" {
+		_, span := tracer.Start(ctx, "import.detect")
+		sample, _ := buffered.Peek(sniffSize)
+		imp, err = DetectImporter(sample)
+		if err == nil {
+			span.SetAttributes(attribute.String("import.format", imp.Name()))
+		}
+		endSpan(span, err)
+	} else {
+		imp, err = FindImporter(name)
+	}
+	if err != nil {
+		return &ImportResult{}, err
+	}
+
+	if native, ok := imp.(NativeCSVImporter); ok {
+		native.Lenient = lenient
+		imp = native
+	}
+
+	_, span := tracer.Start(ctx, "import.parse", trace.WithAttributes(attribute.String("import.format", imp.Name())))
+	parsed, err := imp.Parse(buffered)
+	if err == nil {
+		span.SetAttributes(attribute.Int("import.games", len(parsed.Games)), attribute.Int("import.errors", len(parsed.Errors)))
+	}
+	endSpan(span, err)
+	if err != nil {
+		return &ImportResult{Format: imp.Name()}, err
+	}
+
+	result := &ImportResult{
+		Format:   imp.Name(),
+		Unmapped: parsed.Unmapped,
+		Errors:   parsed.Errors,
+		Warnings: parsed.Warnings,
+	}
+
+	if len(result.Errors) > 0 {
+		return result, fmt.Errorf("import failed: %d errors found", len(result.Errors))
+	}
+
+	if err := saveGames(ctx, parsed.Games, group, result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// saveGames stores validated games in a group, counting them in the result.
+// The games are saved together, so a failure saves none of them.
+func saveGames(ctx context.Context, games []*db.GameResult, group string, result *ImportResult) (err error) {
+	ctx, span := tracer.Start(ctx, "import.save", trace.WithAttributes(attribute.Int("import.games", len(games))))
+	defer func() { endSpan(span, err) }()
+
+	for _, game := range games {
+		game.Group = group
+	}
+	ids, err := db.SaveGames(ctx, games)
+	if err != nil {
+		return fmt.Errorf("failed to save games: %v", err)
+	}
+	result.GamesImported = len(ids)
+	return nil
+}
+
+// endSpan ends a stage's span, marking it failed if err is set
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// NativeCSVImporter reads this app's own export format via ParseCSV. Unless
+// Lenient is set, columns it doesn't recognise are errors.
+type NativeCSVImporter struct {
+	Lenient bool
+}
+
+func (NativeCSVImporter) Name() string { return "csv" }
+
+// Detect accepts headers that name every required native column, in any
+// order, with nothing unrecognised; anything looser goes to AliasCSVImporter
+func (NativeCSVImporter) Detect(sample []byte) bool {
+	header, ok := firstCSVRecord(sample)
+	if !ok {
+		return false
+	}
+	_, _, errors := mapHeader(header, false)
+	return len(errors) == 0
+}
+
+func (imp NativeCSVImporter) Parse(reader io.Reader) (*ParseResult, error) {
+	gameRecords, unknown, errors := parseCSV(reader, imp.Lenient)
+
+	result := &ParseResult{Unmapped: unknown, Errors: errors}
+	for _, gameID := range sortedKeys(gameRecords) {
+		game, err := ValidateAndConvertGame(gameID, gameRecords[gameID])
+		if err != nil {
+			result.Errors = append(result.Errors, ImportError{GameID: gameID, Message: err.Error()})
+			continue
+		}
+		result.Games = append(result.Games, game)
+	}
+
+	return result, nil
+}
+
+// errPlayerCount is returned by buildGame for games it can't score, such
+// as solo plays
+var errPlayerCount = errors.New("invalid player count")
+
+// buildGame validates players mapped from another app and assembles a game.
+// Players are scored as when a game is entered: missing totals come from the
+// categories plus nectar points, missing ranks from the totals with unused
+// food as the tiebreaker and players still tied sharing a rank, and nectar
+// points from the nectar counts. Totals and ranks in the file are kept.
+func buildGame(createdAt time.Time, includeOceania bool, players []scoring.PlayerGameEnd) (*db.GameResult, error) {
+	if len(players) < 2 || len(players) > 5 {
+		return nil, fmt.Errorf("%w: %d (must be 2-5)", errPlayerCount, len(players))
+	}
+
+	hasTotals, hasRanks := false, false
+	seenNames := make(map[string]bool)
+	for _, p := range players {
+		if p.PlayerName == "" {
+			return nil, fmt.Errorf("player name cannot be empty")
+		}
+		if seenNames[p.PlayerName] {
+			return nil, fmt.Errorf("duplicate player %s", p.PlayerName)
+		}
+		seenNames[p.PlayerName] = true
+		hasTotals = hasTotals || p.Total != 0
+		hasRanks = hasRanks || p.Rank != 0
+	}
+
+	scored, nectarScoring := scoring.CalculateGameEndScores(slices.Clone(players), includeOceania)
+	if hasTotals && !hasRanks {
+		scored = slices.Clone(players)
+		scoring.RankPlayers(scored)
+	}
+	byName := make(map[string]scoring.PlayerGameEnd, len(scored))
+	for _, p := range scored {
+		byName[p.PlayerName] = p
+	}
+	for i := range players {
+		if !hasTotals {
+			players[i].Total = byName[players[i].PlayerName].Total
+		}
+		if !hasRanks {
+			players[i].Rank = byName[players[i].PlayerName].Rank
+		}
+	}
+
+	// Ranks run from 1, and players who tie share a rank with the next rank skipped
+	ranks := make([]int, len(players))
+	for i, p := range players {
+		ranks[i] = p.Rank
+	}
+	sort.Ints(ranks)
+	for i, rank := range ranks {
+		if rank != i+1 && (i == 0 || rank != ranks[i-1]) {
+			return nil, fmt.Errorf("invalid ranks %v: must run from 1, with tied players sharing a rank", ranks)
+		}
+	}
+
+	game := &db.GameResult{
+		CreatedAt:      createdAt,
+		NumPlayers:     len(players),
+		IncludeOceania: includeOceania,
+		Players:        players,
+	}
+	if includeOceania {
+		game.NectarScoring = &nectarScoring
+	}
+	for _, p := range players {
+		if p.Rank == 1 {
+			game.WinnerName = p.PlayerName
+			game.WinnerScore = p.Total
+			break
+		}
+	}
+
+	return game, nil
+}
+
+// sortedKeys returns map keys in a stable order so imports are deterministic
+func sortedKeys(m map[string][]*CSVRecord) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// addUnmapped appends a name to the list if it isn't already present
+func addUnmapped(list []string, name string) []string {
+	for _, existing := range list {
+		if existing == name {
+			return list
+		}
+	}
+	return append(list, name)
+}
@@ -0,0 +1,226 @@
+package importgames
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"wingspan-scoring/db"
+	"wingspan-scoring/scoring"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupImportDB initialises a temporary database for import tests
+func setupImportDB(t *testing.T) func() {
+	tmpDB, err := os.CreateTemp("", "test-import-*.db")
+	require.NoError(t, err)
+
+	require.NoError(t, db.Initialize(tmpDB.Name()))
+
+	return func() {
+		db.Close()
+		os.Remove(tmpDB.Name())
+	}
+}
+
+func TestDetectImporter(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{
+			name:     "Native CSV",
+			data:     "GameID,Date,IncludeOceania,PlayerName,BirdPoints,BonusCards,RoundGoals,Eggs,CachedFood,TuckedCards,NectarForest,NectarGrassland,NectarWetland,UnusedFood,Total,Rank\n",
+			expected: "csv",
+		},
+		{
+			name:     "Reordered CSV with aliases",
+			data:     "Player,Score,Birds,Eggs\nAlice,92,45,9\n",
+			expected: "csv-aliases",
+		},
+		{
+			name:     "Scorepad",
+			data:     "Multi-player,Alice,Bob\nBirds,45,40\nBonus cards,12,10\nEggs,9,8\nTotal,66,58\n",
+			expected: "scorepad",
+		},
+		{
+			name:     "BGG XML",
+			data:     `<?xml version="1.0" encoding="utf-8"?><plays username="x"><play id="1"></play></plays>`,
+			expected: "bgg",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			imp, err := DetectImporter([]byte(tc.data))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, imp.Name())
+		})
+	}
+
+	_, err := DetectImporter([]byte("just some text\nwith no scores\n"))
+	assert.Error(t, err)
+}
+
+func TestFindImporter(t *testing.T) {
+	for _, imp := range Importers {
+		found, err := FindImporter(imp.Name())
+		require.NoError(t, err)
+		assert.Equal(t, imp.Name(), found.Name())
+	}
+
+	_, err := FindImporter("xlsx")
+	assert.Error(t, err)
+}
+
+func TestImportWith_DetectsAndSaves(t *testing.T) {
+	cleanup := setupImportDB(t)
+	defer cleanup()
+
+	data := "Multi-player,Alice,Bob\nBirds,45,40\nBonus cards,12,10\nEggs,9,8\nColour,1,2\n"
+	result, err := ImportWith(t.Context(), strings.NewReader(data), "", false)
+	require.NoError(t, err)
+
+	assert.Equal(t, "scorepad", result.Format)
+	assert.Equal(t, 1, result.GamesImported)
+	assert.Equal(t, []string{"Colour"}, result.Unmapped)
+
+	count, err := db.CountGameResults(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestImportWith_SkipsUnsupportedBGGPlays(t *testing.T) {
+	cleanup := setupImportDB(t)
+	defer cleanup()
+
+	data := `<plays>
+		<play id="1" date="2024-01-01"><item name="Wingspan" objectid="266192"/><players>
+			<player name="Alice" score="95" win="1"/>
+		</players></play>
+		<play id="2" date="2024-01-02"><item name="Wingspan" objectid="266192"/><players>
+			<player name="Alice" score="80" win="1"/><player name="Bob" score="70" win="0"/>
+		</players></play>
+		<play id="3" date="2024-01-03"><item name="Wingspan" objectid="266192"/><players>
+			<player name="A" score="60"/><player name="B" score="61"/><player name="C" score="62"/>
+			<player name="D" score="63"/><player name="E" score="64"/><player name="F" score="65" win="1"/>
+		</players></play>
+	</plays>`
+
+	result, err := ImportWith(t.Context(), strings.NewReader(data), "bgg", false)
+	require.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	assert.Equal(t, 1, result.GamesImported)
+	require.Len(t, result.Warnings, 2)
+	assert.Equal(t, "1", result.Warnings[0].GameID)
+	assert.Contains(t, result.Warnings[0].Message, "invalid player count: 1")
+	assert.Equal(t, "3", result.Warnings[1].GameID)
+	assert.Contains(t, result.Warnings[1].Message, "invalid player count: 6")
+}
+
+func TestImportWith_KeepsPlayedDates(t *testing.T) {
+	cleanup := setupImportDB(t)
+	defer cleanup()
+
+	result, err := ImportWith(t.Context(), strings.NewReader(bggPlays), "bgg", false)
+	require.NoError(t, err)
+	require.Equal(t, 2, result.GamesImported)
+
+	games, err := db.GetAllGameResults(t.Context(), 10, 0)
+	require.NoError(t, err)
+	require.Len(t, games, 2)
+	assert.Equal(t, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), games[0].CreatedAt.UTC())
+	assert.Equal(t, time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), games[1].CreatedAt.UTC())
+
+	count, err := db.CountFilteredGameResults(t.Context(), db.GameFilter{From: time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count, "Date filters see the played date")
+}
+
+func TestImportIntoGroup_SavesToGroup(t *testing.T) {
+	cleanup := setupImportDB(t)
+	defer cleanup()
+
+	require.NoError(t, db.CreateGroup(t.Context(), &db.Group{Slug: "club", Name: "Club"}))
+
+	data := "Game,Player,Score\n1,Alice,90\n1,Bob,80\n"
+	result, err := ImportIntoGroup(t.Context(), strings.NewReader(data), "club", "csv-aliases", false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.GamesImported)
+
+	count, err := db.CountGameResults(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	count, err = db.CountFilteredGameResults(t.Context(), db.GameFilter{Group: "club"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestImportWith_ValidationErrorsSaveNothing(t *testing.T) {
+	cleanup := setupImportDB(t)
+	defer cleanup()
+
+	// Second game has only one player
+	data := "Game,Player,Score\n1,Alice,90\n1,Bob,80\n2,Carol,70\n"
+	result, err := ImportWith(t.Context(), strings.NewReader(data), "csv-aliases", false)
+	require.Error(t, err)
+	assert.Equal(t, 0, result.GamesImported)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "2", result.Errors[0].GameID)
+
+	count, err := db.CountGameResults(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestImportWith_UnknownFormat(t *testing.T) {
+	_, err := ImportWith(t.Context(), strings.NewReader("a,b\n"), "nope", false)
+	assert.Error(t, err)
+}
+
+func TestBuildGame_ScoresLikeEnteredGames(t *testing.T) {
+	// Without totals, Oceania nectar points count towards totals and ranks
+	game, err := buildGame(time.Now(), true, []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", BirdPoints: 40},
+		{PlayerName: "Bob", BirdPoints: 38, NectarForest: 3},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 40, game.Players[0].Total)
+	assert.Equal(t, 43, game.Players[1].Total)
+	assert.Equal(t, 1, game.Players[1].Rank)
+	assert.Equal(t, "Bob", game.WinnerName)
+	require.NotNil(t, game.NectarScoring)
+	assert.Equal(t, 5, game.NectarScoring.Forest["Bob"])
+
+	// Players tied on total and unused food share first place
+	game, err = buildGame(time.Now(), false, []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Total: 80, UnusedFood: 1},
+		{PlayerName: "Bob", Total: 80, UnusedFood: 1},
+		{PlayerName: "Carol", Total: 70},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 1, 3}, []int{game.Players[0].Rank, game.Players[1].Rank, game.Players[2].Rank})
+	assert.Equal(t, "Alice", game.WinnerName)
+
+	// Shared ranks from the file are accepted, gaps aren't
+	_, err = buildGame(time.Now(), false, []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Total: 80, Rank: 1},
+		{PlayerName: "Bob", Total: 80, Rank: 1},
+	})
+	require.NoError(t, err)
+	_, err = buildGame(time.Now(), false, []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Total: 80, Rank: 1},
+		{PlayerName: "Bob", Total: 70, Rank: 3},
+	})
+	assert.Error(t, err)
+	_, err = buildGame(time.Now(), false, []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Total: 80},
+		{PlayerName: "Alice", Total: 70},
+	})
+	assert.ErrorContains(t, err, "duplicate player")
+}
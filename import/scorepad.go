@@ -0,0 +1,196 @@
+package importgames
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"wingspan-scoring/scoring"
+)
+
+// ScorepadImporter reads spreadsheets laid out like the printed scorepad
+// (static/images/components/scorepad.webp): a header row of player names,
+// then one row per scoring category with a column per player, ending in a
+// Total row. Several games can follow one another; each new header row starts
+// a game. The header's first cell may hold the game date, and an optional
+// "Date" row is also recognised. Nectar rows per habitat mark the game as
+// Oceania; a single "Nectar" points row can't be split by habitat, so it is
+// reported as unmapped and only counts through the Total row.
+type ScorepadImporter struct{}
+
+func (ScorepadImporter) Name() string { return "scorepad" }
+
+func (ScorepadImporter) Detect(sample []byte) bool {
+	reader := csv.NewReader(bytes.NewReader(sample))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	categoryRows := 0
+	for i := 0; ; i++ {
+		record, err := reader.Read()
+		if err != nil {
+			break
+		}
+		if len(record) < 3 {
+			continue
+		}
+		isCategory := scorepadCategory(record[0])
+		if i == 0 && isCategory {
+			// The scorepad starts with player names, not a category
+			return false
+		}
+		if isCategory {
+			categoryRows++
+		}
+	}
+	return categoryRows >= 3
+}
+
+// scorepadCategory reports whether a row label is a score category
+func scorepadCategory(label string) bool {
+	field, ok := canonicalField(label)
+	if !ok {
+		return false
+	}
+	switch field {
+	case "PlayerName", "GameID", "Date", "IncludeOceania":
+		return false
+	}
+	return true
+}
+
+func (ScorepadImporter) Parse(reader io.Reader) (*ParseResult, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+	csvReader.FieldsPerRecord = -1
+
+	result := &ParseResult{}
+
+	type sheet struct {
+		line    int
+		date    string
+		oceania bool
+		players []scoring.PlayerGameEnd
+		failed  bool
+	}
+	var sheets []*sheet
+	var current *sheet
+
+	lineNum := 0
+	for {
+		lineNum++
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, ImportError{Line: lineNum, Message: fmt.Sprintf("failed to read row: %v", err)})
+			continue
+		}
+		if isBlankRow(row) {
+			continue
+		}
+
+		label := strings.TrimSpace(row[0])
+		field, known := canonicalField(label)
+
+		// A row that isn't a category or date row, and doesn't hold scores
+		// under an unrecognised label, is a new header of player names
+		isScoreRow := scorepadCategory(label) || field == "Date" || (!known && isNumericRow(row[1:]))
+		if current == nil || !isScoreRow {
+			current = &sheet{line: lineNum}
+			if _, err := parseDate(label); err == nil {
+				current.date = label
+			}
+			for _, name := range row[1:] {
+				if name = strings.TrimSpace(name); name != "" {
+					current.players = append(current.players, scoring.PlayerGameEnd{PlayerName: name})
+				}
+			}
+			sheets = append(sheets, current)
+			continue
+		}
+
+		if !known {
+			result.Unmapped = addUnmapped(result.Unmapped, label)
+			continue
+		}
+
+		if field == "Date" {
+			if len(row) > 1 {
+				current.date = strings.TrimSpace(row[1])
+			}
+			continue
+		}
+
+		if isNectarField(field) {
+			current.oceania = true
+		}
+		for i := range current.players {
+			if i+1 >= len(row) {
+				break
+			}
+			if err := setPlayerScore(&current.players[i], field, row[i+1]); err != nil {
+				result.Errors = append(result.Errors, ImportError{
+					Line:    lineNum,
+					Message: fmt.Sprintf("player %s: %v", current.players[i].PlayerName, err),
+				})
+				current.failed = true
+			}
+		}
+	}
+
+	for i, s := range sheets {
+		if s.failed {
+			continue
+		}
+		gameID := fmt.Sprintf("sheet %d", i+1)
+
+		createdAt := time.Now()
+		if s.date != "" {
+			var err error
+			createdAt, err = parseDate(s.date)
+			if err != nil {
+				result.Errors = append(result.Errors, ImportError{Line: s.line, GameID: gameID, Message: fmt.Sprintf("invalid date format: %v", err)})
+				continue
+			}
+		}
+
+		game, err := buildGame(createdAt, s.oceania, s.players)
+		if err != nil {
+			result.Errors = append(result.Errors, ImportError{Line: s.line, GameID: gameID, Message: err.Error()})
+			continue
+		}
+		result.Games = append(result.Games, game)
+	}
+
+	return result, nil
+}
+
+// isBlankRow reports whether every cell in a row is empty
+func isBlankRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// isNumericRow reports whether every non-empty cell is an integer
+func isNumericRow(cells []string) bool {
+	for _, cell := range cells {
+		cell = strings.TrimSpace(cell)
+		if cell == "" {
+			continue
+		}
+		if _, err := strconv.Atoi(cell); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package importgames
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScorepadImporter_SingleGame(t *testing.T) {
+	data := `Multi-player,Alice,Bob,Carol
+Birds,45,40,38
+Bonus cards,12,10,8
+End-of-round goals,18,15,12
+Eggs,9,8,7
+Food on cards,5,4,3
+Tucked cards,3,2,1
+Total,92,79,69`
+
+	result, err := ScorepadImporter{}.Parse(strings.NewReader(data))
+	require.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	assert.Empty(t, result.Unmapped)
+	require.Len(t, result.Games, 1)
+
+	game := result.Games[0]
+	assert.Equal(t, 3, game.NumPlayers)
+	assert.Equal(t, "Alice", game.WinnerName)
+	assert.Equal(t, 92, game.WinnerScore)
+	assert.False(t, game.IncludeOceania)
+
+	bob := game.Players[1]
+	assert.Equal(t, "Bob", bob.PlayerName)
+	assert.Equal(t, 40, bob.BirdPoints)
+	assert.Equal(t, 15, bob.RoundGoals)
+	assert.Equal(t, 4, bob.CachedFood)
+	assert.Equal(t, 2, bob.Rank)
+}
+
+func TestScorepadImporter_MultipleGamesWithDatesAndNectar(t *testing.T) {
+	data := `2024-02-01,Alice,Bob
+Birds,45,40
+Eggs,9,8
+Nectar forest,2,1
+Nectar grassland,0,3
+Nectar wetland,1,1
+Nectar,7,7
+,,
+Multi-player,Carol,Dave
+Date,2024-02-08
+Birds,30,35
+Eggs,5,5`
+
+	result, err := ScorepadImporter{}.Parse(strings.NewReader(data))
+	require.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	assert.Equal(t, []string{"Nectar"}, result.Unmapped)
+	require.Len(t, result.Games, 2)
+
+	first := result.Games[0]
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), first.CreatedAt)
+	assert.True(t, first.IncludeOceania)
+	assert.Equal(t, 2, first.Players[0].NectarForest)
+	// Without a Total row, totals are summed with nectar points: 5 for the
+	// most forest nectar and 3 for sharing first in wetland
+	assert.Equal(t, 62, first.Players[0].Total)
+	require.NotNil(t, first.NectarScoring)
+	assert.Equal(t, 5, first.NectarScoring.Forest["Alice"])
+	assert.Equal(t, 3, first.NectarScoring.Wetland["Alice"])
+	assert.Equal(t, "Alice", first.WinnerName)
+
+	second := result.Games[1]
+	assert.Equal(t, time.Date(2024, 2, 8, 0, 0, 0, 0, time.UTC), second.CreatedAt)
+	assert.False(t, second.IncludeOceania)
+	assert.Equal(t, "Dave", second.WinnerName)
+}
+
+func TestScorepadImporter_InvalidScore(t *testing.T) {
+	data := `Multi-player,Alice,Bob
+Birds,45,lots
+Eggs,9,8`
+
+	result, err := ScorepadImporter{}.Parse(strings.NewReader(data))
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, 2, result.Errors[0].Line)
+	assert.Contains(t, result.Errors[0].Message, "Bob")
+	assert.Empty(t, result.Games)
+}
+
+func TestScorepadImporter_DetectRejectsOtherLayouts(t *testing.T) {
+	assert.False(t, ScorepadImporter{}.Detect([]byte("Player,Birds,Eggs\nAlice,45,9\nBob,40,8\n")))
+	assert.False(t, ScorepadImporter{}.Detect([]byte("Birds,45,40\nEggs,9,8\nTotal,54,48\n")))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"wingspan-scoring/config"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// setupLogging sends log output, including the log package's, through a
+// slog handler with the configured level and format
+func setupLogging(cfg config.Log) {
+	slog.SetDefault(slog.New(newLogHandler(os.Stderr, cfg)))
+}
+
+// newLogHandler returns a handler writing to w with the configured level and
+// format, which tags lines logged while serving a request with its ID and route
+func newLogHandler(w io.Writer, cfg config.Log) slog.Handler {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler = slog.NewTextHandler(w, opts)
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	return requestLogHandler{handler}
+}
+
+// requestLogHandler adds the request ID, route and trace ID from the context
+// to each record, so every line about a request can be found by its ID
+type requestLogHandler struct {
+	slog.Handler
+}
+
+// Handle adds the request's attributes and passes the record on
+func (h requestLogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := requestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if route, ok := ctx.Value(routeKey).(*string); ok && *route != "" {
+		record.AddAttrs(slog.String("route", *route))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs returns a handler that also adds the request's attributes
+func (h requestLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestLogHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup returns a handler that also adds the request's attributes
+func (h requestLogHandler) WithGroup(name string) slog.Handler {
+	return requestLogHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"wingspan-scoring/config"
+	"wingspan-scoring/db"
+	"wingspan-scoring/scoring"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLogs sends log output to a buffer in format until the test ends
+func captureLogs(t *testing.T, format string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	original := slog.Default()
+	slog.SetDefault(slog.New(newLogHandler(&buf, config.Log{Level: "debug", Format: format})))
+	t.Cleanup(func() {
+		slog.SetDefault(original)
+		log.SetOutput(os.Stderr)
+		log.SetFlags(log.LstdFlags)
+	})
+	return &buf
+}
+
+// logLines decodes JSON log output into one map per line
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &entry), line)
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+// TestNewLogHandler tests the level and format settings
+func TestNewLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(newLogHandler(&buf, config.Log{Level: "warn", Format: "json"}))
+	logger.Info("Hidden")
+	logger.Warn("Shown", "game_id", 7)
+
+	lines := logLines(t, &buf)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "Shown", lines[0]["msg"])
+	assert.Equal(t, "WARN", lines[0]["level"])
+	assert.Equal(t, 7.0, lines[0]["game_id"])
+
+	buf.Reset()
+	logger = slog.New(newLogHandler(&buf, config.Log{Level: "info", Format: "text"}))
+	logger.Info("Plain", "file", "games.csv")
+	assert.Contains(t, buf.String(), "msg=Plain file=games.csv")
+}
+
+// TestRequestIDMiddleware tests assigning, keeping and echoing request IDs
+func TestRequestIDMiddleware(t *testing.T) {
+	var got string
+	handler := requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = requestID(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.NotEmpty(t, got)
+	assert.Equal(t, got, w.Header().Get("X-Request-ID"))
+	first := got
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.NotEqual(t, first, got, "each request gets its own ID")
+
+	// IDs assigned by a proxy are kept unless they aren't safe to log
+	for id, keep := range map[string]bool{
+		"abc-123_x.y":           true,
+		"bad id":                false,
+		"line\nbreak":           false,
+		strings.Repeat("a", 65): false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-ID", id)
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, keep, got == id, id)
+		assert.Equal(t, got, w.Header().Get("X-Request-ID"))
+	}
+}
+
+// TestRequestLogging tests that a request's log lines carry its ID and route
+func TestRequestLogging(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+	buf := captureLogs(t, "json")
+	handler := newAuthTestServer("admin")
+
+	req := httptest.NewRequest(http.MethodGet, "/g/default/api/games/999", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "req-1", w.Header().Get("X-Request-ID"))
+
+	gameID, err := db.SaveGame(t.Context(), &db.GameResult{Players: []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Total: 90, Rank: 1},
+		{PlayerName: "Bob", Total: 70, Rank: 2},
+	}})
+	require.NoError(t, err)
+	req = httptest.NewRequest(http.MethodDelete, "/api/games/"+strconv.FormatInt(gameID, 10), nil)
+	req.Header.Set("X-Request-ID", "req-2")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("csvFile", "games.csv")
+	require.NoError(t, err)
+	part.Write([]byte("not,a,known,format\n"))
+	form.Close()
+	req = httptest.NewRequest(http.MethodPost, "/api/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("X-Request-ID", "req-3")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	find := func(msg, id string) map[string]any {
+		for _, line := range logLines(t, buf) {
+			if line["msg"] == msg && line["request_id"] == id {
+				return line
+			}
+		}
+		require.Failf(t, "missing log line", "%s for %s in %s", msg, id, buf.String())
+		return nil
+	}
+
+	request := find("Request", "req-1")
+	assert.Equal(t, "/api/games/", request["route"])
+	assert.Equal(t, "GET", request["method"])
+	assert.Equal(t, "/g/default/api/games/999", request["path"])
+	assert.Equal(t, 404.0, request["status"])
+	assert.Contains(t, request, "duration")
+
+	deleted := find("Deleted game", "req-2")
+	assert.Equal(t, "/api/games/", deleted["route"])
+	assert.Equal(t, float64(gameID), deleted["game_id"])
+
+	assert.Equal(t, "games.csv", find("Processing import file", "req-3")["file"])
+	assert.Equal(t, "games.csv", find("Import failed", "req-3")["file"])
+}
@@ -1,17 +1,35 @@
 package main
 
 import (
+	"compress/gzip"
+	"context"
 	"embed"
 	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"html/template"
+	"io"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"wingspan-scoring/config"
 	"wingspan-scoring/db"
 	"wingspan-scoring/export"
 	"wingspan-scoring/goals"
 	importgames "wingspan-scoring/import"
+	"wingspan-scoring/metrics"
 	"wingspan-scoring/scoring"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 //go:embed templates static
@@ -22,6 +40,12 @@ var tmpl *template.Template
 // version is set at build time via -ldflags
 var version = "dev"
 
+// maxRecordLimit caps how many entries each record list can return
+const maxRecordLimit = 50
+
+// maxTrendWindow caps the moving-average window for score trends
+const maxTrendWindow = 52
+
 func init() {
 	var err error
 	tmpl, err = template.ParseFS(content, "templates/*.html")
@@ -41,37 +65,221 @@ type PageData struct {
 	PageSubtitle string
 	CurrentPage  string
 	Version      string
+	BasePath     string // /g/{slug} when viewing a group by URL, so links and API calls stay in it
+	User         *db.User
+	CSRFToken    string // Sent with requests that change data
+	Error        string // Sign-in error
+	Next         string // Where to go after signing in
+	OIDCName     string // Label of the OpenID Connect sign-in button, when configured
+	Features     config.Features
+}
+
+// server is the app built from its configuration, with the sign-in
+// providers the configuration turns on
+type server struct {
+	config *config.Config
+	oidc   *oidcLogin // nil when OpenID Connect is off
+	proxy  *proxyAuth // nil when proxy authentication is off
+}
+
+// newServer builds the server for cfg, discovering the OpenID Connect
+// provider if one is configured
+func newServer(ctx context.Context, cfg *config.Config) (*server, error) {
+	s := &server{config: cfg}
+	var err error
+	if s.oidc, err = newOIDCLogin(ctx, cfg.Auth.OIDC); err != nil {
+		return nil, fmt.Errorf("failed to configure OpenID Connect: %w", err)
+	}
+	if s.proxy, err = newProxyAuth(cfg.Auth.Proxy); err != nil {
+		return nil, fmt.Errorf("failed to configure proxy authentication: %w", err)
+	}
+	return s, nil
+}
+
+// handler returns the app's routes behind its middleware
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	s.registerRoutes(mux)
+	app := requestIDMiddleware(forwardedProtoMiddleware(s.proxy, loggingMiddleware(tracingMiddleware(securityHeadersMiddleware(s.config.TLS.HSTSMaxAge, groupMiddleware(proxyAuthMiddleware(s.proxy, authMiddleware(s.config.Auth.AnonymousRole, routeRecorder(mux)))))))))
+
+	// Probes skip logging, groups and sign-in so Kubernetes can always reach them
+	root := http.NewServeMux()
+	root.HandleFunc("/healthz", handleHealthz)
+	root.HandleFunc("/readyz", handleReadyz)
+	root.Handle("/", app)
+	return root
 }
 
 func main() {
+	cfg, opts, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+	if opts.PrintConfig {
+		if err := cfg.Write(os.Stdout); err != nil {
+			log.Fatal("Failed to print configuration:", err)
+		}
+		return
+	}
+
+	setupLogging(cfg.Log)
+	if opts.File != "" {
+		slog.Info("Loaded configuration", "file", opts.File)
+	}
+
+	// Kubernetes sends SIGTERM before stopping the pod
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ln, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		log.Fatal("Failed to listen:", err)
+	}
+	if err := run(ctx, cfg, ln); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run serves the app on ln until ctx is done, then finishes the requests
+// in flight and closes the database
+func run(ctx context.Context, cfg *config.Config, ln net.Listener) error {
+	defer ln.Close()
+
+	shutdownTracing, err := setupTracing(ctx, cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Failed to flush traces", "error", err)
+		}
+	}()
+	if cfg.Tracing.Endpoint != "" {
+		slog.Info("Sending traces", "endpoint", cfg.Tracing.Endpoint, "service", cfg.Tracing.ServiceName)
+	}
+
 	// Initialize database
-	if err := db.Initialize(); err != nil {
-		log.Fatal("Failed to initialize database:", err)
+	if err := db.Initialize(cfg.DBPath); err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			slog.Error("Failed to close database", "error", err)
+			return
+		}
+		slog.Info("Database closed")
+	}()
+	slog.Info("Database initialized")
+
+	if err := ensureAdminAccount(ctx, cfg.Auth); err != nil {
+		return fmt.Errorf("failed to create admin account: %w", err)
+	}
+	if err := db.DeleteExpiredSessions(ctx); err != nil {
+		slog.Error("Failed to clean up sessions", "error", err)
+	}
+
+	srv, err := newServer(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if srv.oidc != nil {
+		slog.Info("OpenID Connect sign-in enabled", "issuer", srv.oidc.provider.Issuer())
+	}
+	if srv.proxy != nil {
+		slog.Info("Trusting proxy authentication headers", "header", srv.proxy.userHeader, "proxies", srv.proxy.trusted)
+	}
+
+	httpServer := newHTTPServer(cfg.HTTP, srv.handler())
+	scheme := "HTTP"
+	if cfg.TLS.CertFile != "" {
+		certs, err := newCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return err
+		}
+		go certs.watch(ctx, cfg.TLS.ReloadInterval)
+		httpServer.TLSConfig = certs.tlsConfig()
+		scheme = "HTTPS"
+	}
+	endpoints := []endpoint{{httpServer, ln}}
+
+	if cfg.TLS.RedirectListen != "" {
+		redirectLn, err := net.Listen("tcp", cfg.TLS.RedirectListen)
+		if err != nil {
+			return fmt.Errorf("failed to listen for HTTP redirects: %w", err)
+		}
+		defer redirectLn.Close()
+		endpoints = append(endpoints, endpoint{newHTTPServer(cfg.HTTP, redirectToHTTPS(cfg.Listen)), redirectLn})
+		slog.Info("Redirecting HTTP to HTTPS", "addr", redirectLn.Addr().String())
 	}
-	defer db.Close()
-	log.Println("Database initialized successfully")
 
+	slog.Info("Starting Wingspan Scoring server", "addr", ln.Addr().String(), "scheme", scheme, "version", version)
+	return serveAll(ctx, endpoints, cfg.HTTP.ShutdownTimeout)
+}
+
+// registerRoutes adds the app's pages, static files and API to mux, leaving
+// out features that are turned off
+func (s *server) registerRoutes(mux *http.ServeMux) {
 	// Serve static files
 	fs := http.FileServer(http.FS(content))
-	http.Handle("/static/", fs)
+	mux.Handle("/static/", fs)
 
 	// Routes
-	http.HandleFunc("/", handleHome)
-	http.HandleFunc("/history", handleHistory)
-	http.HandleFunc("/api/version", handleVersion)
-	http.HandleFunc("/api/new-game", handleNewGame)
-	http.HandleFunc("/api/goals", handleGetGoals)
-	http.HandleFunc("/api/calculate-scores", handleCalculateScores)
-	http.HandleFunc("/api/calculate-game-end", handleCalculateGameEnd)
-	http.HandleFunc("/api/games", handleGetGames)
-	http.HandleFunc("/api/games/", handleGameRoute)
-	http.HandleFunc("/api/stats/", handleGetPlayerStats)
-	http.HandleFunc("/api/leaderboard", handleGetLeaderboard)
-	http.HandleFunc("/api/import", handleImportGames)
-	http.HandleFunc("/api/export", handleExportGames)
-
-	log.Println("Starting Wingspan Scoring server on :8080")
-	log.Fatal(http.ListenAndServe(":8080", loggingMiddleware(http.DefaultServeMux)))
+	mux.HandleFunc("/", handleHome)
+	mux.HandleFunc("/history", s.handleHistory)
+	mux.HandleFunc("/login", s.handleLogin)
+	mux.HandleFunc("/login/oidc", s.handleOIDCLogin)
+	mux.HandleFunc(oidcCallbackPath, s.handleOIDCCallback)
+	mux.HandleFunc("/logout", handleLogout)
+	mux.HandleFunc("/api/me", handleGetCurrentUser)
+	mux.HandleFunc("/api/users", handleUsers)
+	mux.HandleFunc("/api/users/", handleUserRoute)
+	mux.HandleFunc("/api/tokens", handleTokens)
+	mux.HandleFunc("/api/tokens/", handleTokenRoute)
+	mux.HandleFunc("/api/version", handleVersion)
+	mux.HandleFunc("/api/groups", handleGroups)
+	mux.HandleFunc("/api/new-game", handleNewGame)
+	mux.HandleFunc("/api/goals", handleGetGoals)
+	mux.HandleFunc("/api/calculate-scores", handleCalculateScores)
+	mux.HandleFunc("/api/calculate-game-end", handleCalculateGameEnd)
+	mux.HandleFunc("/api/games", handleGetGames)
+	mux.HandleFunc("/api/games/", handleGameRoute)
+	mux.HandleFunc("/api/stats/", handleStatsRoute)
+	mux.HandleFunc("/api/stats/head-to-head", handleGetHeadToHead)
+	mux.HandleFunc("/api/stats/head-to-head/matrix", handleGetHeadToHeadMatrix)
+	mux.HandleFunc("/api/leaderboard", handleGetLeaderboard)
+	mux.HandleFunc("/api/records", handleGetRecords)
+	mux.HandleFunc("/api/analytics/trends", handleGetTrends)
+	mux.HandleFunc("/api/analytics/goals", handleGetGoalAnalytics)
+	mux.HandleFunc("/api/ratings", handleGetRatings)
+	mux.HandleFunc("/api/achievements", handleGetAchievements)
+	mux.HandleFunc("/api/players/", handleGetPlayerAchievements)
+	mux.HandleFunc("/api/ratings/", handleGetRatingHistory)
+	mux.Handle("/metrics", metrics.Handler())
+
+	// Turned-off features answer 404 rather than falling through to the home page
+	features := s.config.Features
+	for _, route := range []struct {
+		enabled bool
+		pattern string
+		handler http.HandlerFunc
+	}{
+		{features.Seasons, "/api/seasons", handleSeasons},
+		{features.Seasons, "/api/seasons/", handleSeasonRoute},
+		{features.Tournaments, "/api/tournaments", handleTournaments},
+		{features.Tournaments, "/api/tournaments/", handleTournamentRoute},
+		{features.Import, "/api/import", handleImportGames},
+		{features.Export, "/api/export", handleExportGames},
+	} {
+		if !route.enabled {
+			route.handler = http.NotFound
+		}
+		mux.HandleFunc(route.pattern, route.handler)
+	}
 }
 
 func handleHome(w http.ResponseWriter, r *http.Request) {
@@ -94,11 +302,14 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
 		PageSubtitle: "Round End Goals",
 		CurrentPage:  "home",
 		Version:      version,
+		BasePath:     requestBasePath(r),
+		User:         requestUser(r),
+		CSRFToken:    requestCSRFToken(r),
 	}
 
 	err = tmpl.ExecuteTemplate(w, "index.html", data)
 	if err != nil {
-		log.Println("Error rendering template:", err)
+		slog.ErrorContext(r.Context(), "Failed to render template", "error", err)
 		http.Error(w, "Error rendering page", http.StatusInternalServerError)
 	}
 }
@@ -110,6 +321,61 @@ func handleVersion(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func handleGroups(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		groups, err := db.GetGroups(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to get groups", "error", err)
+			http.Error(w, "Failed to retrieve groups", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(groups)
+	case http.MethodPost:
+		var group db.Group
+		if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := group.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		exists, err := db.GroupExists(r.Context(), group.Slug)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to check group", "group", group.Slug, "error", err)
+			http.Error(w, "Failed to create group", http.StatusInternalServerError)
+			return
+		}
+		if exists {
+			http.Error(w, "Group already exists", http.StatusConflict)
+			return
+		}
+
+		if err := db.CreateGroup(r.Context(), &group); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to create group", "error", err)
+			http.Error(w, "Failed to create group", http.StatusInternalServerError)
+			return
+		}
+		created, err := db.GetGroup(r.Context(), group.Slug)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to load group", "group", group.Slug, "error", err)
+			http.Error(w, "Failed to create group", http.StatusInternalServerError)
+			return
+		}
+		slog.InfoContext(r.Context(), "Created group", "group", created.Slug)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 func handleNewGame(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -173,17 +439,41 @@ func handleCalculateScores(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	_, span := startStage(r.Context(), "scoring.round_goal",
+		attribute.String("scoring.mode", request.Mode), attribute.Int("scoring.players", len(request.PlayerCounts)))
 	var scores []goals.PlayerScore
 	if request.Mode == "green" {
 		scores = goals.CalculateGreenScores(request.PlayerCounts, request.Round)
 	} else {
 		scores = goals.CalculateBlueScores(request.PlayerCounts)
 	}
+	endStage(span, nil)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(scores)
 }
 
+// validateGameGoals checks a game's goal board side and that each round's
+// goal, when known, is one of the game's goals
+func validateGameGoals(gameGoals *db.GameGoals) error {
+	if gameGoals == nil {
+		return nil
+	}
+	if gameGoals.Side != "green" && gameGoals.Side != "blue" {
+		return errors.New("goals side must be green or blue")
+	}
+	known := make(map[string]bool)
+	for _, goal := range goals.GetAllGoals(true, true, true) {
+		known[goal.ID] = true
+	}
+	for i, id := range gameGoals.Rounds {
+		if id != "" && !known[id] {
+			return fmt.Errorf("unknown goal %q for round %d", id, i+1)
+		}
+	}
+	return nil
+}
+
 func handleCalculateGameEnd(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -193,6 +483,8 @@ func handleCalculateGameEnd(w http.ResponseWriter, r *http.Request) {
 	var request struct {
 		Players        []scoring.PlayerGameEnd `json:"players"`
 		IncludeOceania bool                    `json:"includeOceania"`
+		Goals          *db.GameGoals           `json:"goals,omitempty"`
+		SeasonID       *int64                  `json:"seasonId,omitempty"`
 	}
 
 	err := json.NewDecoder(r.Body).Decode(&request)
@@ -200,18 +492,38 @@ func handleCalculateGameEnd(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if err := validateGameGoals(request.Goals); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.SeasonID != nil {
+		season, err := db.GetSeason(r.Context(), *request.SeasonID)
+		if err != nil || season.Group != requestGroup(r) {
+			http.Error(w, "Season not found", http.StatusBadRequest)
+			return
+		}
+	}
 
 	// Calculate game end scores
+	_, span := startStage(r.Context(), "scoring.game_end", attribute.Int("scoring.players", len(request.Players)))
 	players, nectarScoring := scoring.CalculateGameEndScores(request.Players, request.IncludeOceania)
+	endStage(span, nil)
 
 	// Save game result to database
-	gameID, err := db.SaveGameResult(players, nectarScoring, request.IncludeOceania)
+	gameID, err := db.SaveGame(r.Context(), &db.GameResult{
+		Players:        players,
+		NectarScoring:  &nectarScoring,
+		IncludeOceania: request.IncludeOceania,
+		Goals:          request.Goals,
+		SeasonID:       request.SeasonID,
+		Group:          requestGroup(r),
+	})
 	if err != nil {
-		log.Printf("Failed to save game result: %v", err)
-		// Don't fail the request - just log the error
-	} else {
-		log.Printf("Saved game result with ID: %d", gameID)
+		slog.ErrorContext(r.Context(), "Failed to save game result", "error", err)
+		http.Error(w, "Failed to save game", http.StatusInternalServerError)
+		return
 	}
+	slog.InfoContext(r.Context(), "Saved game result", "game_id", gameID)
 
 	response := struct {
 		Players       []scoring.PlayerGameEnd `json:"players"`
@@ -227,7 +539,7 @@ func handleCalculateGameEnd(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-func handleHistory(w http.ResponseWriter, r *http.Request) {
+func (s *server) handleHistory(w http.ResponseWriter, r *http.Request) {
 	data := PageData{
 		BaseGame:     true,
 		European:     true,
@@ -237,11 +549,15 @@ func handleHistory(w http.ResponseWriter, r *http.Request) {
 		PageSubtitle: "Game History",
 		CurrentPage:  "history",
 		Version:      version,
+		BasePath:     requestBasePath(r),
+		User:         requestUser(r),
+		CSRFToken:    requestCSRFToken(r),
+		Features:     s.config.Features,
 	}
 
 	err := tmpl.ExecuteTemplate(w, "history.html", data)
 	if err != nil {
-		log.Println("Error rendering template:", err)
+		slog.ErrorContext(r.Context(), "Failed to render template", "error", err)
 		http.Error(w, "Error rendering page", http.StatusInternalServerError)
 	}
 }
@@ -256,18 +572,24 @@ func handleGetGames(w http.ResponseWriter, r *http.Request) {
 	limit := parseIntDefault(r.URL.Query().Get("limit"), 50)
 	offset := parseIntDefault(r.URL.Query().Get("offset"), 0)
 
+	filter, err := parseRequestFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Get games from database
-	games, err := db.GetAllGameResults(limit, offset)
+	games, err := db.GetFilteredGameResults(r.Context(), filter, limit, offset)
 	if err != nil {
-		log.Printf("Failed to get games: %v", err)
+		slog.ErrorContext(r.Context(), "Failed to get games", "error", err)
 		http.Error(w, "Failed to retrieve games", http.StatusInternalServerError)
 		return
 	}
 
 	// Get total count
-	totalCount, err := db.CountGameResults()
+	totalCount, err := db.CountFilteredGameResults(r.Context(), filter)
 	if err != nil {
-		log.Printf("Failed to count games: %v", err)
+		slog.ErrorContext(r.Context(), "Failed to count games", "error", err)
 		totalCount = 0
 	}
 
@@ -288,6 +610,20 @@ func handleGetGames(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleGameRoute(w http.ResponseWriter, r *http.Request) {
+	// Games in other groups are hidden
+	idStr, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/games/"), "/")
+	if id, err := strconv.ParseInt(idStr, 10, 64); err == nil {
+		if game, err := db.GetGameResult(r.Context(), id); err == nil && game.Group != requestGroup(r) {
+			http.Error(w, "Game not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/season") {
+		handleSetGameSeason(w, r)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		handleGetGame(w, r)
@@ -309,9 +645,9 @@ func handleGetGame(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get game from database
-	game, err := db.GetGameResult(id)
+	game, err := db.GetGameResult(r.Context(), id)
 	if err != nil {
-		log.Printf("Failed to get game %d: %v", id, err)
+		slog.ErrorContext(r.Context(), "Failed to get game", "game_id", id, "error", err)
 		http.Error(w, "Game not found", http.StatusNotFound)
 		return
 	}
@@ -331,14 +667,14 @@ func handleDeleteGame(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Delete game from database
-	err = db.DeleteGameResult(id)
+	err = db.DeleteGameResult(r.Context(), id)
 	if err != nil {
-		log.Printf("Failed to delete game %d: %v", id, err)
+		slog.ErrorContext(r.Context(), "Failed to delete game", "game_id", id, "error", err)
 		http.Error(w, "Failed to delete game", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("Deleted game with ID: %d", id)
+	slog.InfoContext(r.Context(), "Deleted game", "game_id", id)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -347,6 +683,54 @@ func handleDeleteGame(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func handleSetGameSeason(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract ID from /api/games/{id}/season
+	idStr := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/games/"), "/season")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid game ID", http.StatusBadRequest)
+		return
+	}
+
+	var request struct {
+		SeasonID *int64 `json:"seasonId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err = db.SetGameSeason(r.Context(), id, request.SeasonID)
+	if errors.Is(err, db.ErrSeasonNotFound) {
+		http.Error(w, "Season not found", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to set season for game", "game_id", id, "error", err)
+		http.Error(w, "Game not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"seasonId": request.SeasonID,
+	})
+}
+
+func handleStatsRoute(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/profile") {
+		handleGetPlayerProfile(w, r)
+		return
+	}
+	handleGetPlayerStats(w, r)
+}
+
 func handleGetPlayerStats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -361,10 +745,16 @@ func handleGetPlayerStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	filter, err := parseRequestFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Get player stats from database
-	stats, err := db.GetPlayerStats(playerName)
+	stats, err := db.GetPlayerStats(r.Context(), playerName, filter)
 	if err != nil {
-		log.Printf("Failed to get stats for player %s: %v", playerName, err)
+		slog.ErrorContext(r.Context(), "Failed to get player stats", "player", playerName, "error", err)
 		http.Error(w, "Failed to retrieve player stats", http.StatusInternalServerError)
 		return
 	}
@@ -373,132 +763,1051 @@ func handleGetPlayerStats(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(stats)
 }
 
-func handleGetLeaderboard(w http.ResponseWriter, r *http.Request) {
+func handleGetPlayerProfile(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Get leaderboard stats from database
-	leaderboard, err := db.GetLeaderboardStats()
+	// Extract player name from /api/stats/{player}/profile
+	path := strings.TrimPrefix(r.URL.Path, "/api/stats/")
+	playerName := strings.TrimSuffix(path, "/profile")
+	if playerName == "" {
+		http.Error(w, "Player name required", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseRequestFilter(r)
 	if err != nil {
-		log.Printf("Failed to get leaderboard stats: %v", err)
-		http.Error(w, "Failed to retrieve leaderboard", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	profile, err := db.GetPlayerProfile(r.Context(), playerName, filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get player profile", "player", playerName, "error", err)
+		http.Error(w, "Failed to retrieve player profile", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(leaderboard)
+	json.NewEncoder(w).Encode(profile)
 }
 
-// Helper to parse int with default
-func parseIntDefault(s string, defaultVal int) int {
-	if i, err := strconv.Atoi(s); err == nil {
-		return i
+func handleGetHeadToHead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
-	return defaultVal
+
+	playerA := strings.TrimSpace(r.URL.Query().Get("a"))
+	playerB := strings.TrimSpace(r.URL.Query().Get("b"))
+	if playerA == "" || playerB == "" {
+		http.Error(w, "Two player names required (a and b)", http.StatusBadRequest)
+		return
+	}
+	if playerA == playerB {
+		http.Error(w, "Players a and b must be different", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseRequestFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h2h, err := db.GetHeadToHead(r.Context(), playerA, playerB, filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get head-to-head", "player_a", playerA, "player_b", playerB, "error", err)
+		http.Error(w, "Failed to retrieve head-to-head stats", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h2h)
 }
 
-func handleImportGames(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
+func handleGetHeadToHeadMatrix(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Parse multipart form (max 10MB)
-	err := r.ParseMultipartForm(10 << 20)
+	filter, err := parseRequestFilter(r)
 	if err != nil {
-		http.Error(w, "Failed to parse form data", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Get the file from form data
-	file, header, err := r.FormFile("csvFile")
+	matrix, err := db.GetHeadToHeadMatrix(r.Context(), filter)
 	if err != nil {
-		http.Error(w, "Failed to read file: "+err.Error(), http.StatusBadRequest)
+		slog.ErrorContext(r.Context(), "Failed to get head-to-head matrix", "error", err)
+		http.Error(w, "Failed to retrieve head-to-head matrix", http.StatusInternalServerError)
 		return
 	}
-	defer file.Close()
 
-	log.Printf("Processing import file: %s (%d bytes)", header.Filename, header.Size)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(matrix)
+}
+
+func handleGetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-	// Import games
-	result, err := importgames.ImportGames(file)
+	filter, err := parseRequestFilter(r)
 	if err != nil {
-		log.Printf("Import failed: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-		// Return error details
-		response := struct {
-			Success       bool                      `json:"success"`
-			Message       string                    `json:"message"`
-			GamesImported int                       `json:"gamesImported"`
-			Errors        []importgames.ImportError `json:"errors"`
-		}{
-			Success:       false,
-			Message:       err.Error(),
-			GamesImported: result.GamesImported,
-			Errors:        result.Errors,
-		}
+	// Get leaderboard stats from database
+	leaderboard, err := db.GetFilteredLeaderboardStats(r.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get leaderboard stats", "error", err)
+		http.Error(w, "Failed to retrieve leaderboard", http.StatusInternalServerError)
+		return
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(response)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(leaderboard)
+}
+
+func handleGetRecords(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	log.Printf("Successfully imported %d games", result.GamesImported)
+	filter, err := parseRequestFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// Return success response
-	response := struct {
-		Success       bool                      `json:"success"`
-		Message       string                    `json:"message"`
-		GamesImported int                       `json:"gamesImported"`
-		Errors        []importgames.ImportError `json:"errors"`
-	}{
-		Success:       true,
-		Message:       "Import completed successfully",
-		GamesImported: result.GamesImported,
-		Errors:        result.Errors,
+	limit := parseIntDefault(r.URL.Query().Get("limit"), db.DefaultRecordLimit)
+	if limit < 1 || limit > maxRecordLimit {
+		http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxRecordLimit), http.StatusBadRequest)
+		return
+	}
+
+	book, err := db.GetRecords(r.Context(), filter, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get records", "error", err)
+		http.Error(w, "Failed to retrieve records", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(book)
 }
 
-func handleExportGames(w http.ResponseWriter, r *http.Request) {
+func handleGetTrends(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Get total count to determine limit
-	totalCount, err := db.CountGameResults()
+	filter, err := parseRequestFilter(r)
 	if err != nil {
-		log.Printf("Failed to count games for export: %v", err)
-		http.Error(w, "Failed to export games", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "month"
+	}
+	if !db.ValidTrendPeriod(period) {
+		http.Error(w, fmt.Sprintf("invalid period: %s (must be week, month, quarter or season)", period), http.StatusBadRequest)
+		return
+	}
+
+	window := parseIntDefault(r.URL.Query().Get("window"), db.DefaultTrendWindow)
+	if window < 1 || window > maxTrendWindow {
+		http.Error(w, fmt.Sprintf("window must be between 1 and %d", maxTrendWindow), http.StatusBadRequest)
 		return
 	}
 
-	// Get all games (use total count as limit to get everything)
-	games, err := db.GetAllGameResults(totalCount, 0)
+	trends, err := db.GetTrends(r.Context(), period, window, filter)
 	if err != nil {
-		log.Printf("Failed to get games for export: %v", err)
-		http.Error(w, "Failed to export games", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to get trends", "error", err)
+		http.Error(w, "Failed to retrieve trends", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trends)
+}
+
+func handleGetGoalAnalytics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := parseRequestFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	analytics, err := db.GetGoalAnalytics(r.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get goal analytics", "error", err)
+		http.Error(w, "Failed to retrieve goal analytics", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(analytics)
+}
+
+func handleGetRatings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Convert to CSV
-	csvData, err := export.ExportGamesToCSV(games)
+	ratings, err := db.GetRatings(r.Context(), requestGroup(r))
 	if err != nil {
-		log.Printf("Failed to generate CSV: %v", err)
-		http.Error(w, "Failed to generate CSV", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to get ratings", "error", err)
+		http.Error(w, "Failed to retrieve ratings", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("Exporting %d games as CSV", len(games))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ratings)
+}
 
-	// Set headers for file download
-	w.Header().Set("Content-Type", "text/csv")
-	w.Header().Set("Content-Disposition", "attachment; filename=\"wingspan-games-export.csv\"")
-	w.Write(csvData)
+func handleGetRatingHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract player name from /api/ratings/{player}/history
+	path := strings.TrimPrefix(r.URL.Path, "/api/ratings/")
+	playerName, ok := strings.CutSuffix(path, "/history")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if playerName == "" {
+		http.Error(w, "Player name required", http.StatusBadRequest)
+		return
+	}
+
+	history, err := db.GetRatingHistory(r.Context(), requestGroup(r), playerName)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get rating history", "player", playerName, "error", err)
+		http.Error(w, "Failed to retrieve rating history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+func handleGetAchievements(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(db.AchievementRules)
+}
+
+func handleGetPlayerAchievements(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract player name from /api/players/{player}/achievements
+	path := strings.TrimPrefix(r.URL.Path, "/api/players/")
+	playerName, ok := strings.CutSuffix(path, "/achievements")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if playerName == "" {
+		http.Error(w, "Player name required", http.StatusBadRequest)
+		return
+	}
+
+	achievements, err := db.GetPlayerAchievements(r.Context(), requestGroup(r), playerName)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get achievements", "player", playerName, "error", err)
+		http.Error(w, "Failed to retrieve achievements", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(achievements)
+}
+
+func handleSeasons(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		seasons, err := db.GetSeasons(r.Context(), requestGroup(r))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to get seasons", "error", err)
+			http.Error(w, "Failed to retrieve seasons", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(seasons)
+	case http.MethodPost:
+		var season db.Season
+		if err := json.NewDecoder(r.Body).Decode(&season); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := season.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		season.Group = requestGroup(r)
+		if _, err := db.CreateSeason(r.Context(), &season); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to create season", "error", err)
+			http.Error(w, "Failed to create season", http.StatusInternalServerError)
+			return
+		}
+		slog.InfoContext(r.Context(), "Created season", "season", season.Name, "season_id", season.ID)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(season)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSeasonRoute dispatches /api/seasons/{id} and its standings,
+// leaderboard and stats/{player} sub-routes
+func handleSeasonRoute(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/seasons/")
+	idStr, rest, _ := strings.Cut(path, "/")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid season ID", http.StatusBadRequest)
+		return
+	}
+
+	// Seasons in other groups are hidden
+	if season, err := db.GetSeason(r.Context(), id); err == nil && season.Group != requestGroup(r) {
+		writeSeasonError(w, r, id, db.ErrSeasonNotFound)
+		return
+	}
+
+	switch {
+	case rest == "":
+		handleSeason(w, r, id)
+	case rest == "standings":
+		handleGetSeasonStandings(w, r, id)
+	case rest == "leaderboard":
+		handleGetSeasonLeaderboard(w, r, id)
+	case strings.HasPrefix(rest, "stats/"):
+		handleGetSeasonPlayerStats(w, r, id, strings.TrimPrefix(rest, "stats/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func handleSeason(w http.ResponseWriter, r *http.Request, id int64) {
+	switch r.Method {
+	case http.MethodGet:
+		season, err := db.GetSeason(r.Context(), id)
+		if err != nil {
+			writeSeasonError(w, r, id, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(season)
+	case http.MethodPut:
+		var season db.Season
+		if err := json.NewDecoder(r.Body).Decode(&season); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		season.ID = id
+		if err := season.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := db.UpdateSeason(r.Context(), &season); err != nil {
+			writeSeasonError(w, r, id, err)
+			return
+		}
+
+		updated, err := db.GetSeason(r.Context(), id)
+		if err != nil {
+			writeSeasonError(w, r, id, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updated)
+	case http.MethodDelete:
+		if err := db.DeleteSeason(r.Context(), id); err != nil {
+			writeSeasonError(w, r, id, err)
+			return
+		}
+		slog.InfoContext(r.Context(), "Deleted season", "season_id", id)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Season deleted successfully",
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleGetSeasonStandings(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	standings, err := db.GetSeasonStandings(r.Context(), id)
+	if err != nil {
+		writeSeasonError(w, r, id, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(standings)
+}
+
+func handleGetSeasonLeaderboard(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, ok := seasonFilter(w, r, id)
+	if !ok {
+		return
+	}
+
+	leaderboard, err := db.GetFilteredLeaderboardStats(r.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get season leaderboard", "season_id", id, "error", err)
+		http.Error(w, "Failed to retrieve leaderboard", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(leaderboard)
+}
+
+func handleGetSeasonPlayerStats(w http.ResponseWriter, r *http.Request, id int64, playerName string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if playerName == "" {
+		http.Error(w, "Player name required", http.StatusBadRequest)
+		return
+	}
+
+	filter, ok := seasonFilter(w, r, id)
+	if !ok {
+		return
+	}
+
+	stats, err := db.GetPlayerStats(r.Context(), playerName, filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get season player stats", "season_id", id, "player", playerName, "error", err)
+		http.Error(w, "Failed to retrieve player stats", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// seasonFilter parses the request's game filters, scoped to a season that
+// must exist. It writes the error response and returns false on failure.
+func seasonFilter(w http.ResponseWriter, r *http.Request, id int64) (db.GameFilter, bool) {
+	if _, err := db.GetSeason(r.Context(), id); err != nil {
+		writeSeasonError(w, r, id, err)
+		return db.GameFilter{}, false
+	}
+
+	filter, err := parseRequestFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return filter, false
+	}
+	filter.Season = id
+	return filter, true
+}
+
+// writeSeasonError responds 404 for a missing season and 500 otherwise
+func writeSeasonError(w http.ResponseWriter, r *http.Request, id int64, err error) {
+	if errors.Is(err, db.ErrSeasonNotFound) {
+		http.Error(w, "Season not found", http.StatusNotFound)
+		return
+	}
+	slog.ErrorContext(r.Context(), "Failed to load season", "season_id", id, "error", err)
+	http.Error(w, "Failed to retrieve season", http.StatusInternalServerError)
+}
+
+func handleTournaments(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		tournaments, err := db.GetTournaments(r.Context(), requestGroup(r))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to get tournaments", "error", err)
+			http.Error(w, "Failed to retrieve tournaments", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tournaments)
+	case http.MethodPost:
+		var tournament db.Tournament
+		if err := json.NewDecoder(r.Body).Decode(&tournament); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := tournament.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tournament.Group = requestGroup(r)
+		if _, err := db.CreateTournament(r.Context(), &tournament); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to create tournament", "error", err)
+			http.Error(w, "Failed to create tournament", http.StatusInternalServerError)
+			return
+		}
+		slog.InfoContext(r.Context(), "Created tournament", "tournament", tournament.Name, "tournament_id", tournament.ID)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(tournament)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTournamentRoute dispatches /api/tournaments/{id} and its players,
+// rounds, final, standings and tables/{tableId}/result sub-routes
+func handleTournamentRoute(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/tournaments/")
+	idStr, rest, _ := strings.Cut(path, "/")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid tournament ID", http.StatusBadRequest)
+		return
+	}
+
+	// Tournaments in other groups are hidden
+	if tournament, err := db.GetTournament(r.Context(), id); err == nil && tournament.Group != requestGroup(r) {
+		writeTournamentError(w, r, id, db.ErrTournamentNotFound)
+		return
+	}
+
+	switch {
+	case rest == "":
+		handleTournament(w, r, id)
+	case rest == "players":
+		handleRegisterTournamentPlayer(w, r, id)
+	case strings.HasPrefix(rest, "players/"):
+		handleWithdrawTournamentPlayer(w, r, id, strings.TrimPrefix(rest, "players/"))
+	case rest == "rounds":
+		handleStartTournamentRound(w, r, id)
+	case rest == "final":
+		handleStartTournamentFinal(w, r, id)
+	case rest == "standings":
+		handleGetTournamentStandings(w, r, id)
+	case strings.HasPrefix(rest, "tables/") && strings.HasSuffix(rest, "/result"):
+		tableID, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(rest, "tables/"), "/result"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid table ID", http.StatusBadRequest)
+			return
+		}
+		handleRecordTournamentResult(w, r, id, tableID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func handleTournament(w http.ResponseWriter, r *http.Request, id int64) {
+	switch r.Method {
+	case http.MethodGet:
+		tournament, err := db.GetTournament(r.Context(), id)
+		if err != nil {
+			writeTournamentError(w, r, id, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tournament)
+	case http.MethodDelete:
+		if err := db.DeleteTournament(r.Context(), id); err != nil {
+			writeTournamentError(w, r, id, err)
+			return
+		}
+		slog.InfoContext(r.Context(), "Deleted tournament", "tournament_id", id)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Tournament deleted successfully",
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleRegisterTournamentPlayer(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		PlayerName string `json:"playerName"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(request.PlayerName) == "" {
+		http.Error(w, "Player name required", http.StatusBadRequest)
+		return
+	}
+
+	player, err := db.RegisterTournamentPlayer(r.Context(), id, request.PlayerName)
+	if err != nil {
+		writeTournamentError(w, r, id, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(player)
+}
+
+func handleWithdrawTournamentPlayer(w http.ResponseWriter, r *http.Request, id int64, playerName string) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := db.WithdrawTournamentPlayer(r.Context(), id, playerName); err != nil {
+		writeTournamentError(w, r, id, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Player withdrawn successfully",
+	})
+}
+
+func handleStartTournamentRound(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tables, err := db.StartTournamentRound(r.Context(), id)
+	if err != nil {
+		writeTournamentError(w, r, id, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tables)
+}
+
+func handleStartTournamentFinal(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	table, err := db.StartTournamentFinal(r.Context(), id)
+	if err != nil {
+		writeTournamentError(w, r, id, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(table)
+}
+
+func handleGetTournamentStandings(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	standings, err := db.GetTournamentStandings(r.Context(), id)
+	if err != nil {
+		writeTournamentError(w, r, id, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(standings)
+}
+
+// handleRecordTournamentResult scores a table's game the same way as
+// /api/calculate-game-end and saves it as the table's result
+func handleRecordTournamentResult(w http.ResponseWriter, r *http.Request, id, tableID int64) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Players        []scoring.PlayerGameEnd `json:"players"`
+		IncludeOceania bool                    `json:"includeOceania"`
+		Goals          *db.GameGoals           `json:"goals,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := validateGameGoals(request.Goals); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	players, nectarScoring := scoring.CalculateGameEndScores(request.Players, request.IncludeOceania)
+
+	gameID, err := db.RecordTournamentResult(r.Context(), id, tableID, &db.GameResult{
+		Players:        players,
+		NectarScoring:  &nectarScoring,
+		IncludeOceania: request.IncludeOceania,
+		Goals:          request.Goals,
+	})
+	if err != nil {
+		writeTournamentError(w, r, id, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "Saved tournament table result", "tournament_id", id, "table_id", tableID, "game_id", gameID)
+
+	response := struct {
+		Players       []scoring.PlayerGameEnd `json:"players"`
+		NectarScoring scoring.NectarScoring   `json:"nectarScoring"`
+		GameID        int64                   `json:"gameId"`
+	}{
+		Players:       players,
+		NectarScoring: nectarScoring,
+		GameID:        gameID,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeTournamentError responds 404 for a missing tournament or table, 409
+// for actions out of turn, 400 for mismatched results and 500 otherwise
+func writeTournamentError(w http.ResponseWriter, r *http.Request, id int64, err error) {
+	switch {
+	case errors.Is(err, db.ErrTournamentNotFound):
+		http.Error(w, "Tournament not found", http.StatusNotFound)
+	case errors.Is(err, db.ErrTournamentTableNotFound):
+		http.Error(w, "Table not found", http.StatusNotFound)
+	case errors.Is(err, db.ErrTournamentState):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, db.ErrTournamentResult):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		slog.ErrorContext(r.Context(), "Failed to update tournament", "tournament_id", id, "error", err)
+		http.Error(w, "Failed to process tournament", http.StatusInternalServerError)
+	}
+}
+
+// Helper to parse int with default
+func parseIntDefault(s string, defaultVal int) int {
+	if i, err := strconv.Atoi(s); err == nil {
+		return i
+	}
+	return defaultVal
+}
+
+// parseGameFilter builds a game filter from history/export query parameters:
+// from and to (YYYY-MM-DD, inclusive), player (repeatable), oceania, numPlayers and winner
+func parseGameFilter(q url.Values) (db.GameFilter, error) {
+	var filter db.GameFilter
+
+	if from := q.Get("from"); from != "" {
+		t, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from date: %s", from)
+		}
+		filter.From = t
+	}
+	if to := q.Get("to"); to != "" {
+		t, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to date: %s", to)
+		}
+		// Include the whole "to" day
+		filter.To = t.AddDate(0, 0, 1)
+	}
+
+	for _, name := range q["player"] {
+		if name = strings.TrimSpace(name); name != "" {
+			filter.Players = append(filter.Players, name)
+		}
+	}
+
+	if oceania := q.Get("oceania"); oceania != "" {
+		b, err := strconv.ParseBool(oceania)
+		if err != nil {
+			return filter, fmt.Errorf("invalid oceania value: %s", oceania)
+		}
+		filter.Oceania = &b
+	}
+
+	if numPlayers := q.Get("numPlayers"); numPlayers != "" {
+		n, err := strconv.Atoi(numPlayers)
+		if err != nil || n < 1 {
+			return filter, fmt.Errorf("invalid numPlayers value: %s", numPlayers)
+		}
+		filter.NumPlayers = n
+	}
+
+	filter.Winner = strings.TrimSpace(q.Get("winner"))
+
+	if season := q.Get("season"); season != "" {
+		id, err := strconv.ParseInt(season, 10, 64)
+		if err != nil || id < 1 {
+			return filter, fmt.Errorf("invalid season value: %s", season)
+		}
+		filter.Season = id
+	}
+
+	return filter, nil
+}
+
+// parseRequestFilter parses the request's game filter, scoped to its group
+func parseRequestFilter(r *http.Request) (db.GameFilter, error) {
+	filter, err := parseGameFilter(r.URL.Query())
+	filter.Group = requestGroup(r)
+	return filter, err
+}
+
+var (
+	imports = metrics.NewCounter("wingspan_imports_total",
+		"Import requests, by file format and result.", "format", "result")
+	importedGames = metrics.NewCounter("wingspan_imported_games_total",
+		"Games saved by imports, by file format.", "format")
+	exports = metrics.NewCounter("wingspan_exports_total",
+		"Export requests, by file format and result.", "format", "result")
+	exportedGames = metrics.NewCounter("wingspan_exported_games_total",
+		"Games written by exports, by file format.", "format")
+)
+
+// countImport records an import's result in the import metrics
+func countImport(result *importgames.ImportResult, err error) {
+	format := result.Format
+	if format == "" {
+		format = "unknown"
+	}
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	imports.Inc(format, outcome)
+	importedGames.Add(float64(result.GamesImported), format)
+}
+
+func handleImportGames(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse multipart form (max 10MB)
+	err := r.ParseMultipartForm(10 << 20)
+	if err != nil {
+		http.Error(w, "Failed to parse form data", http.StatusBadRequest)
+		return
+	}
+
+	// Get the file from form data
+	file, header, err := r.FormFile("csvFile")
+	if err != nil {
+		http.Error(w, "Failed to read file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	logger := slog.With("file", header.Filename)
+	logger.InfoContext(r.Context(), "Processing import file", "bytes", header.Size)
+
+	// Import games, detecting the source format unless one is given
+	result, err := importgames.ImportIntoGroup(r.Context(), file, requestGroup(r), r.FormValue("format"), r.FormValue("lenient") == "true")
+	countImport(result, err)
+	if err != nil {
+		logger.WarnContext(r.Context(), "Import failed", "format", result.Format, "games", result.GamesImported, "error", err)
+
+		// Return error details
+		response := struct {
+			Success         bool                      `json:"success"`
+			Message         string                    `json:"message"`
+			Format          string                    `json:"format"`
+			GamesImported   int                       `json:"gamesImported"`
+			UnmappedColumns []string                  `json:"unmappedColumns"`
+			Errors          []importgames.ImportError `json:"errors"`
+			Warnings        []importgames.ImportError `json:"warnings"`
+		}{
+			Success:         false,
+			Message:         err.Error(),
+			Format:          result.Format,
+			GamesImported:   result.GamesImported,
+			UnmappedColumns: result.Unmapped,
+			Errors:          result.Errors,
+			Warnings:        result.Warnings,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	logger.InfoContext(r.Context(), "Imported games", "format", result.Format, "games", result.GamesImported)
+
+	// Return success response
+	response := struct {
+		Success         bool                      `json:"success"`
+		Message         string                    `json:"message"`
+		Format          string                    `json:"format"`
+		GamesImported   int                       `json:"gamesImported"`
+		UnmappedColumns []string                  `json:"unmappedColumns"`
+		Errors          []importgames.ImportError `json:"errors"`
+		Warnings        []importgames.ImportError `json:"warnings"`
+	}{
+		Success:         true,
+		Message:         "Import completed successfully",
+		Format:          result.Format,
+		GamesImported:   result.GamesImported,
+		UnmappedColumns: result.Unmapped,
+		Errors:          result.Errors,
+		Warnings:        result.Warnings,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func handleExportGames(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := parseRequestFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	shape, err := export.ParseRowShape(r.URL.Query().Get("shape"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	exporter, err := export.NewExporter(format, shape)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Stream games straight from the database instead of loading them all
+	games, err := db.IterateGameResults(r.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to query games for export", "error", err)
+		http.Error(w, "Failed to export games", http.StatusInternalServerError)
+		return
+	}
+	defer games.Close()
+
+	useGzip := r.URL.Query().Get("gzip") == "true"
+
+	// Set headers for file download. No Content-Length is set, so the
+	// response is sent with chunked transfer encoding.
+	filename := "wingspan-games-export." + exporter.FileExtension()
+	if useGzip {
+		filename += ".gz"
+		w.Header().Set("Content-Type", "application/gzip")
+	} else {
+		w.Header().Set("Content-Type", exporter.ContentType())
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+
+	var out io.Writer = &flushWriter{w: w, rc: http.NewResponseController(w)}
+	var gz *gzip.Writer
+	if useGzip {
+		gz = gzip.NewWriter(out)
+		out = gz
+	}
+
+	_, span := startStage(r.Context(), "export.write",
+		attribute.String("export.format", exporter.FileExtension()), attribute.Bool("export.gzip", useGzip))
+	count, err := exporter.Export(out, games)
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+	span.SetAttributes(attribute.Int("export.games", count))
+	endStage(span, err)
+	exportedGames.Add(float64(count), exporter.FileExtension())
+	if err != nil {
+		exports.Inc(exporter.FileExtension(), "failure")
+		// Headers are already sent, so the client sees a truncated file
+		slog.ErrorContext(r.Context(), "Failed to stream export", "format", exporter.FileExtension(), "games", count, "error", err)
+		return
+	}
+
+	exports.Inc(exporter.FileExtension(), "success")
+	slog.InfoContext(r.Context(), "Exported games", "file", filename, "games", count)
+}
+
+// exportChunkTimeout is how long each chunk of a streamed export has to
+// reach the client. It replaces the server's write timeout, which would cut
+// off exports of a long history part way through.
+const exportChunkTimeout = time.Minute
+
+// flushWriter flushes the response after every write so streamed exports
+// reach the client in chunks instead of accumulating in server buffers
+type flushWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	// Best-effort too, as not every writer has a deadline
+	_ = fw.rc.SetWriteDeadline(time.Now().Add(exportChunkTimeout))
+	n, err := fw.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	// Flushing is best-effort; writers that can't flush still get the data
+	_ = fw.rc.Flush()
+	return n, nil
 }
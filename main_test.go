@@ -2,7 +2,11 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -11,6 +15,8 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
+	"wingspan-scoring/config"
 	"wingspan-scoring/db"
 	"wingspan-scoring/goals"
 	"wingspan-scoring/scoring"
@@ -28,13 +34,11 @@ func setupTestDB(t *testing.T) func() {
 	tmpDir, err := os.MkdirTemp("", "wingspan-test-*")
 	require.NoError(t, err)
 
-	// Set custom DB_PATH
+	// Use a custom path
 	customPath := filepath.Join(tmpDir, "test.db")
-	originalDBPath := os.Getenv("DB_PATH")
-	os.Setenv("DB_PATH", customPath)
 
 	// Initialize database
-	err = db.Initialize()
+	err = db.Initialize(customPath)
 	require.NoError(t, err)
 	require.NotNil(t, db.DB)
 
@@ -42,7 +46,6 @@ func setupTestDB(t *testing.T) func() {
 	return func() {
 		db.Close()
 		db.DB = originalDB
-		os.Setenv("DB_PATH", originalDBPath)
 		os.RemoveAll(tmpDir)
 	}
 }
@@ -413,6 +416,29 @@ func TestHandleCalculateGameEnd_ValidRequest(t *testing.T) {
 	assert.GreaterOrEqual(t, result.Players[1].Rank, 1)
 }
 
+// TestHandleCalculateGameEnd_SaveFails tests that a game that can't be saved
+// fails the request rather than reporting it recorded
+func TestHandleCalculateGameEnd_SaveFails(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+	require.NoError(t, db.Close())
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"players": []map[string]interface{}{
+			{"playerName": "Alice", "birdPoints": 50},
+			{"playerName": "Bob", "birdPoints": 45},
+		},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/calculate-game-end", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handleCalculateGameEnd(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "gameId")
+}
+
 // TestHandleCalculateGameEnd_InvalidJSON tests error handling
 func TestHandleCalculateGameEnd_InvalidJSON(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/api/calculate-game-end", strings.NewReader("invalid"))
@@ -445,7 +471,7 @@ func TestHandleGetGames_ValidRequest(t *testing.T) {
 			{PlayerName: "Alice", Total: 100, Rank: 1},
 		}
 		nectarScoring := scoring.NectarScoring{}
-		_, err := db.SaveGameResult(players, nectarScoring, false)
+		_, err := db.SaveGameResult(t.Context(), players, nectarScoring, false)
 		require.NoError(t, err)
 	}
 
@@ -492,7 +518,7 @@ func TestHandleGetGame_ValidID(t *testing.T) {
 		{PlayerName: "Alice", Total: 100, Rank: 1},
 	}
 	nectarScoring := scoring.NectarScoring{}
-	id, err := db.SaveGameResult(players, nectarScoring, false)
+	id, err := db.SaveGameResult(t.Context(), players, nectarScoring, false)
 	require.NoError(t, err)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/games/"+strconv.FormatInt(id, 10), nil)
@@ -544,7 +570,7 @@ func TestHandleDeleteGame_ValidID(t *testing.T) {
 		{PlayerName: "Alice", Total: 100, Rank: 1},
 	}
 	nectarScoring := scoring.NectarScoring{}
-	id, err := db.SaveGameResult(players, nectarScoring, false)
+	id, err := db.SaveGameResult(t.Context(), players, nectarScoring, false)
 	require.NoError(t, err)
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/games/"+strconv.FormatInt(id, 10), nil)
@@ -561,7 +587,7 @@ func TestHandleDeleteGame_ValidID(t *testing.T) {
 	assert.True(t, result["success"].(bool))
 
 	// Verify game was actually deleted
-	_, err = db.GetGameResult(id)
+	_, err = db.GetGameResult(t.Context(), id)
 	assert.Error(t, err)
 }
 
@@ -594,7 +620,7 @@ func TestHandleGetPlayerStats_ValidPlayer(t *testing.T) {
 
 	for _, players := range games {
 		nectarScoring := scoring.NectarScoring{}
-		_, err := db.SaveGameResult(players, nectarScoring, false)
+		_, err := db.SaveGameResult(t.Context(), players, nectarScoring, false)
 		require.NoError(t, err)
 	}
 
@@ -615,6 +641,86 @@ func TestHandleGetPlayerStats_ValidPlayer(t *testing.T) {
 	assert.Equal(t, float64(1), result["wins"])
 }
 
+// TestHandleGetPlayerStats_DateFilter tests GET /api/stats/{playerName} with a date range
+func TestHandleGetPlayerStats_DateFilter(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	for i, date := range []string{"2024-01-10", "2024-02-10"} {
+		players := []scoring.PlayerGameEnd{
+			{PlayerName: "Alice", Total: 100 - i, Rank: 1},
+			{PlayerName: "Bob", Total: 90, Rank: 2},
+		}
+		id, err := db.SaveGameResult(t.Context(), players, scoring.NectarScoring{}, false)
+		require.NoError(t, err)
+		_, err = db.DB.Exec("UPDATE game_results SET created_at = ? WHERE id = ?", date+" 12:00:00", id)
+		require.NoError(t, err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/Alice?from=2024-02-01", nil)
+	w := httptest.NewRecorder()
+
+	handleGetPlayerStats(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result db.PlayerStats
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+	assert.Equal(t, 1, result.GamesPlayed)
+	assert.Equal(t, 99.0, result.AverageScore)
+	assert.Equal(t, 1, result.ByPlayerCount[2].Wins)
+	assert.Contains(t, result.Categories, "birdPoints")
+
+	req = httptest.NewRequest(http.MethodGet, "/api/stats/Alice?from=yesterday", nil)
+	w = httptest.NewRecorder()
+
+	handleGetPlayerStats(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// TestHandleStatsRoute_Profile tests GET /api/stats/{playerName}/profile
+func TestHandleStatsRoute_Profile(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	players := []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", BirdPoints: 20, Eggs: 20, Total: 40, Rank: 1},
+		{PlayerName: "Bob", BirdPoints: 36, Eggs: 4, Total: 40, Rank: 2},
+	}
+	_, err := db.SaveGameResult(t.Context(), players, scoring.NectarScoring{}, false)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/Alice/profile", nil)
+	w := httptest.NewRecorder()
+
+	handleStatsRoute(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var profile db.PlayerProfile
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&profile))
+	assert.Equal(t, "Alice", profile.PlayerName)
+	assert.Equal(t, "egg engine", profile.Strategy)
+	assert.Equal(t, 50.0, profile.Categories["eggs"].Share)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/stats/Alice", nil)
+	w = httptest.NewRecorder()
+
+	handleStatsRoute(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var stats db.PlayerStats
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&stats))
+	assert.Equal(t, 1, stats.GamesPlayed)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/stats//profile", nil)
+	w = httptest.NewRecorder()
+
+	handleStatsRoute(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
 // TestHandleGetPlayerStats_EmptyName tests error for empty player name
 func TestHandleGetPlayerStats_EmptyName(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/api/stats/", nil)
@@ -635,6 +741,686 @@ func TestHandleGetPlayerStats_InvalidMethod(t *testing.T) {
 	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
 }
 
+// TestHandleGetRatings tests GET /api/ratings and /api/ratings/{player}/history
+func TestHandleGetRatings(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	players := []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Total: 100, Rank: 1},
+		{PlayerName: "Bob", Total: 90, Rank: 2},
+	}
+	_, err := db.SaveGameResult(t.Context(), players, scoring.NectarScoring{}, false)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/ratings", nil)
+	w := httptest.NewRecorder()
+
+	handleGetRatings(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var ratings []db.PlayerRating
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&ratings))
+	require.Len(t, ratings, 2)
+	assert.Equal(t, "Alice", ratings[0].PlayerName)
+	assert.Greater(t, ratings[0].Rating, db.InitialRating)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/ratings/Bob/history", nil)
+	w = httptest.NewRecorder()
+
+	handleGetRatingHistory(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var history []db.RatingChange
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&history))
+	require.Len(t, history, 1)
+	assert.Less(t, history[0].Change, 0.0)
+}
+
+// TestHandleGetRatingHistory_InvalidPath tests malformed rating history paths
+func TestHandleGetRatingHistory_InvalidPath(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/ratings/Bob", nil)
+	w := httptest.NewRecorder()
+	handleGetRatingHistory(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/ratings//history", nil)
+	w = httptest.NewRecorder()
+	handleGetRatingHistory(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/ratings", nil)
+	w = httptest.NewRecorder()
+	handleGetRatings(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+// TestHandleGetHeadToHead tests GET /api/stats/head-to-head
+func TestHandleGetHeadToHead(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	players := []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Total: 100, Rank: 1},
+		{PlayerName: "Bob", Total: 90, Rank: 2},
+	}
+	_, err := db.SaveGameResult(t.Context(), players, scoring.NectarScoring{}, false)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/head-to-head?a=Alice&b=Bob", nil)
+	w := httptest.NewRecorder()
+
+	handleGetHeadToHead(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var h2h db.HeadToHead
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&h2h))
+	assert.Equal(t, 1, h2h.GamesTogether)
+	assert.Equal(t, 1, h2h.AAhead)
+	assert.Equal(t, 10.0, h2h.AverageMargin)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/stats/head-to-head/matrix", nil)
+	w = httptest.NewRecorder()
+
+	handleGetHeadToHeadMatrix(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var matrix db.HeadToHeadMatrix
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&matrix))
+	assert.Equal(t, []string{"Alice", "Bob"}, matrix.Players)
+	assert.Equal(t, [][]int{{0, 1}, {0, 0}}, matrix.Ahead)
+}
+
+// TestHandleGetHeadToHead_InvalidParams tests missing or identical players are rejected
+func TestHandleGetHeadToHead_InvalidParams(t *testing.T) {
+	for _, query := range []string{"", "a=Alice", "b=Bob", "a=Alice&b=Alice", "a=Alice&b=Bob&from=bad"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/stats/head-to-head?"+query, nil)
+		w := httptest.NewRecorder()
+
+		handleGetHeadToHead(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, "query %s", query)
+	}
+}
+
+// TestHandleGetRecords tests GET /api/records
+func TestHandleGetRecords(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	players := []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Total: 100, Rank: 1},
+		{PlayerName: "Bob", Total: 90, Rank: 2},
+	}
+	id, err := db.SaveGameResult(t.Context(), players, scoring.NectarScoring{}, false)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/records?numPlayers=2&limit=3", nil)
+	w := httptest.NewRecorder()
+
+	handleGetRecords(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var book db.RecordBook
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&book))
+	require.Len(t, book.Categories["totalScore"], 2)
+	assert.Equal(t, id, book.Categories["totalScore"][0].GameID)
+	require.Len(t, book.ClosestGame, 1)
+	assert.Equal(t, 10, book.ClosestGame[0].Value)
+
+	for _, query := range []string{"limit=0", "limit=500", "oceania=maybe"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/records?"+query, nil)
+		w := httptest.NewRecorder()
+
+		handleGetRecords(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, "query %s", query)
+	}
+}
+
+// TestHandleGetTrends tests GET /api/analytics/trends
+func TestHandleGetTrends(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	players := []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Total: 100, Rank: 1},
+		{PlayerName: "Bob", Total: 90, Rank: 2},
+	}
+	_, err := db.SaveGameResult(t.Context(), players, scoring.NectarScoring{}, false)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/analytics/trends?player=Bob", nil)
+	w := httptest.NewRecorder()
+
+	handleGetTrends(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var trends db.Trends
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&trends))
+	assert.Equal(t, "month", trends.Period)
+	require.Len(t, trends.Group.Points, 1)
+	assert.Equal(t, 95.0, trends.Group.Points[0].Averages["totalScore"])
+	require.Len(t, trends.Players, 1)
+	assert.Equal(t, 90.0, trends.Players[0].Points[0].Averages["totalScore"])
+
+	for _, query := range []string{"period=day", "window=0", "window=100", "from=bad"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/analytics/trends?"+query, nil)
+		w := httptest.NewRecorder()
+
+		handleGetTrends(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, "query %s", query)
+	}
+}
+
+// TestHandleCalculateGameEnd_Goals tests that goals sent with a game are saved
+func TestHandleCalculateGameEnd_Goals(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	body := `{"players":[{"playerName":"Alice","birdPoints":10},{"playerName":"Bob","birdPoints":5}],` +
+		`"goals":{"side":"blue","rounds":["base-birds-forest","","",""]}}`
+	req := httptest.NewRequest(http.MethodPost, "/api/calculate-game-end", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handleCalculateGameEnd(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	games, err := db.GetAllGameResults(t.Context(), 0, 0)
+	require.NoError(t, err)
+	require.Len(t, games, 1)
+	require.NotNil(t, games[0].Goals)
+	assert.Equal(t, "blue", games[0].Goals.Side)
+	assert.Equal(t, "base-birds-forest", games[0].Goals.Rounds[0])
+
+	req = httptest.NewRequest(http.MethodPost, "/api/calculate-game-end",
+		strings.NewReader(`{"players":[{"playerName":"Alice"}],"goals":{"side":"red"}}`))
+	w = httptest.NewRecorder()
+
+	handleCalculateGameEnd(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Goal IDs must be known goals
+	req = httptest.NewRequest(http.MethodPost, "/api/calculate-game-end",
+		strings.NewReader(`{"players":[{"playerName":"Alice"}],"goals":{"side":"green","rounds":["","made-up","",""]}}`))
+	w = httptest.NewRecorder()
+
+	handleCalculateGameEnd(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "round 2")
+	games, err = db.GetAllGameResults(t.Context(), 0, 0)
+	require.NoError(t, err)
+	assert.Len(t, games, 1)
+}
+
+// TestHandleGetGoalAnalytics tests GET /api/analytics/goals
+func TestHandleGetGoalAnalytics(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	_, err := db.SaveGame(t.Context(), &db.GameResult{
+		Players: []scoring.PlayerGameEnd{
+			{PlayerName: "Alice", Total: 100, Rank: 1, RoundGoalsBreakdown: &scoring.RoundGoalBreakdown{Round1: 5}},
+			{PlayerName: "Bob", Total: 90, Rank: 2, RoundGoalsBreakdown: &scoring.RoundGoalBreakdown{Round1: 1}},
+		},
+		Goals: &db.GameGoals{Side: "blue", Rounds: [4]string{"base-birds-forest", "", "", ""}},
+	})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/analytics/goals", nil)
+	w := httptest.NewRecorder()
+
+	handleGetGoalAnalytics(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var analytics []db.GoalStats
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&analytics))
+	require.Len(t, analytics, 1)
+	assert.Equal(t, "base-birds-forest", analytics[0].GoalID)
+	require.Len(t, analytics[0].Rounds, 1)
+	assert.Equal(t, 3.0, analytics[0].Rounds[0].AveragePoints)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/analytics/goals?from=bad", nil)
+	w = httptest.NewRecorder()
+
+	handleGetGoalAnalytics(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// TestHandleSeasons tests creating, listing, updating and deleting seasons
+func TestHandleSeasons(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	body := `{"name":"Spring","startDate":"2024-01-01","endDate":"2024-03-31","placementPoints":[5,3,1]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/seasons", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handleSeasons(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var season db.Season
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&season))
+	assert.NotZero(t, season.ID)
+	assert.Equal(t, []int{5, 3, 1}, season.PlacementPoints)
+	id := strconv.FormatInt(season.ID, 10)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/seasons", strings.NewReader(`{"name":"Bad","startDate":"2024-02-01","endDate":"2024-01-01"}`))
+	w = httptest.NewRecorder()
+	handleSeasons(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/seasons", nil)
+	w = httptest.NewRecorder()
+	handleSeasons(w, req)
+	var seasons []db.Season
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&seasons))
+	assert.Len(t, seasons, 1)
+
+	req = httptest.NewRequest(http.MethodPut, "/api/seasons/"+id, strings.NewReader(`{"name":"Summer","startDate":"2024-06-01","endDate":"2024-08-31"}`))
+	w = httptest.NewRecorder()
+	handleSeasonRoute(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&season))
+	assert.Equal(t, "Summer", season.Name)
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/seasons/"+id, nil)
+	w = httptest.NewRecorder()
+	handleSeasonRoute(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	for _, path := range []string{"/api/seasons/" + id, "/api/seasons/" + id + "/standings", "/api/seasons/" + id + "/leaderboard"} {
+		req = httptest.NewRequest(http.MethodGet, path, nil)
+		w = httptest.NewRecorder()
+		handleSeasonRoute(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/seasons/abc", nil)
+	w = httptest.NewRecorder()
+	handleSeasonRoute(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// TestHandleSeasonStandingsAndScopedStats tests standings, the season leaderboard and season stats
+func TestHandleSeasonStandingsAndScopedStats(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	seasonID, err := db.CreateSeason(t.Context(), &db.Season{Name: "Now", StartDate: "2000-01-01", EndDate: "2999-12-31"})
+	require.NoError(t, err)
+	otherID, err := db.CreateSeason(t.Context(), &db.Season{Name: "Past", StartDate: "1990-01-01", EndDate: "1990-12-31"})
+	require.NoError(t, err)
+	id := strconv.FormatInt(seasonID, 10)
+
+	_, err = db.SaveGameResult(t.Context(), []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Total: 100, Rank: 1},
+		{PlayerName: "Bob", Total: 90, Rank: 2},
+	}, scoring.NectarScoring{}, false)
+	require.NoError(t, err)
+
+	// Tagging moves this game out of the current season
+	gameID, err := db.SaveGameResult(t.Context(), []scoring.PlayerGameEnd{
+		{PlayerName: "Bob", Total: 150, Rank: 1},
+		{PlayerName: "Alice", Total: 80, Rank: 2},
+	}, scoring.NectarScoring{}, false)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/games/%d/season", gameID),
+		strings.NewReader(fmt.Sprintf(`{"seasonId":%d}`, otherID)))
+	w := httptest.NewRecorder()
+	handleGameRoute(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/seasons/"+id+"/standings", nil)
+	w = httptest.NewRecorder()
+	handleSeasonRoute(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var standings db.SeasonStandings
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&standings))
+	assert.Equal(t, 1, standings.Games)
+	require.Len(t, standings.Standings, 2)
+	assert.Equal(t, "Alice", standings.Standings[0].PlayerName)
+	assert.Equal(t, 3, standings.Standings[0].Points)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/seasons/"+id+"/leaderboard", nil)
+	w = httptest.NewRecorder()
+	handleSeasonRoute(w, req)
+	var leaderboard db.LeaderboardStats
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&leaderboard))
+	assert.Equal(t, "Alice", leaderboard.TotalScore.PlayerName)
+	assert.Equal(t, 100, leaderboard.TotalScore.Score)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/seasons/"+id+"/stats/Bob", nil)
+	w = httptest.NewRecorder()
+	handleSeasonRoute(w, req)
+	var stats db.PlayerStats
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&stats))
+	assert.Equal(t, 1, stats.GamesPlayed)
+	assert.Equal(t, 90.0, stats.AverageScore)
+
+	// The season filter also works on the general endpoints
+	req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/leaderboard?season=%d", otherID), nil)
+	w = httptest.NewRecorder()
+	handleGetLeaderboard(w, req)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&leaderboard))
+	assert.Equal(t, "Bob", leaderboard.TotalScore.PlayerName)
+
+	req = httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/games/%d/season", gameID), strings.NewReader(`{"seasonId":999}`))
+	w = httptest.NewRecorder()
+	handleGameRoute(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// TestHandleTournaments tests running a tournament through the API
+func TestHandleTournaments(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		if path == "/api/tournaments" {
+			handleTournaments(w, req)
+		} else {
+			handleTournamentRoute(w, req)
+		}
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/tournaments", `{"name":"Open","tableSize":3}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var tournament db.Tournament
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&tournament))
+	base := fmt.Sprintf("/api/tournaments/%d", tournament.ID)
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/tournaments", `{"name":"Bad","pairing":"knockout"}`).Code)
+
+	for _, name := range []string{"Alice", "Bob", "Carol"} {
+		assert.Equal(t, http.StatusCreated, do(http.MethodPost, base+"/players", `{"playerName":"`+name+`"}`).Code)
+	}
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, base+"/players", `{"playerName":"Alice"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, base+"/players", `{"playerName":""}`).Code)
+
+	w = do(http.MethodPost, base+"/rounds", "")
+	require.Equal(t, http.StatusCreated, w.Code)
+	var tables []db.TournamentTable
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&tables))
+	require.Len(t, tables, 1)
+	resultPath := fmt.Sprintf("%s/tables/%d/result", base, tables[0].ID)
+
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, base+"/final", "").Code, "round 1 has no result yet")
+
+	// Scores are ranked by the scoring package
+	body := `{"players":[{"playerName":"Alice","birdPoints":10},{"playerName":"Bob","birdPoints":30},{"playerName":"Carol","birdPoints":20}]}`
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, resultPath, `{"players":[{"playerName":"Dave"}]}`).Code)
+	w = do(http.MethodPost, resultPath, body)
+	require.Equal(t, http.StatusOK, w.Code)
+	var result struct {
+		Players []scoring.PlayerGameEnd `json:"players"`
+		GameID  int64                   `json:"gameId"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+	assert.NotZero(t, result.GameID)
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, resultPath, body).Code)
+
+	w = do(http.MethodGet, base+"/standings", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var standings []db.TournamentStanding
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&standings))
+	require.Len(t, standings, 3)
+	assert.Equal(t, "Bob", standings[0].PlayerName)
+	assert.Equal(t, 5, standings[0].Points)
+
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, base+"/final", "").Code)
+
+	w = do(http.MethodGet, base, "")
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&tournament))
+	assert.Equal(t, db.TournamentFinal, tournament.Status)
+	assert.Len(t, tournament.Tables, 2)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, base, "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, base+"/standings", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/api/tournaments/abc", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, base+"/unknown", "").Code)
+}
+
+// TestHandleGetPlayerAchievements tests GET /api/players/{player}/achievements
+func TestHandleGetPlayerAchievements(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	players := []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", BonusCards: 3, Total: 110, Rank: 1},
+		{PlayerName: "Bob", Total: 90, Rank: 2},
+	}
+	gameID, err := db.SaveGameResult(t.Context(), players, scoring.NectarScoring{}, false)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/players/Alice/achievements", nil)
+	w := httptest.NewRecorder()
+
+	handleGetPlayerAchievements(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var achievements []db.PlayerAchievement
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&achievements))
+	require.Len(t, achievements, 2)
+	assert.Equal(t, "first-win", achievements[0].AchievementID)
+	assert.Equal(t, "century", achievements[1].AchievementID)
+	assert.Equal(t, gameID, achievements[1].GameID)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/players/Alice", nil)
+	w = httptest.NewRecorder()
+	handleGetPlayerAchievements(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/achievements", nil)
+	w = httptest.NewRecorder()
+	handleGetAchievements(w, req)
+	var rules []db.AchievementRule
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&rules))
+	assert.Equal(t, db.AchievementRules, rules)
+}
+
+// newTestServer returns the app's routes, with the default settings,
+// behind the group middleware
+func newTestServer() http.Handler {
+	srv := &server{config: config.Default()}
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	return groupMiddleware(mux)
+}
+
+// newConfiguredServer builds the server from settings given as environment variables
+func newConfiguredServer(t *testing.T, env map[string]string) *server {
+	t.Helper()
+	cfg, _, err := config.Load(nil, func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	})
+	require.NoError(t, err)
+	srv, err := newServer(context.Background(), cfg)
+	require.NoError(t, err)
+	return srv
+}
+
+// TestFeatureToggles tests that turned-off features have no routes or page controls
+func TestFeatureToggles(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	handler := newConfiguredServer(t, map[string]string{
+		"ANONYMOUS_ROLE":      db.RoleAdmin,
+		"FEATURE_IMPORT":      "false",
+		"FEATURE_TOURNAMENTS": "false",
+	}).handler()
+
+	for path, want := range map[string]int{
+		"/api/import":      http.StatusNotFound,
+		"/api/tournaments": http.StatusNotFound,
+		"/api/export":      http.StatusOK,
+		"/api/seasons":     http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, want, w.Code, path)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/history", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), `id="importForm"`)
+	assert.Contains(t, w.Body.String(), `id="exportGamesBtn"`)
+}
+
+// serveJSON sends a request to handler and decodes a successful JSON response into v
+func serveJSON(t *testing.T, handler http.Handler, method, path, body string, v interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if v != nil && w.Code < 300 {
+		require.NoError(t, json.NewDecoder(w.Body).Decode(v))
+	}
+	return w.Code
+}
+
+// TestHandleGroups tests creating and listing groups
+func TestHandleGroups(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	server := newTestServer()
+
+	var group db.Group
+	assert.Equal(t, http.StatusCreated, serveJSON(t, server, http.MethodPost, "/api/groups", `{"slug":"club","name":"Bird Club"}`, &group))
+	assert.Equal(t, "club", group.Slug)
+	assert.Equal(t, "Bird Club", group.Name)
+
+	assert.Equal(t, http.StatusConflict, serveJSON(t, server, http.MethodPost, "/api/groups", `{"slug":"club","name":"Again"}`, nil))
+	assert.Equal(t, http.StatusBadRequest, serveJSON(t, server, http.MethodPost, "/api/groups", `{"slug":"Bad Slug","name":"Bad"}`, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, serveJSON(t, server, http.MethodDelete, "/api/groups", "", nil))
+
+	var groups []db.Group
+	assert.Equal(t, http.StatusOK, serveJSON(t, server, http.MethodGet, "/api/groups", "", &groups))
+	require.Len(t, groups, 2)
+	assert.Equal(t, "club", groups[0].Slug)
+	assert.Equal(t, db.DefaultGroup, groups[1].Slug)
+}
+
+// TestGroupIsolation tests that a group's API never returns or changes
+// another group's data
+func TestGroupIsolation(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	require.NoError(t, db.CreateGroup(t.Context(), &db.Group{Slug: "club", Name: "Club"}))
+	server := newTestServer()
+
+	game := func(winner, loser string, score int) string {
+		return fmt.Sprintf(`{"players":[{"playerName":%q,"birdPoints":%d},{"playerName":%q,"birdPoints":10}]}`, winner, score, loser)
+	}
+	var saved struct {
+		GameID int64 `json:"gameId"`
+	}
+	require.Equal(t, http.StatusOK, serveJSON(t, server, http.MethodPost, "/api/calculate-game-end", game("Alice", "Bob", 40), &saved))
+	defaultGame := saved.GameID
+	require.Equal(t, http.StatusOK, serveJSON(t, server, http.MethodPost, "/g/club/api/calculate-game-end", game("Carol", "Dan", 70), &saved))
+	clubGame := saved.GameID
+
+	// Game lists and counts
+	var games struct {
+		Games      []db.GameResult `json:"games"`
+		TotalCount int             `json:"totalCount"`
+	}
+	serveJSON(t, server, http.MethodGet, "/api/games", "", &games)
+	require.Len(t, games.Games, 1)
+	assert.Equal(t, defaultGame, games.Games[0].ID)
+	serveJSON(t, server, http.MethodGet, "/g/club/api/games", "", &games)
+	require.Len(t, games.Games, 1)
+	assert.Equal(t, clubGame, games.Games[0].ID)
+	assert.Equal(t, 1, games.TotalCount)
+
+	// The header selects a group too
+	req := httptest.NewRequest(http.MethodGet, "/api/games/"+strconv.FormatInt(clubGame, 10), nil)
+	req.Header.Set(groupHeader, "club")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Single games can't be read, tagged or deleted from another group
+	clubGamePath := "/api/games/" + strconv.FormatInt(clubGame, 10)
+	assert.Equal(t, http.StatusNotFound, serveJSON(t, server, http.MethodGet, clubGamePath, "", nil))
+	assert.Equal(t, http.StatusNotFound, serveJSON(t, server, http.MethodDelete, clubGamePath, "", nil))
+	assert.Equal(t, http.StatusNotFound, serveJSON(t, server, http.MethodPut, clubGamePath+"/season", `{"seasonId":null}`, nil))
+	assert.Equal(t, http.StatusOK, serveJSON(t, server, http.MethodGet, "/g/club"+clubGamePath, "", nil))
+
+	// Leaderboards, stats and ratings
+	var leaderboard db.LeaderboardStats
+	serveJSON(t, server, http.MethodGet, "/api/leaderboard", "", &leaderboard)
+	assert.Equal(t, "Alice", leaderboard.TotalScore.PlayerName)
+	serveJSON(t, server, http.MethodGet, "/g/club/api/leaderboard", "", &leaderboard)
+	assert.Equal(t, "Carol", leaderboard.TotalScore.PlayerName)
+
+	var stats db.PlayerStats
+	serveJSON(t, server, http.MethodGet, "/api/stats/Carol", "", &stats)
+	assert.Equal(t, 0, stats.GamesPlayed)
+	serveJSON(t, server, http.MethodGet, "/g/club/api/stats/Carol", "", &stats)
+	assert.Equal(t, 1, stats.GamesPlayed)
+
+	var ratings []db.PlayerRating
+	serveJSON(t, server, http.MethodGet, "/g/club/api/ratings", "", &ratings)
+	require.Len(t, ratings, 2)
+	for _, r := range ratings {
+		assert.Contains(t, []string{"Carol", "Dan"}, r.PlayerName)
+	}
+
+	var achievements []db.PlayerAchievement
+	serveJSON(t, server, http.MethodGet, "/api/players/Carol/achievements", "", &achievements)
+	assert.Empty(t, achievements)
+
+	// Seasons and tournaments
+	var season db.Season
+	require.Equal(t, http.StatusCreated, serveJSON(t, server, http.MethodPost, "/g/club/api/seasons",
+		`{"name":"Club League","startDate":"2000-01-01","endDate":"2100-12-31"}`, &season))
+	seasonPath := "/api/seasons/" + strconv.FormatInt(season.ID, 10)
+	var seasons []db.Season
+	serveJSON(t, server, http.MethodGet, "/api/seasons", "", &seasons)
+	assert.Empty(t, seasons)
+	assert.Equal(t, http.StatusNotFound, serveJSON(t, server, http.MethodGet, seasonPath+"/standings", "", nil))
+	assert.Equal(t, http.StatusNotFound, serveJSON(t, server, http.MethodDelete, seasonPath, "", nil))
+	assert.Equal(t, http.StatusBadRequest, serveJSON(t, server, http.MethodPost, "/api/calculate-game-end",
+		fmt.Sprintf(`{"players":[{"playerName":"Alice","birdPoints":5}],"seasonId":%d}`, season.ID), nil))
+
+	var standings db.SeasonStandings
+	serveJSON(t, server, http.MethodGet, "/g/club"+seasonPath+"/standings", "", &standings)
+	assert.Equal(t, 1, standings.Games)
+
+	var tournament db.Tournament
+	require.Equal(t, http.StatusCreated, serveJSON(t, server, http.MethodPost, "/g/club/api/tournaments", `{"name":"Club Cup"}`, &tournament))
+	tournamentPath := "/api/tournaments/" + strconv.FormatInt(tournament.ID, 10)
+	var tournaments []db.Tournament
+	serveJSON(t, server, http.MethodGet, "/api/tournaments", "", &tournaments)
+	assert.Empty(t, tournaments)
+	assert.Equal(t, http.StatusNotFound, serveJSON(t, server, http.MethodPost, tournamentPath+"/players", `{"playerName":"Eve"}`, nil))
+	assert.Equal(t, http.StatusCreated, serveJSON(t, server, http.MethodPost, "/g/club"+tournamentPath+"/players", `{"playerName":"Eve"}`, nil))
+
+	// Export only includes the group's games
+	req = httptest.NewRequest(http.MethodGet, "/g/club/api/export?format=json", nil)
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Carol")
+	assert.NotContains(t, w.Body.String(), "Alice")
+
+	// Unknown groups are rejected
+	assert.Equal(t, http.StatusNotFound, serveJSON(t, server, http.MethodGet, "/g/missing/api/games", "", nil))
+}
+
 // TestParseIntDefault tests the parseIntDefault helper function
 func TestParseIntDefault(t *testing.T) {
 	testCases := []struct {
@@ -736,7 +1522,7 @@ func TestHandleExportGames_WithGames(t *testing.T) {
 			Grassland: make(map[string]int),
 			Wetland:   make(map[string]int),
 		}
-		_, err := db.SaveGameResult(g.players, nectarScoring, g.includeOceania)
+		_, err := db.SaveGameResult(t.Context(), g.players, nectarScoring, g.includeOceania)
 		require.NoError(t, err)
 	}
 
@@ -762,6 +1548,213 @@ func TestHandleExportGames_WithGames(t *testing.T) {
 	assert.Contains(t, body, "Dave")
 }
 
+// TestHandleExportGames_Gzip tests that gzip=true returns a compressed CSV
+func TestHandleExportGames_Gzip(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	players := []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", BirdPoints: 45, Total: 92, Rank: 1},
+		{PlayerName: "Bob", BirdPoints: 40, Total: 79, Rank: 2},
+	}
+	_, err := db.SaveGameResult(t.Context(), players, scoring.NectarScoring{}, false)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export?gzip=true", nil)
+	w := httptest.NewRecorder()
+
+	handleExportGames(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/gzip", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "wingspan-games-export.csv.gz")
+
+	gz, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	assert.Len(t, lines, 3, "Should have header + 2 player rows")
+	assert.Contains(t, lines[0], "GameID,Date,IncludeOceania,PlayerName")
+}
+
+// TestHandleExportGames_StreamsThroughMiddleware tests the export flushes through the logging middleware
+func TestHandleExportGames_StreamsThroughMiddleware(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	players := []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Total: 92, Rank: 1},
+		{PlayerName: "Bob", Total: 79, Rank: 2},
+	}
+	_, err := db.SaveGameResult(t.Context(), players, scoring.NectarScoring{}, false)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export", nil)
+	w := httptest.NewRecorder()
+
+	loggingMiddleware(http.HandlerFunc(handleExportGames)).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, w.Flushed, "Export should flush the response while streaming")
+	assert.Contains(t, w.Body.String(), "Alice")
+}
+
+// TestHandleExportGames_OutlivesWriteTimeout tests that streaming an export
+// isn't cut off by the server's write timeout
+func TestHandleExportGames_OutlivesWriteTimeout(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	players := []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Total: 92, Rank: 1},
+		{PlayerName: "Bob", Total: 79, Rank: 2},
+	}
+	_, err := db.SaveGameResult(t.Context(), players, scoring.NectarScoring{}, false)
+	require.NoError(t, err)
+
+	// The export starts writing after the server's write deadline has passed
+	cfg := config.Default().HTTP
+	cfg.WriteTimeout = 50 * time.Millisecond
+	app := newAuthTestServer(db.RoleAdmin)
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Config = newHTTPServer(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(4 * cfg.WriteTimeout)
+		app.ServeHTTP(w, r)
+	}))
+	ts.Start()
+	defer ts.Close()
+
+	for _, query := range []string{"format=csv", "format=csv&gzip=true"} {
+		resp, err := ts.Client().Get(ts.URL + "/api/export?" + query)
+		require.NoError(t, err, query)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err, query)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, query)
+		assert.NotEmpty(t, body, query)
+	}
+}
+
+// TestHandleExportGames_Filtered tests that export honours history filters and row shape
+func TestHandleExportGames_Filtered(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	_, err := db.SaveGameResult(t.Context(), []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Total: 92, Rank: 1},
+		{PlayerName: "Bob", Total: 79, Rank: 2},
+	}, scoring.NectarScoring{}, false)
+	require.NoError(t, err)
+	_, err = db.SaveGameResult(t.Context(), []scoring.PlayerGameEnd{
+		{PlayerName: "Carol", Total: 101, Rank: 1},
+		{PlayerName: "Dave", Total: 88, Rank: 2},
+		{PlayerName: "Erin", Total: 70, Rank: 3},
+	}, scoring.NectarScoring{}, true)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export?oceania=true&shape=game", nil)
+	w := httptest.NewRecorder()
+
+	handleExportGames(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Len(t, lines, 2, "Should have header + 1 game row")
+	assert.True(t, strings.HasPrefix(lines[0], "GameID,Date,IncludeOceania,NumPlayers,WinnerName"))
+	assert.Contains(t, lines[1], "Carol")
+	assert.NotContains(t, lines[1], "Alice")
+}
+
+// TestHandleExportGames_Formats tests the format parameter selects the exporter
+func TestHandleExportGames_Formats(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	_, err := db.SaveGameResult(t.Context(), []scoring.PlayerGameEnd{
+		{PlayerName: "Alice", Total: 92, Rank: 1},
+		{PlayerName: "Bob", Total: 79, Rank: 2},
+	}, scoring.NectarScoring{}, false)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		format      string
+		contentType string
+		filename    string
+	}{
+		{"json", "application/json", "wingspan-games-export.json"},
+		{"ndjson", "application/x-ndjson", "wingspan-games-export.ndjson"},
+		{"xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "wingspan-games-export.xlsx"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/export?format="+tc.format, nil)
+			w := httptest.NewRecorder()
+
+			handleExportGames(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tc.contentType, w.Header().Get("Content-Type"))
+			assert.Contains(t, w.Header().Get("Content-Disposition"), tc.filename)
+			assert.NotZero(t, w.Body.Len())
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export?format=json", nil)
+	w := httptest.NewRecorder()
+	handleExportGames(w, req)
+
+	var games []db.GameResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &games))
+	require.Len(t, games, 1)
+	assert.Equal(t, "Alice", games[0].WinnerName)
+}
+
+// TestHandleExportGames_InvalidParams tests that bad filters and shapes are rejected
+func TestHandleExportGames_InvalidParams(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	for _, query := range []string{"format=pdf", "shape=wide", "from=yesterday", "oceania=maybe", "numPlayers=0"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/export?"+query, nil)
+		w := httptest.NewRecorder()
+
+		handleExportGames(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, "query %s", query)
+	}
+}
+
+// TestParseGameFilter tests query parameter parsing for history and export filters
+func TestParseGameFilter(t *testing.T) {
+	q := url.Values{}
+	q.Set("from", "2024-01-01")
+	q.Set("to", "2024-01-31")
+	q.Add("player", "Alice")
+	q.Add("player", " Bob ")
+	q.Set("oceania", "false")
+	q.Set("numPlayers", "4")
+	q.Set("winner", "Alice")
+	q.Set("season", "3")
+
+	filter, err := parseGameFilter(q)
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), filter.From)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), filter.To, "to date should be inclusive")
+	assert.Equal(t, []string{"Alice", "Bob"}, filter.Players)
+	require.NotNil(t, filter.Oceania)
+	assert.False(t, *filter.Oceania)
+	assert.Equal(t, 4, filter.NumPlayers)
+	assert.Equal(t, "Alice", filter.Winner)
+	assert.Equal(t, int64(3), filter.Season)
+
+	_, err = parseGameFilter(url.Values{"season": {"0"}})
+	assert.Error(t, err)
+}
+
 // TestHandleExportGames_InvalidMethod tests non-GET methods are rejected
 func TestHandleExportGames_InvalidMethod(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/api/export", nil)
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCounter tests counting by label values
+func TestCounter(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounter("test_requests_total", "Requests served.", "route", "status")
+	requests.Inc("/api/games", "200")
+	requests.Inc("/api/games", "200")
+	requests.Add(3, "/", "404")
+	games := r.NewCounter("test_games_total", "Games saved.")
+	games.Inc()
+
+	assert.Equal(t, 2.0, requests.Value("/api/games", "200"))
+	assert.Equal(t, 0.0, requests.Value("/api/games", "500"))
+	assert.Panics(t, func() { requests.Inc("/api/games") }, "label values must match the label names")
+	assert.Panics(t, func() { games.Add(-1) }, "counters can't go down")
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteText(&buf))
+	assert.Equal(t, `# HELP test_requests_total Requests served.
+# TYPE test_requests_total counter
+test_requests_total{route="/",status="404"} 3
+test_requests_total{route="/api/games",status="200"} 2
+# HELP test_games_total Games saved.
+# TYPE test_games_total counter
+test_games_total 1
+`, buf.String())
+}
+
+// TestHistogram tests bucketing observations
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	latency := r.NewHistogram("test_duration_seconds", "Query time.", []float64{0.1, 1}, "query")
+	latency.Observe(0.05, "GetGames")
+	latency.Observe(0.1, "GetGames")
+	latency.Observe(0.5, "GetGames")
+	latency.Observe(2, "GetGames")
+
+	assert.Equal(t, uint64(4), latency.Count("GetGames"))
+	assert.Equal(t, uint64(0), latency.Count("SaveGame"))
+	assert.Panics(t, func() { r.NewHistogram("test_unsorted", "", []float64{1, 0.1}) })
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteText(&buf))
+	assert.Equal(t, `# HELP test_duration_seconds Query time.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{query="GetGames",le="0.1"} 2
+test_duration_seconds_bucket{query="GetGames",le="1"} 3
+test_duration_seconds_bucket{query="GetGames",le="+Inf"} 4
+test_duration_seconds_sum{query="GetGames"} 2.65
+test_duration_seconds_count{query="GetGames"} 4
+`, buf.String())
+}
+
+// TestEscape tests label values with characters the format reserves
+func TestEscape(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "Escaping.", "name").Inc("say \"hi\"\\\n")
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteText(&buf))
+	assert.Contains(t, buf.String(), `test_total{name="say \"hi\"\\\n"} 1`)
+}
+
+// TestHandler tests serving metrics to Prometheus
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "Served.").Inc()
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "test_total 1\n")
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
@@ -1,9 +1,19 @@
 package main
 
 import (
-	"log"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
+	"wingspan-scoring/db"
+	"wingspan-scoring/metrics"
 )
 
 // responseWriter wraps http.ResponseWriter to capture the status code
@@ -18,11 +28,68 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
-// loggingMiddleware logs HTTP requests with method, path, status code, and duration
+// Unwrap exposes the underlying ResponseWriter so http.ResponseController
+// can reach optional interfaces such as http.Flusher
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+var (
+	httpRequests = metrics.NewCounter("wingspan_http_requests_total",
+		"HTTP requests served, by route pattern, method and status code.", "route", "method", "status")
+	httpDuration = metrics.NewHistogram("wingspan_http_request_duration_seconds",
+		"Time spent serving HTTP requests, by route pattern and method.", metrics.DefaultBuckets, "route", "method")
+)
+
+// requestIDHeader carries a request's ID, from a proxy that assigned one
+// and back to the client
+const requestIDHeader = "X-Request-ID"
+
+// requestIDMiddleware gives each request an ID, keeping one a proxy has
+// already assigned, and echoes it in the response so reports of a failed
+// request can be matched to its log lines
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = rand.Text()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+// validRequestID reports whether an ID from a request header is safe to log:
+// short, and only letters, digits, dots, dashes and underscores
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// requestID returns the ID of the request being served with ctx, if any
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// loggingMiddleware logs HTTP requests with method, path, status code, and
+// duration, and counts them in the request metrics
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
+		// Filled in by routeRecorder once the request reaches the routes, so
+		// lines logged by handlers carry it too
+		route := new(string)
+		r = r.WithContext(context.WithValue(r.Context(), routeKey, route))
+
 		// Wrap the response writer to capture status code
 		wrapped := &responseWriter{
 			ResponseWriter: w,
@@ -34,12 +101,259 @@ func loggingMiddleware(next http.Handler) http.Handler {
 
 		// Log the request
 		duration := time.Since(start)
-		log.Printf("%s %s %d %s from %s",
-			r.Method,
-			r.URL.Path,
-			wrapped.statusCode,
-			duration,
-			r.RemoteAddr,
+		if *route == "" {
+			*route = "unmatched"
+		}
+		httpRequests.Inc(*route, r.Method, strconv.Itoa(wrapped.statusCode))
+		httpDuration.Observe(duration.Seconds(), *route, r.Method)
+		slog.InfoContext(r.Context(), "Request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", wrapped.statusCode,
+			"duration", duration,
+			"remote_addr", r.RemoteAddr,
 		)
 	})
 }
+
+// routeRecorder tells loggingMiddleware which of mux's patterns serves each
+// request, so metrics and logs are labelled by route rather than by every
+// path seen
+func routeRecorder(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routeKey).(*string); ok {
+			_, *route = mux.Handler(r)
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// securityHeadersMiddleware sets headers that stop browsers sniffing
+// content types, framing pages or leaking full URLs to other sites. HTTPS
+// responses also get Strict-Transport-Security, unless hstsMaxAge is zero.
+func securityHeadersMiddleware(hstsMaxAge time.Duration, next http.Handler) http.Handler {
+	hsts := fmt.Sprintf("max-age=%d; includeSubDomains", int64(hstsMaxAge.Seconds()))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		if hstsMaxAge > 0 && isHTTPS(r) {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// maxBodyMiddleware refuses request bodies larger than limit bytes. Bodies
+// that say their size up front are refused straight away; others fail to
+// read past the limit.
+func maxBodyMiddleware(limit int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// groupHeader selects a group for API clients that don't use a /g/{slug} prefix
+const groupHeader = "X-Wingspan-Group"
+
+// contextKey keys request context values set by middleware
+type contextKey int
+
+const (
+	groupKey contextKey = iota
+	basePathKey
+	userKey
+	sessionKey
+	routeKey
+	requestIDKey
+)
+
+// groupMiddleware scopes each request to a group, chosen by a /g/{slug} path
+// prefix or the X-Wingspan-Group header, and defaulting to db.DefaultGroup.
+// The prefix is stripped so the usual routes handle the request. Unknown
+// groups get a 404.
+func groupMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slug := r.Header.Get(groupHeader)
+		basePath := ""
+
+		if rest, ok := strings.CutPrefix(r.URL.Path, "/g/"); ok {
+			var path string
+			slug, path, _ = strings.Cut(rest, "/")
+			basePath = "/g/" + slug
+
+			r = r.Clone(r.Context())
+			r.URL.Path = "/" + path
+			r.URL.RawPath = ""
+		}
+
+		if slug == "" {
+			slug = db.DefaultGroup
+		} else if !db.ValidGroupSlug(slug) {
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		} else {
+			exists, err := db.GroupExists(r.Context(), slug)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to check group", "group", slug, "error", err)
+				http.Error(w, "Failed to retrieve group", http.StatusInternalServerError)
+				return
+			}
+			if !exists {
+				http.Error(w, "Group not found", http.StatusNotFound)
+				return
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), groupKey, slug)
+		ctx = context.WithValue(ctx, basePathKey, basePath)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestGroup returns the slug of the group a request is scoped to
+func requestGroup(r *http.Request) string {
+	if slug, ok := r.Context().Value(groupKey).(string); ok {
+		return slug
+	}
+	return db.DefaultGroup
+}
+
+// requestBasePath returns the /g/{slug} prefix the request was made under, if any
+func requestBasePath(r *http.Request) string {
+	basePath, _ := r.Context().Value(basePathKey).(string)
+	return basePath
+}
+
+// sessionCookie holds a signed-in browser's session token
+const sessionCookie = "wingspan_session"
+
+// csrfHeader carries the session's CSRF token on requests that change data
+const csrfHeader = "X-CSRF-Token"
+
+// authMiddleware identifies the user from an API token (Authorization:
+// Bearer) or session cookie, unless proxyAuthMiddleware already has, and
+// enforces the role each route needs. Requests without either get
+// anonymousRole, which may be empty to require signing in. Browsers must
+// send their session's CSRF token with requests that change data.
+func authMiddleware(anonymousRole string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Requests from a trusted proxy arrive with their user already set
+		user := requestUser(r)
+		var session *db.Session
+
+		if user != nil {
+			// Authenticated by proxyAuthMiddleware
+		} else if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			var err error
+			user, err = db.AuthenticateAPIToken(r.Context(), strings.TrimSpace(token))
+			if err != nil {
+				if !errors.Is(err, db.ErrAPITokenNotFound) {
+					slog.ErrorContext(r.Context(), "Failed to check API token", "error", err)
+				}
+				w.Header().Set("WWW-Authenticate", `Bearer realm="wingspan"`)
+				http.Error(w, "Invalid API token", http.StatusUnauthorized)
+				return
+			}
+		} else if cookie, err := r.Cookie(sessionCookie); err == nil {
+			session, err = db.GetSession(r.Context(), cookie.Value)
+			if err != nil {
+				if !errors.Is(err, db.ErrSessionNotFound) {
+					slog.ErrorContext(r.Context(), "Failed to check session", "error", err)
+				}
+				clearSessionCookie(w, r)
+			} else {
+				user = &session.User
+			}
+		}
+
+		role := anonymousRole
+		if user != nil {
+			role = user.Role
+		}
+
+		if required := requiredRole(r.Method, r.URL.Path); required != "" && !db.HasRole(role, required) {
+			switch {
+			case user != nil:
+				http.Error(w, "Forbidden", http.StatusForbidden)
+			case r.Method == http.MethodGet && !strings.HasPrefix(r.URL.Path, "/api/") && r.URL.Path != "/metrics":
+				// Pages send browsers to sign in; scrapers and API clients get a 401
+				loginURL := requestBasePath(r) + "/login?next=" + url.QueryEscape(requestBasePath(r)+r.URL.RequestURI())
+				http.Redirect(w, r, loginURL, http.StatusSeeOther)
+			default:
+				w.Header().Set("WWW-Authenticate", `Bearer realm="wingspan"`)
+				http.Error(w, "Authentication required", http.StatusUnauthorized)
+			}
+			return
+		}
+
+		if session != nil && !safeMethod(r.Method) && r.URL.Path != "/login" && !validCSRFToken(r, session.CSRFToken) {
+			http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userKey, user)
+		ctx = context.WithValue(ctx, sessionKey, session)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requiredRole returns the role a route needs, or "" for public routes.
+// Reading needs viewer, saving games and managing seasons and tournaments
+// needs scorer, and deleting, importing and managing users or groups needs
+// admin.
+func requiredRole(method, path string) string {
+	switch {
+	case path == "/login" || strings.HasPrefix(path, "/login/") || path == "/logout" || path == "/api/version" || strings.HasPrefix(path, "/static/"):
+		return ""
+	case path == "/api/import" || path == "/api/users" || strings.HasPrefix(path, "/api/users/"):
+		return db.RoleAdmin
+	case path == "/api/groups" && !safeMethod(method):
+		return db.RoleAdmin
+	case method == http.MethodDelete && !strings.HasPrefix(path, "/api/tokens/"):
+		return db.RoleAdmin
+	case safeMethod(method):
+		return db.RoleViewer
+	case path == "/api/new-game" || path == "/api/calculate-scores":
+		// Goal generation and round scoring don't save anything
+		return db.RoleViewer
+	case path == "/api/tokens" || strings.HasPrefix(path, "/api/tokens/"):
+		return db.RoleViewer
+	}
+	return db.RoleScorer
+}
+
+// safeMethod reports whether a method only reads data
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// validCSRFToken checks the CSRF header, or the csrf_token field of a
+// URL-encoded form, against the session's token
+func validCSRFToken(r *http.Request, want string) bool {
+	got := r.Header.Get(csrfHeader)
+	if got == "" && strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		got = r.PostFormValue("csrf_token")
+	}
+	return got != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// requestUser returns the signed-in user, or nil for anonymous requests
+func requestUser(r *http.Request) *db.User {
+	user, _ := r.Context().Value(userKey).(*db.User)
+	return user
+}
+
+// requestCSRFToken returns the CSRF token of the request's session, if any
+func requestCSRFToken(r *http.Request) string {
+	if session, _ := r.Context().Value(sessionKey).(*db.Session); session != nil {
+		return session.CSRFToken
+	}
+	return ""
+}
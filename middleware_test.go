@@ -1,22 +1,20 @@
 package main
 
 import (
-	"bytes"
-	"log"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"strings"
 	"testing"
+	"time"
+	"wingspan-scoring/db"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLoggingMiddleware_LogsRequest(t *testing.T) {
 	// Capture log output
-	var buf bytes.Buffer
-	log.SetOutput(&buf)
-	defer log.SetOutput(os.Stderr) // Reset to default after test
+	buf := captureLogs(t, "text")
 
 	// Create a test handler
 	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -61,9 +59,7 @@ func TestLoggingMiddleware_LogsStatusCode(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Capture log output
-			var buf bytes.Buffer
-			log.SetOutput(&buf)
-			defer log.SetOutput(os.Stderr)
+			buf := captureLogs(t, "text")
 
 			// Create test handler that returns specific status code
 			testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -98,9 +94,7 @@ func TestLoggingMiddleware_LogsDifferentMethods(t *testing.T) {
 	for _, method := range methods {
 		t.Run(method, func(t *testing.T) {
 			// Capture log output
-			var buf bytes.Buffer
-			log.SetOutput(&buf)
-			defer log.SetOutput(os.Stderr)
+			buf := captureLogs(t, "text")
 
 			// Create test handler
 			testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -180,9 +174,7 @@ func TestResponseWriter_DefaultStatusCode(t *testing.T) {
 
 func TestLoggingMiddleware_LogsRequestDuration(t *testing.T) {
 	// Capture log output
-	var buf bytes.Buffer
-	log.SetOutput(&buf)
-	defer log.SetOutput(nil)
+	buf := captureLogs(t, "text")
 
 	// Create test handler
 	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -204,3 +196,81 @@ func TestLoggingMiddleware_LogsRequestDuration(t *testing.T) {
 		strings.Contains(logOutput, "s")
 	assert.True(t, hasDuration, "Log should contain request duration")
 }
+
+// TestGroupMiddleware tests selecting a group by path prefix or header
+func TestGroupMiddleware(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	require.NoError(t, db.CreateGroup(t.Context(), &db.Group{Slug: "club", Name: "Club"}))
+
+	var gotPath, gotGroup, gotBasePath string
+	handler := groupMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotGroup, gotBasePath = r.URL.Path, requestGroup(r), requestBasePath(r)
+	}))
+
+	tests := []struct {
+		name     string
+		path     string
+		header   string
+		status   int
+		routed   string
+		group    string
+		basePath string
+	}{
+		{"default", "/api/games", "", http.StatusOK, "/api/games", db.DefaultGroup, ""},
+		{"prefix", "/g/club/api/games", "", http.StatusOK, "/api/games", "club", "/g/club"},
+		{"prefix root", "/g/club", "", http.StatusOK, "/", "club", "/g/club"},
+		{"header", "/api/games", "club", http.StatusOK, "/api/games", "club", ""},
+		{"prefix wins over header", "/g/default/api/games", "club", http.StatusOK, "/api/games", db.DefaultGroup, "/g/default"},
+		{"unknown prefix", "/g/missing/api/games", "", http.StatusNotFound, "", "", ""},
+		{"unknown header", "/api/games", "missing", http.StatusNotFound, "", "", ""},
+		{"invalid slug", "/g/Bad%20Slug/api/games", "", http.StatusNotFound, "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotPath, gotGroup, gotBasePath = "", "", ""
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(groupHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.routed, gotPath)
+			assert.Equal(t, tt.group, gotGroup)
+			assert.Equal(t, tt.basePath, gotBasePath)
+		})
+	}
+}
+
+// TestSecurityHeadersMiddleware tests the headers sent with every response
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	handler := securityHeadersMiddleware(time.Hour, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "strict-origin-when-cross-origin", w.Header().Get("Referrer-Policy"))
+	assert.Empty(t, w.Header().Get("Strict-Transport-Security"), "HSTS is only sent over HTTPS")
+
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "max-age=3600; includeSubDomains", w.Header().Get("Strict-Transport-Security"))
+
+	// Behind a TLS-terminating proxy
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.NotEmpty(t, w.Header().Get("Strict-Transport-Security"))
+
+	w = httptest.NewRecorder()
+	securityHeadersMiddleware(0, http.NotFoundHandler()).ServeHTTP(w, req)
+	assert.Empty(t, w.Header().Get("Strict-Transport-Security"))
+}
@@ -0,0 +1,154 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"wingspan-scoring/oidc/oidctest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// discover finds a mock issuer
+func discover(t *testing.T, issuer *oidctest.Issuer) *Provider {
+	t.Helper()
+	provider, err := Discover(context.Background(), Config{
+		Issuer:       issuer.URL,
+		ClientID:     issuer.ClientID,
+		ClientSecret: issuer.ClientSecret,
+	})
+	require.NoError(t, err)
+	return provider
+}
+
+// authorize follows an authorization URL to the mock issuer and returns the
+// query of the redirect back to the app
+func authorize(t *testing.T, authURL string) url.Values {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	return location.Query()
+}
+
+// TestDiscover tests reading the provider's discovery document
+func TestDiscover(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	provider := discover(t, issuer)
+	assert.Equal(t, issuer.URL, provider.Issuer())
+
+	_, err := Discover(context.Background(), Config{Issuer: issuer.URL + "/other", ClientID: "wingspan"})
+	assert.Error(t, err)
+
+	_, err = Discover(context.Background(), Config{Issuer: issuer.URL})
+	assert.Error(t, err)
+}
+
+// TestAuthCodeURL tests the parameters sent to the provider
+func TestAuthCodeURL(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	provider := discover(t, issuer)
+
+	authURL, err := url.Parse(provider.AuthCodeURL("https://app.example/callback", "state1", "nonce1", "verifier1"))
+	require.NoError(t, err)
+	q := authURL.Query()
+	assert.Equal(t, issuer.URL+"/authorize", authURL.Scheme+"://"+authURL.Host+authURL.Path)
+	assert.Equal(t, "code", q.Get("response_type"))
+	assert.Equal(t, issuer.ClientID, q.Get("client_id"))
+	assert.Equal(t, "https://app.example/callback", q.Get("redirect_uri"))
+	assert.Equal(t, "openid profile email", q.Get("scope"))
+	assert.Equal(t, "state1", q.Get("state"))
+	assert.Equal(t, "nonce1", q.Get("nonce"))
+	assert.Equal(t, Challenge("verifier1"), q.Get("code_challenge"))
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+}
+
+// TestLoginFlow tests the authorization code flow with PKCE end to end
+func TestLoginFlow(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	issuer.SetClaims(map[string]any{"sub": "alice-id", "preferred_username": "alice", "groups": []string{"birders"}})
+	provider := discover(t, issuer)
+	ctx := context.Background()
+
+	verifier, err := RandomString()
+	require.NoError(t, err)
+	redirectURL := "https://app.example/callback"
+	q := authorize(t, provider.AuthCodeURL(redirectURL, "state1", "nonce1", verifier))
+	assert.Equal(t, "state1", q.Get("state"))
+
+	// The code is bound to the PKCE verifier
+	_, err = provider.Exchange(ctx, q.Get("code"), redirectURL, "wrong-verifier")
+	assert.Error(t, err)
+
+	q = authorize(t, provider.AuthCodeURL(redirectURL, "state1", "nonce1", verifier))
+	rawToken, err := provider.Exchange(ctx, q.Get("code"), redirectURL, verifier)
+	require.NoError(t, err)
+
+	claims, err := provider.Verify(ctx, rawToken, "nonce1")
+	require.NoError(t, err)
+	assert.Equal(t, "alice-id", claims.Subject())
+	assert.Equal(t, "alice", claims.String("preferred_username"))
+	assert.Equal(t, []string{"birders"}, claims.Strings("groups"))
+
+	_, err = provider.Verify(ctx, rawToken, "other-nonce")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// Codes can only be used once
+	_, err = provider.Exchange(ctx, q.Get("code"), redirectURL, verifier)
+	assert.Error(t, err)
+}
+
+// TestVerify_Rejects tests that tampered, expired and misdirected ID tokens are rejected
+func TestVerify_Rejects(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	provider := discover(t, issuer)
+	ctx := context.Background()
+	now := time.Now()
+
+	valid := issuer.IDToken("n", map[string]any{"sub": "user-1"})
+	_, err := provider.Verify(ctx, valid, "n")
+	require.NoError(t, err)
+
+	parts := strings.Split(valid, ".")
+	other := strings.Split(issuer.IDToken("n", map[string]any{"sub": "admin"}), ".")
+	unsigned := `eyJhbGciOiJub25lIn0.` + parts[1] + "."
+
+	tests := map[string]string{
+		"expired":        issuer.IDToken("n", map[string]any{"sub": "user-1", "exp": now.Add(-time.Hour).Unix()}),
+		"no expiry":      issuer.Sign(map[string]any{"iss": issuer.URL, "aud": issuer.ClientID, "sub": "user-1"}),
+		"future iat":     issuer.IDToken("n", map[string]any{"sub": "user-1", "iat": now.Add(time.Hour).Unix()}),
+		"other audience": issuer.IDToken("n", map[string]any{"sub": "user-1", "aud": "someone-else"}),
+		"other azp":      issuer.IDToken("n", map[string]any{"sub": "user-1", "aud": []string{issuer.ClientID, "x"}, "azp": "x"}),
+		"other issuer":   issuer.IDToken("n", map[string]any{"sub": "user-1", "iss": "https://evil.example"}),
+		"no subject":     issuer.IDToken("n", nil),
+		"tampered":       parts[0] + "." + other[1] + "." + parts[2],
+		"alg none":       unsigned,
+		"malformed":      "not-a-token",
+	}
+	for name, token := range tests {
+		_, err := provider.Verify(ctx, token, "n")
+		assert.True(t, errors.Is(err, ErrInvalidToken), "%s: %v", name, err)
+	}
+}
+
+// TestClaimsStrings tests reading claims that may be a string or a list
+func TestClaimsStrings(t *testing.T) {
+	claims := Claims{"one": "a", "many": []any{"a", "b", 3}, "number": 1.0}
+	assert.Equal(t, []string{"a"}, claims.Strings("one"))
+	assert.Equal(t, []string{"a", "b"}, claims.Strings("many"))
+	assert.Nil(t, claims.Strings("number"))
+	assert.Nil(t, claims.Strings("missing"))
+	assert.Equal(t, "", claims.String("number"))
+}
@@ -68,7 +68,7 @@ func CalculateGameEndScores(players []PlayerGameEnd, includeOceania bool) ([]Pla
 	}
 
 	// Determine rankings with tiebreaker
-	determineRankings(players)
+	RankPlayers(players)
 
 	return players, nectarScoring
 }
@@ -155,8 +155,9 @@ func scoreHabitat(players []PlayerGameEnd, getNectar func(PlayerGameEnd) int) ma
 	return points
 }
 
-// determineRankings assigns ranks to players based on total score and tiebreakers
-func determineRankings(players []PlayerGameEnd) {
+// RankPlayers assigns ranks to players based on total score and tiebreakers,
+// sorting them into finishing order
+func RankPlayers(players []PlayerGameEnd) {
 	// Sort players by total (descending), then by unused food (descending) for tiebreaker
 	sort.Slice(players, func(i, j int) bool {
 		if players[i].Total == players[j].Total {
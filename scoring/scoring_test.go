@@ -266,15 +266,15 @@ func TestCalculateNectarPoints_MultipleHabitats(t *testing.T) {
 	assert.Equal(t, 3, scoring.Wetland["Bob"])
 }
 
-// TestDetermineRankings_NoTies tests ranking with clear winners
-func TestDetermineRankings_NoTies(t *testing.T) {
+// TestRankPlayers_NoTies tests ranking with clear winners
+func TestRankPlayers_NoTies(t *testing.T) {
 	players := []PlayerGameEnd{
 		{PlayerName: "Carol", Total: 85, UnusedFood: 2},
 		{PlayerName: "Alice", Total: 100, UnusedFood: 3},
 		{PlayerName: "Bob", Total: 90, UnusedFood: 5},
 	}
 
-	determineRankings(players)
+	RankPlayers(players)
 
 	// Should be sorted by total score descending
 	assert.Equal(t, "Alice", players[0].PlayerName)
@@ -287,15 +287,15 @@ func TestDetermineRankings_NoTies(t *testing.T) {
 	assert.Equal(t, 3, players[2].Rank)
 }
 
-// TestDetermineRankings_TieBrokenByUnusedFood tests tiebreaker mechanism
-func TestDetermineRankings_TieBrokenByUnusedFood(t *testing.T) {
+// TestRankPlayers_TieBrokenByUnusedFood tests tiebreaker mechanism
+func TestRankPlayers_TieBrokenByUnusedFood(t *testing.T) {
 	players := []PlayerGameEnd{
 		{PlayerName: "Alice", Total: 100, UnusedFood: 3},
 		{PlayerName: "Bob", Total: 100, UnusedFood: 5}, // Same total, more unused food
 		{PlayerName: "Carol", Total: 90, UnusedFood: 2},
 	}
 
-	determineRankings(players)
+	RankPlayers(players)
 
 	// Bob should win due to tiebreaker (more unused food)
 	assert.Equal(t, "Bob", players[0].PlayerName)
@@ -308,15 +308,15 @@ func TestDetermineRankings_TieBrokenByUnusedFood(t *testing.T) {
 	assert.Equal(t, 3, players[2].Rank)
 }
 
-// TestDetermineRankings_CompleteTie tests when players have identical totals and unused food
-func TestDetermineRankings_CompleteTie(t *testing.T) {
+// TestRankPlayers_CompleteTie tests when players have identical totals and unused food
+func TestRankPlayers_CompleteTie(t *testing.T) {
 	players := []PlayerGameEnd{
 		{PlayerName: "Alice", Total: 100, UnusedFood: 5},
 		{PlayerName: "Bob", Total: 100, UnusedFood: 5}, // Complete tie
 		{PlayerName: "Carol", Total: 90, UnusedFood: 3},
 	}
 
-	determineRankings(players)
+	RankPlayers(players)
 
 	// Alice and Bob should share rank 1
 	assert.Equal(t, 1, players[0].Rank)
@@ -327,8 +327,8 @@ func TestDetermineRankings_CompleteTie(t *testing.T) {
 	assert.Equal(t, 3, players[2].Rank)
 }
 
-// TestDetermineRankings_MultipleGroups tests multiple tied groups
-func TestDetermineRankings_MultipleGroups(t *testing.T) {
+// TestRankPlayers_MultipleGroups tests multiple tied groups
+func TestRankPlayers_MultipleGroups(t *testing.T) {
 	players := []PlayerGameEnd{
 		{PlayerName: "Alice", Total: 100, UnusedFood: 5},
 		{PlayerName: "Bob", Total: 100, UnusedFood: 5}, // Tied with Alice for 1st
@@ -337,7 +337,7 @@ func TestDetermineRankings_MultipleGroups(t *testing.T) {
 		{PlayerName: "Eve", Total: 80, UnusedFood: 2},
 	}
 
-	determineRankings(players)
+	RankPlayers(players)
 
 	// Alice and Bob share rank 1
 	assert.Equal(t, 1, players[0].Rank)
@@ -352,13 +352,13 @@ func TestDetermineRankings_MultipleGroups(t *testing.T) {
 	assert.Equal(t, 5, players[4].Rank)
 }
 
-// TestDetermineRankings_SinglePlayer tests edge case with one player
-func TestDetermineRankings_SinglePlayer(t *testing.T) {
+// TestRankPlayers_SinglePlayer tests edge case with one player
+func TestRankPlayers_SinglePlayer(t *testing.T) {
 	players := []PlayerGameEnd{
 		{PlayerName: "Alice", Total: 100, UnusedFood: 5},
 	}
 
-	determineRankings(players)
+	RankPlayers(players)
 
 	assert.Equal(t, 1, players[0].Rank)
 }
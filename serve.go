@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+	"wingspan-scoring/config"
+)
+
+// newHTTPServer returns a server for handler with the configured timeouts
+// and size limits
+func newHTTPServer(cfg config.HTTP, handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           maxBodyMiddleware(cfg.MaxBodyBytes, handler),
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+}
+
+// serve answers requests on ln until ctx is done, then stops accepting
+// connections and waits up to shutdownTimeout for requests in flight to
+// finish. Requests still running after that are cut off.
+func serve(ctx context.Context, srv *http.Server, ln net.Listener, shutdownTimeout time.Duration) error {
+	errc := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			errc <- srv.ServeTLS(ln, "", "")
+		} else {
+			errc <- srv.Serve(ln)
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down, waiting for requests in flight")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("failed to finish requests in flight: %w", err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	slog.Info("Server stopped")
+	return nil
+}
+
+// endpoint is a server and the listener it answers on
+type endpoint struct {
+	srv *http.Server
+	ln  net.Listener
+}
+
+// serveAll runs serve for each endpoint, stopping them all when ctx is done
+// or any of them fails
+func serveAll(ctx context.Context, endpoints []endpoint, shutdownTimeout time.Duration) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errc := make(chan error, len(endpoints))
+	for _, e := range endpoints {
+		go func() {
+			errc <- serve(ctx, e.srv, e.ln, shutdownTimeout)
+			cancel()
+		}()
+	}
+
+	var errs []error
+	for range endpoints {
+		errs = append(errs, <-errc)
+	}
+	return errors.Join(errs...)
+}
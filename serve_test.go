@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"wingspan-scoring/config"
+	"wingspan-scoring/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startServe runs serve for handler on a local port until the returned
+// cancel function is called, reporting its result on the returned channel
+func startServe(t *testing.T, handler http.Handler, shutdownTimeout time.Duration) (string, context.CancelFunc, <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	done := make(chan error, 1)
+	go func() { done <- serve(ctx, &http.Server{Handler: handler}, ln, shutdownTimeout) }()
+	return "http://" + ln.Addr().String(), cancel, done
+}
+
+// TestServe_Drain tests that stopping waits for requests in flight and refuses new ones
+func TestServe_Drain(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	url, cancel, done := startServe(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "finished")
+	}), 5*time.Second)
+
+	type result struct {
+		body string
+		err  error
+	}
+	inFlight := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			inFlight <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		inFlight <- result{string(body), err}
+	}()
+	<-started
+
+	cancel()
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+		if err == nil {
+			conn.Close()
+		}
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond, "listener should close")
+
+	select {
+	case err := <-done:
+		t.Fatalf("serve returned before the request finished: %v", err)
+	default:
+	}
+
+	close(release)
+	res := <-inFlight
+	require.NoError(t, res.err)
+	assert.Equal(t, "finished", res.body)
+	assert.NoError(t, <-done)
+}
+
+// TestServe_ShutdownTimeout tests that requests running past the timeout are cut off
+func TestServe_ShutdownTimeout(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	url, cancel, done := startServe(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}), 50*time.Millisecond)
+
+	go func() {
+		if resp, err := http.Get(url); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve didn't stop after the shutdown timeout")
+	}
+}
+
+// TestNewHTTPServer tests the configured timeouts and limits
+func TestNewHTTPServer(t *testing.T) {
+	cfg := config.Default()
+	cfg.HTTP.MaxBodyBytes = 16
+	srv := newHTTPServer(cfg.HTTP, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		}
+	}))
+	assert.Equal(t, cfg.HTTP.ReadHeaderTimeout, srv.ReadHeaderTimeout)
+	assert.Equal(t, cfg.HTTP.WriteTimeout, srv.WriteTimeout)
+	assert.Equal(t, cfg.HTTP.IdleTimeout, srv.IdleTimeout)
+	assert.Equal(t, cfg.HTTP.MaxHeaderBytes, srv.MaxHeaderBytes)
+	assert.Nil(t, srv.TLSConfig)
+
+	w := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("small")))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 17))))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// Bodies without a length fail once they pass the limit
+	req := httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader(strings.Repeat("x", 17))))
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// TestRun tests serving the app and closing the database when it stops
+func TestRun(t *testing.T) {
+	originalDB := db.DB
+	defer func() { db.DB = originalDB }()
+
+	cfg := config.Default()
+	cfg.DBPath = filepath.Join(t.TempDir(), "wingspan.db")
+	cfg.Auth.AdminPassword = "password1"
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, cfg, ln) }()
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/api/version")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 10*time.Second, 20*time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("run didn't stop")
+	}
+	assert.ErrorContains(t, db.DB.Ping(), "database is closed")
+}
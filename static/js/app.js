@@ -1,3 +1,8 @@
+// Prefix for API calls and links when viewing a group at /g/{slug}
+const BASE_PATH = document.body.dataset.basePath || '';
+// Sent with requests that change data when signed in
+const CSRF_TOKEN = document.body.dataset.csrfToken || '';
+
 // Track current mode
 let currentMode = 'blue'; // 'blue' or 'green'
 
@@ -64,7 +69,7 @@ async function fetchAllGoals() {
         const european = document.getElementById('european').checked;
         const oceania = document.getElementById('oceania').checked;
 
-        const response = await fetch(`/api/goals?base=${base}&european=${european}&oceania=${oceania}`);
+        const response = await fetch(`${BASE_PATH}/api/goals?base=${base}&european=${european}&oceania=${oceania}`);
         if (!response.ok) {
             throw new Error('Failed to fetch goals');
         }
@@ -588,9 +593,9 @@ async function calculateRoundScoresFromAPI(round) {
     }
 
     try {
-        const response = await fetch('/api/calculate-scores', {
+        const response = await fetch(BASE_PATH + '/api/calculate-scores', {
             method: 'POST',
-            headers: { 'Content-Type': 'application/json' },
+            headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': CSRF_TOKEN },
             body: JSON.stringify({
                 mode: currentMode,
                 round: round,
@@ -987,10 +992,11 @@ async function generateNewGame() {
     }
 
     try {
-        const response = await fetch('/api/new-game', {
+        const response = await fetch(BASE_PATH + '/api/new-game', {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/x-www-form-urlencoded',
+                'X-CSRF-Token': CSRF_TOKEN,
             },
             body: `base=${base}&european=${european}&oceania=${oceania}`
         });
@@ -1571,14 +1577,20 @@ async function calculateGameEndScores() {
     }
 
     try {
-        const response = await fetch('/api/calculate-game-end', {
+        const response = await fetch(BASE_PATH + '/api/calculate-game-end', {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/json',
+                'X-CSRF-Token': CSRF_TOKEN,
             },
             body: JSON.stringify({
                 players: players,
-                includeOceania: gameEndState.includeOceania
+                includeOceania: gameEndState.includeOceania,
+                goals: {
+                    side: currentMode,
+                    rounds: ['round1', 'round2', 'round3', 'round4'].map(roundKey =>
+                        (gameState.goals && gameState.goals[roundKey] && gameState.goals[roundKey].id) || '')
+                }
             })
         });
 
@@ -1829,9 +1841,9 @@ document.addEventListener('DOMContentLoaded', () => {
             const targetPage = this.dataset.page;
 
             // Determine target URL
-            let targetUrl = '/';
+            let targetUrl = BASE_PATH + '/';
             if (targetPage === 'history') {
-                targetUrl = '/history';
+                targetUrl = BASE_PATH + '/history';
             }
 
             // Animate slider to clicked position
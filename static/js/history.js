@@ -1,5 +1,10 @@
 // Game History Page JavaScript
 
+// Prefix for API calls and links when viewing a group at /g/{slug}
+const BASE_PATH = document.body.dataset.basePath || '';
+// Sent with requests that change data when signed in
+const CSRF_TOKEN = document.body.dataset.csrfToken || '';
+
 const ITEMS_PER_PAGE = 20;
 let currentPage = 1;
 let totalGames = 0;
@@ -83,7 +88,7 @@ async function loadGames() {
 
     try {
         const offset = (currentPage - 1) * ITEMS_PER_PAGE;
-        const response = await fetch(`/api/games?limit=${ITEMS_PER_PAGE}&offset=${offset}`);
+        const response = await fetch(`${BASE_PATH}/api/games?limit=${ITEMS_PER_PAGE}&offset=${offset}`);
 
         if (!response.ok) {
             throw new Error('Failed to load games');
@@ -200,7 +205,7 @@ async function viewGameDetails(gameId) {
     modal.style.display = 'flex';
 
     try {
-        const response = await fetch(`/api/games/${gameId}`);
+        const response = await fetch(`${BASE_PATH}/api/games/${gameId}`);
 
         if (!response.ok) {
             throw new Error('Failed to load game details');
@@ -422,8 +427,9 @@ async function confirmDelete() {
     if (!gameToDelete) return;
 
     try {
-        const response = await fetch(`/api/games/${gameToDelete}`, {
-            method: 'DELETE'
+        const response = await fetch(`${BASE_PATH}/api/games/${gameToDelete}`, {
+            method: 'DELETE',
+            headers: { 'X-CSRF-Token': CSRF_TOKEN }
         });
 
         if (!response.ok) {
@@ -454,7 +460,7 @@ async function loadLeaderboard() {
     statsContent.innerHTML = '<div class="loading">Loading leaderboard...</div>';
 
     try {
-        const response = await fetch('/api/leaderboard');
+        const response = await fetch(BASE_PATH + '/api/leaderboard');
 
         if (!response.ok) {
             throw new Error('Failed to load leaderboard');
@@ -513,9 +519,9 @@ document.addEventListener('DOMContentLoaded', () => {
             const targetPage = this.dataset.page;
 
             // Determine target URL
-            let targetUrl = '/';
+            let targetUrl = BASE_PATH + '/';
             if (targetPage === 'history') {
-                targetUrl = '/history';
+                targetUrl = BASE_PATH + '/history';
             }
 
             // Animate slider to clicked position
@@ -573,9 +579,9 @@ async function handleImportSubmit(e) {
 
     const file = csvFileInput.files[0];
 
-    // Validate file type
-    if (!file.name.endsWith('.csv')) {
-        showImportError('Please select a valid CSV file.');
+    // Validate file type (CSV from this or other score trackers, or BGG play XML)
+    if (!file.name.endsWith('.csv') && !file.name.endsWith('.xml')) {
+        showImportError('Please select a valid CSV or XML file.');
         return;
     }
 
@@ -587,8 +593,9 @@ async function handleImportSubmit(e) {
     formData.append('csvFile', file);
 
     try {
-        const response = await fetch('/api/import', {
+        const response = await fetch(BASE_PATH + '/api/import', {
             method: 'POST',
+            headers: { 'X-CSRF-Token': CSRF_TOKEN },
             body: formData
         });
 
@@ -649,7 +656,8 @@ function displayImportErrors(errors) {
     errors.forEach(error => {
         const line = error.Line ? `Line ${error.Line}` : '';
         const gameID = error.GameID ? `Game ${error.GameID}` : '';
-        const location = [line, gameID].filter(Boolean).join(' - ');
+        const column = error.Column ? `Column ${error.Column}` : '';
+        const location = [line, gameID, column].filter(Boolean).join(' - ');
         errorHTML += `<li>${location ? `${location}: ` : ''}${error.Message}</li>`;
     });
     errorHTML += '</ul>';
@@ -678,7 +686,7 @@ async function exportGames() {
     importStatus.style.display = 'none';
 
     try {
-        const response = await fetch('/api/export');
+        const response = await fetch(BASE_PATH + '/api/export');
 
         if (!response.ok) {
             throw new Error('Failed to export games');
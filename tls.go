@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// certReloader serves a certificate from files and picks up new ones when
+// the files change, such as when cert-manager renews them
+type certReloader struct {
+	certFile, keyFile string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modTimes [2]time.Time // Of the certificate and key files when last loaded
+}
+
+// newCertReloader loads the certificate and key files
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate returns the current certificate for tls.Config
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// reload loads the files again if either has changed, reporting whether it did.
+// The current certificate is kept if the new files can't be loaded.
+func (c *certReloader) reload() (bool, error) {
+	var modTimes [2]time.Time
+	for i, name := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return false, fmt.Errorf("failed to read TLS certificate: %w", err)
+		}
+		modTimes[i] = info.ModTime()
+	}
+
+	c.mu.RLock()
+	unchanged := c.cert != nil && modTimes == c.modTimes
+	c.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	c.mu.Lock()
+	c.cert, c.modTimes = &cert, modTimes
+	c.mu.Unlock()
+	return true, nil
+}
+
+// watch checks the files every interval until ctx is done
+func (c *certReloader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := c.reload()
+		if err != nil {
+			// Files being replaced one at a time may not match yet
+			slog.Warn("Keeping the current TLS certificate", "error", err)
+		} else if reloaded {
+			slog.Info("Reloaded TLS certificate", "file", c.certFile)
+		}
+	}
+}
+
+// tlsConfig returns the server TLS settings using the reloader's certificate
+func (c *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{GetCertificate: c.GetCertificate, MinVersion: tls.VersionTLS12}
+}
+
+// redirectToHTTPS sends requests to the same URL over HTTPS, on the port of
+// httpsAddr
+func redirectToHTTPS(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = strings.Trim(r.Host, "[]")
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
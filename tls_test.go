@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"wingspan-scoring/config"
+	"wingspan-scoring/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert writes a self-signed certificate for localhost named commonName
+// and its key, dated modTime, returning the certificate
+func writeCert(t *testing.T, certFile, keyFile, commonName string, modTime time.Time) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+// servedName returns the common name of the reloader's current certificate
+func servedName(t *testing.T, certs *certReloader) string {
+	t.Helper()
+	cert, err := certs.GetCertificate(nil)
+	require.NoError(t, err)
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return parsed.Subject.CommonName
+}
+
+// TestCertReloader tests picking up replaced certificate files
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	start := time.Now().Add(-time.Minute)
+	writeCert(t, certFile, keyFile, "first", start)
+
+	certs, err := newCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, "first", servedName(t, certs))
+
+	reloaded, err := certs.reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded, "unchanged files aren't loaded again")
+
+	writeCert(t, certFile, keyFile, "second", start.Add(time.Second))
+	reloaded, err = certs.reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, "second", servedName(t, certs))
+
+	// A certificate whose key hasn't been replaced yet is refused until it is
+	otherKey := filepath.Join(dir, "other.key")
+	writeCert(t, certFile, otherKey, "third", start.Add(2*time.Second))
+	_, err = certs.reload()
+	assert.Error(t, err)
+	assert.Equal(t, "second", servedName(t, certs))
+
+	require.NoError(t, os.Rename(otherKey, keyFile))
+	reloaded, err = certs.reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, "third", servedName(t, certs))
+
+	_, err = newCertReloader(filepath.Join(dir, "missing.crt"), keyFile)
+	assert.Error(t, err)
+}
+
+// TestCertReloader_Watch tests that watching reloads changed files
+func TestCertReloader_Watch(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	start := time.Now().Add(-time.Minute)
+	writeCert(t, certFile, keyFile, "first", start)
+	certs, err := newCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go certs.watch(ctx, 10*time.Millisecond)
+
+	writeCert(t, certFile, keyFile, "renewed", start.Add(time.Second))
+	assert.Eventually(t, func() bool { return servedName(t, certs) == "renewed" }, 5*time.Second, 10*time.Millisecond)
+}
+
+// TestRedirectToHTTPS tests sending plain HTTP requests to HTTPS
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		httpsAddr, host, target, want string
+	}{
+		{":8443", "example.com:8080", "/history?page=2", "https://example.com:8443/history?page=2"},
+		{":443", "example.com", "/", "https://example.com/"},
+		{"0.0.0.0:443", "example.com:80", "/api/games", "https://example.com/api/games"},
+		{":8443", "[::1]:8080", "/", "https://[::1]:8443/"},
+		{":443", "[::1]", "/", "https://[::1]/"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		redirectToHTTPS(tt.httpsAddr).ServeHTTP(w, req)
+		assert.Equal(t, http.StatusMovedPermanently, w.Code)
+		assert.Equal(t, tt.want, w.Header().Get("Location"), tt.host+tt.target)
+	}
+}
+
+// TestRun_TLS tests serving HTTPS with security headers
+func TestRun_TLS(t *testing.T) {
+	originalDB := db.DB
+	defer func() { db.DB = originalDB }()
+
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.DBPath = filepath.Join(dir, "wingspan.db")
+	cfg.Auth.AdminPassword = "password1"
+	cfg.TLS.CertFile, cfg.TLS.KeyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	cert := writeCert(t, cfg.TLS.CertFile, cfg.TLS.KeyFile, "localhost", time.Now())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, cfg, ln) }()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	defer client.CloseIdleConnections()
+
+	var resp *http.Response
+	require.Eventually(t, func() bool {
+		resp, err = client.Get("https://" + ln.Addr().String() + "/api/version")
+		return err == nil
+	}, 10*time.Second, 20*time.Millisecond)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "max-age=31536000; includeSubDomains", resp.Header.Get("Strict-Transport-Security"))
+	assert.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+
+	cancel()
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"wingspan-scoring/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	spanExporter    = tracetest.NewInMemoryExporter()
+	installExporter sync.Once
+)
+
+// recordSpans collects the spans ended during the test. The provider is
+// installed once, since tracers made before then stick to the first one.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	installExporter.Do(func() {
+		_, err := setupTracing(t.Context(), config.Tracing{})
+		require.NoError(t, err)
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spanExporter)))
+	})
+	spanExporter.Reset()
+	t.Cleanup(spanExporter.Reset)
+	return spanExporter
+}
+
+// findSpan returns the ended span with the given name
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	require.Failf(t, "missing span", "%s in %v", name, names)
+	return tracetest.SpanStub{}
+}
+
+// TestTracingMiddleware tests that requests get server spans, continuing
+// the caller's trace, with the db queries they run as children
+func TestTracingMiddleware(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+	exporter := recordSpans(t)
+	handler := newAuthTestServer("admin")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/leaderboard", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	spans := exporter.GetSpans()
+	server := findSpan(t, spans, "GET /api/leaderboard")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+	assert.Contains(t, server.Attributes, attribute.String("http.route", "/api/leaderboard"))
+	assert.Contains(t, server.Attributes, attribute.String("http.request.id", "req-1"))
+
+	query := findSpan(t, spans, "db.GetFilteredLeaderboardStats")
+	assert.Equal(t, server.SpanContext.SpanID(), query.Parent.SpanID())
+	assert.Contains(t, query.Attributes, attribute.String("db.system.name", "sqlite"))
+
+	// Spans are named by route pattern rather than by every path seen
+	exporter.Reset()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/static/missing.js", nil))
+	findSpan(t, exporter.GetSpans(), "GET /static/")
+}
+
+// TestTracingStages tests the spans for scoring, exporting and importing
+func TestTracingStages(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+	exporter := recordSpans(t)
+	logs := captureLogs(t, "json")
+	handler := newAuthTestServer("admin")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/calculate-game-end", bytes.NewBufferString(testGame))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	spans := exporter.GetSpans()
+	server := findSpan(t, spans, "POST /api/calculate-game-end")
+	assert.Equal(t, server.SpanContext.SpanID(), findSpan(t, spans, "scoring.game_end").Parent.SpanID())
+	assert.Equal(t, server.SpanContext.SpanID(), findSpan(t, spans, "db.SaveGame").Parent.SpanID())
+
+	exporter.Reset()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/export?format=csv", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	write := findSpan(t, exporter.GetSpans(), "export.write")
+	assert.Contains(t, write.Attributes, attribute.String("export.format", "csv"))
+	assert.Contains(t, write.Attributes, attribute.Int("export.games", 1))
+
+	exporter.Reset()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("csvFile", "games.csv")
+	require.NoError(t, err)
+	io.Copy(part, w.Body)
+	form.Close()
+	req = httptest.NewRequest(http.MethodPost, "/api/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	spans = exporter.GetSpans()
+	server = findSpan(t, spans, "POST /api/import")
+	parse := findSpan(t, spans, "import.parse")
+	assert.Equal(t, server.SpanContext.SpanID(), parse.Parent.SpanID())
+	assert.Contains(t, parse.Attributes, attribute.String("import.format", "csv"))
+	save := findSpan(t, spans, "import.save")
+	assert.Equal(t, save.SpanContext.SpanID(), findSpan(t, spans, "db.SaveGames").Parent.SpanID())
+
+	// Lines logged by handlers can be matched to the trace
+	var imported map[string]any
+	for _, line := range logLines(t, logs) {
+		if line["msg"] == "Imported games" {
+			imported = line
+		}
+	}
+	require.NotNil(t, imported)
+	assert.Equal(t, server.SpanContext.TraceID().String(), imported["trace_id"])
+}
+
+// TestSetupTracing tests that tracing is off without an endpoint and
+// otherwise sends spans to the collector's traces path
+func TestSetupTracing(t *testing.T) {
+	recordSpans(t)
+	original := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(original)
+
+	shutdown, err := setupTracing(t.Context(), config.Default().Tracing)
+	require.NoError(t, err)
+	assert.Equal(t, original, otel.GetTracerProvider())
+	assert.NoError(t, shutdown(t.Context()))
+
+	paths := make(chan string, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case paths <- r.URL.Path:
+		default:
+		}
+	}))
+	defer collector.Close()
+
+	cfg := config.Default().Tracing
+	cfg.Endpoint = collector.URL
+	shutdown, err = setupTracing(t.Context(), cfg)
+	require.NoError(t, err)
+	_, span := otel.GetTracerProvider().Tracer("test").Start(t.Context(), "test")
+	span.End()
+	require.NoError(t, shutdown(t.Context()))
+	assert.Equal(t, "/v1/traces", <-paths)
+}